@@ -2,8 +2,10 @@ package bot
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	"github.com/umputun/tg-spam/lib/spamcheck"
 )
@@ -22,7 +24,50 @@ type Response struct {
 	ChannelID     int64                // channel to ban, if set then User and BanInterval are ignored
 	ReplyTo       int                  // message to reply to, if 0 then no reply but common message
 	DeleteReplyTo bool                 // delete message what bot replays to
+	Restrict      bool                 // restrict user for BanInterval instead of ban
+	Warn          bool                 // warn user
+	ReportOnly    bool                 // report to admin chat only, no ban and no deletion
 	CheckResults  []spamcheck.Response // check results for the message
+	Score         float64              // total score of the checks
+	ThreadID      int                  // forum topic to send the response to, 0 for general topic or no topics
+}
+
+// SpamAction returns the action on spam defined by the response
+func (r Response) SpamAction() Action {
+	switch {
+	case r.ReportOnly:
+		return Action{Kind: ActionReport}
+	case r.BanInterval > 0 && r.Restrict:
+		return Action{Kind: ActionMute, Duration: r.BanInterval}
+	case r.BanInterval > 0:
+		return Action{Kind: ActionBan, Duration: r.BanInterval}
+	case r.Warn:
+		return Action{Kind: ActionWarn}
+	case r.DeleteReplyTo:
+		return Action{Kind: ActionDelete}
+	}
+	return Action{}
+}
+
+// WithSpamAction returns a copy of the response with ban, restriction, warning and deletion set by the action
+func (r Response) WithSpamAction(a Action) Response {
+	r.BanInterval, r.Restrict, r.Warn, r.DeleteReplyTo, r.ReportOnly = 0, false, false, false, false
+	switch a.Kind {
+	case ActionBan:
+		r.BanInterval, r.DeleteReplyTo = a.Duration, true
+		if r.BanInterval <= 0 {
+			r.BanInterval = PermanentBanDuration
+		}
+	case ActionMute:
+		r.BanInterval, r.Restrict, r.DeleteReplyTo = a.Duration, true, true
+	case ActionWarn:
+		r.Warn, r.DeleteReplyTo = true, true
+	case ActionDelete:
+		r.DeleteReplyTo = true
+	case ActionReport:
+		r.ReportOnly = true
+	}
+	return r
 }
 
 // SenderChat is the sender of the message, sent on behalf of a chat. The
@@ -55,11 +100,91 @@ type Message struct {
 		SenderChat SenderChat `json:"sender_chat,omitempty"`
 	} `json:",omitempty"`
 
-	WithVideo     bool `json:",omitempty"`
-	WithVideoNote bool `json:",omitempty"`
-	WithForward   bool `json:",omitempty"`
-	WithAudio     bool `json:",omitempty"`
-	WithKeyboard  bool `json:",omitempty"`
+	WithVideo     bool   `json:",omitempty"`
+	WithVideoNote bool   `json:",omitempty"`
+	WithForward   bool   `json:",omitempty"`
+	WithAudio     bool   `json:",omitempty"`
+	WithKeyboard  bool   `json:",omitempty"`
+	WithDocument  bool   `json:",omitempty"`
+	WithSticker   bool   `json:",omitempty"`
+	WithAnimation bool   `json:",omitempty"`
+	WithVoice     bool   `json:",omitempty"`
+	WithPoll      bool   `json:",omitempty"`
+	WithContact   bool   `json:",omitempty"`
+	WithLocation  bool   `json:",omitempty"`
+	WithVenue     bool   `json:",omitempty"`
+	WithDice      bool   `json:",omitempty"`
+	DocumentName  string `json:",omitempty"` // file name of the attached document
+	ViaBot        string `json:",omitempty"` // username of the inline bot the message sent via
+	Edited        bool   `json:",omitempty"` // message edited after posting
+	Strict        bool   `json:",omitempty"` // check the message even if the user is approved, e.g. in a strict topic
+	ThreadID      int    `json:",omitempty"` // forum topic of the message, 0 for general topic or no topics
+}
+
+// IsEmpty returns true if the message has no text and no media of any kind, e.g. service message
+func (m *Message) IsEmpty() bool {
+	if strings.TrimSpace(m.Text) != "" || m.Image != nil {
+		return false
+	}
+	return !m.WithVideo && !m.WithVideoNote && !m.WithAudio && !m.WithDocument && !m.WithSticker && !m.WithAnimation &&
+		!m.WithVoice && !m.WithPoll && !m.WithContact && !m.WithLocation && !m.WithVenue && !m.WithDice
+}
+
+var textURLRe = regexp.MustCompile(`https?://\S+`)
+
+// URLs returns links of the message: urls in the text, "url" entities, including ones without scheme like t.me/xxx,
+// and urls hidden behind "text_link" entities. Each link is returned once, in order of appearance.
+func (m *Message) URLs() []string {
+	res := []string{}
+	seen := map[string]bool{}
+	add := func(u string) {
+		if u = strings.TrimSpace(u); u != "" && !seen[u] {
+			seen[u] = true
+			res = append(res, u)
+		}
+	}
+
+	if m.Entities == nil { // no entities, e.g. message not from telegram, look for links in the text
+		for _, u := range textURLRe.FindAllString(m.Text, -1) {
+			add(u)
+		}
+		return res
+	}
+
+	text := utf16.Encode([]rune(m.Text)) // entity offsets are in utf-16 code units
+	for _, e := range *m.Entities {
+		switch e.Type {
+		case "url":
+			if e.Offset >= 0 && e.Length > 0 && e.Offset+e.Length <= len(text) {
+				add(string(utf16.Decode(text[e.Offset : e.Offset+e.Length])))
+			}
+		case "text_link":
+			add(e.URL)
+		}
+	}
+	return res
+}
+
+// MentionedUsernames returns usernames of "mention" entities, without "@" prefix. Each username is returned once,
+// in order of appearance. Mentions of users without username ("text_mention") are not included.
+func (m *Message) MentionedUsernames() []string {
+	res := []string{}
+	if m.Entities == nil {
+		return res
+	}
+	seen := map[string]bool{}
+	text := utf16.Encode([]rune(m.Text)) // entity offsets are in utf-16 code units
+	for _, e := range *m.Entities {
+		if e.Type != "mention" || e.Offset < 0 || e.Length <= 1 || e.Offset+e.Length > len(text) {
+			continue
+		}
+		name := strings.TrimPrefix(string(utf16.Decode(text[e.Offset:e.Offset+e.Length])), "@")
+		if key := strings.ToLower(name); name != "" && !seen[key] {
+			seen[key] = true
+			res = append(res, name)
+		}
+	}
+	return res
 }
 
 // Entity represents one special entity in a text message.
@@ -87,6 +212,7 @@ type User struct {
 	ID          int64  `json:"id"`
 	Username    string `json:"user_name,omitempty"`
 	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"` // user's bio, set for profile screening only
 }
 
 // DisplayName returns user's display name or username or id
@@ -2,6 +2,7 @@ package bot
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,3 +51,111 @@ func TestDisplayName(t *testing.T) {
 		})
 	}
 }
+
+func TestResponse_SpamAction(t *testing.T) {
+	tbl := []struct {
+		name   string
+		action Action
+		resp   Response
+	}{
+		{name: "none", action: Action{}, resp: Response{}},
+		{name: "report", action: Action{Kind: ActionReport}, resp: Response{ReportOnly: true}},
+		{name: "delete", action: Action{Kind: ActionDelete}, resp: Response{DeleteReplyTo: true}},
+		{name: "warn", action: Action{Kind: ActionWarn}, resp: Response{Warn: true, DeleteReplyTo: true}},
+		{name: "mute", action: Action{Kind: ActionMute, Duration: time.Hour},
+			resp: Response{BanInterval: time.Hour, Restrict: true, DeleteReplyTo: true}},
+		{name: "ban", action: Action{Kind: ActionBan, Duration: time.Hour}, resp: Response{BanInterval: time.Hour, DeleteReplyTo: true}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// previous action is reset
+			resp := Response{Text: "spam", BanInterval: time.Minute, Restrict: true, ReportOnly: true}.WithSpamAction(tt.action)
+			tt.resp.Text = "spam"
+			assert.Equal(t, tt.resp, resp)
+			assert.Equal(t, tt.action, resp.SpamAction())
+		})
+	}
+
+	resp := Response{}.WithSpamAction(Action{Kind: ActionBan})
+	assert.Equal(t, PermanentBanDuration, resp.BanInterval, "ban without duration is permanent")
+}
+
+func TestMessage_IsEmpty(t *testing.T) {
+	tbl := []struct {
+		name string
+		msg  Message
+		want bool
+	}{
+		{name: "empty", msg: Message{}, want: true},
+		{name: "spaces only", msg: Message{Text: " \n"}, want: true},
+		{name: "text", msg: Message{Text: "hello"}, want: false},
+		{name: "image", msg: Message{Image: &Image{FileID: "123"}}, want: false},
+		{name: "sticker", msg: Message{WithSticker: true}, want: false},
+		{name: "document", msg: Message{WithDocument: true}, want: false},
+		{name: "contact", msg: Message{WithContact: true}, want: false},
+		{name: "venue", msg: Message{WithVenue: true}, want: false},
+		{name: "dice", msg: Message{WithDice: true}, want: false},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.msg.IsEmpty())
+		})
+	}
+}
+
+func TestMessage_URLs(t *testing.T) {
+	tbl := []struct {
+		name string
+		msg  Message
+		want []string
+	}{
+		{name: "no links", msg: Message{Text: "hello"}, want: []string{}},
+		{name: "text links without entities", msg: Message{Text: "see https://example.com and http://test.com"},
+			want: []string{"https://example.com", "http://test.com"}},
+		{name: "url entity without scheme", msg: Message{Text: "join t.me/xxx now",
+			Entities: &[]Entity{{Type: "url", Offset: 5, Length: 8}}}, want: []string{"t.me/xxx"}},
+		{name: "hidden text link", msg: Message{Text: "click here",
+			Entities: &[]Entity{{Type: "bold", Offset: 0, Length: 5}, {Type: "text_link", Offset: 6, Length: 4, URL: "https://spam.com"}}},
+			want: []string{"https://spam.com"}},
+		{name: "offsets in utf-16", msg: Message{Text: "👍 привет bit.ly/abc",
+			Entities: &[]Entity{{Type: "url", Offset: 10, Length: 10}}}, want: []string{"bit.ly/abc"}},
+		{name: "duplicates", msg: Message{Text: "https://a.com https://a.com",
+			Entities: &[]Entity{{Type: "url", Offset: 0, Length: 13}, {Type: "url", Offset: 14, Length: 13}}},
+			want: []string{"https://a.com"}},
+		{name: "entity out of text", msg: Message{Text: "short",
+			Entities: &[]Entity{{Type: "url", Offset: 3, Length: 10}}}, want: []string{}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.msg.URLs())
+		})
+	}
+}
+
+func TestMessage_MentionedUsernames(t *testing.T) {
+	tbl := []struct {
+		name string
+		msg  Message
+		want []string
+	}{
+		{name: "no entities", msg: Message{Text: "hello @user"}, want: []string{}},
+		{name: "mentions", msg: Message{Text: "ask @promo or @Helper_Bot",
+			Entities: &[]Entity{{Type: "mention", Offset: 4, Length: 6}, {Type: "mention", Offset: 14, Length: 11}}},
+			want: []string{"promo", "Helper_Bot"}},
+		{name: "text mention and duplicates skipped", msg: Message{Text: "👍 @promo @PROMO john",
+			Entities: &[]Entity{{Type: "mention", Offset: 3, Length: 6}, {Type: "mention", Offset: 10, Length: 6},
+				{Type: "text_mention", Offset: 17, Length: 4, User: &User{ID: 1}}}},
+			want: []string{"promo"}},
+		{name: "entity out of text", msg: Message{Text: "@a", Entities: &[]Entity{{Type: "mention", Offset: 0, Length: 5}}},
+			want: []string{}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.msg.MentionedUsernames())
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	"context"
 	"github.com/umputun/tg-spam/lib/approved"
 	"github.com/umputun/tg-spam/lib/spamcheck"
 	"github.com/umputun/tg-spam/lib/tgspam"
@@ -20,15 +21,27 @@ import (
 //			AddApprovedUserFunc: func(user approved.UserInfo) error {
 //				panic("mock out the AddApprovedUser method")
 //			},
+//			ApprovedUserFunc: func(userID string) (approved.UserInfo, bool) {
+//				panic("mock out the ApprovedUser method")
+//			},
 //			ApprovedUsersFunc: func() []approved.UserInfo {
 //				panic("mock out the ApprovedUsers method")
 //			},
-//			CheckFunc: func(request spamcheck.Request) (bool, []spamcheck.Response) {
-//				panic("mock out the Check method")
+//			CheckContextFunc: func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+//				panic("mock out the CheckContext method")
+//			},
+//			CheckUserFunc: func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+//				panic("mock out the CheckUser method")
+//			},
+//			CountCleanMessageFunc: func(userID string, userName string) {
+//				panic("mock out the CountCleanMessage method")
 //			},
 //			IsApprovedUserFunc: func(userID string) bool {
 //				panic("mock out the IsApprovedUser method")
 //			},
+//			LoadDomainsFunc: func(denied io.Reader, allowed io.Reader) (tgspam.LoadResult, error) {
+//				panic("mock out the LoadDomains method")
+//			},
 //			LoadSamplesFunc: func(exclReader io.Reader, spamReaders []io.Reader, hamReaders []io.Reader) (tgspam.LoadResult, error) {
 //				panic("mock out the LoadSamples method")
 //			},
@@ -60,15 +73,27 @@ type DetectorMock struct {
 	// AddApprovedUserFunc mocks the AddApprovedUser method.
 	AddApprovedUserFunc func(user approved.UserInfo) error
 
+	// ApprovedUserFunc mocks the ApprovedUser method.
+	ApprovedUserFunc func(userID string) (approved.UserInfo, bool)
+
 	// ApprovedUsersFunc mocks the ApprovedUsers method.
 	ApprovedUsersFunc func() []approved.UserInfo
 
-	// CheckFunc mocks the Check method.
-	CheckFunc func(request spamcheck.Request) (bool, []spamcheck.Response)
+	// CheckContextFunc mocks the CheckContext method.
+	CheckContextFunc func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response)
+
+	// CheckUserFunc mocks the CheckUser method.
+	CheckUserFunc func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response)
+
+	// CountCleanMessageFunc mocks the CountCleanMessage method.
+	CountCleanMessageFunc func(userID string, userName string)
 
 	// IsApprovedUserFunc mocks the IsApprovedUser method.
 	IsApprovedUserFunc func(userID string) bool
 
+	// LoadDomainsFunc mocks the LoadDomains method.
+	LoadDomainsFunc func(denied io.Reader, allowed io.Reader) (tgspam.LoadResult, error)
+
 	// LoadSamplesFunc mocks the LoadSamples method.
 	LoadSamplesFunc func(exclReader io.Reader, spamReaders []io.Reader, hamReaders []io.Reader) (tgspam.LoadResult, error)
 
@@ -97,19 +122,47 @@ type DetectorMock struct {
 			// user is the user argument value.
 			User approved.UserInfo
 		}
+		// ApprovedUser holds details about calls to the ApprovedUser method.
+		ApprovedUser []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
 		// ApprovedUsers holds details about calls to the ApprovedUsers method.
 		ApprovedUsers []struct {
 		}
-		// check holds details about calls to the Check method.
-		Check []struct {
-			// request is the request argument value.
-			Request spamcheck.Request
+		// CheckContext holds details about calls to the CheckContext method.
+		CheckContext []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req spamcheck.Request
+		}
+		// CheckUser holds details about calls to the CheckUser method.
+		CheckUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req spamcheck.Request
+		}
+		// CountCleanMessage holds details about calls to the CountCleanMessage method.
+		CountCleanMessage []struct {
+			// UserID is the userID argument value.
+			UserID string
+			// UserName is the userName argument value.
+			UserName string
 		}
 		// IsApprovedUser holds details about calls to the IsApprovedUser method.
 		IsApprovedUser []struct {
 			// UserID is the userID argument value.
 			UserID string
 		}
+		// LoadDomains holds details about calls to the LoadDomains method.
+		LoadDomains []struct {
+			// Denied is the denied argument value.
+			Denied io.Reader
+			// Allowed is the allowed argument value.
+			Allowed io.Reader
+		}
 		// LoadSamples holds details about calls to the LoadSamples method.
 		LoadSamples []struct {
 			// ExclReader is the exclReader argument value.
@@ -151,9 +204,13 @@ type DetectorMock struct {
 		}
 	}
 	lockAddApprovedUser    sync.RWMutex
+	lockApprovedUser       sync.RWMutex
 	lockApprovedUsers      sync.RWMutex
-	lockCheck              sync.RWMutex
+	lockCheckContext       sync.RWMutex
+	lockCheckUser          sync.RWMutex
+	lockCountCleanMessage  sync.RWMutex
 	lockIsApprovedUser     sync.RWMutex
+	lockLoadDomains        sync.RWMutex
 	lockLoadSamples        sync.RWMutex
 	lockLoadStopWords      sync.RWMutex
 	lockRemoveApprovedUser sync.RWMutex
@@ -202,6 +259,45 @@ func (mock *DetectorMock) ResetAddApprovedUserCalls() {
 	mock.lockAddApprovedUser.Unlock()
 }
 
+// ApprovedUser calls ApprovedUserFunc.
+func (mock *DetectorMock) ApprovedUser(userID string) (approved.UserInfo, bool) {
+	if mock.ApprovedUserFunc == nil {
+		panic("DetectorMock.ApprovedUserFunc: method is nil but Detector.ApprovedUser was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockApprovedUser.Lock()
+	mock.calls.ApprovedUser = append(mock.calls.ApprovedUser, callInfo)
+	mock.lockApprovedUser.Unlock()
+	return mock.ApprovedUserFunc(userID)
+}
+
+// ApprovedUserCalls gets all the calls that were made to ApprovedUser.
+// Check the length with:
+//
+//	len(mockedDetector.ApprovedUserCalls())
+func (mock *DetectorMock) ApprovedUserCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockApprovedUser.RLock()
+	calls = mock.calls.ApprovedUser
+	mock.lockApprovedUser.RUnlock()
+	return calls
+}
+
+// ResetApprovedUserCalls reset all the calls that were made to ApprovedUser.
+func (mock *DetectorMock) ResetApprovedUserCalls() {
+	mock.lockApprovedUser.Lock()
+	mock.calls.ApprovedUser = nil
+	mock.lockApprovedUser.Unlock()
+}
+
 // ApprovedUsers calls ApprovedUsersFunc.
 func (mock *DetectorMock) ApprovedUsers() []approved.UserInfo {
 	if mock.ApprovedUsersFunc == nil {
@@ -236,43 +332,133 @@ func (mock *DetectorMock) ResetApprovedUsersCalls() {
 	mock.lockApprovedUsers.Unlock()
 }
 
-// Check calls CheckFunc.
-func (mock *DetectorMock) Check(request spamcheck.Request) (bool, []spamcheck.Response) {
-	if mock.CheckFunc == nil {
-		panic("DetectorMock.CheckFunc: method is nil but Detector.Check was just called")
+// CheckContext calls CheckContextFunc.
+func (mock *DetectorMock) CheckContext(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+	if mock.CheckContextFunc == nil {
+		panic("DetectorMock.CheckContextFunc: method is nil but Detector.CheckContext was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req spamcheck.Request
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = append(mock.calls.CheckContext, callInfo)
+	mock.lockCheckContext.Unlock()
+	return mock.CheckContextFunc(ctx, req)
+}
+
+// CheckContextCalls gets all the calls that were made to CheckContext.
+// Check the length with:
+//
+//	len(mockedDetector.CheckContextCalls())
+func (mock *DetectorMock) CheckContextCalls() []struct {
+	Ctx context.Context
+	Req spamcheck.Request
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req spamcheck.Request
+	}
+	mock.lockCheckContext.RLock()
+	calls = mock.calls.CheckContext
+	mock.lockCheckContext.RUnlock()
+	return calls
+}
+
+// ResetCheckContextCalls reset all the calls that were made to CheckContext.
+func (mock *DetectorMock) ResetCheckContextCalls() {
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = nil
+	mock.lockCheckContext.Unlock()
+}
+
+// CheckUser calls CheckUserFunc.
+func (mock *DetectorMock) CheckUser(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+	if mock.CheckUserFunc == nil {
+		panic("DetectorMock.CheckUserFunc: method is nil but Detector.CheckUser was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req spamcheck.Request
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = append(mock.calls.CheckUser, callInfo)
+	mock.lockCheckUser.Unlock()
+	return mock.CheckUserFunc(ctx, req)
+}
+
+// CheckUserCalls gets all the calls that were made to CheckUser.
+// Check the length with:
+//
+//	len(mockedDetector.CheckUserCalls())
+func (mock *DetectorMock) CheckUserCalls() []struct {
+	Ctx context.Context
+	Req spamcheck.Request
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req spamcheck.Request
+	}
+	mock.lockCheckUser.RLock()
+	calls = mock.calls.CheckUser
+	mock.lockCheckUser.RUnlock()
+	return calls
+}
+
+// ResetCheckUserCalls reset all the calls that were made to CheckUser.
+func (mock *DetectorMock) ResetCheckUserCalls() {
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = nil
+	mock.lockCheckUser.Unlock()
+}
+
+// CountCleanMessage calls CountCleanMessageFunc.
+func (mock *DetectorMock) CountCleanMessage(userID string, userName string) {
+	if mock.CountCleanMessageFunc == nil {
+		panic("DetectorMock.CountCleanMessageFunc: method is nil but Detector.CountCleanMessage was just called")
 	}
 	callInfo := struct {
-		Request spamcheck.Request
+		UserID   string
+		UserName string
 	}{
-		Request: request,
+		UserID:   userID,
+		UserName: userName,
 	}
-	mock.lockCheck.Lock()
-	mock.calls.Check = append(mock.calls.Check, callInfo)
-	mock.lockCheck.Unlock()
-	return mock.CheckFunc(request)
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = append(mock.calls.CountCleanMessage, callInfo)
+	mock.lockCountCleanMessage.Unlock()
+	mock.CountCleanMessageFunc(userID, userName)
 }
 
-// CheckCalls gets all the calls that were made to Check.
+// CountCleanMessageCalls gets all the calls that were made to CountCleanMessage.
 // Check the length with:
 //
-//	len(mockedDetector.CheckCalls())
-func (mock *DetectorMock) CheckCalls() []struct {
-	Request spamcheck.Request
+//	len(mockedDetector.CountCleanMessageCalls())
+func (mock *DetectorMock) CountCleanMessageCalls() []struct {
+	UserID   string
+	UserName string
 } {
 	var calls []struct {
-		Request spamcheck.Request
+		UserID   string
+		UserName string
 	}
-	mock.lockCheck.RLock()
-	calls = mock.calls.Check
-	mock.lockCheck.RUnlock()
+	mock.lockCountCleanMessage.RLock()
+	calls = mock.calls.CountCleanMessage
+	mock.lockCountCleanMessage.RUnlock()
 	return calls
 }
 
-// ResetCheckCalls reset all the calls that were made to Check.
-func (mock *DetectorMock) ResetCheckCalls() {
-	mock.lockCheck.Lock()
-	mock.calls.Check = nil
-	mock.lockCheck.Unlock()
+// ResetCountCleanMessageCalls reset all the calls that were made to CountCleanMessage.
+func (mock *DetectorMock) ResetCountCleanMessageCalls() {
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = nil
+	mock.lockCountCleanMessage.Unlock()
 }
 
 // IsApprovedUser calls IsApprovedUserFunc.
@@ -314,6 +500,49 @@ func (mock *DetectorMock) ResetIsApprovedUserCalls() {
 	mock.lockIsApprovedUser.Unlock()
 }
 
+// LoadDomains calls LoadDomainsFunc.
+func (mock *DetectorMock) LoadDomains(denied io.Reader, allowed io.Reader) (tgspam.LoadResult, error) {
+	if mock.LoadDomainsFunc == nil {
+		panic("DetectorMock.LoadDomainsFunc: method is nil but Detector.LoadDomains was just called")
+	}
+	callInfo := struct {
+		Denied  io.Reader
+		Allowed io.Reader
+	}{
+		Denied:  denied,
+		Allowed: allowed,
+	}
+	mock.lockLoadDomains.Lock()
+	mock.calls.LoadDomains = append(mock.calls.LoadDomains, callInfo)
+	mock.lockLoadDomains.Unlock()
+	return mock.LoadDomainsFunc(denied, allowed)
+}
+
+// LoadDomainsCalls gets all the calls that were made to LoadDomains.
+// Check the length with:
+//
+//	len(mockedDetector.LoadDomainsCalls())
+func (mock *DetectorMock) LoadDomainsCalls() []struct {
+	Denied  io.Reader
+	Allowed io.Reader
+} {
+	var calls []struct {
+		Denied  io.Reader
+		Allowed io.Reader
+	}
+	mock.lockLoadDomains.RLock()
+	calls = mock.calls.LoadDomains
+	mock.lockLoadDomains.RUnlock()
+	return calls
+}
+
+// ResetLoadDomainsCalls reset all the calls that were made to LoadDomains.
+func (mock *DetectorMock) ResetLoadDomainsCalls() {
+	mock.lockLoadDomains.Lock()
+	mock.calls.LoadDomains = nil
+	mock.lockLoadDomains.Unlock()
+}
+
 // LoadSamples calls LoadSamplesFunc.
 func (mock *DetectorMock) LoadSamples(exclReader io.Reader, spamReaders []io.Reader, hamReaders []io.Reader) (tgspam.LoadResult, error) {
 	if mock.LoadSamplesFunc == nil {
@@ -601,18 +830,34 @@ func (mock *DetectorMock) ResetCalls() {
 	mock.calls.AddApprovedUser = nil
 	mock.lockAddApprovedUser.Unlock()
 
+	mock.lockApprovedUser.Lock()
+	mock.calls.ApprovedUser = nil
+	mock.lockApprovedUser.Unlock()
+
 	mock.lockApprovedUsers.Lock()
 	mock.calls.ApprovedUsers = nil
 	mock.lockApprovedUsers.Unlock()
 
-	mock.lockCheck.Lock()
-	mock.calls.Check = nil
-	mock.lockCheck.Unlock()
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = nil
+	mock.lockCheckContext.Unlock()
+
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = nil
+	mock.lockCheckUser.Unlock()
+
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = nil
+	mock.lockCountCleanMessage.Unlock()
 
 	mock.lockIsApprovedUser.Lock()
 	mock.calls.IsApprovedUser = nil
 	mock.lockIsApprovedUser.Unlock()
 
+	mock.lockLoadDomains.Lock()
+	mock.calls.LoadDomains = nil
+	mock.lockLoadDomains.Unlock()
+
 	mock.lockLoadSamples.Lock()
 	mock.calls.LoadSamples = nil
 	mock.lockLoadSamples.Unlock()
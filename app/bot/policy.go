@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// ActionKind defines a kind of reaction on detected spam. Kinds are ordered by severity.
+type ActionKind int
+
+// enum for ActionKind
+const (
+	ActionNone   ActionKind = iota // not set, default action used
+	ActionReport                   // report to admin chat only, no deletion and no ban
+	ActionDelete                   // delete the message only
+	ActionWarn                     // delete the message and warn the user
+	ActionMute                     // delete the message and restrict the user for a duration
+	ActionBan                      // delete the message and ban the user for a duration, permanently if not set
+)
+
+// Action is a reaction on detected spam
+type Action struct {
+	Kind     ActionKind
+	Duration time.Duration // mute or ban duration
+}
+
+// ParseAction parses action from "kind[:duration]" string, e.g. "ban", "ban:24h", "mute:1h", "warn", "delete", "report"
+func ParseAction(s string) (Action, error) {
+	kindStr, durStr, hasDur := strings.Cut(strings.TrimSpace(s), ":")
+	res := Action{}
+	switch strings.ToLower(kindStr) {
+	case "ban":
+		res.Kind = ActionBan
+	case "mute":
+		res.Kind = ActionMute
+	case "delete":
+		res.Kind = ActionDelete
+	case "warn":
+		res.Kind = ActionWarn
+	case "report":
+		res.Kind = ActionReport
+	default:
+		return Action{}, fmt.Errorf("unknown action %q", kindStr)
+	}
+
+	if hasDur {
+		if res.Kind != ActionBan && res.Kind != ActionMute {
+			return Action{}, fmt.Errorf("duration is not supported for action %q", kindStr)
+		}
+		dur, err := time.ParseDuration(durStr)
+		if err != nil {
+			return Action{}, fmt.Errorf("invalid duration %q: %w", durStr, err)
+		}
+		res.Duration = dur
+	}
+	if res.Kind == ActionMute && res.Duration <= 0 {
+		return Action{}, fmt.Errorf("mute action requires duration")
+	}
+	return res, nil
+}
+
+// String returns action in the same format as accepted by ParseAction
+func (a Action) String() string {
+	kind := ""
+	switch a.Kind {
+	case ActionBan:
+		kind = "ban"
+	case ActionMute:
+		kind = "mute"
+	case ActionDelete:
+		kind = "delete"
+	case ActionWarn:
+		kind = "warn"
+	case ActionReport:
+		kind = "report"
+	default:
+		return "none"
+	}
+	if a.Duration > 0 && a.Duration < PermanentBanDuration {
+		return kind + ":" + a.Duration.String()
+	}
+	return kind
+}
+
+// policyOperatorChars are characters of score comparison operators, not allowed in check names
+const policyOperatorChars = "<>=!"
+
+// PolicyRule maps spam detected by a check to an action
+type PolicyRule struct {
+	Check    string  // name of the check, "*" matches any check
+	MinScore float64 // rule matches if the check score is at least MinScore
+	MaxScore float64 // and below MaxScore, if set
+	Action   Action
+}
+
+// ParsePolicyRule parses rule from "check[<score|>=score]:action[:duration]" string,
+// e.g. "cas:ban", "stopword:mute:24h", "emoji:delete", "classifier<0.8:report".
+// Only ">=" and "<" operators are supported, other operators, like ">", "<=" or "=", are rejected.
+func ParsePolicyRule(s string) (PolicyRule, error) {
+	checkStr, actionStr, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || checkStr == "" {
+		return PolicyRule{}, fmt.Errorf("invalid policy rule %q, expected check:action", s)
+	}
+
+	res := PolicyRule{Check: checkStr}
+	if idx := strings.IndexAny(checkStr, policyOperatorChars); idx >= 0 {
+		res.Check = checkStr[:idx]
+		val := strings.TrimLeft(checkStr[idx:], policyOperatorChars)
+		op := checkStr[idx : len(checkStr)-len(val)]
+		if res.Check == "" {
+			return PolicyRule{}, fmt.Errorf("invalid policy rule %q, check name is missing", s)
+		}
+		score, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return PolicyRule{}, fmt.Errorf("invalid score in policy rule %q: %w", s, err)
+		}
+		switch op {
+		case ">=":
+			res.MinScore = score
+		case "<":
+			res.MaxScore = score
+		default:
+			return PolicyRule{}, fmt.Errorf("unsupported operator %q in policy rule %q, only >= and < allowed", op, s)
+		}
+	}
+
+	action, err := ParseAction(actionStr)
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("invalid policy rule %q: %w", s, err)
+	}
+	res.Action = action
+	return res, nil
+}
+
+// matches checks if the rule applies to the check response
+func (r PolicyRule) matches(cr spamcheck.Response) bool {
+	if r.Check != "*" && r.Check != cr.Name {
+		return false
+	}
+	if cr.Score < r.MinScore {
+		return false
+	}
+	if r.MaxScore > 0 && cr.Score >= r.MaxScore {
+		return false
+	}
+	return true
+}
+
+// Policy maps check results to the action on detected spam
+type Policy struct {
+	Rules   []PolicyRule
+	Default Action // action if no rule matched, permanent ban if not set
+}
+
+// Resolve returns the action for the given check results. Each check detected spam is mapped to the action
+// of the first matching rule, or to the default action, and the most severe action wins.
+// Default action is returned if no check detected spam, e.g. when spam decided by the total score.
+func (p Policy) Resolve(checks []spamcheck.Response) Action {
+	def := p.Default
+	if def.Kind == ActionNone {
+		def = Action{Kind: ActionBan}
+	}
+
+	res := Action{}
+	for _, cr := range checks {
+		if !cr.Spam {
+			continue
+		}
+		action := def
+		for _, r := range p.Rules {
+			if r.matches(cr) {
+				action = r.Action
+				break
+			}
+		}
+		if action.MoreSevere(res) {
+			res = action
+		}
+	}
+
+	if res.Kind == ActionNone {
+		res = def
+	}
+	if res.Kind == ActionBan && res.Duration <= 0 {
+		res.Duration = PermanentBanDuration
+	}
+	return res
+}
+
+// MoreSevere reports if the action is more severe than the other one
+func (a Action) MoreSevere(other Action) bool {
+	return a.Kind > other.Kind || (a.Kind == other.Kind && a.duration() > other.duration())
+}
+
+// duration returns the effective duration of the action, permanent for ban without duration
+func (a Action) duration() time.Duration {
+	if a.Kind == ActionBan && a.Duration <= 0 {
+		return PermanentBanDuration
+	}
+	return a.Duration
+}
+
+// Ladder defines escalation of actions by the number of active strikes of the user.
+// The first step is used for the first strike, the last step for all strikes above the ladder length.
+type Ladder []Action
+
+// ParseLadder parses ladder from the list of actions, e.g. "warn", "mute:1h", "mute:24h", "ban"
+func ParseLadder(steps []string) (Ladder, error) {
+	res := make(Ladder, 0, len(steps))
+	for _, s := range steps {
+		a, err := ParseAction(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ladder step %q: %w", s, err)
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
+
+// Step returns the action for the given number of strikes, ActionNone if ladder is empty or no strikes
+func (l Ladder) Step(strikes int) Action {
+	if len(l) == 0 || strikes <= 0 {
+		return Action{}
+	}
+	if strikes > len(l) {
+		return l[len(l)-1]
+	}
+	return l[strikes-1]
+}
@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestParseAction(t *testing.T) {
+	tbl := []struct {
+		in      string
+		want    Action
+		wantErr bool
+	}{
+		{in: "ban", want: Action{Kind: ActionBan}},
+		{in: "ban:24h", want: Action{Kind: ActionBan, Duration: 24 * time.Hour}},
+		{in: "mute:30m", want: Action{Kind: ActionMute, Duration: 30 * time.Minute}},
+		{in: "Delete", want: Action{Kind: ActionDelete}},
+		{in: "report", want: Action{Kind: ActionReport}},
+		{in: "warn", want: Action{Kind: ActionWarn}},
+		{in: "warn:1h", wantErr: true},
+		{in: "mute", wantErr: true},
+		{in: "delete:1h", wantErr: true},
+		{in: "ban:blah", wantErr: true},
+		{in: "kick", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.in, func(t *testing.T) {
+			res, err := ParseAction(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res)
+		})
+	}
+}
+
+func TestAction_String(t *testing.T) {
+	assert.Equal(t, "none", Action{}.String())
+	assert.Equal(t, "ban", Action{Kind: ActionBan}.String())
+	assert.Equal(t, "ban", Action{Kind: ActionBan, Duration: PermanentBanDuration}.String())
+	assert.Equal(t, "ban:1h0m0s", Action{Kind: ActionBan, Duration: time.Hour}.String())
+	assert.Equal(t, "mute:24h0m0s", Action{Kind: ActionMute, Duration: 24 * time.Hour}.String())
+	assert.Equal(t, "delete", Action{Kind: ActionDelete}.String())
+	assert.Equal(t, "warn", Action{Kind: ActionWarn}.String())
+	assert.Equal(t, "report", Action{Kind: ActionReport}.String())
+}
+
+func TestParsePolicyRule(t *testing.T) {
+	tbl := []struct {
+		in      string
+		want    PolicyRule
+		wantErr bool
+	}{
+		{in: "cas:ban", want: PolicyRule{Check: "cas", Action: Action{Kind: ActionBan}}},
+		{in: "stopword:mute:24h", want: PolicyRule{Check: "stopword", Action: Action{Kind: ActionMute, Duration: 24 * time.Hour}}},
+		{in: "*:delete", want: PolicyRule{Check: "*", Action: Action{Kind: ActionDelete}}},
+		{in: "classifier<0.8:report", want: PolicyRule{Check: "classifier", MaxScore: 0.8, Action: Action{Kind: ActionReport}}},
+		{in: "classifier>=0.8:ban", want: PolicyRule{Check: "classifier", MinScore: 0.8, Action: Action{Kind: ActionBan}}},
+		{in: "classifier<abc:report", wantErr: true},
+		{in: "classifier>=abc:report", wantErr: true},
+		{in: "classifier>0.5:ban", wantErr: true},
+		{in: "classifier<=0.5:ban", wantErr: true},
+		{in: "cas=1:mute", wantErr: true},
+		{in: "cas==1:mute", wantErr: true},
+		{in: "cas!=1:mute", wantErr: true},
+		{in: "classifier>=0.5<0.9:ban", wantErr: true},
+		{in: ">=0.5:ban", wantErr: true},
+		{in: "cas", wantErr: true},
+		{in: ":ban", wantErr: true},
+		{in: "cas:kick", wantErr: true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.in, func(t *testing.T) {
+			res, err := ParsePolicyRule(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res)
+		})
+	}
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	policy := Policy{Rules: []PolicyRule{
+		{Check: "cas", Action: Action{Kind: ActionBan}},
+		{Check: "stopword", Action: Action{Kind: ActionMute, Duration: 24 * time.Hour}},
+		{Check: "emoji", Action: Action{Kind: ActionDelete}},
+		{Check: "classifier", MaxScore: 0.8, Action: Action{Kind: ActionReport}},
+		{Check: "classifier", Action: Action{Kind: ActionMute, Duration: time.Hour}},
+	}}
+
+	tbl := []struct {
+		name   string
+		policy Policy
+		checks []spamcheck.Response
+		want   Action
+	}{
+		{name: "cas", policy: policy, checks: []spamcheck.Response{{Name: "cas", Spam: true}},
+			want: Action{Kind: ActionBan, Duration: PermanentBanDuration}},
+		{name: "stop word", policy: policy, checks: []spamcheck.Response{{Name: "stopword", Spam: true}},
+			want: Action{Kind: ActionMute, Duration: 24 * time.Hour}},
+		{name: "emoji", policy: policy, checks: []spamcheck.Response{{Name: "emoji", Spam: true}, {Name: "cas"}},
+			want: Action{Kind: ActionDelete}},
+		{name: "borderline classifier", policy: policy, checks: []spamcheck.Response{{Name: "classifier", Spam: true, Score: 0.6}},
+			want: Action{Kind: ActionReport}},
+		{name: "confident classifier", policy: policy, checks: []spamcheck.Response{{Name: "classifier", Spam: true, Score: 0.9}},
+			want: Action{Kind: ActionMute, Duration: time.Hour}},
+		{name: "most severe wins", policy: policy,
+			checks: []spamcheck.Response{{Name: "emoji", Spam: true}, {Name: "stopword", Spam: true}, {Name: "classifier", Spam: true, Score: 0.9}},
+			want:   Action{Kind: ActionMute, Duration: 24 * time.Hour}},
+		{name: "no rule, default ban", policy: policy, checks: []spamcheck.Response{{Name: "emoji", Spam: true}, {Name: "similarity", Spam: true}},
+			want: Action{Kind: ActionBan, Duration: PermanentBanDuration}},
+		{name: "custom default", policy: Policy{Default: Action{Kind: ActionDelete}}, checks: []spamcheck.Response{{Name: "similarity", Spam: true}},
+			want: Action{Kind: ActionDelete}},
+		{name: "no spam checks, default", policy: Policy{Default: Action{Kind: ActionMute, Duration: time.Hour}},
+			checks: []spamcheck.Response{{Name: "similarity", Score: 0.7}}, want: Action{Kind: ActionMute, Duration: time.Hour}},
+		{name: "wildcard", policy: Policy{Rules: []PolicyRule{{Check: "*", Action: Action{Kind: ActionReport}}}},
+			checks: []spamcheck.Response{{Name: "similarity", Spam: true}}, want: Action{Kind: ActionReport}},
+		{name: "empty policy", checks: []spamcheck.Response{{Name: "similarity", Spam: true}},
+			want: Action{Kind: ActionBan, Duration: PermanentBanDuration}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Resolve(tt.checks))
+		})
+	}
+}
+
+func TestAction_MoreSevere(t *testing.T) {
+	assert.True(t, Action{Kind: ActionBan}.MoreSevere(Action{Kind: ActionMute, Duration: 24 * time.Hour}))
+	assert.True(t, Action{Kind: ActionMute, Duration: 24 * time.Hour}.MoreSevere(Action{Kind: ActionMute, Duration: time.Hour}))
+	assert.True(t, Action{Kind: ActionBan}.MoreSevere(Action{Kind: ActionBan, Duration: time.Hour}), "ban without duration is permanent")
+	assert.True(t, Action{Kind: ActionWarn}.MoreSevere(Action{Kind: ActionDelete}))
+	assert.True(t, Action{Kind: ActionReport}.MoreSevere(Action{}))
+	assert.False(t, Action{Kind: ActionMute, Duration: time.Hour}.MoreSevere(Action{Kind: ActionMute, Duration: time.Hour}))
+	assert.False(t, Action{Kind: ActionDelete}.MoreSevere(Action{Kind: ActionWarn}))
+}
+
+func TestParseLadder(t *testing.T) {
+	res, err := ParseLadder([]string{"warn", "mute:1h", "mute:24h", "ban"})
+	require.NoError(t, err)
+	assert.Equal(t, Ladder{{Kind: ActionWarn}, {Kind: ActionMute, Duration: time.Hour},
+		{Kind: ActionMute, Duration: 24 * time.Hour}, {Kind: ActionBan}}, res)
+
+	res, err = ParseLadder(nil)
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	_, err = ParseLadder([]string{"warn", "mute"})
+	assert.EqualError(t, err, `invalid ladder step "mute": mute action requires duration`)
+}
+
+func TestLadder_Step(t *testing.T) {
+	ladder := Ladder{{Kind: ActionWarn}, {Kind: ActionMute, Duration: time.Hour}, {Kind: ActionBan}}
+	assert.Equal(t, Action{}, ladder.Step(0))
+	assert.Equal(t, Action{Kind: ActionWarn}, ladder.Step(1))
+	assert.Equal(t, Action{Kind: ActionMute, Duration: time.Hour}, ladder.Step(2))
+	assert.Equal(t, Action{Kind: ActionBan}, ladder.Step(3))
+	assert.Equal(t, Action{Kind: ActionBan}, ladder.Step(10), "last step for strikes above the ladder")
+	assert.Equal(t, Action{}, Ladder{}.Step(1))
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -29,27 +30,33 @@ type SpamFilter struct {
 
 // SpamConfig is a full set of parameters for spam bot
 type SpamConfig struct {
-	SamplesStore SamplesStore // storage for spam samples
-	DictStore    DictStore    // storage for stop words and excluded tokens
+	SamplesStore       SamplesStore // storage for spam samples
+	SharedSamplesStore SamplesStore // storage for samples shared by all groups, optional, loaded in addition to SamplesStore
+	DictStore          DictStore    // storage for stop words and excluded tokens
 
 	SpamMsg    string
 	SpamDryMsg string
 	GroupID    string
 	Dry        bool
+	Policy     Policy // maps check results to the action on detected spam
 }
 
 // Detector is a spam detector interface
 type Detector interface {
-	Check(request spamcheck.Request) (spam bool, cr []spamcheck.Response)
+	CheckContext(ctx context.Context, req spamcheck.Request) (spam bool, cr []spamcheck.Response)
+	CheckUser(ctx context.Context, req spamcheck.Request) (spam bool, cr []spamcheck.Response)
 	LoadSamples(exclReader io.Reader, spamReaders, hamReaders []io.Reader) (tgspam.LoadResult, error)
 	LoadStopWords(readers ...io.Reader) (tgspam.LoadResult, error)
+	LoadDomains(denied, allowed io.Reader) (tgspam.LoadResult, error)
 	UpdateSpam(msg string) error
 	UpdateHam(msg string) error
 	RemoveHam(msg string) error
 	RemoveSpam(msg string) error
 	AddApprovedUser(user approved.UserInfo) error
+	CountCleanMessage(userID, userName string)
 	RemoveApprovedUser(id string) error
 	ApprovedUsers() (res []approved.UserInfo)
+	ApprovedUser(userID string) (approved.UserInfo, bool)
 	IsApprovedUser(userID string) bool
 }
 
@@ -70,15 +77,20 @@ func NewSpamFilter(detector Detector, params SpamConfig) *SpamFilter {
 	return &SpamFilter{Detector: detector, params: params}
 }
 
-// OnMessage checks if user already approved and if not checks if user is a spammer
-func (s *SpamFilter) OnMessage(msg Message, checkOnly bool) (response Response) {
+// OnMessage checks if user already approved and if not checks if user is a spammer.
+// Checks are interrupted when ctx is done, and the user is not approved by the interrupted checks.
+func (s *SpamFilter) OnMessage(ctx context.Context, msg Message, checkOnly bool) (response Response) {
 	if msg.From.ID == 0 { // don't check system messages
 		return Response{}
 	}
 	displayUsername := DisplayName(msg)
 
-	spamReq := spamcheck.Request{Msg: msg.Text, CheckOnly: checkOnly,
-		UserID: strconv.FormatInt(msg.From.ID, 10), UserName: msg.From.Username}
+	// edits are checked even for approved users, and never count toward approval.
+	// messages without text, e.g. stickers or dice, are checked but don't count toward approval either.
+	noText := strings.TrimSpace(msg.Text) == ""
+	spamReq := spamcheck.Request{Msg: msg.Text, CheckOnly: checkOnly || msg.Edited || noText, Edited: msg.Edited, Strict: msg.Strict,
+		UserID: strconv.FormatInt(msg.From.ID, 10), UserName: msg.From.Username,
+		UserDisplayName: strings.TrimSpace(msg.From.DisplayName), UserBio: msg.From.Bio}
 	if msg.Image != nil {
 		spamReq.Meta.Images = 1
 	}
@@ -94,7 +106,14 @@ func (s *SpamFilter) OnMessage(msg Message, checkOnly bool) (response Response)
 	if msg.WithKeyboard {
 		spamReq.Meta.HasKeyboard = true
 	}
-	spamReq.Meta.Links = strings.Count(msg.Text, "http://") + strings.Count(msg.Text, "https://")
+	spamReq.Meta.HasDocument, spamReq.Meta.DocumentName = msg.WithDocument, msg.DocumentName
+	spamReq.Meta.HasSticker, spamReq.Meta.HasAnimation, spamReq.Meta.HasVoice = msg.WithSticker, msg.WithAnimation, msg.WithVoice
+	spamReq.Meta.HasPoll, spamReq.Meta.HasContact, spamReq.Meta.HasDice = msg.WithPoll, msg.WithContact, msg.WithDice
+	spamReq.Meta.HasLocation, spamReq.Meta.HasVenue = msg.WithLocation, msg.WithVenue
+	spamReq.Meta.ViaBot = msg.ViaBot
+	if urls := msg.URLs(); len(urls) > 0 {
+		spamReq.Meta.URLs, spamReq.Meta.Links = urls, len(urls)
+	}
 
 	// count mentions from entities
 	if msg.Entities != nil {
@@ -104,25 +123,49 @@ func (s *SpamFilter) OnMessage(msg Message, checkOnly bool) (response Response)
 			}
 		}
 	}
-	isSpam, checkResults := s.Check(spamReq)
+	if usernames := msg.MentionedUsernames(); len(usernames) > 0 {
+		spamReq.Meta.MentionedUsernames = usernames
+	}
+	isSpam, checkResults := s.CheckContext(ctx, spamReq)
 	crs := []string{}
 	for _, cr := range checkResults {
 		crs = append(crs, fmt.Sprintf("{name: %s, spam: %v, details: %s}", cr.Name, cr.Spam, cr.Details))
 	}
 	checkResultStr := strings.Join(crs, ", ")
+	score := spamcheck.TotalScore(checkResults)
 	if isSpam {
-		log.Printf("[INFO] user %s detected as spammer: %s, %q", displayUsername, checkResultStr, msg.Text)
+		log.Printf("[INFO] user %s detected as spammer: %s, score: %.2f, %q", displayUsername, checkResultStr, score, msg.Text)
 		msgPrefix := s.params.SpamMsg
 		if s.params.Dry {
 			msgPrefix = s.params.SpamDryMsg
 		}
 		spamRespMsg := fmt.Sprintf("%s: %q (%d)", msgPrefix, displayUsername, msg.From.ID)
-		return Response{Text: spamRespMsg, Send: true, ReplyTo: msg.ID, BanInterval: PermanentBanDuration, CheckResults: checkResults,
-			DeleteReplyTo: true, User: User{Username: msg.From.Username, ID: msg.From.ID, DisplayName: msg.From.DisplayName},
+		resp := Response{Text: spamRespMsg, Send: true, ReplyTo: msg.ID, CheckResults: checkResults, Score: score,
+			User:     User{Username: msg.From.Username, ID: msg.From.ID, DisplayName: msg.From.DisplayName},
+			ThreadID: msg.ThreadID,
 		}
+		action := s.params.Policy.Resolve(checkResults)
+		log.Printf("[DEBUG] spam action for %s: %s", displayUsername, action)
+		return resp.WithSpamAction(action)
+	}
+	log.Printf("[DEBUG] user %s is not a spammer, %s, score: %.2f", displayUsername, checkResultStr, score)
+	return Response{CheckResults: checkResults, Score: score} // not a spam
+}
+
+// CheckUser checks if the user is a spammer by the user info only, before any message is posted, e.g. on join request.
+// Checks stop words against the user's name, bio and username, CAS and username symbols. Response.Send is set for spammers.
+func (s *SpamFilter) CheckUser(ctx context.Context, user User) Response {
+	req := spamcheck.Request{Msg: strings.TrimSpace(user.DisplayName + "\n" + user.Bio), UserID: strconv.FormatInt(user.ID, 10),
+		UserName: user.Username, UserDisplayName: user.DisplayName, UserBio: user.Bio, CheckOnly: true}
+	isSpam, checkResults := s.Detector.CheckUser(ctx, req)
+	score := spamcheck.TotalScore(checkResults)
+	if isSpam {
+		log.Printf("[INFO] user %q (%d) detected as spammer by user checks: %v, score: %.2f",
+			user.Username, user.ID, checkResults, score)
+		return Response{Send: true, User: user, CheckResults: checkResults, Score: score}
 	}
-	log.Printf("[DEBUG] user %s is not a spammer, %s", displayUsername, checkResultStr)
-	return Response{CheckResults: checkResults} // not a spam
+	log.Printf("[DEBUG] user %q (%d) passed user checks, score: %.2f", user.Username, user.ID, score)
+	return Response{User: user, CheckResults: checkResults, Score: score}
 }
 
 // UpdateSpam appends a message to the spam samples file and updates the classifier
@@ -152,6 +195,15 @@ func (s *SpamFilter) IsApprovedUser(userID int64) bool {
 	return s.Detector.IsApprovedUser(fmt.Sprintf("%d", userID))
 }
 
+// ApprovedAt returns the time the user got approved, false if the user is not approved
+func (s *SpamFilter) ApprovedAt(userID int64) (time.Time, bool) {
+	ui, ok := s.Detector.ApprovedUser(strconv.FormatInt(userID, 10))
+	if !ok {
+		return time.Time{}, false
+	}
+	return ui.Timestamp, true
+}
+
 // AddApprovedUser adds users to the list of approved users, to both the detector and the storage
 func (s *SpamFilter) AddApprovedUser(id int64, name string) error {
 	log.Printf("[INFO] add aproved user: id:%d, name:%q", id, name)
@@ -161,6 +213,11 @@ func (s *SpamFilter) AddApprovedUser(id int64, name string) error {
 	return nil
 }
 
+// CountCleanMessage counts a clean message of the user toward first messages check, without approving the user
+func (s *SpamFilter) CountCleanMessage(id int64, name string) {
+	s.Detector.CountCleanMessage(strconv.FormatInt(id, 10), name)
+}
+
 // RemoveApprovedUser removes users from the list of approved users in both the detector and the storage
 func (s *SpamFilter) RemoveApprovedUser(id int64) error {
 	log.Printf("[INFO] remove aproved user: %d", id)
@@ -170,19 +227,28 @@ func (s *SpamFilter) RemoveApprovedUser(id int64) error {
 	return nil
 }
 
-// ReloadSamples reloads samples and stop-words
+// ReloadSamples reloads samples and stop-words. Detector builds the new model aside and swaps it when ready,
+// so messages keep being checked with the old samples while reloading.
 func (s *SpamFilter) ReloadSamples() (err error) {
 	log.Printf("[DEBUG] reloading samples")
 
 	var exclReader, spamReader, hamReader, stopWordsReader, spamDynamicReader, hamDynamicReader io.ReadCloser
 	ctx := context.TODO()
 
-	// check mandatory data presence
+	// check mandatory data presence, preset samples can be in the group's store or in the shared one
 	st, err := s.params.SamplesStore.Stats(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get samples store stats: %w", err)
 	}
-	if st.PresetSpam == 0 || st.PresetHam == 0 {
+	presetSpam, presetHam := st.PresetSpam, st.PresetHam
+	if s.params.SharedSamplesStore != nil {
+		sst, sErr := s.params.SharedSamplesStore.Stats(ctx)
+		if sErr != nil {
+			return fmt.Errorf("failed to get shared samples store stats: %w", sErr)
+		}
+		presetSpam, presetHam = presetSpam+sst.PresetSpam, presetHam+sst.PresetHam
+	}
+	if presetSpam == 0 || presetHam == 0 {
 		return fmt.Errorf("no pesistent spam or ham samples found in the store")
 	}
 
@@ -218,9 +284,34 @@ func (s *SpamFilter) ReloadSamples() (err error) {
 	}
 	defer exclReader.Close()
 
+	spamReaders, hamReaders := []io.Reader{spamReader, spamDynamicReader}, []io.Reader{hamReader, hamDynamicReader}
+
+	// shared samples, both preset and dynamic, are added to the group's samples
+	if s.params.SharedSamplesStore != nil {
+		sharedReaders := []io.ReadCloser{}
+		defer func() {
+			for _, r := range sharedReaders {
+				r.Close()
+			}
+		}()
+		for _, o := range []storage.SampleOrigin{storage.SampleOriginPreset, storage.SampleOriginUser} {
+			for _, t := range []storage.SampleType{storage.SampleTypeSpam, storage.SampleTypeHam} {
+				r, sErr := s.params.SharedSamplesStore.Reader(ctx, t, o)
+				if sErr != nil {
+					return fmt.Errorf("failed to get shared %s %s samples: %w", o, t, sErr)
+				}
+				sharedReaders = append(sharedReaders, r)
+				if t == storage.SampleTypeSpam {
+					spamReaders = append(spamReaders, r)
+				} else {
+					hamReaders = append(hamReaders, r)
+				}
+			}
+		}
+	}
+
 	// reload samples and stop-words. note: we don't need reset as LoadSamples and LoadStopWords clear the state first
-	lr, err := s.LoadSamples(exclReader, []io.Reader{spamReader, spamDynamicReader},
-		[]io.Reader{hamReader, hamDynamicReader})
+	lr, err := s.LoadSamples(exclReader, spamReaders, hamReaders)
 	if err != nil {
 		return fmt.Errorf("failed to reload samples: %w", err)
 	}
@@ -232,7 +323,33 @@ func (s *SpamFilter) ReloadSamples() (err error) {
 
 	log.Printf("[INFO] loaded samples - spam: %d, ham: %d, excluded tokens: %d, stop-words: %d",
 		lr.SpamSamples, lr.HamSamples, lr.ExcludedTokens, ls.StopWords)
+	if lr.PrunedNGrams > 0 {
+		log.Printf("[INFO] pruned rare n-grams: %d", lr.PrunedNGrams)
+	}
 
+	return s.ReloadDomains()
+}
+
+// ReloadDomains reloads denied and allowed domains from the dictionary, both are optional
+func (s *SpamFilter) ReloadDomains() error {
+	ctx := context.TODO()
+	deniedReader, err := s.params.DictStore.Reader(ctx, storage.DictionaryTypeDeniedDomain)
+	if err != nil {
+		return fmt.Errorf("failed to get denied domains: %w", err)
+	}
+	defer deniedReader.Close()
+
+	allowedReader, err := s.params.DictStore.Reader(ctx, storage.DictionaryTypeAllowedDomain)
+	if err != nil {
+		return fmt.Errorf("failed to get allowed domains: %w", err)
+	}
+	defer allowedReader.Close()
+
+	ld, err := s.LoadDomains(deniedReader, allowedReader)
+	if err != nil {
+		return fmt.Errorf("failed to reload domains: %w", err)
+	}
+	log.Printf("[INFO] loaded domains - denied: %d, allowed: %d", ld.DeniedDomains, ld.AllowedDomains)
 	return nil
 }
 
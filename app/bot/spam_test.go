@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -156,7 +158,8 @@ func TestSpamFilter_OnMessage(t *testing.T) {
 				Msg:      "message https://example.com/path?param=1 http://test.com http://test.com/another",
 				UserID:   "1",
 				UserName: "user1",
-				Meta:     spamcheck.MetaData{Links: 3},
+				Meta: spamcheck.MetaData{Links: 3,
+					URLs: []string{"https://example.com/path?param=1", "http://test.com", "http://test.com/another"}},
 			},
 		},
 		{
@@ -174,9 +177,101 @@ func TestSpamFilter_OnMessage(t *testing.T) {
 				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
 			},
 			wantRequest: spamcheck.Request{
-				Msg:      "spam message",
-				UserID:   "1",
-				UserName: "user1",
+				Msg:             "spam message",
+				UserID:          "1",
+				UserName:        "user1",
+				UserDisplayName: "User One",
+			},
+		},
+		{
+			name:    "edited message, check only",
+			message: Message{Text: "spam message", From: User{ID: 1, Username: "user1"}, Edited: true},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
+			},
+			wantRequest: spamcheck.Request{
+				Msg:       "spam message",
+				UserID:    "1",
+				UserName:  "user1",
+				CheckOnly: true,
+				Edited:    true,
+			},
+		},
+		{
+			name:    "strict message in forum topic",
+			message: Message{Text: "spam message", From: User{ID: 1, Username: "user1"}, Strict: true, ThreadID: 12},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
+				ThreadID:      12,
+			},
+			wantRequest: spamcheck.Request{Msg: "spam message", UserID: "1", UserName: "user1", Strict: true},
+		},
+		{
+			name: "spam with document and contact via bot",
+			message: Message{Text: "spam message", From: User{ID: 1, Username: "user1"},
+				WithDocument: true, DocumentName: "setup.apk", WithContact: true, ViaBot: "somebot"},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
+			},
+			wantRequest: spamcheck.Request{Msg: "spam message", UserID: "1", UserName: "user1",
+				Meta: spamcheck.MetaData{HasDocument: true, DocumentName: "setup.apk", HasContact: true, ViaBot: "somebot"}},
+		},
+		{
+			name: "spam with hidden text link",
+			message: Message{Text: "click here", From: User{ID: 1, Username: "user1"},
+				Entities: &[]Entity{{Type: "text_link", Offset: 6, Length: 4, URL: "https://spam.com"}}},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
+			},
+			wantRequest: spamcheck.Request{Msg: "click here", UserID: "1", UserName: "user1",
+				Meta: spamcheck.MetaData{Links: 1, URLs: []string{"https://spam.com"}}},
+		},
+		{
+			name:    "sticker without text, check only",
+			message: Message{From: User{ID: 1, Username: "user1"}, WithSticker: true},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults:  []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}},
+			},
+			wantRequest: spamcheck.Request{UserID: "1", UserName: "user1", CheckOnly: true,
+				Meta: spamcheck.MetaData{HasSticker: true}},
+		},
+		{
+			name:    "spam with total score",
+			message: Message{Text: "scored message", From: User{ID: 1, Username: "user1"}},
+			wantResponse: Response{
+				Text:          `detected: "user1" (1)`,
+				Send:          true,
+				BanInterval:   PermanentBanDuration,
+				DeleteReplyTo: true,
+				User:          User{ID: 1, Username: "user1"},
+				CheckResults: []spamcheck.Response{{Name: "test1", Spam: true, Details: "spam", Score: 1},
+					{Name: "test2", Spam: false, Details: "ham", Score: 0.25}},
+				Score: 1.25,
 			},
 		},
 	}
@@ -184,13 +279,17 @@ func TestSpamFilter_OnMessage(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			det := &mocks.DetectorMock{
-				CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
-					if tc.wantRequest != (spamcheck.Request{}) {
+				CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+					if !reflect.DeepEqual(tc.wantRequest, spamcheck.Request{}) {
 						assert.Equal(t, tc.wantRequest, req)
 					}
 					if tc.message.Text == "good message" {
 						return false, []spamcheck.Response{{Name: "test", Spam: false, Details: "ham"}}
 					}
+					if tc.message.Text == "scored message" {
+						return true, []spamcheck.Response{{Name: "test1", Spam: true, Details: "spam", Score: 1},
+							{Name: "test2", Spam: false, Details: "ham", Score: 0.25}}
+					}
 					return true, []spamcheck.Response{{Name: "test", Spam: true, Details: "spam"}}
 				},
 			}
@@ -201,18 +300,88 @@ func TestSpamFilter_OnMessage(t *testing.T) {
 				Dry:        tc.dry,
 			})
 
-			got := s.OnMessage(tc.message, tc.checkOnly)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			got := s.OnMessage(ctx, tc.message, tc.checkOnly)
 			assert.Equal(t, tc.wantResponse, got)
 
 			if tc.message.From.ID == 0 {
-				assert.Empty(t, det.CheckCalls())
+				assert.Empty(t, det.CheckContextCalls())
 			} else {
-				assert.Equal(t, 1, len(det.CheckCalls()))
+				require.Equal(t, 1, len(det.CheckContextCalls()))
+				assert.Equal(t, ctx, det.CheckContextCalls()[0].Ctx, "listener's context passed to the detector")
 			}
 		})
 	}
 }
 
+func TestSpamFilter_OnMessageWithPolicy(t *testing.T) {
+	det := &mocks.DetectorMock{
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+			return true, []spamcheck.Response{{Name: req.Msg, Spam: true, Details: "spam"}}
+		},
+	}
+	s := NewSpamFilter(det, SpamConfig{SpamMsg: "detected", Policy: Policy{Rules: []PolicyRule{
+		{Check: "cas", Action: Action{Kind: ActionBan}},
+		{Check: "stopword", Action: Action{Kind: ActionMute, Duration: 24 * time.Hour}},
+		{Check: "emoji", Action: Action{Kind: ActionDelete}},
+		{Check: "classifier", Action: Action{Kind: ActionReport}},
+	}}})
+
+	tbl := []struct {
+		check         string
+		banInterval   time.Duration
+		deleteReplyTo bool
+		restrict      bool
+		reportOnly    bool
+	}{
+		{check: "cas", banInterval: PermanentBanDuration, deleteReplyTo: true},
+		{check: "stopword", banInterval: 24 * time.Hour, deleteReplyTo: true, restrict: true},
+		{check: "emoji", deleteReplyTo: true},
+		{check: "classifier", reportOnly: true},
+		{check: "similarity", banInterval: PermanentBanDuration, deleteReplyTo: true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.check, func(t *testing.T) {
+			resp := s.OnMessage(context.Background(), Message{ID: 10, Text: tt.check, From: User{ID: 1, Username: "user1"}}, false)
+			assert.True(t, resp.Send)
+			assert.Equal(t, 10, resp.ReplyTo)
+			assert.Equal(t, tt.banInterval, resp.BanInterval)
+			assert.Equal(t, tt.deleteReplyTo, resp.DeleteReplyTo)
+			assert.Equal(t, tt.restrict, resp.Restrict)
+			assert.Equal(t, tt.reportOnly, resp.ReportOnly)
+		})
+	}
+}
+
+func TestSpamFilter_CheckUser(t *testing.T) {
+	det := &mocks.DetectorMock{
+		CheckUserFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+			if req.UserName == "spammer" {
+				return true, []spamcheck.Response{{Name: "stopword", Spam: true, Details: "spammer"}}
+			}
+			return false, []spamcheck.Response{{Name: "stopword", Spam: false, Details: "not found"}}
+		},
+	}
+	s := NewSpamFilter(det, SpamConfig{})
+
+	resp := s.CheckUser(context.Background(), User{ID: 1, Username: "spammer", DisplayName: "Spam Bot"})
+	assert.True(t, resp.Send)
+	assert.Equal(t, User{ID: 1, Username: "spammer", DisplayName: "Spam Bot"}, resp.User)
+	assert.Equal(t, []spamcheck.Response{{Name: "stopword", Spam: true, Details: "spammer"}}, resp.CheckResults)
+	require.Len(t, det.CheckUserCalls(), 1)
+	assert.Equal(t, spamcheck.Request{Msg: "Spam Bot", UserID: "1", UserName: "spammer", UserDisplayName: "Spam Bot",
+		CheckOnly: true},
+		det.CheckUserCalls()[0].Req)
+
+	resp = s.CheckUser(context.Background(), User{ID: 2, Username: "user", DisplayName: "John", Bio: "just a bio"})
+	assert.False(t, resp.Send)
+	assert.Len(t, resp.CheckResults, 1)
+	require.Len(t, det.CheckUserCalls(), 2)
+	assert.Equal(t, "John\njust a bio", det.CheckUserCalls()[1].Req.Msg, "bio checked with the name")
+}
+
 func TestSpamFilter_UpdateSpam(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -429,6 +598,7 @@ func TestSpamFilter_ReloadSamples(t *testing.T) {
 		readerErr    error
 		loadErr      error
 		stopWordsErr error
+		domainsErr   error
 		expectError  bool
 	}{
 		{
@@ -463,6 +633,12 @@ func TestSpamFilter_ReloadSamples(t *testing.T) {
 			stopWordsErr: errors.New("stop words error"),
 			expectError:  true,
 		},
+		{
+			name:        "domains error",
+			statsResult: &storage.SamplesStats{PresetSpam: 10, PresetHam: 5},
+			domainsErr:  errors.New("domains error"),
+			expectError: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -474,6 +650,9 @@ func TestSpamFilter_ReloadSamples(t *testing.T) {
 				LoadStopWordsFunc: func(readers ...io.Reader) (tgspam.LoadResult, error) {
 					return tgspam.LoadResult{StopWords: 3}, tc.stopWordsErr
 				},
+				LoadDomainsFunc: func(denied, allowed io.Reader) (tgspam.LoadResult, error) {
+					return tgspam.LoadResult{DeniedDomains: 2}, tc.domainsErr
+				},
 			}
 
 			samplesStore := &mocks.SamplesStoreMock{
@@ -510,11 +689,121 @@ func TestSpamFilter_ReloadSamples(t *testing.T) {
 			// verify all required methods were called
 			assert.Equal(t, 1, len(det.LoadSamplesCalls()))
 			assert.Equal(t, 1, len(det.LoadStopWordsCalls()))
+			assert.Equal(t, 1, len(det.LoadDomainsCalls()))
 			assert.Equal(t, 1, len(samplesStore.StatsCalls()))
 		})
 	}
 }
 
+func TestSpamFilter_ReloadDomains(t *testing.T) {
+	var denied, allowed string
+	det := &mocks.DetectorMock{
+		LoadDomainsFunc: func(d, a io.Reader) (tgspam.LoadResult, error) {
+			db, _ := io.ReadAll(d)
+			ab, _ := io.ReadAll(a)
+			denied, allowed = string(db), string(ab)
+			return tgspam.LoadResult{DeniedDomains: 2, AllowedDomains: 1}, nil
+		},
+	}
+	dictStore := &mocks.DictStoreMock{
+		ReaderFunc: func(ctx context.Context, t storage.DictionaryType) (io.ReadCloser, error) {
+			switch t {
+			case storage.DictionaryTypeDeniedDomain:
+				return io.NopCloser(strings.NewReader("spam.com\nt.me/+")), nil
+			case storage.DictionaryTypeAllowedDomain:
+				return io.NopCloser(strings.NewReader("example.com")), nil
+			}
+			return nil, errors.New("unexpected type")
+		},
+	}
+
+	s := NewSpamFilter(det, SpamConfig{DictStore: dictStore})
+	require.NoError(t, s.ReloadDomains())
+	assert.Equal(t, "spam.com\nt.me/+", denied)
+	assert.Equal(t, "example.com", allowed)
+
+	dictStore.ReaderFunc = func(ctx context.Context, t storage.DictionaryType) (io.ReadCloser, error) {
+		return nil, errors.New("db error")
+	}
+	assert.EqualError(t, s.ReloadDomains(), "failed to get denied domains: db error")
+}
+
+func TestSpamFilter_ReloadSamplesShared(t *testing.T) {
+	det := &mocks.DetectorMock{
+		LoadSamplesFunc: func(exclReader io.Reader, spamReaders []io.Reader, hamReaders []io.Reader) (tgspam.LoadResult, error) {
+			return tgspam.LoadResult{SpamSamples: 10, HamSamples: 5}, nil
+		},
+		LoadStopWordsFunc: func(readers ...io.Reader) (tgspam.LoadResult, error) {
+			return tgspam.LoadResult{StopWords: 3}, nil
+		},
+		LoadDomainsFunc: func(denied, allowed io.Reader) (tgspam.LoadResult, error) {
+			return tgspam.LoadResult{DeniedDomains: 2}, nil
+		},
+	}
+	reader := func(ctx context.Context, t storage.SampleType, o storage.SampleOrigin) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("test data")), nil
+	}
+	dictStore := &mocks.DictStoreMock{
+		ReaderFunc: func(ctx context.Context, t storage.DictionaryType) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("test data")), nil
+		},
+	}
+
+	t.Run("group without own presets uses shared ones", func(t *testing.T) {
+		det.ResetCalls()
+		samplesStore := &mocks.SamplesStoreMock{
+			StatsFunc:  func(ctx context.Context) (*storage.SamplesStats, error) { return &storage.SamplesStats{}, nil },
+			ReaderFunc: reader,
+		}
+		sharedStore := &mocks.SamplesStoreMock{
+			StatsFunc: func(ctx context.Context) (*storage.SamplesStats, error) {
+				return &storage.SamplesStats{PresetSpam: 10, PresetHam: 5}, nil
+			},
+			ReaderFunc: reader,
+		}
+		s := NewSpamFilter(det, SpamConfig{SamplesStore: samplesStore, SharedSamplesStore: sharedStore, DictStore: dictStore})
+		require.NoError(t, s.ReloadSamples())
+
+		require.Len(t, det.LoadSamplesCalls(), 1)
+		assert.Len(t, det.LoadSamplesCalls()[0].SpamReaders, 4, "own preset and user, shared preset and user")
+		assert.Len(t, det.LoadSamplesCalls()[0].HamReaders, 4)
+		require.Len(t, sharedStore.ReaderCalls(), 4)
+		assert.Equal(t, storage.SampleOriginPreset, sharedStore.ReaderCalls()[0].O)
+		assert.Equal(t, storage.SampleOriginUser, sharedStore.ReaderCalls()[3].O)
+	})
+
+	t.Run("shared stats error", func(t *testing.T) {
+		samplesStore := &mocks.SamplesStoreMock{
+			StatsFunc: func(ctx context.Context) (*storage.SamplesStats, error) {
+				return &storage.SamplesStats{PresetSpam: 10, PresetHam: 5}, nil
+			},
+			ReaderFunc: reader,
+		}
+		sharedStore := &mocks.SamplesStoreMock{
+			StatsFunc: func(ctx context.Context) (*storage.SamplesStats, error) { return nil, errors.New("stats error") },
+		}
+		s := NewSpamFilter(det, SpamConfig{SamplesStore: samplesStore, SharedSamplesStore: sharedStore, DictStore: dictStore})
+		assert.ErrorContains(t, s.ReloadSamples(), "shared samples store stats")
+	})
+
+	t.Run("shared reader error", func(t *testing.T) {
+		samplesStore := &mocks.SamplesStoreMock{
+			StatsFunc: func(ctx context.Context) (*storage.SamplesStats, error) {
+				return &storage.SamplesStats{PresetSpam: 10, PresetHam: 5}, nil
+			},
+			ReaderFunc: reader,
+		}
+		sharedStore := &mocks.SamplesStoreMock{
+			StatsFunc: func(ctx context.Context) (*storage.SamplesStats, error) { return &storage.SamplesStats{}, nil },
+			ReaderFunc: func(ctx context.Context, t storage.SampleType, o storage.SampleOrigin) (io.ReadCloser, error) {
+				return nil, errors.New("reader error")
+			},
+		}
+		s := NewSpamFilter(det, SpamConfig{SamplesStore: samplesStore, SharedSamplesStore: sharedStore, DictStore: dictStore})
+		assert.ErrorContains(t, s.ReloadSamples(), "failed to get shared preset spam samples")
+	})
+}
+
 func TestSpamFilter_RemoveDynamicSample(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -544,6 +833,9 @@ func TestSpamFilter_RemoveDynamicSample(t *testing.T) {
 				LoadStopWordsFunc: func(readers ...io.Reader) (tgspam.LoadResult, error) {
 					return tgspam.LoadResult{}, nil
 				},
+				LoadDomainsFunc: func(denied, allowed io.Reader) (tgspam.LoadResult, error) {
+					return tgspam.LoadResult{DeniedDomains: 2}, nil
+				},
 				RemoveSpamFunc: func(msg string) error {
 					assert.Equal(t, tc.sample, msg)
 					return tc.deleteErr
@@ -623,6 +915,39 @@ func TestSpamFilter_IsApprovedUser(t *testing.T) {
 	}
 }
 
+func TestSpamFilter_ApprovedAt(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	det := &mocks.DetectorMock{
+		ApprovedUserFunc: func(userID string) (approved.UserInfo, bool) {
+			if userID == "123" {
+				return approved.UserInfo{UserID: "123", Timestamp: ts}, true
+			}
+			return approved.UserInfo{}, false
+		},
+	}
+	s := NewSpamFilter(det, SpamConfig{})
+
+	got, ok := s.ApprovedAt(123)
+	assert.True(t, ok)
+	assert.Equal(t, ts, got)
+
+	got, ok = s.ApprovedAt(456)
+	assert.False(t, ok)
+	assert.True(t, got.IsZero())
+	assert.Len(t, det.ApprovedUserCalls(), 2)
+}
+
+func TestSpamFilter_CountCleanMessage(t *testing.T) {
+	det := &mocks.DetectorMock{CountCleanMessageFunc: func(userID, userName string) {}}
+	s := NewSpamFilter(det, SpamConfig{})
+
+	s.CountCleanMessage(123, "user")
+	require.Len(t, det.CountCleanMessageCalls(), 1)
+	assert.Equal(t, "123", det.CountCleanMessageCalls()[0].UserID)
+	assert.Equal(t, "user", det.CountCleanMessageCalls()[0].UserName)
+	assert.Empty(t, det.AddApprovedUserCalls())
+}
+
 func TestSpamFilter_DynamicSamples(t *testing.T) {
 	tests := []struct {
 		name        string
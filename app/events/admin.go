@@ -15,6 +15,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/storage"
 )
 
 // admin is a helper to handle all admin-group related stuff, created by listener
@@ -30,34 +31,110 @@ type admin struct {
 	softBan      bool // if true, the user not banned automatically, but only restricted
 	dry          bool
 	warnMsg      string
+
+	strikes       Strikes    // user strikes storage, optional
+	strikesLadder bot.Ladder // escalation ladder for strikes
+
+	groupName string // name of the group, set for multi-group setup only. Added to reports and callback data
 }
 
 const (
 	confirmationPrefix = "?"
 	banPrefix          = "+"
 	infoPrefix         = "!"
+	joinApprovePrefix  = "A"
+	joinDeclinePrefix  = "D"
 )
 
 // ReportBan a ban message to admin chat with a button to unban the user
 func (a *admin) ReportBan(banUserStr string, msg *bot.Message) {
-	log.Printf("[DEBUG] report to admin chat, ban msgsData for %s, group: %d", banUserStr, a.adminChatID)
+	a.ReportSpam(banUserStr, msg, "permanently banned")
+}
+
+// ReportSpam sends a message about the action taken on detected spam to admin chat with a button to unban the user.
+// action describes what was done, e.g. "permanently banned", "muted for 24h0m0s" or "deleted message from".
+func (a *admin) ReportSpam(banUserStr string, msg *bot.Message, action string) {
+	log.Printf("[DEBUG] report to admin chat, %s msgsData for %s, group: %d", action, banUserStr, a.adminChatID)
 	text := strings.ReplaceAll(escapeMarkDownV1Text(msg.Text), "\n", " ")
 	would := ""
 	if a.dry {
 		would = "would have "
 	}
 
-	forwardMsg := fmt.Sprintf("**%spermanently banned [%s](tg://user?id=%d)**\n\n%s\n\n",
-		would, escapeMarkDownV1Text(banUserStr), msg.From.ID, text)
+	inGroup := ""
+	if a.groupName != "" {
+		inGroup = " in " + escapeMarkDownV1Text(a.groupName)
+	}
+	if link := topicLink(msg.ChatID, msg.ThreadID); link != "" {
+		inGroup += fmt.Sprintf(" in [topic %d](%s)", msg.ThreadID, link)
+	}
+
+	forwardMsg := fmt.Sprintf("**%s%s [%s](tg://user?id=%d)%s**\n\n%s\n\n",
+		would, action, escapeMarkDownV1Text(banUserStr), msg.From.ID, inGroup, text)
 	if err := a.sendWithUnbanMarkup(forwardMsg, "change ban", msg.From, msg.ID, a.adminChatID); err != nil {
 		log.Printf("[WARN] failed to send admin message, %v", err)
 	}
 }
 
+// ReportJoinRequest sends a message about suspicious join request to admin chat with check results.
+// Pending request has buttons to approve or decline it, declined one is reported for information only.
+// callback data: AuserID:0:chatID to approve, DuserID:0:chatID to decline
+func (a *admin) ReportJoinRequest(user bot.User, chatID int64, resp bot.Response, declined bool) {
+	inGroup := ""
+	if a.groupName != "" {
+		inGroup = " in " + escapeMarkDownV1Text(a.groupName)
+	}
+	action := "suspicious join request from"
+	if declined {
+		action = "declined join request from"
+		if a.dry {
+			action = "would have declined join request from"
+		}
+	}
+	checks := []string{}
+	for _, cr := range resp.CheckResults {
+		if cr.Spam {
+			checks = append(checks, "- "+escapeMarkDownV1Text(cr.String()))
+		}
+	}
+	text := fmt.Sprintf("**%s [%s](tg://user?id=%d)%s**\n\n%s", action, escapeMarkDownV1Text(fmt.Sprintf("%v", user)),
+		user.ID, inGroup, strings.Join(checks, "\n"))
+
+	tbMsg := tbapi.NewMessage(a.adminChatID, text)
+	tbMsg.ParseMode = tbapi.ModeMarkdown
+	tbMsg.LinkPreviewOptions = tbapi.LinkPreviewOptions{IsDisabled: true}
+	if !declined {
+		data := fmt.Sprintf("%d:0:%d", user.ID, chatID)
+		tbMsg.ReplyMarkup = tbapi.NewInlineKeyboardMarkup(tbapi.NewInlineKeyboardRow(
+			tbapi.NewInlineKeyboardButtonData("✅ approve", joinApprovePrefix+data),
+			tbapi.NewInlineKeyboardButtonData("⛔︎ decline", joinDeclinePrefix+data),
+		))
+	}
+	if _, err := a.tbAPI.Send(tbMsg); err != nil {
+		log.Printf("[WARN] failed to send join request to admin chat, %v", err)
+	}
+}
+
+// ReportCampaign sends a message about detected spam campaign to admin chat, with the message and the users of campaign
+func (a *admin) ReportCampaign(msg *bot.Message, details string) {
+	inGroup := ""
+	if a.groupName != "" {
+		inGroup = " in " + escapeMarkDownV1Text(a.groupName)
+	}
+	text := fmt.Sprintf("**spam campaign detected%s**\n\n%s\n\n%s", inGroup,
+		strings.ReplaceAll(escapeMarkDownV1Text(msg.Text), "\n", " "), escapeMarkDownV1Text(details))
+	tbMsg := tbapi.NewMessage(a.adminChatID, text)
+	tbMsg.ParseMode = tbapi.ModeMarkdown
+	tbMsg.LinkPreviewOptions = tbapi.LinkPreviewOptions{IsDisabled: true}
+	if _, err := a.tbAPI.Send(tbMsg); err != nil {
+		log.Printf("[WARN] failed to send campaign report to admin chat, %v", err)
+	}
+}
+
 // MsgHandler handles messages received on admin chat. this is usually forwarded spam failed
 // to be detected by the bot. we need to update spam filter with this message and ban the user.
 // the user will be baned even in training mode, but not in the dry mode.
-func (a *admin) MsgHandler(update tbapi.Update) error {
+func (a *admin) MsgHandler(ctx context.Context, update tbapi.Update) error {
 	shrink := func(inp string, maxLen int) string {
 		if utf8.RuneCountInString(inp) <= maxLen {
 			return inp
@@ -94,7 +171,7 @@ func (a *admin) MsgHandler(update tbapi.Update) error {
 
 	// it would be nice to ban this user right away, but we don't have forwarded user ID here due to tg privacy limitation.
 	// it is empty in update.Message. to ban this user, we need to get the match on the message from the locator and ban from there.
-	info, ok := a.locator.Message(context.TODO(), msgTxt)
+	info, ok := a.locator.Message(ctx, msgTxt)
 	if !ok {
 		return fmt.Errorf("not found %q in locator", shrink(msgTxt, 50))
 	}
@@ -116,11 +193,14 @@ func (a *admin) MsgHandler(update tbapi.Update) error {
 	spamInfo := []string{}
 	// check only, don't update the storage, as all we care here is to get checks results.
 	// without checkOnly flag, it may add approved user to the storage after we removed it above.
-	resp := a.bot.OnMessage(bot.Message{Text: update.Message.Text, From: bot.User{ID: info.UserID}}, true)
+	resp := a.bot.OnMessage(ctx, bot.Message{Text: update.Message.Text, From: bot.User{ID: info.UserID}}, true)
 	spamInfoText := "**can't get spam info**"
 	for _, check := range resp.CheckResults {
 		spamInfo = append(spamInfo, "- "+escapeMarkDownV1Text(check.String()))
 	}
+	if resp.Score > 0 {
+		spamInfo = append(spamInfo, fmt.Sprintf("- total score: %.2f", resp.Score))
+	}
 	if len(spamInfo) > 0 {
 		spamInfoText = strings.Join(spamInfo, "\n")
 	}
@@ -164,19 +244,20 @@ func (a *admin) MsgHandler(update tbapi.Update) error {
 }
 
 // DirectSpamReport handles messages replayed with "/spam" or "spam" by admin
-func (a *admin) DirectSpamReport(update tbapi.Update) error {
-	return a.directReport(update, true)
+func (a *admin) DirectSpamReport(ctx context.Context, update tbapi.Update) error {
+	return a.directReport(ctx, update, true)
 }
 
 // DirectBanReport handles messages replayed with "/ban" or "ban" by admin. doing all the same as DirectSpamReport
 // but without updating spam samples
-func (a *admin) DirectBanReport(update tbapi.Update) error {
-	return a.directReport(update, false)
+func (a *admin) DirectBanReport(ctx context.Context, update tbapi.Update) error {
+	return a.directReport(ctx, update, false)
 }
 
 // DirectWarnReport handles messages replayed with "/warn" or "warn" by admin.
-// it is removing the original message and posting a warning to the main chat as well as recording the warning th admin chat
-func (a *admin) DirectWarnReport(update tbapi.Update) error {
+// it is removing the original message and posting a warning to the main chat as well as recording the warning th admin chat.
+// if strikes enabled, the warning adds a strike, and the user is muted or banned once the ladder step for active strikes requires it.
+func (a *admin) DirectWarnReport(ctx context.Context, update tbapi.Update) error {
 	log.Printf("[DEBUG] direct warn by admin %q: msg id: %d, from: %q (%d)",
 		update.Message.From.UserName, update.Message.ReplyToMessage.MessageID,
 		update.Message.ReplyToMessage.From.UserName, update.Message.ReplyToMessage.From.ID)
@@ -219,13 +300,57 @@ func (a *admin) DirectWarnReport(update tbapi.Update) error {
 	// make a warning message and replay to origMsg.MessageID
 	warnMsg := fmt.Sprintf("warning from %s\n\n@%s %s", update.Message.From.UserName,
 		origMsg.From.UserName, a.warnMsg)
-	if err := send(tbapi.NewMessage(a.primChatID, escapeMarkDownV1Text(warnMsg)), a.tbAPI); err != nil {
+	tbMsg := tbapi.NewMessage(a.primChatID, escapeMarkDownV1Text(warnMsg))
+	tbMsg.MessageThreadID = threadID(origMsg)
+	if err := send(tbMsg, a.tbAPI); err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("failed to send warning to main chat: %w", err))
 	}
 
+	if err := a.strikeWarned(ctx, update); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
 	return errs.ErrorOrNil()
 }
 
+// strikeWarned adds a strike for the user warned by admin and mutes or bans the user if the ladder step requires it
+func (a *admin) strikeWarned(ctx context.Context, update tbapi.Update) error {
+	if a.strikes == nil {
+		return nil
+	}
+	origMsg := update.Message.ReplyToMessage
+	count, err := a.strikes.Add(ctx, storage.StrikeInfo{UserID: origMsg.From.ID, UserName: origMsg.From.UserName,
+		Source: "admin", Reason: "warned by " + update.Message.From.UserName, Applied: true})
+	if err != nil {
+		return fmt.Errorf("failed to add strike for %d: %w", origMsg.From.ID, err)
+	}
+	if err := a.strikes.MarkApplied(ctx, origMsg.From.ID); err != nil {
+		log.Printf("[WARN] failed to mark strikes applied for %d: %v", origMsg.From.ID, err)
+	}
+
+	step := a.strikesLadder.Step(count)
+	if step.Kind != bot.ActionMute && step.Kind != bot.ActionBan {
+		return nil
+	}
+
+	resp := bot.Response{Send: true}.WithSpamAction(step)
+	userName := origMsg.From.UserName
+	if userName == "" {
+		userName = fmt.Sprintf("%d", origMsg.From.ID)
+	}
+	log.Printf("[INFO] escalate warning for %q (%d) to %s, active strikes: %d", userName, origMsg.From.ID, step, count)
+	banReq := banRequest{duration: resp.BanInterval, userID: origMsg.From.ID, chatID: a.primChatID, userName: userName,
+		tbAPI: a.tbAPI, dry: a.dry, training: a.trainingMode, restrict: a.softBan || resp.Restrict}
+	if err := banUserOrChannel(banReq); err != nil {
+		return fmt.Errorf("failed to ban %s: %w", userName, err)
+	}
+	if a.adminChatID != 0 {
+		msg := transform(origMsg)
+		a.ReportSpam(userName, msg, spamActionText(resp))
+	}
+	return nil
+}
+
 // returns the user ID and username from the tg update if's forwarded message,
 // or just username in case sender is hidden user
 func (a *admin) getForwardUsernameAndID(update tbapi.Update) (fwdID int64, username string) {
@@ -241,7 +366,7 @@ func (a *admin) getForwardUsernameAndID(update tbapi.Update) (fwdID int64, usern
 }
 
 // directReport handles messages replayed with "/spam" or "spam", or "/ban" or "ban" by admin
-func (a *admin) directReport(update tbapi.Update, updateSamples bool) error {
+func (a *admin) directReport(ctx context.Context, update tbapi.Update, updateSamples bool) error {
 	log.Printf("[DEBUG] direct ban by admin %q: msg id: %d, from: %q (%d)",
 		update.Message.From.UserName, update.Message.ReplyToMessage.MessageID,
 		update.Message.ReplyToMessage.From.UserName, update.Message.ReplyToMessage.From.ID)
@@ -273,11 +398,14 @@ func (a *admin) directReport(update tbapi.Update, updateSamples bool) error {
 	// make a message with spam info and send to admin chat
 	spamInfo := []string{}
 	// check only, don't update the storage with the new approved user as all we care here is to get checks results
-	resp := a.bot.OnMessage(bot.Message{Text: msgTxt, From: bot.User{ID: origMsg.From.ID}}, true)
+	resp := a.bot.OnMessage(ctx, bot.Message{Text: msgTxt, From: bot.User{ID: origMsg.From.ID}}, true)
 	spamInfoText := "**can't get spam info**"
 	for _, check := range resp.CheckResults {
 		spamInfo = append(spamInfo, "- "+escapeMarkDownV1Text(check.String()))
 	}
+	if resp.Score > 0 {
+		spamInfo = append(spamInfo, fmt.Sprintf("- total score: %.2f", resp.Score))
+	}
 	if len(spamInfo) > 0 {
 		spamInfoText = strings.Join(spamInfo, "\n")
 	}
@@ -336,7 +464,7 @@ func (a *admin) directReport(update tbapi.Update, updateSamples bool) error {
 
 // InlineCallbackHandler handles a callback from Telegram, which is a response to a message with inline keyboard.
 // The callback contains user info, which is used to unban the user.
-func (a *admin) InlineCallbackHandler(query *tbapi.CallbackQuery) error {
+func (a *admin) InlineCallbackHandler(ctx context.Context, query *tbapi.CallbackQuery) error {
 	callbackData := query.Data
 	chatID := query.Message.Chat.ID // this is ID of admin chat
 	if chatID != a.adminChatID {    // ignore callbacks from other chats, only admin chat is allowed
@@ -355,7 +483,7 @@ func (a *admin) InlineCallbackHandler(query *tbapi.CallbackQuery) error {
 
 	// if callback msgsData starts with "+", we should not unban the user, but rather clear the keyboard and add to spam samples
 	if strings.HasPrefix(callbackData, banPrefix) {
-		if err := a.callbackBanConfirmed(query); err != nil {
+		if err := a.callbackBanConfirmed(ctx, query); err != nil {
 			return fmt.Errorf("failed confirmation ban: %w", err)
 		}
 		log.Printf("[DEBUG] ban confirmed, chatID: %d, userID: %s, orig: %q", chatID, callbackData, query.Message.Text)
@@ -364,16 +492,25 @@ func (a *admin) InlineCallbackHandler(query *tbapi.CallbackQuery) error {
 
 	// if callback msgsData starts with "!", we should show a spam info details
 	if strings.HasPrefix(callbackData, infoPrefix) {
-		if err := a.callbackShowInfo(query); err != nil {
+		if err := a.callbackShowInfo(ctx, query); err != nil {
 			return fmt.Errorf("failed to show spam info: %w", err)
 		}
 		log.Printf("[DEBUG] spam info sent, chatID: %d, userID: %s, orig: %q", chatID, callbackData, query.Message.Text)
 		return nil
 	}
 
+	// if callback msgsData starts with "A" or "D", we should approve or decline the join request
+	if strings.HasPrefix(callbackData, joinApprovePrefix) || strings.HasPrefix(callbackData, joinDeclinePrefix) {
+		if err := a.callbackJoinRequest(query, strings.HasPrefix(callbackData, joinApprovePrefix)); err != nil {
+			return fmt.Errorf("failed to process join request: %w", err)
+		}
+		log.Printf("[DEBUG] join request processed, chatID: %d, data: %s, orig: %q", chatID, callbackData, query.Message.Text)
+		return nil
+	}
+
 	// no prefix, callback msgsData here is userID, we should unban the user
 	log.Printf("[DEBUG] unban action activated, chatID: %d, userID: %s, orig: %q", chatID, callbackData, query.Message.Text)
-	if err := a.callbackUnbanConfirmed(query); err != nil {
+	if err := a.callbackUnbanConfirmed(ctx, query); err != nil {
 		return fmt.Errorf("failed to unban user: %w", err)
 	}
 	log.Printf("[INFO] user unbanned, chatID: %d, userID: %s, orig: %q", chatID, callbackData, query.Message.Text)
@@ -409,7 +546,7 @@ func (a *admin) callbackAskBanConfirmation(query *tbapi.CallbackQuery) error {
 // it clears the keyboard and updates the message text with confirmation of ban kept in place.
 // it also updates spam samples with the original message
 // callback data: +userID:msgID
-func (a *admin) callbackBanConfirmed(query *tbapi.CallbackQuery) error {
+func (a *admin) callbackBanConfirmed(ctx context.Context, query *tbapi.CallbackQuery) error {
 	// clear keyboard and update message text with confirmation
 	updText := query.Message.Text + fmt.Sprintf("\n\n_ban confirmed by %s in %v_", query.From.UserName, a.sinceQuery(query))
 	editMsg := tbapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, updText)
@@ -435,7 +572,7 @@ func (a *admin) callbackBanConfirmed(query *tbapi.CallbackQuery) error {
 
 	if a.trainingMode {
 		// in training mode, the user is not banned automatically, here we do the real ban & delete the message
-		if err := a.deleteAndBan(query, userID, msgID); err != nil {
+		if err := a.deleteAndBan(ctx, query, userID, msgID); err != nil {
 			return fmt.Errorf("failed to ban user %d: %w", userID, err)
 		}
 	}
@@ -461,7 +598,7 @@ func (a *admin) callbackBanConfirmed(query *tbapi.CallbackQuery) error {
 // it clears the keyboard and updates the message text with confirmation of unban.
 // also it unbans the user, adds it to the approved list and updates ham samples with the original message.
 // callback data: userID:msgID
-func (a *admin) callbackUnbanConfirmed(query *tbapi.CallbackQuery) error {
+func (a *admin) callbackUnbanConfirmed(ctx context.Context, query *tbapi.CallbackQuery) error {
 	callbackData := query.Data
 	chatID := query.Message.Chat.ID // this is ID of admin chat
 	log.Printf("[DEBUG] unban action activated, chatID: %d, userID: %s", chatID, callbackData)
@@ -494,6 +631,13 @@ func (a *admin) callbackUnbanConfirmed(query *tbapi.CallbackQuery) error {
 		}
 	}
 
+	// reset strikes, unbanned user starts from the first step of the ladder
+	if a.strikes != nil {
+		if err := a.strikes.Clear(ctx, userID); err != nil {
+			log.Printf("[WARN] failed to clear strikes for %d: %v", userID, err)
+		}
+	}
+
 	// add user to the approved list
 	name, err := a.extractUsername(query.Message.Text) // try to extract username from the message
 	if err != nil {
@@ -511,7 +655,7 @@ func (a *admin) callbackUnbanConfirmed(query *tbapi.CallbackQuery) error {
 	if !strings.Contains(query.Message.Text, "spam detection results") && userID != 0 {
 		spamInfoText := []string{"\n\n**original detection results**\n"}
 
-		info, found := a.locator.Spam(context.TODO(), userID)
+		info, found := a.locator.Spam(ctx, userID)
 		if found {
 			for _, check := range info.Checks {
 				spamInfoText = append(spamInfoText, "- "+escapeMarkDownV1Text(check.String()))
@@ -566,9 +710,44 @@ func (a *admin) unban(userID int64) error {
 	return nil
 }
 
+// callbackJoinRequest handles the callback when admin approves or declines the join request.
+// it clears the keyboard and updates the message text with the decision.
+// callback data: AuserID:0:chatID or DuserID:0:chatID
+func (a *admin) callbackJoinRequest(query *tbapi.CallbackQuery, approve bool) error {
+	userID, _, err := a.parseCallbackData(query.Data)
+	if err != nil {
+		return fmt.Errorf("failed to parse callback's userID %q: %w", query.Data, err)
+	}
+	chatID := callbackChatID(query.Data)
+	if chatID == 0 {
+		chatID = a.primChatID
+	}
+
+	decision := "declined"
+	var req tbapi.Chattable = tbapi.DeclineChatJoinRequest{ChatConfig: tbapi.ChatConfig{ChatID: chatID}, UserID: userID}
+	if approve {
+		decision = "approved"
+		req = tbapi.ApproveChatJoinRequestConfig{ChatConfig: tbapi.ChatConfig{ChatID: chatID}, UserID: userID}
+	}
+	if !a.dry {
+		if _, reqErr := a.tbAPI.Request(req); reqErr != nil {
+			return fmt.Errorf("failed to %s join request of %d in %d: %w", strings.TrimSuffix(decision, "d"), userID, chatID, reqErr)
+		}
+	}
+	log.Printf("[INFO] join request of %d in %d %s by %s", userID, chatID, decision, query.From.UserName)
+
+	updText := query.Message.Text + fmt.Sprintf("\n\n_join request %s by %s in %v_", decision, query.From.UserName, a.sinceQuery(query))
+	editMsg := tbapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, updText)
+	editMsg.ReplyMarkup = &tbapi.InlineKeyboardMarkup{InlineKeyboard: [][]tbapi.InlineKeyboardButton{}}
+	if err = send(editMsg, a.tbAPI); err != nil {
+		return fmt.Errorf("failed to clear keyboard, chatID:%d, msgID:%d, %w", query.Message.Chat.ID, query.Message.MessageID, err)
+	}
+	return nil
+}
+
 // callbackShowInfo handles the callback when user asks for spam detection details for the ban.
 // callback data: !userID:msgID
-func (a *admin) callbackShowInfo(query *tbapi.CallbackQuery) error {
+func (a *admin) callbackShowInfo(ctx context.Context, query *tbapi.CallbackQuery) error {
 	callbackData := query.Data
 	spamInfoText := "**can't get spam info**"
 	spamInfo := []string{}
@@ -579,7 +758,7 @@ func (a *admin) callbackShowInfo(query *tbapi.CallbackQuery) error {
 
 	// collect spam detection details
 	if userID != 0 {
-		info, found := a.locator.Spam(context.TODO(), userID)
+		info, found := a.locator.Spam(ctx, userID)
 		if found {
 			for _, check := range info.Checks {
 				spamInfo = append(spamInfo, "- "+escapeMarkDownV1Text(check.String()))
@@ -606,9 +785,9 @@ func (a *admin) callbackShowInfo(query *tbapi.CallbackQuery) error {
 }
 
 // deleteAndBan deletes the message and bans the user
-func (a *admin) deleteAndBan(query *tbapi.CallbackQuery, userID int64, msgID int) error {
+func (a *admin) deleteAndBan(ctx context.Context, query *tbapi.CallbackQuery, userID int64, msgID int) error {
 	errs := new(multierror.Error)
-	userName := a.locator.UserNameByID(context.TODO(), userID)
+	userName := a.locator.UserNameByID(ctx, userID)
 	banReq := banRequest{
 		duration: bot.PermanentBanDuration,
 		userID:   userID,
@@ -696,12 +875,17 @@ func (a *admin) sendWithUnbanMarkup(text, action string, user bot.User, msgID in
 	tbMsg.ParseMode = tbapi.ModeMarkdown
 	tbMsg.LinkPreviewOptions = tbapi.LinkPreviewOptions{IsDisabled: true}
 
+	// chat ID added for multi-group setup to route the callback to the group the message came from
+	data := fmt.Sprintf("%d:%d", user.ID, msgID)
+	if a.groupName != "" {
+		data += fmt.Sprintf(":%d", a.primChatID)
+	}
 	tbMsg.ReplyMarkup = tbapi.NewInlineKeyboardMarkup(
 		tbapi.NewInlineKeyboardRow(
 			// ?userID to request confirmation
-			tbapi.NewInlineKeyboardButtonData("⛔︎ "+action, fmt.Sprintf("%s%d:%d", confirmationPrefix, user.ID, msgID)),
+			tbapi.NewInlineKeyboardButtonData("⛔︎ "+action, confirmationPrefix+data),
 			// !userID to request info
-			tbapi.NewInlineKeyboardButtonData("️⚑ info", fmt.Sprintf("%s%d:%d", infoPrefix, user.ID, msgID)),
+			tbapi.NewInlineKeyboardButtonData("️⚑ info", infoPrefix+data),
 		),
 	)
 
@@ -711,19 +895,20 @@ func (a *admin) sendWithUnbanMarkup(text, action string, user bot.User, msgID in
 	return nil
 }
 
-// callbackData is a string with userID and msgID separated by ":"
+// callbackData is a string with userID and msgID separated by ":", optionally followed by ":chatID" in multi-group setup
 func (a *admin) parseCallbackData(data string) (userID int64, msgID int, err error) {
 	if len(data) < 3 {
 		return 0, 0, fmt.Errorf("unexpected callback data, too short %q", data)
 	}
 
 	// remove prefix if present from the parsed data
-	if data[:1] == confirmationPrefix || data[:1] == banPrefix || data[:1] == infoPrefix {
+	if data[:1] == confirmationPrefix || data[:1] == banPrefix || data[:1] == infoPrefix ||
+		data[:1] == joinApprovePrefix || data[:1] == joinDeclinePrefix {
 		data = data[1:]
 	}
 
 	parts := strings.Split(data, ":")
-	if len(parts) != 2 {
+	if len(parts) != 2 && len(parts) != 3 {
 		return 0, 0, fmt.Errorf("unexpected callback data, should have both ids %q", data)
 	}
 	if userID, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
@@ -736,6 +921,19 @@ func (a *admin) parseCallbackData(data string) (userID int64, msgID int, err err
 	return userID, msgID, nil
 }
 
+// callbackChatID returns chat ID of the group from callback data "userID:msgID:chatID", 0 if not set
+func callbackChatID(data string) int64 {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	chatID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return chatID
+}
+
 // extractUsername tries to extract the username from a ban message
 func (a *admin) extractUsername(text string) (string, error) {
 	// regex for markdown format: [username](tg://user?id=123456)
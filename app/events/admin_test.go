@@ -2,9 +2,11 @@ package events
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	tbapi "github.com/OvyFlash/telegram-bot-api"
 	"github.com/stretchr/testify/assert"
@@ -62,6 +64,29 @@ func TestAdmin_reportBan(t *testing.T) {
 		assert.Equal(t, "⛔︎ change ban",
 			mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).ReplyMarkup.(tbapi.InlineKeyboardMarkup).InlineKeyboard[0][0].Text)
 	})
+
+	t.Run("with group name", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		grAdm := admin{tbAPI: mockAPI, adminChatID: 123, primChatID: -100777, groupName: "my_group"}
+		grAdm.ReportBan("testUser", msg)
+
+		require.Equal(t, 1, len(mockAPI.SendCalls()))
+		sent := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+		assert.Contains(t, sent.Text, "permanently banned [testUser](tg://user?id=456) in my\\_group**")
+		kb := sent.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+		assert.Equal(t, "?456:0:-100777", *kb.InlineKeyboard[0][0].CallbackData)
+		assert.Equal(t, "!456:0:-100777", *kb.InlineKeyboard[0][1].CallbackData)
+	})
+
+	t.Run("with forum topic", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		topicMsg := &bot.Message{From: bot.User{ID: 456}, Text: "spam", ChatID: -1001234567890, ThreadID: 12}
+		adm.ReportBan("testUser", topicMsg)
+
+		require.Equal(t, 1, len(mockAPI.SendCalls()))
+		assert.Contains(t, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text,
+			"permanently banned [testUser](tg://user?id=456) in [topic 12](https://t.me/c/1234567890/12)**")
+	})
 }
 
 func TestAdmin_getCleanMessage(t *testing.T) {
@@ -161,6 +186,8 @@ func TestAdmin_parseCallbackData(t *testing.T) {
 		{"valid prefix+ with valid data", "+12345:678", 12345, 678, false},
 		{"valid prefix! with valid data", "!12345:678", 12345, 678, false},
 		{"valid prefix? with valid data", "?12345:678", 12345, 678, false},
+		{"valid data with chat id", "?12345:678:-100123", 12345, 678, false},
+		{"too many parts", "12345:678:1:2", 0, 0, true},
 	}
 
 	for _, tt := range tests {
@@ -178,6 +205,13 @@ func TestAdmin_parseCallbackData(t *testing.T) {
 	}
 }
 
+func TestAdmin_callbackChatID(t *testing.T) {
+	assert.Equal(t, int64(-100123), callbackChatID("?12345:678:-100123"))
+	assert.Equal(t, int64(0), callbackChatID("12345:678"))
+	assert.Equal(t, int64(0), callbackChatID("12345:678:abc"))
+	assert.Equal(t, int64(0), callbackChatID(""))
+}
+
 func TestAdmin_extractUsername(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -259,11 +293,12 @@ func TestAdmin_DirectCommands(t *testing.T) {
 			RemoveApprovedUserFunc: func(id int64) error {
 				return nil
 			},
-			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+			OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 				return bot.Response{
 					CheckResults: []spamcheck.Response{
-						{Name: "test", Spam: true, Details: "test details"},
+						{Name: "test", Spam: true, Details: "test details", Score: 0.8},
 					},
+					Score: 0.8,
 				}
 			},
 			UpdateSpamFunc: func(msg string) error {
@@ -311,6 +346,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		adminMsg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
 		assert.Equal(t, int64(456), adminMsg.ChatID) // should be sent to admin chat
 		assert.Contains(t, adminMsg.Text, "original detection results for spammer (222)")
+		assert.Contains(t, adminMsg.Text, "total score: 0.80")
 		assert.Contains(t, adminMsg.Text, "the user banned by")
 
 		// check that appropriate bot methods were called
@@ -347,7 +383,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		update := createReplyUpdate("admin", 111, "spammer", 222, "spam message text")
 
 		// test the DirectBanReport function
-		err := adm.DirectBanReport(update)
+		err := adm.DirectBanReport(context.Background(), update)
 		require.NoError(t, err)
 
 		verifyDirectReportResults(t, mockAPI, botMock)
@@ -363,7 +399,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		update := createReplyUpdate("admin", 111, "spammer", 222, "spam message text")
 
 		// test the DirectSpamReport function
-		err := adm.DirectSpamReport(update)
+		err := adm.DirectSpamReport(context.Background(), update)
 		require.NoError(t, err)
 
 		verifyDirectReportResults(t, mockAPI, botMock)
@@ -381,7 +417,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		update := createReplyUpdate("admin", 111, "spammer", 222, "spam message text")
 
 		// test the DirectSpamReport function in dry mode
-		err := adm.DirectSpamReport(update)
+		err := adm.DirectSpamReport(context.Background(), update)
 		require.NoError(t, err)
 
 		// check that admin was notified
@@ -403,7 +439,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		update := createReplyUpdate("admin", 111, "user", 222, "inappropriate message")
 
 		// test the DirectWarnReport function
-		err := adm.DirectWarnReport(update)
+		err := adm.DirectWarnReport(context.Background(), update)
 		require.NoError(t, err)
 
 		// check that the API was called to delete messages
@@ -421,6 +457,45 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		assert.Contains(t, warnMsg.Text, "@user please follow our rules")
 	})
 
+	t.Run("DirectWarnReport_Strikes", func(t *testing.T) {
+		mockAPI, _, adm, teardown := setupTest()
+		defer teardown()
+
+		count := 0
+		strikes := &mocks.StrikesMock{
+			AddFunc: func(ctx context.Context, strike storage.StrikeInfo) (int, error) {
+				count++
+				return count, nil
+			},
+			MarkAppliedFunc: func(ctx context.Context, userID int64) error { return nil },
+		}
+		adm.strikes = strikes
+		adm.strikesLadder = bot.Ladder{{Kind: bot.ActionWarn}, {Kind: bot.ActionMute, Duration: time.Hour}}
+
+		// first warning, no escalation
+		update := createReplyUpdate("admin", 111, "user", 222, "inappropriate message")
+		require.NoError(t, adm.DirectWarnReport(context.Background(), update))
+		require.Len(t, strikes.AddCalls(), 1)
+		assert.Equal(t, int64(222), strikes.AddCalls()[0].Strike.UserID)
+		assert.Equal(t, "user", strikes.AddCalls()[0].Strike.UserName)
+		assert.Equal(t, "admin", strikes.AddCalls()[0].Strike.Source)
+		assert.Equal(t, "warned by admin", strikes.AddCalls()[0].Strike.Reason)
+		assert.Len(t, mockAPI.RequestCalls(), 2, "only deletes")
+		assert.Len(t, mockAPI.SendCalls(), 1, "only warning")
+
+		// second warning, user muted and reported to admin chat
+		mockAPI.ResetCalls()
+		require.NoError(t, adm.DirectWarnReport(context.Background(), update))
+		require.Len(t, mockAPI.RequestCalls(), 3)
+		restrict := mockAPI.RequestCalls()[2].C.(tbapi.RestrictChatMemberConfig)
+		assert.Equal(t, int64(222), restrict.UserID)
+		assert.Equal(t, int64(123), restrict.ChatID)
+		require.Len(t, mockAPI.SendCalls(), 2)
+		report := mockAPI.SendCalls()[1].C.(tbapi.MessageConfig)
+		assert.Equal(t, int64(456), report.ChatID)
+		assert.Contains(t, report.Text, "muted for 1h0m0s")
+	})
+
 	t.Run("DirectWarnReport_SuperUser", func(t *testing.T) {
 		mockAPI, _, adm, teardown := setupTest()
 		defer teardown()
@@ -429,7 +504,7 @@ func TestAdmin_DirectCommands(t *testing.T) {
 		update := createReplyUpdate("admin", 111, "superuser", 222, "inappropriate message")
 
 		// test the DirectWarnReport function with superuser
-		err := adm.DirectWarnReport(update)
+		err := adm.DirectWarnReport(context.Background(), update)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "warn message is from super-user")
 
@@ -509,7 +584,7 @@ func TestAdmin_InlineCallbacks(t *testing.T) {
 		mockAPI, botMock, adm, query := setupCallback(false, false)
 
 		// test the callback handler
-		err := adm.callbackBanConfirmed(query)
+		err := adm.callbackBanConfirmed(context.Background(), query)
 		require.NoError(t, err)
 
 		// check that edit message was called with updated text
@@ -525,11 +600,26 @@ func TestAdmin_InlineCallbacks(t *testing.T) {
 		assert.Equal(t, "Spam message text", botMock.UpdateSpamCalls()[0].Msg)
 	})
 
+	t.Run("callbackUnbanConfirmed_ClearStrikes", func(t *testing.T) {
+		mockAPI, botMock, adm, query := setupCallback(false, false)
+		botMock.UpdateHamFunc = func(msg string) error { return nil }
+		botMock.AddApprovedUserFunc = func(id int64, name string) error { return nil }
+		strikes := &mocks.StrikesMock{ClearFunc: func(ctx context.Context, userID int64) error { return nil }}
+		adm.strikes = strikes
+		query.Data = "12345:999"
+
+		require.NoError(t, adm.callbackUnbanConfirmed(context.Background(), query))
+		require.Len(t, strikes.ClearCalls(), 1)
+		assert.Equal(t, int64(12345), strikes.ClearCalls()[0].UserID)
+		unban := mockAPI.RequestCalls()[1].C.(tbapi.UnbanChatMemberConfig)
+		assert.Equal(t, int64(12345), unban.UserID)
+	})
+
 	t.Run("callbackBanConfirmed_TrainingMode", func(t *testing.T) {
 		mockAPI, _, adm, query := setupCallback(true, false)
 
 		// test the callback handler in training mode
-		err := adm.callbackBanConfirmed(query)
+		err := adm.callbackBanConfirmed(context.Background(), query)
 		require.NoError(t, err)
 
 		// in training mode, deleteAndBan should be called
@@ -545,7 +635,7 @@ func TestAdmin_InlineCallbacks(t *testing.T) {
 		mockAPI, botMock, adm, query := setupCallback(false, true)
 
 		// test the callback handler in soft ban mode
-		err := adm.callbackBanConfirmed(query)
+		err := adm.callbackBanConfirmed(context.Background(), query)
 		require.NoError(t, err)
 
 		// in soft ban mode, a real ban should be performed
@@ -648,7 +738,7 @@ func TestAdmin_PreserveUserLinks_Issue223(t *testing.T) {
 		}
 
 		// run the function that needs to maintain the links
-		err := adm.callbackShowInfo(query)
+		err := adm.callbackShowInfo(context.Background(), query)
 		assert.NoError(t, err)
 
 		// our improved implementation should:
@@ -767,7 +857,7 @@ func TestAdmin_MsgHandlerWithEmptyText(t *testing.T) {
 			botMock.ResetCalls()
 
 			update := tbapi.Update{Message: tt.msg}
-			err := adminHandler.MsgHandler(update)
+			err := adminHandler.MsgHandler(context.Background(), update)
 			assert.Error(t, err)
 			assert.Equal(t, "empty message text", err.Error())
 
@@ -809,7 +899,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 		assert.NoError(t, err)
 
 		// verify no actions were taken
@@ -832,7 +922,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 			RemoveApprovedUserFunc: func(id int64) error {
 				return nil
 			},
-			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+			OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 				return bot.Response{
 					CheckResults: []spamcheck.Response{
 						{Name: "test", Spam: true, Details: "test details"},
@@ -876,7 +966,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "forwarded message is about super-user")
 
@@ -898,7 +988,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 			RemoveApprovedUserFunc: func(id int64) error {
 				return nil
 			},
-			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+			OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 				return bot.Response{
 					CheckResults: []spamcheck.Response{
 						{Name: "test", Spam: true, Details: "test details"},
@@ -948,7 +1038,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 		assert.NoError(t, err)
 
 		// verify correct sequence of operations
@@ -997,7 +1087,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "not found")
 	})
@@ -1013,7 +1103,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 			RemoveApprovedUserFunc: func(id int64) error {
 				return nil
 			},
-			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+			OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 				return bot.Response{
 					CheckResults: []spamcheck.Response{
 						{Name: "test", Spam: true, Details: "test details"},
@@ -1061,7 +1151,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 		assert.NoError(t, err)
 
 		// in dry mode, we should only notify admin but not delete or ban
@@ -1084,7 +1174,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 			RemoveApprovedUserFunc: func(id int64) error {
 				return fmt.Errorf("failed to remove user")
 			},
-			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+			OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 				return bot.Response{
 					CheckResults: []spamcheck.Response{
 						{Name: "test", Spam: true, Details: "test details"},
@@ -1134,7 +1224,7 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		}
 
 		update := tbapi.Update{Message: msg}
-		err := adminHandler.MsgHandler(update)
+		err := adminHandler.MsgHandler(context.Background(), update)
 
 		// in the actual code, the error from RemoveApprovedUser is collected in a multierror
 		// so the final error should include that failure
@@ -1148,3 +1238,53 @@ func TestAdmin_MsgHandler(t *testing.T) {
 		assert.Equal(t, 1, len(botMock.UpdateSpamCalls()), "Should update spam samples")
 	})
 }
+
+func TestAdmin_callbackJoinRequest(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	a := &admin{tbAPI: mockAPI, primChatID: 123, adminChatID: 456}
+	query := func(data string) *tbapi.CallbackQuery {
+		return &tbapi.CallbackQuery{Data: data, From: &tbapi.User{UserName: "moderator"},
+			Message: &tbapi.Message{MessageID: 77, Chat: tbapi.Chat{ID: 456}, Text: "suspicious join request",
+				Date: int(time.Now().Unix())}}
+	}
+
+	t.Run("approve", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := a.InlineCallbackHandler(context.Background(), query("A200:0:789"))
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		req := mockAPI.RequestCalls()[0].C.(tbapi.ApproveChatJoinRequestConfig)
+		assert.Equal(t, int64(789), req.ChatID)
+		assert.Equal(t, int64(200), req.UserID)
+		require.Len(t, mockAPI.SendCalls(), 1)
+		edit := mockAPI.SendCalls()[0].C.(tbapi.EditMessageTextConfig)
+		assert.Equal(t, 77, edit.MessageID)
+		assert.Contains(t, edit.Text, "join request approved by moderator")
+		assert.Empty(t, edit.ReplyMarkup.InlineKeyboard)
+	})
+
+	t.Run("decline, primary chat", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := a.InlineCallbackHandler(context.Background(), query("D200:0"))
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		req := mockAPI.RequestCalls()[0].C.(tbapi.DeclineChatJoinRequest)
+		assert.Equal(t, int64(123), req.ChatID)
+		require.Len(t, mockAPI.SendCalls(), 1)
+		assert.Contains(t, mockAPI.SendCalls()[0].C.(tbapi.EditMessageTextConfig).Text, "join request declined by moderator")
+	})
+
+	t.Run("request failed", func(t *testing.T) {
+		failAPI := &mocks.TbAPIMock{RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return nil, errors.New("expired")
+		}}
+		fa := &admin{tbAPI: failAPI, primChatID: 123, adminChatID: 456}
+		err := fa.InlineCallbackHandler(context.Background(), query("A200:0"))
+		assert.EqualError(t, err, "failed to process join request: failed to approve join request of 200 in 123: expired")
+	})
+}
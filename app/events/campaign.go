@@ -0,0 +1,50 @@
+package events
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/umputun/tg-spam/app/bot"
+)
+
+// campaignReportTTL defines how long the reported campaign is remembered, to report each campaign once
+const campaignReportTTL = 24 * time.Hour
+
+// reportCampaign sends the campaign detected by the "campaign" check to admin chat, once per campaign.
+// The report lists every user of the campaign, including ones whose messages passed before the campaign was detected.
+func (l *TelegramListener) reportCampaign(g *chatGroup, msg *bot.Message, resp bot.Response) {
+	if l.adminChatID == 0 {
+		return
+	}
+	for _, cr := range resp.CheckResults {
+		if cr.Name != "campaign" || !cr.Spam {
+			continue
+		}
+		// details are formatted by the check as "campaign <id>, <n> users within <window>: <users>"
+		id, _, _ := strings.Cut(strings.TrimPrefix(cr.Details, "campaign "), ",")
+		if l.isCampaignReported(id) {
+			log.Printf("[DEBUG] campaign %s already reported", id)
+			return
+		}
+		g.admin.ReportCampaign(msg, cr.Details)
+		return
+	}
+}
+
+// isCampaignReported reports if the campaign was reported already and marks it reported, expired campaigns are removed
+func (l *TelegramListener) isCampaignReported(id string) bool {
+	if l.campaigns == nil {
+		l.campaigns = map[string]time.Time{}
+	}
+	for k, ts := range l.campaigns {
+		if time.Since(ts) > campaignReportTTL {
+			delete(l.campaigns, k)
+		}
+	}
+	if _, ok := l.campaigns[id]; ok {
+		return true
+	}
+	l.campaigns[id] = time.Now()
+	return false
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestTelegramListener_reportCampaign(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil }}
+	l := &TelegramListener{TbAPI: mockAPI, chatID: 123, adminChatID: 456}
+	l.adminHandler = &admin{tbAPI: mockAPI, primChatID: 123, adminChatID: 456}
+	g := l.group(123)
+	msg := &bot.Message{Text: "earn 500$ daily", From: bot.User{ID: 3, Username: "user3"}}
+	campaignResp := func(id string) bot.Response {
+		return bot.Response{Send: true, CheckResults: []spamcheck.Response{
+			{Name: "stopword", Spam: false},
+			{Name: "campaign", Spam: true, Details: "campaign " + id + ", 3 users within 10m0s: user1 (1), user2 (2), user3 (3)"},
+		}}
+	}
+
+	l.reportCampaign(g, msg, campaignResp("85edafa8"))
+	require.Len(t, mockAPI.SendCalls(), 1)
+	tbMsg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+	assert.Equal(t, int64(456), tbMsg.ChatID)
+	assert.Equal(t, "**spam campaign detected**\n\nearn 500$ daily\n\n"+
+		"campaign 85edafa8, 3 users within 10m0s: user1 (1), user2 (2), user3 (3)", tbMsg.Text)
+
+	l.reportCampaign(g, msg, campaignResp("85edafa8"))
+	assert.Len(t, mockAPI.SendCalls(), 1, "same campaign reported once")
+
+	l.reportCampaign(g, msg, campaignResp("12345678"))
+	assert.Len(t, mockAPI.SendCalls(), 2, "another campaign reported")
+
+	l.campaigns["85edafa8"] = time.Now().Add(-25 * time.Hour)
+	l.reportCampaign(g, msg, campaignResp("85edafa8"))
+	assert.Len(t, mockAPI.SendCalls(), 3, "expired campaign reported again")
+
+	l.reportCampaign(g, msg, bot.Response{Send: true, CheckResults: []spamcheck.Response{{Name: "campaign", Spam: false}}})
+	assert.Len(t, mockAPI.SendCalls(), 3, "not a campaign")
+
+	l.adminChatID = 0
+	l.reportCampaign(g, msg, campaignResp("abcdef12"))
+	assert.Len(t, mockAPI.SendCalls(), 3, "no admin chat")
+}
@@ -0,0 +1,343 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+// captchaPrefix is a prefix of callback data for join challenge buttons
+const captchaPrefix = "captcha:"
+
+// CaptchaConfig defines join challenge for new members. A new member is restricted until the challenge is solved,
+// and kicked out if not solved within the timeout or answered wrong.
+type CaptchaConfig struct {
+	Enabled      bool          // enable join challenge
+	Timeout      time.Duration // time to solve the challenge
+	Arithmetic   bool          // ask to solve a simple arithmetic task instead of pressing a single button
+	Message      string        // challenge message, sent to the new member
+	CountMessage bool          // count passed challenge as one of the first messages checked for spam
+}
+
+// captcha handles join challenges of new members. Thread safe, challenges are expired by timers.
+// Pending challenges are persisted if the store is set, and restored on start, as timers are lost on restart.
+type captcha struct {
+	CaptchaConfig
+	tbAPI       TbAPI
+	store       Restrictions                              // pending challenges storage, optional
+	permissions func(chatID int64) *tbapi.ChatPermissions // permissions granted to members passed the challenge
+
+	mu      sync.Mutex
+	pending map[string]*challenge // by chatID:userID
+}
+
+// challenge is a pending join challenge of a user
+type challenge struct {
+	chatID    int64
+	userID    int64
+	userName  string
+	joinMsgID int // join service message, deleted if the user kicked out
+	msgID     int // challenge message
+	answer    int
+	bot       Bot
+	timer     *time.Timer
+}
+
+// challengeState is a persisted state of the pending challenge
+type challengeState struct {
+	UserName  string `json:"user_name"`
+	JoinMsgID int    `json:"join_msg_id"`
+	MsgID     int    `json:"msg_id"`
+	Answer    int    `json:"answer"`
+}
+
+// newCaptcha makes captcha handler with default timeout and message if not set. Members passed the challenge
+// get the current permissions of the chat. Store is optional, pending challenges are not persisted if nil.
+func newCaptcha(cfg CaptchaConfig, tbAPI TbAPI, store Restrictions) *captcha {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Minute
+	}
+	if cfg.Message == "" {
+		cfg.Message = "please confirm you are not a bot"
+	}
+	res := &captcha{CaptchaConfig: cfg, tbAPI: tbAPI, store: store, pending: map[string]*challenge{}}
+	res.permissions = func(chatID int64) *tbapi.ChatPermissions { return chatPermissions(tbAPI, chatID) }
+	return res
+}
+
+// Challenge restricts the new member and sends the challenge message with inline buttons.
+// The user is kicked out if the challenge is not solved within the timeout.
+func (c *captcha) Challenge(chatID int64, b Bot, user tbapi.User, joinMsgID int) error {
+	if err := c.restrict(chatID, user.ID, false); err != nil {
+		return fmt.Errorf("failed to restrict new member %d: %w", user.ID, err)
+	}
+
+	ch := &challenge{chatID: chatID, userID: user.ID, userName: displayName(user), joinMsgID: joinMsgID, bot: b}
+	text := fmt.Sprintf("@%s %s, you have %v.", ch.userName, c.Message, c.Timeout)
+	var buttons []tbapi.InlineKeyboardButton
+	if c.Arithmetic {
+		a, b := rand.IntN(10)+1, rand.IntN(10)+1 //nolint:gosec // no need for crypto random here
+		ch.answer = a + b
+		text += fmt.Sprintf(" How much is %d + %d?", a, b)
+		for _, v := range c.options(ch.answer) {
+			buttons = append(buttons, tbapi.NewInlineKeyboardButtonData(strconv.Itoa(v), c.callbackData(ch, v)))
+		}
+	} else {
+		buttons = append(buttons, tbapi.NewInlineKeyboardButtonData("I'm not a bot", c.callbackData(ch, 0)))
+	}
+
+	tbMsg := tbapi.NewMessage(chatID, escapeMarkDownV1Text(text))
+	tbMsg.ParseMode = tbapi.ModeMarkdown
+	tbMsg.ReplyMarkup = tbapi.NewInlineKeyboardMarkup(buttons)
+	tbMsg.DisableNotification = true
+	resp, err := c.tbAPI.Send(tbMsg)
+	if err != nil {
+		// don't leave the user restricted forever if the challenge can't be sent
+		if restrictErr := c.restrict(chatID, user.ID, true); restrictErr != nil {
+			log.Printf("[WARN] failed to lift restrictions for %d: %v", user.ID, restrictErr)
+		}
+		return fmt.Errorf("failed to send challenge to %d: %w", user.ID, err)
+	}
+	ch.msgID = resp.MessageID
+
+	c.add(ch, c.Timeout)
+	c.save(ch, time.Now().Add(c.Timeout))
+	log.Printf("[INFO] join challenge sent to %q (%d) in %d", ch.userName, ch.userID, ch.chatID)
+	return nil
+}
+
+// Restore restores challenges pending before restart from the store, so the users can still answer them.
+// Challenges expired during the downtime are expired right away, i.e. the users are kicked out.
+// The bot for approval of users passed the challenge is picked by the chat.
+func (c *captcha) Restore(botFor func(chatID int64) Bot) error {
+	if c.store == nil {
+		return nil
+	}
+	recs, err := c.store.List(context.TODO(), storage.RestrictionChallenge)
+	if err != nil {
+		return fmt.Errorf("failed to load pending challenges: %w", err)
+	}
+	for _, rec := range recs {
+		var state challengeState
+		if err := json.Unmarshal([]byte(rec.Data), &state); err != nil {
+			log.Printf("[WARN] failed to parse join challenge of %d in %d: %v", rec.UserID, rec.ChatID, err)
+		}
+		ch := &challenge{chatID: rec.ChatID, userID: rec.UserID, userName: state.UserName, joinMsgID: state.JoinMsgID,
+			msgID: state.MsgID, answer: state.Answer, bot: botFor(rec.ChatID)}
+		c.add(ch, max(time.Until(rec.ExpiresAt), 0))
+	}
+	if len(recs) > 0 {
+		log.Printf("[INFO] %d pending join challenges restored", len(recs))
+	}
+	return nil
+}
+
+// CallbackHandler handles a press of the challenge button. Only the challenged user can answer,
+// the right answer lifts restrictions, the wrong one kicks the user out.
+func (c *captcha) CallbackHandler(query *tbapi.CallbackQuery) error {
+	chatID, userID, answer, err := parseCaptchaData(query.Data)
+	if err != nil {
+		return fmt.Errorf("failed to parse challenge callback %q: %w", query.Data, err)
+	}
+	if query.From == nil || query.From.ID != userID {
+		c.answerCallback(query.ID, "this challenge is not for you")
+		return nil
+	}
+
+	ch, ok := c.take(chatID, userID)
+	if !ok {
+		c.answerCallback(query.ID, "challenge expired")
+		return nil
+	}
+
+	if answer != ch.answer {
+		log.Printf("[INFO] wrong answer to join challenge from %q (%d) in %d", ch.userName, ch.userID, ch.chatID)
+		c.answerCallback(query.ID, "wrong answer")
+		return c.kick(ch)
+	}
+
+	log.Printf("[INFO] join challenge passed by %q (%d) in %d", ch.userName, ch.userID, ch.chatID)
+	c.answerCallback(query.ID, "welcome!")
+	errs := new(multierror.Error)
+	if rErr := c.restrict(ch.chatID, ch.userID, true); rErr != nil {
+		errs = multierror.Append(errs, fmt.Errorf("failed to lift restrictions for %d: %w", ch.userID, rErr))
+	}
+	if dErr := c.deleteMessage(ch.chatID, ch.msgID); dErr != nil {
+		errs = multierror.Append(errs, dErr)
+	}
+	if c.CountMessage {
+		ch.bot.CountCleanMessage(ch.userID, ch.userName) // counts toward first messages check, doesn't approve the user
+	}
+	return errs.ErrorOrNil()
+}
+
+// expire kicks out the user if the challenge is still pending
+func (c *captcha) expire(chatID, userID int64) {
+	ch, ok := c.take(chatID, userID)
+	if !ok {
+		return // already answered
+	}
+	log.Printf("[INFO] join challenge expired for %q (%d) in %d", ch.userName, ch.userID, ch.chatID)
+	if err := c.kick(ch); err != nil {
+		log.Printf("[WARN] failed to kick %d on expired challenge: %v", ch.userID, err)
+	}
+}
+
+// add adds the pending challenge and sets the timer to expire it
+func (c *captcha) add(ch *challenge, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[challengeKey(ch.chatID, ch.userID)] = ch
+	ch.timer = time.AfterFunc(timeout, func() { c.expire(ch.chatID, ch.userID) })
+}
+
+// save persists the pending challenge, if the store is set, to restore it after restart
+func (c *captcha) save(ch *challenge, expiresAt time.Time) {
+	if c.store == nil {
+		return
+	}
+	state, err := json.Marshal(challengeState{UserName: ch.userName, JoinMsgID: ch.joinMsgID, MsgID: ch.msgID, Answer: ch.answer})
+	if err != nil {
+		log.Printf("[WARN] failed to marshal join challenge of %d: %v", ch.userID, err)
+		return
+	}
+	rs := storage.Restriction{Kind: storage.RestrictionChallenge, ChatID: ch.chatID, UserID: ch.userID, Data: string(state),
+		ExpiresAt: expiresAt}
+	if err := c.store.Write(context.TODO(), rs); err != nil {
+		log.Printf("[WARN] failed to save join challenge of %d: %v", ch.userID, err)
+	}
+}
+
+// take removes the pending challenge and returns it, false if not found
+func (c *captcha) take(chatID, userID int64) (*challenge, bool) {
+	c.mu.Lock()
+	ch, ok := c.pending[challengeKey(chatID, userID)]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	delete(c.pending, challengeKey(chatID, userID))
+	ch.timer.Stop()
+	c.mu.Unlock()
+
+	if c.store != nil {
+		if err := c.store.Delete(context.TODO(), storage.RestrictionChallenge, chatID, userID); err != nil {
+			log.Printf("[WARN] failed to delete join challenge of %d: %v", userID, err)
+		}
+	}
+	return ch, true
+}
+
+// kick removes the user from the chat without a permanent ban, and deletes join and challenge messages
+func (c *captcha) kick(ch *challenge) error {
+	errs := new(multierror.Error)
+	memberCfg := tbapi.ChatMemberConfig{UserID: ch.userID, ChatConfig: tbapi.ChatConfig{ChatID: ch.chatID}}
+	if _, err := c.tbAPI.Request(tbapi.BanChatMemberConfig{ChatMemberConfig: memberCfg}); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("failed to kick %d: %w", ch.userID, err))
+	} else if _, unbanErr := c.tbAPI.Request(tbapi.UnbanChatMemberConfig{ChatMemberConfig: memberCfg, OnlyIfBanned: true}); unbanErr != nil {
+		// unban allows the user to join again, the challenge is repeated on the next join
+		errs = multierror.Append(errs, fmt.Errorf("failed to unban kicked %d: %w", ch.userID, unbanErr))
+	}
+	for _, msgID := range []int{ch.msgID, ch.joinMsgID} {
+		if err := c.deleteMessage(ch.chatID, msgID); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	log.Printf("[INFO] %q (%d) kicked out of %d, join challenge not passed", ch.userName, ch.userID, ch.chatID)
+	return errs.ErrorOrNil()
+}
+
+// restrict restricts the user from sending anything if allow is false, and lifts the restrictions otherwise.
+// Lifted restrictions are replaced by the chat permissions, i.e. the user gets the same permissions as other members.
+func (c *captcha) restrict(chatID, userID int64, allow bool) error {
+	perms := &tbapi.ChatPermissions{}
+	if allow {
+		perms = c.permissions(chatID)
+	}
+	_, err := c.tbAPI.Request(tbapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tbapi.ChatMemberConfig{UserID: userID, ChatConfig: tbapi.ChatConfig{ChatID: chatID}},
+		Permissions:      perms,
+	})
+	return err
+}
+
+func (c *captcha) deleteMessage(chatID int64, msgID int) error {
+	if msgID == 0 {
+		return nil
+	}
+	if _, err := c.tbAPI.Request(tbapi.DeleteMessageConfig{
+		BaseChatMessage: tbapi.BaseChatMessage{ChatConfig: tbapi.ChatConfig{ChatID: chatID}, MessageID: msgID},
+	}); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", msgID, err)
+	}
+	return nil
+}
+
+func (c *captcha) answerCallback(queryID, text string) {
+	if _, err := c.tbAPI.Request(tbapi.NewCallback(queryID, text)); err != nil {
+		log.Printf("[WARN] failed to answer challenge callback: %v", err)
+	}
+}
+
+// options returns shuffled answer options for the arithmetic challenge, one of them is the right answer.
+// wrong options are around the right one, so the right answer is not always the smallest or the largest.
+func (c *captcha) options(answer int) []int {
+	deltas := []int{-2, -1, 1, 2, 3, 4}
+	res := []int{answer}
+	for _, i := range rand.Perm(len(deltas))[:3] { //nolint:gosec // no need for crypto random here
+		res = append(res, answer+deltas[i])
+	}
+	rand.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] }) //nolint:gosec // same as above
+	return res
+}
+
+// callbackData makes callback data of the challenge button, as captcha:chatID:userID:answer
+func (c *captcha) callbackData(ch *challenge, answer int) string {
+	return fmt.Sprintf("%s%d:%d:%d", captchaPrefix, ch.chatID, ch.userID, answer)
+}
+
+// parseCaptchaData parses callback data of the challenge button, as captcha:chatID:userID:answer
+func parseCaptchaData(data string) (chatID, userID int64, answer int, err error) {
+	parts := strings.Split(strings.TrimPrefix(data, captchaPrefix), ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected callback data, %q", data)
+	}
+	if chatID, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse chat id %q: %w", parts[0], err)
+	}
+	if userID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse user id %q: %w", parts[1], err)
+	}
+	if answer, err = strconv.Atoi(parts[2]); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse answer %q: %w", parts[2], err)
+	}
+	return chatID, userID, answer, nil
+}
+
+func challengeKey(chatID, userID int64) string {
+	return fmt.Sprintf("%d:%d", chatID, userID)
+}
+
+// displayName returns the username of the user, or the full name if username is not set
+func displayName(user tbapi.User) string {
+	if user.UserName != "" {
+		return user.UserName
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// isCaptchaCallback checks if the callback is a press of the join challenge button
+func isCaptchaCallback(data string) bool {
+	return strings.HasPrefix(data, captchaPrefix)
+}
@@ -0,0 +1,345 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+func TestCaptcha_ChallengeAndPass(t *testing.T) {
+	chatPerms := &tbapi.ChatPermissions{CanSendMessages: true, CanSendPhotos: true}
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 555}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Permissions: chatPerms}, nil
+		},
+	}
+	store := &mocks.RestrictionsMock{
+		WriteFunc:  func(ctx context.Context, rs storage.Restriction) error { return nil },
+		DeleteFunc: func(ctx context.Context, kind storage.RestrictionKind, chatID, userID int64) error { return nil },
+	}
+	b := &mocks.BotMock{CountCleanMessageFunc: func(id int64, name string) {}}
+	c := newCaptcha(CaptchaConfig{Enabled: true, Timeout: time.Minute, CountMessage: true}, mockAPI, store)
+
+	err := c.Challenge(123, b, tbapi.User{ID: 42, UserName: "new_user"}, 22)
+	require.NoError(t, err)
+
+	require.Len(t, store.WriteCalls(), 1, "challenge persisted")
+	rs := store.WriteCalls()[0].Rs
+	assert.Equal(t, storage.RestrictionChallenge, rs.Kind)
+	assert.Equal(t, int64(123), rs.ChatID)
+	assert.Equal(t, int64(42), rs.UserID)
+	assert.JSONEq(t, `{"user_name":"new_user","join_msg_id":22,"msg_id":555,"answer":0}`, rs.Data)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), rs.ExpiresAt, time.Second)
+
+	require.Len(t, mockAPI.RequestCalls(), 1)
+	restrictCfg := mockAPI.RequestCalls()[0].C.(tbapi.RestrictChatMemberConfig)
+	assert.Equal(t, int64(42), restrictCfg.UserID)
+	assert.False(t, restrictCfg.Permissions.CanSendMessages)
+
+	require.Len(t, mockAPI.SendCalls(), 1)
+	msg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+	assert.Equal(t, int64(123), msg.ChatID)
+	assert.Contains(t, msg.Text, "@new\\_user please confirm you are not a bot, you have 1m0s.")
+	markup := msg.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+	require.Len(t, markup.InlineKeyboard[0], 1)
+	assert.Equal(t, "captcha:123:42:0", *markup.InlineKeyboard[0][0].CallbackData)
+
+	t.Run("pressed by another user", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := c.CallbackHandler(&tbapi.CallbackQuery{ID: "q1", Data: "captcha:123:42:0", From: &tbapi.User{ID: 43}})
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		assert.Equal(t, "this challenge is not for you", mockAPI.RequestCalls()[0].C.(tbapi.CallbackConfig).Text)
+		assert.Len(t, c.pending, 1, "challenge still pending")
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := c.CallbackHandler(&tbapi.CallbackQuery{ID: "q2", Data: "captcha:123:42:0", From: &tbapi.User{ID: 42}})
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 3)
+		assert.Equal(t, "welcome!", mockAPI.RequestCalls()[0].C.(tbapi.CallbackConfig).Text)
+		assert.Equal(t, chatPerms, mockAPI.RequestCalls()[1].C.(tbapi.RestrictChatMemberConfig).Permissions,
+			"chat permissions granted")
+		assert.Equal(t, 555, mockAPI.RequestCalls()[2].C.(tbapi.DeleteMessageConfig).MessageID)
+		require.Len(t, b.CountCleanMessageCalls(), 1)
+		assert.Equal(t, int64(42), b.CountCleanMessageCalls()[0].ID)
+		assert.Empty(t, c.pending)
+		require.Len(t, store.DeleteCalls(), 1, "persisted challenge deleted")
+		assert.Equal(t, int64(123), store.DeleteCalls()[0].ChatID)
+		assert.Equal(t, int64(42), store.DeleteCalls()[0].UserID)
+	})
+
+	t.Run("pressed again", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := c.CallbackHandler(&tbapi.CallbackQuery{ID: "q3", Data: "captcha:123:42:0", From: &tbapi.User{ID: 42}})
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		assert.Equal(t, "challenge expired", mockAPI.RequestCalls()[0].C.(tbapi.CallbackConfig).Text)
+	})
+}
+
+func TestCaptcha_ArithmeticWrongAnswer(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 555}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	b := &mocks.BotMock{}
+	c := newCaptcha(CaptchaConfig{Enabled: true, Arithmetic: true}, mockAPI, nil)
+	assert.Equal(t, 2*time.Minute, c.Timeout, "default timeout")
+
+	require.NoError(t, c.Challenge(123, b, tbapi.User{ID: 42, FirstName: "John"}, 22))
+	msg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+	assert.Contains(t, msg.Text, "@John please confirm you are not a bot, you have 2m0s. How much is ")
+	markup := msg.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+	require.Len(t, markup.InlineKeyboard[0], 4)
+
+	answer := c.pending["123:42"].answer
+	wrong := ""
+	for _, btn := range markup.InlineKeyboard[0] {
+		if btn.Text != fmt.Sprintf("%d", answer) {
+			wrong = *btn.CallbackData
+		}
+	}
+	require.NotEmpty(t, wrong)
+
+	mockAPI.ResetCalls()
+	err := c.CallbackHandler(&tbapi.CallbackQuery{ID: "q1", Data: wrong, From: &tbapi.User{ID: 42}})
+	require.NoError(t, err)
+	require.Len(t, mockAPI.RequestCalls(), 5)
+	assert.Equal(t, "wrong answer", mockAPI.RequestCalls()[0].C.(tbapi.CallbackConfig).Text)
+	assert.Equal(t, int64(42), mockAPI.RequestCalls()[1].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.True(t, mockAPI.RequestCalls()[2].C.(tbapi.UnbanChatMemberConfig).OnlyIfBanned)
+	assert.Equal(t, 555, mockAPI.RequestCalls()[3].C.(tbapi.DeleteMessageConfig).MessageID)
+	assert.Equal(t, 22, mockAPI.RequestCalls()[4].C.(tbapi.DeleteMessageConfig).MessageID)
+	assert.Empty(t, b.CountCleanMessageCalls())
+}
+
+func TestCaptcha_Expired(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 555}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	c := newCaptcha(CaptchaConfig{Enabled: true, Timeout: 10 * time.Millisecond}, mockAPI, nil)
+	require.NoError(t, c.Challenge(123, &mocks.BotMock{}, tbapi.User{ID: 42, UserName: "new_user"}, 22))
+
+	// restrict, ban, unban and two deletes
+	require.Eventually(t, func() bool { return len(mockAPI.RequestCalls()) == 5 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(42), mockAPI.RequestCalls()[1].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.Equal(t, 22, mockAPI.RequestCalls()[4].C.(tbapi.DeleteMessageConfig).MessageID)
+
+	c.mu.Lock()
+	assert.Empty(t, c.pending)
+	c.mu.Unlock()
+}
+
+func TestCaptcha_ChallengeSendFailed(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, fmt.Errorf("send error") },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{}, fmt.Errorf("get chat error")
+		},
+	}
+	c := newCaptcha(CaptchaConfig{Enabled: true}, mockAPI, nil)
+	err := c.Challenge(123, &mocks.BotMock{}, tbapi.User{ID: 42}, 22)
+	require.ErrorContains(t, err, "failed to send challenge to 42")
+	require.Len(t, mockAPI.RequestCalls(), 2)
+	assert.True(t, mockAPI.RequestCalls()[1].C.(tbapi.RestrictChatMemberConfig).Permissions.CanSendMessages,
+		"restrictions lifted, full permissions granted as chat permissions unknown")
+	assert.Empty(t, c.pending)
+}
+
+func TestCaptcha_Restore(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Permissions: &tbapi.ChatPermissions{CanSendMessages: true}}, nil
+		},
+	}
+	store := &mocks.RestrictionsMock{
+		ListFunc: func(ctx context.Context, kind storage.RestrictionKind) ([]storage.Restriction, error) {
+			return []storage.Restriction{
+				{Kind: kind, ChatID: 123, UserID: 41, ExpiresAt: time.Now().Add(-time.Minute),
+					Data: `{"user_name":"expired","join_msg_id":21,"msg_id":551,"answer":0}`},
+				{Kind: kind, ChatID: 123, UserID: 42, ExpiresAt: time.Now().Add(time.Minute),
+					Data: `{"user_name":"live","join_msg_id":22,"msg_id":552,"answer":7}`},
+			}, nil
+		},
+		DeleteFunc: func(ctx context.Context, kind storage.RestrictionKind, chatID, userID int64) error { return nil },
+	}
+	b := &mocks.BotMock{CountCleanMessageFunc: func(id int64, name string) {}}
+	c := newCaptcha(CaptchaConfig{Enabled: true, Arithmetic: true, CountMessage: true}, mockAPI, store)
+	require.NoError(t, c.Restore(func(chatID int64) Bot { return b }))
+
+	// expired challenge is expired right away: ban, unban and two deletes
+	require.Eventually(t, func() bool { return len(mockAPI.RequestCalls()) == 4 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(41), mockAPI.RequestCalls()[0].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.Equal(t, 551, mockAPI.RequestCalls()[2].C.(tbapi.DeleteMessageConfig).MessageID)
+	assert.Equal(t, 21, mockAPI.RequestCalls()[3].C.(tbapi.DeleteMessageConfig).MessageID)
+	require.Len(t, store.DeleteCalls(), 1)
+	assert.Equal(t, int64(41), store.DeleteCalls()[0].UserID)
+
+	// live challenge can be answered with the button sent before restart
+	mockAPI.ResetCalls()
+	err := c.CallbackHandler(&tbapi.CallbackQuery{ID: "q1", Data: "captcha:123:42:7", From: &tbapi.User{ID: 42}})
+	require.NoError(t, err)
+	require.Len(t, mockAPI.RequestCalls(), 3)
+	assert.Equal(t, "welcome!", mockAPI.RequestCalls()[0].C.(tbapi.CallbackConfig).Text)
+	assert.True(t, mockAPI.RequestCalls()[1].C.(tbapi.RestrictChatMemberConfig).Permissions.CanSendMessages)
+	assert.Equal(t, 552, mockAPI.RequestCalls()[2].C.(tbapi.DeleteMessageConfig).MessageID)
+	require.Len(t, b.CountCleanMessageCalls(), 1)
+	assert.Equal(t, "live", b.CountCleanMessageCalls()[0].Name)
+	assert.Len(t, store.DeleteCalls(), 2)
+
+	t.Run("no store", func(t *testing.T) {
+		c := newCaptcha(CaptchaConfig{Enabled: true}, mockAPI, nil)
+		require.NoError(t, c.Restore(func(chatID int64) Bot { return b }))
+		assert.Empty(t, c.pending)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		store := &mocks.RestrictionsMock{
+			ListFunc: func(ctx context.Context, kind storage.RestrictionKind) ([]storage.Restriction, error) {
+				return nil, fmt.Errorf("db error")
+			},
+		}
+		c := newCaptcha(CaptchaConfig{Enabled: true}, mockAPI, store)
+		require.ErrorContains(t, c.Restore(func(chatID int64) Bot { return b }), "failed to load pending challenges: db error")
+	})
+}
+
+func TestCaptcha_options(t *testing.T) {
+	c := newCaptcha(CaptchaConfig{}, nil, nil)
+	for range 100 {
+		opts := c.options(7)
+		require.Len(t, opts, 4)
+		assert.Contains(t, opts, 7)
+		seen := map[int]bool{}
+		for _, v := range opts {
+			assert.False(t, seen[v], "duplicate option %d in %v", v, opts)
+			seen[v] = true
+			assert.InDelta(t, 7, v, 4)
+		}
+	}
+}
+
+func TestParseCaptchaData(t *testing.T) {
+	tests := []struct {
+		data           string
+		chatID, userID int64
+		answer         int
+		err            string
+	}{
+		{data: "captcha:-100123:42:7", chatID: -100123, userID: 42, answer: 7},
+		{data: "captcha:123:42:0", chatID: 123, userID: 42},
+		{data: "captcha:123:42", err: `unexpected callback data, "captcha:123:42"`},
+		{data: "captcha:abc:42:1", err: `failed to parse chat id "abc"`},
+		{data: "captcha:123:abc:1", err: `failed to parse user id "abc"`},
+		{data: "captcha:123:42:abc", err: `failed to parse answer "abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			chatID, userID, answer, err := parseCaptchaData(tt.data)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.chatID, chatID)
+			assert.Equal(t, tt.userID, userID)
+			assert.Equal(t, tt.answer, answer)
+		})
+	}
+}
+
+func TestTelegramListener_DoWithCaptcha(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 555}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+			return nil, nil
+		},
+	}
+	b := &mocks.BotMock{
+		OnMessageFunc:          func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response { return bot.Response{} },
+		IsApprovedUserFunc:     func(userID int64) bool { return userID == 100 },
+		AddApprovedUserFunc:    func(id int64, name string) error { return nil },
+		CountCleanMessageFunc:  func(id int64, name string) {},
+		RemoveApprovedUserFunc: func(id int64) error { return nil },
+	}
+
+	locator, teardown := prepTestLocator(t)
+	defer teardown()
+
+	l := TelegramListener{
+		TbAPI:      mockAPI,
+		Bot:        b,
+		SuperUsers: SuperUsers{"admin"},
+		Group:      "gr",
+		Locator:    locator,
+		Captcha:    CaptchaConfig{Enabled: true, Timeout: time.Minute, CountMessage: true},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	joinMsg := func(from, member tbapi.User, msgID int) tbapi.Update {
+		return tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, From: &from,
+			NewChatMembers: []tbapi.User{member}, MessageID: msgID}}
+	}
+	updChan := make(chan tbapi.Update, 10)
+	updChan <- joinMsg(tbapi.User{ID: 42, UserName: "new_user"}, tbapi.User{ID: 42, UserName: "new_user"}, 22)
+	updChan <- joinMsg(tbapi.User{ID: 100, UserName: "approved"}, tbapi.User{ID: 100, UserName: "approved"}, 23)
+	updChan <- joinMsg(tbapi.User{ID: 1, UserName: "admin"}, tbapi.User{ID: 43, UserName: "invited"}, 24)
+	updChan <- joinMsg(tbapi.User{ID: 44, UserName: "bot"}, tbapi.User{ID: 44, UserName: "bot", IsBot: true}, 25)
+	updChan <- tbapi.Update{CallbackQuery: &tbapi.CallbackQuery{ID: "q1", Data: "captcha:123:42:0",
+		From: &tbapi.User{ID: 42}, Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}}}}
+	close(updChan)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	err := l.Do(ctx)
+	assert.EqualError(t, err, "telegram update chan closed")
+
+	require.Len(t, mockAPI.SendCalls(), 1, "only one challenge sent")
+	assert.True(t, strings.HasPrefix(mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text, "@new\\_user please confirm"))
+
+	// restrict on join, then callback answer, lift restrictions and delete challenge on pass
+	require.Len(t, mockAPI.RequestCalls(), 4)
+	assert.False(t, mockAPI.RequestCalls()[0].C.(tbapi.RestrictChatMemberConfig).Permissions.CanSendMessages)
+	assert.Equal(t, "welcome!", mockAPI.RequestCalls()[1].C.(tbapi.CallbackConfig).Text)
+	assert.True(t, mockAPI.RequestCalls()[2].C.(tbapi.RestrictChatMemberConfig).Permissions.CanSendMessages)
+	assert.Equal(t, 555, mockAPI.RequestCalls()[3].C.(tbapi.DeleteMessageConfig).MessageID)
+
+	require.Len(t, b.CountCleanMessageCalls(), 1)
+	assert.Equal(t, int64(42), b.CountCleanMessageCalls()[0].ID)
+	assert.Equal(t, "new_user", b.CountCleanMessageCalls()[0].Name)
+	assert.Empty(t, b.AddApprovedUserCalls(), "passed challenge doesn't approve the user")
+}
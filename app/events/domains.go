@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+// Dictionary is an interface for dictionary storage, used to manage denied and allowed domains from admin chat
+type Dictionary interface {
+	Set(ctx context.Context, t storage.DictionaryType, data string) error
+	Remove(ctx context.Context, t storage.DictionaryType, data string) error
+	Read(ctx context.Context, t storage.DictionaryType) ([]string, error)
+}
+
+// domainCommands maps admin chat commands to the dictionary type and the action, true for add and false for remove
+var domainCommands = map[string]struct {
+	dt  storage.DictionaryType
+	add bool
+}{
+	"/deny":    {dt: storage.DictionaryTypeDeniedDomain, add: true},
+	"/undeny":  {dt: storage.DictionaryTypeDeniedDomain, add: false},
+	"/allow":   {dt: storage.DictionaryTypeAllowedDomain, add: true},
+	"/unallow": {dt: storage.DictionaryTypeAllowedDomain, add: false},
+}
+
+// isDomainsCommand checks if the admin chat message is one of domain commands, i.e. /domains, /deny, /undeny,
+// /allow or /unallow. Command can be addressed to the bot, like "/deny@bot_name example.com".
+func isDomainsCommand(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+	cmd, _, _ := strings.Cut(fields[0], "@")
+	if cmd == "/domains" {
+		return true
+	}
+	_, ok := domainCommands[cmd]
+	return ok
+}
+
+// procDomainsCommand handles domain commands sent to admin chat. "/domains" lists denied and allowed domains,
+// "/deny example.com" and "/allow example.com" add domain to the list, moving it from the other list if there,
+// "/undeny example.com" and "/unallow example.com" remove it. After the change domains reloaded for all groups,
+// groups failed to reload are reported in the reply, as the change itself is saved.
+func (l *TelegramListener) procDomainsCommand(ctx context.Context, text string) (reply string, err error) {
+	if l.Dictionary == nil {
+		return "", fmt.Errorf("domains are not managed by the bot")
+	}
+	if !isDomainsCommand(text) {
+		return "", fmt.Errorf("unknown domains command %q", text)
+	}
+	fields := strings.Fields(text)
+	cmd, _, _ := strings.Cut(fields[0], "@")
+
+	if cmd == "/domains" {
+		denied, err := l.Dictionary.Read(ctx, storage.DictionaryTypeDeniedDomain)
+		if err != nil {
+			return "", fmt.Errorf("failed to read denied domains: %w", err)
+		}
+		allowed, err := l.Dictionary.Read(ctx, storage.DictionaryTypeAllowedDomain)
+		if err != nil {
+			return "", fmt.Errorf("failed to read allowed domains: %w", err)
+		}
+		return fmt.Sprintf("denied domains: %s\nallowed domains: %s", domainsList(denied), domainsList(allowed)), nil
+	}
+
+	c := domainCommands[cmd]
+	if len(fields) < 2 {
+		return "", fmt.Errorf("domain is missing, usage: %s example.com", cmd)
+	}
+	domain := strings.ToLower(fields[1])
+	if c.add {
+		if err := l.Dictionary.Set(ctx, c.dt, domain); err != nil {
+			return "", fmt.Errorf("failed to add %s: %w", domain, err)
+		}
+	} else if err := l.Dictionary.Remove(ctx, c.dt, domain); err != nil {
+		return "", fmt.Errorf("failed to remove %s: %w", domain, err)
+	}
+
+	action := "added to"
+	if !c.add {
+		action = "removed from"
+	}
+	log.Printf("[INFO] domain %s %s %s", domain, action, c.dt)
+	reply = fmt.Sprintf("domain %s %s %s", domain, action, strings.ReplaceAll(string(c.dt), "_", " ")+"s")
+
+	// the change is saved already, so reload failure of a group is reported in the reply and doesn't stop the others
+	var failed []string
+	for _, g := range l.allGroups() {
+		if err := g.bot.ReloadDomains(); err != nil {
+			log.Printf("[WARN] failed to reload domains for %q (%d): %v", g.name, g.chatID, err)
+			failed = append(failed, fmt.Sprintf("%q (%d): %v", g.name, g.chatID, err))
+		}
+	}
+	if len(failed) > 0 {
+		reply += ", saved, but reload failed for " + strings.Join(failed, "; ")
+	}
+	return reply, nil
+}
+
+// domainsList returns comma-separated list of domains, "none" for empty list
+func domainsList(domains []string) string {
+	if len(domains) == 0 {
+		return "none"
+	}
+	return strings.Join(domains, ", ")
+}
+
+// replyDomainsCommand processes the domain command and responds to admin chat with the result or the error
+func (l *TelegramListener) replyDomainsCommand(ctx context.Context, text string, replyTo int) {
+	reply, err := l.procDomainsCommand(ctx, text)
+	if err != nil {
+		log.Printf("[WARN] failed to process domains command: %v", err)
+		reply = "error: " + err.Error()
+	}
+	resp := bot.Response{Send: true, Text: escapeMarkDownV1Text(reply), ReplyTo: replyTo}
+	if errResp := l.sendBotResponse(resp, l.adminChatID, NotificationSilent); errResp != nil {
+		log.Printf("[WARN] failed to respond on domains command, %v", errResp)
+	}
+}
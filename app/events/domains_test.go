@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+func TestIsDomainsCommand(t *testing.T) {
+	tbl := []struct {
+		text string
+		want bool
+	}{
+		{"/domains", true},
+		{"/deny example.com", true},
+		{"/allow@tg_spam_bot example.com", true},
+		{"/undeny example.com", true},
+		{"/unallow example.com", true},
+		{"/denied example.com", false},
+		{"deny example.com", false},
+		{"", false},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.want, isDomainsCommand(tt.text), tt.text)
+	}
+}
+
+func TestTelegramListener_procDomainsCommand(t *testing.T) {
+	dict := &mocks.DictionaryMock{
+		ReadFunc: func(ctx context.Context, dt storage.DictionaryType) ([]string, error) {
+			if dt == storage.DictionaryTypeDeniedDomain {
+				return []string{"spam.com", "t.me/+"}, nil
+			}
+			return []string{}, nil
+		},
+		SetFunc: func(ctx context.Context, dt storage.DictionaryType, data string) error { return nil },
+		RemoveFunc: func(ctx context.Context, dt storage.DictionaryType, data string) error {
+			if data != "spam.com" {
+				return fmt.Errorf("%s %q not found", dt, data)
+			}
+			return nil
+		},
+	}
+	primBot := &mocks.BotMock{ReloadDomainsFunc: func() error { return nil }}
+	groupBot := &mocks.BotMock{ReloadDomainsFunc: func() error { return nil }}
+	l := &TelegramListener{Bot: primBot, Dictionary: dict, chatID: 123,
+		groups: map[int64]*chatGroup{789: {name: "group2", chatID: 789, bot: groupBot}}}
+
+	t.Run("list", func(t *testing.T) {
+		reply, err := l.procDomainsCommand(context.Background(), "/domains")
+		require.NoError(t, err)
+		assert.Equal(t, "denied domains: spam.com, t.me/+\nallowed domains: none", reply)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		dict.ResetCalls()
+		primBot.ResetCalls()
+		groupBot.ResetCalls()
+		reply, err := l.procDomainsCommand(context.Background(), "/deny@tg_spam_bot Casino.io")
+		require.NoError(t, err)
+		assert.Equal(t, "domain casino.io added to denied domains", reply)
+		require.Len(t, dict.SetCalls(), 1)
+		assert.Equal(t, storage.DictionaryTypeDeniedDomain, dict.SetCalls()[0].T)
+		assert.Equal(t, "casino.io", dict.SetCalls()[0].Data)
+		assert.Len(t, primBot.ReloadDomainsCalls(), 1)
+		assert.Len(t, groupBot.ReloadDomainsCalls(), 1, "domains reloaded for all groups")
+	})
+
+	t.Run("unallow", func(t *testing.T) {
+		dict.ResetCalls()
+		reply, err := l.procDomainsCommand(context.Background(), "/unallow spam.com")
+		require.NoError(t, err)
+		assert.Equal(t, "domain spam.com removed from allowed domains", reply)
+		require.Len(t, dict.RemoveCalls(), 1)
+		assert.Equal(t, storage.DictionaryTypeAllowedDomain, dict.RemoveCalls()[0].T)
+	})
+
+	t.Run("reload failed", func(t *testing.T) {
+		dict.ResetCalls()
+		failedBot := &mocks.BotMock{ReloadDomainsFunc: func() error { return fmt.Errorf("db is down") }}
+		okBot := &mocks.BotMock{ReloadDomainsFunc: func() error { return nil }}
+		ll := &TelegramListener{Bot: failedBot, Dictionary: dict, chatID: 123, Group: "group1",
+			groups: map[int64]*chatGroup{789: {name: "group2", chatID: 789, bot: okBot}}}
+		reply, err := ll.procDomainsCommand(context.Background(), "/allow example.com")
+		require.NoError(t, err, "the change is saved, failure reported in the reply")
+		assert.Equal(t, `domain example.com added to allowed domains, saved, but reload failed for "group1" (123): db is down`, reply)
+		assert.Len(t, dict.SetCalls(), 1)
+		assert.Len(t, failedBot.ReloadDomainsCalls(), 1)
+		assert.Len(t, okBot.ReloadDomainsCalls(), 1, "other groups reloaded after the failure")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := l.procDomainsCommand(context.Background(), "/allow")
+		require.EqualError(t, err, "domain is missing, usage: /allow example.com")
+
+		_, err = l.procDomainsCommand(context.Background(), "/undeny unknown.com")
+		require.EqualError(t, err, `failed to remove unknown.com: denied_domain "unknown.com" not found`)
+
+		_, err = (&TelegramListener{}).procDomainsCommand(context.Background(), "/domains")
+		require.Error(t, err)
+	})
+
+	t.Run("reply to admin chat", func(t *testing.T) {
+		mockAPI := &mocks.TbAPIMock{SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil }}
+		ll := &TelegramListener{TbAPI: mockAPI, Bot: primBot, Dictionary: dict, chatID: 123, adminChatID: 456}
+		ll.replyDomainsCommand(context.Background(), "/domains", 42)
+		require.Len(t, mockAPI.SendCalls(), 1)
+		msg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+		assert.Equal(t, int64(456), msg.ChatID)
+		assert.Equal(t, 42, msg.ReplyParameters.MessageID)
+		assert.Equal(t, "denied domains: spam.com, t.me/+\nallowed domains: none", msg.Text)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	tbapi "github.com/OvyFlash/telegram-bot-api"
 
@@ -18,6 +19,11 @@ import (
 //go:generate moq --out mocks/spam_logger.go --pkg mocks --with-resets --skip-ensure . SpamLogger
 //go:generate moq --out mocks/bot.go --pkg mocks --with-resets --skip-ensure . Bot
 //go:generate moq --out mocks/locator.go --pkg mocks --with-resets --skip-ensure . Locator
+//go:generate moq --out mocks/strikes.go --pkg mocks --with-resets --skip-ensure . Strikes
+//go:generate moq --out mocks/dictionary.go --pkg mocks --with-resets --skip-ensure . Dictionary
+//go:generate moq --out mocks/mention_cache.go --pkg mocks --with-resets --skip-ensure . MentionCache
+//go:generate moq --out mocks/bio_cache.go --pkg mocks --with-resets --skip-ensure . BioCache
+//go:generate moq --out mocks/restrictions.go --pkg mocks --with-resets --skip-ensure . Restrictions
 
 // TbAPI is an interface for telegram bot API, only subset of methods used
 type TbAPI interface {
@@ -46,19 +52,40 @@ type Locator interface {
 	AddMessage(ctx context.Context, msg string, chatID, userID int64, userName string, msgID int) error
 	AddSpam(ctx context.Context, userID int64, checks []spamcheck.Response) error
 	Message(ctx context.Context, msg string) (storage.MsgMeta, bool)
+	Joins(ctx context.Context, chatID int64, since time.Time) ([]storage.MsgMeta, error)
 	Spam(ctx context.Context, userID int64) (storage.SpamData, bool)
+	UserMessages(ctx context.Context, chatID, userID int64, since time.Time) ([]storage.MsgMeta, error)
 	MsgHash(msg string) string
 	UserNameByID(ctx context.Context, userID int64) string
 }
 
+// Strikes is an interface for user strikes storage
+type Strikes interface {
+	Add(ctx context.Context, strike storage.StrikeInfo) (int, error)
+	Pending(ctx context.Context, userID int64) (count int, pending bool, err error)
+	MarkApplied(ctx context.Context, userID int64) error
+	Clear(ctx context.Context, userID int64) error
+}
+
+// Restrictions is an interface for storage of temporary restrictions, restored after restart
+type Restrictions interface {
+	Write(ctx context.Context, rs storage.Restriction) error
+	Delete(ctx context.Context, kind storage.RestrictionKind, chatID, userID int64) error
+	List(ctx context.Context, kind storage.RestrictionKind) ([]storage.Restriction, error)
+}
+
 // Bot is an interface for bot events.
 type Bot interface {
-	OnMessage(msg bot.Message, checkOnly bool) (response bot.Response)
+	OnMessage(ctx context.Context, msg bot.Message, checkOnly bool) (response bot.Response)
+	CheckUser(ctx context.Context, user bot.User) (response bot.Response)
 	UpdateSpam(msg string) error
 	UpdateHam(msg string) error
 	AddApprovedUser(id int64, name string) error
+	CountCleanMessage(id int64, name string)
 	RemoveApprovedUser(id int64) error
 	IsApprovedUser(userID int64) bool
+	ApprovedAt(userID int64) (time.Time, bool)
+	ReloadDomains() error
 }
 
 func escapeMarkDownV1Text(text string) string {
@@ -126,6 +153,18 @@ func send(tbMsg tbapi.Chattable, tbAPI TbAPI) error {
 	return nil
 }
 
+// chatPermissions returns current permissions of the chat members, full permissions if failed to get them
+func chatPermissions(tbAPI TbAPI, chatID int64) *tbapi.ChatPermissions {
+	chat, err := tbAPI.GetChat(tbapi.ChatInfoConfig{ChatConfig: tbapi.ChatConfig{ChatID: chatID}})
+	if err == nil && chat.Permissions != nil {
+		return chat.Permissions
+	}
+	log.Printf("[WARN] failed to get permissions of %d, full permissions used: %v", chatID, err)
+	return &tbapi.ChatPermissions{CanSendMessages: true, CanSendAudios: true, CanSendDocuments: true, CanSendPhotos: true,
+		CanSendVideos: true, CanSendVideoNotes: true, CanSendVoiceNotes: true, CanSendPolls: true,
+		CanSendOtherMessages: true, CanAddWebPagePreviews: true, CanInviteUsers: true}
+}
+
 type banRequest struct {
 	tbAPI TbAPI
 
@@ -267,10 +306,12 @@ func transform(msg *tbapi.Message) *bot.Message {
 
 	// initialize message with basic fields
 	message := bot.Message{
-		ID:     msg.MessageID,
-		Sent:   msg.Time(),
-		Text:   msg.Text,
-		ChatID: msg.Chat.ID,
+		ID:       msg.MessageID,
+		Sent:     msg.Time(),
+		Text:     msg.Text,
+		ChatID:   msg.Chat.ID,
+		Edited:   msg.EditDate != 0,
+		ThreadID: threadID(msg),
 	}
 
 	// set sender info
@@ -332,6 +373,40 @@ func transform(msg *tbapi.Message) *bot.Message {
 	if msg.ReplyMarkup != nil { // detect attached keyboards/buttons
 		message.WithKeyboard = true
 	}
+	if msg.Animation != nil { // animation (gif) is sent with the document set as well
+		message.WithAnimation = true
+	} else if msg.Document != nil {
+		message.WithDocument = true
+		message.DocumentName = msg.Document.FileName
+	}
+	if msg.Sticker != nil {
+		message.WithSticker = true
+	}
+	if msg.Voice != nil {
+		message.WithVoice = true
+	}
+	if msg.Contact != nil {
+		message.WithContact = true
+	}
+	if msg.Venue != nil { // venue is sent with the location set as well
+		message.WithVenue = true
+	} else if msg.Location != nil {
+		message.WithLocation = true
+	}
+	if msg.Dice != nil {
+		message.WithDice = true
+	}
+	if msg.ViaBot != nil {
+		message.ViaBot = msg.ViaBot.UserName
+	}
+	if msg.Poll != nil { // poll question and options are checked as the message text
+		message.WithPoll = true
+		pollText := []string{msg.Poll.Question}
+		for _, o := range msg.Poll.Options {
+			pollText = append(pollText, o.Text)
+		}
+		message.Text = strings.TrimSpace(message.Text + "\n" + strings.Join(pollText, "\n"))
+	}
 
 	// handle reply-to message if present
 	if msg.ReplyToMessage != nil {
@@ -354,13 +429,26 @@ func transform(msg *tbapi.Message) *bot.Message {
 
 	// handle caption - either as main text if no text present, or append to existing text
 	if msg.Caption != "" {
+		captionOffset := 0 // caption entities are relative to the caption, shifted if the caption is appended
 		if message.Text == "" {
 			log.Printf("[DEBUG] caption only message: %q", msg.Caption)
 			message.Text = msg.Caption
 		} else {
 			log.Printf("[DEBUG] caption appended to message: %q", msg.Caption)
+			captionOffset = len(utf16.Encode([]rune(message.Text))) + 1
 			message.Text += "\n" + msg.Caption
 		}
+		if captionEntities := transformEntities(msg.CaptionEntities); captionEntities != nil {
+			entities := []bot.Entity{}
+			if message.Entities != nil {
+				entities = append(entities, *message.Entities...)
+			}
+			for _, e := range *captionEntities {
+				e.Offset += captionOffset
+				entities = append(entities, e)
+			}
+			message.Entities = &entities
+		}
 	}
 
 	return &message
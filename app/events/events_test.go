@@ -342,8 +342,9 @@ func TestTelegramListener_transformTextMessage(t *testing.T) {
 func TestTelegramListener_transformPhoto(t *testing.T) {
 	assert.Equal(t,
 		&bot.Message{
-			Text: "caption",
-			Sent: time.Unix(1578627415, 0),
+			Text:     "caption",
+			Sent:     time.Unix(1578627415, 0),
+			Entities: &[]bot.Entity{{Type: "bold", Offset: 0, Length: 7}},
 			Image: &bot.Image{
 				FileID:  "AgADAgADFKwxG8r0qUiQByxwp9Gi4s1qwQ8ABAEAAwIAA3kAA5K9AgABFgQ",
 				Width:   1280,
@@ -394,6 +395,87 @@ func TestTelegramListener_transformPhoto(t *testing.T) {
 	)
 }
 
+func TestTelegramListener_transformMedia(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *tbapi.Message
+		expected *bot.Message
+	}{
+		{
+			name:     "document",
+			input:    &tbapi.Message{Document: &tbapi.Document{FileName: "setup.apk"}},
+			expected: &bot.Message{WithDocument: true, DocumentName: "setup.apk"},
+		},
+		{
+			name:     "animation with document",
+			input:    &tbapi.Message{Animation: &tbapi.Animation{}, Document: &tbapi.Document{FileName: "funny.mp4"}},
+			expected: &bot.Message{WithAnimation: true},
+		},
+		{
+			name:     "sticker",
+			input:    &tbapi.Message{Sticker: &tbapi.Sticker{}},
+			expected: &bot.Message{WithSticker: true},
+		},
+		{
+			name:     "voice",
+			input:    &tbapi.Message{Voice: &tbapi.Voice{}},
+			expected: &bot.Message{WithVoice: true},
+		},
+		{
+			name:     "contact",
+			input:    &tbapi.Message{Contact: &tbapi.Contact{PhoneNumber: "123"}},
+			expected: &bot.Message{WithContact: true},
+		},
+		{
+			name:     "location",
+			input:    &tbapi.Message{Location: &tbapi.Location{}},
+			expected: &bot.Message{WithLocation: true},
+		},
+		{
+			name:     "venue with location",
+			input:    &tbapi.Message{Venue: &tbapi.Venue{}, Location: &tbapi.Location{}},
+			expected: &bot.Message{WithVenue: true},
+		},
+		{
+			name:     "dice",
+			input:    &tbapi.Message{Dice: &tbapi.Dice{}},
+			expected: &bot.Message{WithDice: true},
+		},
+		{
+			name:     "via bot",
+			input:    &tbapi.Message{Text: "some text", ViaBot: &tbapi.User{UserName: "somebot"}},
+			expected: &bot.Message{Text: "some text", ViaBot: "somebot"},
+		},
+		{
+			name: "video with caption entities",
+			input: &tbapi.Message{Video: &tbapi.Video{}, Caption: "join t.me/xxx",
+				CaptionEntities: []tbapi.MessageEntity{{Type: "url", Offset: 5, Length: 8}}},
+			expected: &bot.Message{WithVideo: true, Text: "join t.me/xxx",
+				Entities: &[]bot.Entity{{Type: "url", Offset: 5, Length: 8}}},
+		},
+		{
+			name: "poll with caption entities shifted",
+			input: &tbapi.Message{Poll: &tbapi.Poll{Question: "ok?"}, Caption: "see here",
+				CaptionEntities: []tbapi.MessageEntity{{Type: "text_link", Offset: 4, Length: 4, URL: "https://spam.com"}}},
+			expected: &bot.Message{WithPoll: true, Text: "ok?\nsee here",
+				Entities: &[]bot.Entity{{Type: "text_link", Offset: 8, Length: 4, URL: "https://spam.com"}}},
+		},
+		{
+			name: "poll",
+			input: &tbapi.Message{Poll: &tbapi.Poll{Question: "easy money?",
+				Options: []tbapi.PollOption{{Text: "yes"}, {Text: "dm me"}}}},
+			expected: &bot.Message{WithPoll: true, Text: "easy money?\nyes\ndm me"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Date = 1578627415
+			tt.expected.Sent = time.Unix(1578627415, 0)
+			assert.Equal(t, tt.expected, transform(tt.input))
+		})
+	}
+}
+
 func TestTelegramListener_transformEntities(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// JoinRequestConfig defines screening of join requests, sent by groups with "approve new members" enabled
+type JoinRequestConfig struct {
+	Enabled bool // screen join requests, requests are left to admins if not set
+	Review  bool // forward suspicious requests to admin chat with approve/decline buttons instead of declining them
+}
+
+// procJoinRequest screens the join request before the user is admitted. The user is checked by CAS, stop words,
+// username symbols and profile checks, and spam detected for the user before is looked up. Clean requests are approved,
+// suspicious ones declined or forwarded to admin chat for review. No action taken in dry mode.
+func (l *TelegramListener) procJoinRequest(ctx context.Context, req *tbapi.ChatJoinRequest) error {
+	fromChat := req.Chat.ID
+	if !l.isChatAllowed(fromChat) {
+		return nil
+	}
+	g := l.group(fromChat)
+	user := bot.User{ID: req.From.ID, Username: req.From.UserName,
+		DisplayName: strings.TrimSpace(req.From.FirstName + " " + req.From.LastName)}
+	if l.Profile.Enabled {
+		user.Bio = req.Bio // join request has the bio, no need to fetch it
+	}
+	log.Printf("[DEBUG] join request from %v in %d", user, fromChat)
+
+	if l.SuperUsers.IsSuper(user.Username, user.ID) || g.bot.IsApprovedUser(user.ID) {
+		log.Printf("[DEBUG] join request from superuser or approved user %v", user)
+		return l.decideJoinRequest(fromChat, user, true)
+	}
+
+	resp := g.bot.CheckUser(ctx, user)
+	if spam, found := g.locator.Spam(ctx, user.ID); found {
+		resp.Send = true
+		resp.CheckResults = append(resp.CheckResults, spamcheck.Response{Name: "detected-spam", Spam: true,
+			Details: "spam detected at " + spam.Time.Format("2006-01-02 15:04:05")})
+	}
+	if !resp.Send {
+		return l.decideJoinRequest(fromChat, user, true)
+	}
+
+	log.Printf("[INFO] suspicious join request from %v in %d: %v", user, fromChat, resp.CheckResults)
+	review := l.JoinRequests.Review || l.TrainingMode // never decline in training mode, let admins decide
+	if review && l.adminChatID != 0 {
+		g.admin.ReportJoinRequest(user, fromChat, resp, false)
+		return nil
+	}
+	if review {
+		log.Printf("[WARN] no admin chat to review join request from %v, left pending", user)
+		return nil
+	}
+	if err := l.decideJoinRequest(fromChat, user, false); err != nil {
+		return err
+	}
+	if l.adminChatID != 0 {
+		g.admin.ReportJoinRequest(user, fromChat, resp, true)
+	}
+	return nil
+}
+
+// decideJoinRequest approves or declines the join request, does nothing in dry mode
+func (l *TelegramListener) decideJoinRequest(chatID int64, user bot.User, approve bool) error {
+	decision := "declined"
+	var req tbapi.Chattable = tbapi.DeclineChatJoinRequest{ChatConfig: tbapi.ChatConfig{ChatID: chatID}, UserID: user.ID}
+	if approve {
+		decision = "approved"
+		req = tbapi.ApproveChatJoinRequestConfig{ChatConfig: tbapi.ChatConfig{ChatID: chatID}, UserID: user.ID}
+	}
+	if l.Dry {
+		log.Printf("[INFO] dry run: join request from %v in %d would be %s", user, chatID, decision)
+		return nil
+	}
+	if _, err := l.TbAPI.Request(req); err != nil {
+		return fmt.Errorf("failed to handle join request from %d, %s: %w", user.ID, decision, err)
+	}
+	log.Printf("[INFO] join request from %v in %d %s", user, chatID, decision)
+	return nil
+}
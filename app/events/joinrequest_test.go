@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestTelegramListener_procJoinRequest(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	b := &mocks.BotMock{
+		IsApprovedUserFunc: func(userID int64) bool { return userID == 100 },
+		CheckUserFunc: func(_ context.Context, user bot.User) bot.Response {
+			if user.Username == "spammer" {
+				return bot.Response{Send: true, User: user, CheckResults: []spamcheck.Response{
+					{Name: "stopword", Spam: true, Details: "buy"}}}
+			}
+			return bot.Response{CheckResults: []spamcheck.Response{{Name: "stopword", Spam: false}}}
+		},
+	}
+	locator := &mocks.LocatorMock{
+		SpamFunc: func(ctx context.Context, userID int64) (storage.SpamData, bool) {
+			if userID == 300 {
+				return storage.SpamData{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, true
+			}
+			return storage.SpamData{}, false
+		},
+	}
+
+	makeListener := func() *TelegramListener {
+		l := &TelegramListener{TbAPI: mockAPI, Bot: b, Locator: locator, SuperUsers: SuperUsers{"admin"},
+			chatID: 123, adminChatID: 456, JoinRequests: JoinRequestConfig{Enabled: true}}
+		l.adminHandler = &admin{tbAPI: mockAPI, bot: b, locator: locator, primChatID: 123, adminChatID: 456}
+		return l
+	}
+	joinReq := func(chatID, userID int64, userName string) *tbapi.ChatJoinRequest {
+		return &tbapi.ChatJoinRequest{Chat: tbapi.Chat{ID: chatID},
+			From: tbapi.User{ID: userID, UserName: userName, FirstName: "first", LastName: "last"}}
+	}
+
+	t.Run("clean user approved", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		b.ResetCalls()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		err := makeListener().procJoinRequest(ctx, joinReq(123, 200, "good_user"))
+		require.NoError(t, err)
+		require.Len(t, b.CheckUserCalls(), 1)
+		assert.Equal(t, ctx, b.CheckUserCalls()[0].Ctx)
+		assert.Equal(t, bot.User{ID: 200, Username: "good_user", DisplayName: "first last"}, b.CheckUserCalls()[0].User)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		req := mockAPI.RequestCalls()[0].C.(tbapi.ApproveChatJoinRequestConfig)
+		assert.Equal(t, int64(123), req.ChatID)
+		assert.Equal(t, int64(200), req.UserID)
+		assert.Empty(t, mockAPI.SendCalls())
+	})
+
+	t.Run("bio checked with profile check enabled", func(t *testing.T) {
+		b.ResetCalls()
+		req := joinReq(123, 200, "good_user")
+		req.Bio = "dm me for signals"
+		require.NoError(t, makeListener().procJoinRequest(context.Background(), req))
+		l := makeListener()
+		l.Profile.Enabled = true
+		require.NoError(t, l.procJoinRequest(context.Background(), req))
+		require.Len(t, b.CheckUserCalls(), 2)
+		assert.Empty(t, b.CheckUserCalls()[0].User.Bio)
+		assert.Equal(t, "dm me for signals", b.CheckUserCalls()[1].User.Bio)
+	})
+
+	t.Run("superuser and approved user approved without check", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		b.ResetCalls()
+		l := makeListener()
+		require.NoError(t, l.procJoinRequest(context.Background(), joinReq(123, 1, "admin")))
+		require.NoError(t, l.procJoinRequest(context.Background(), joinReq(123, 100, "approved")))
+		assert.Empty(t, b.CheckUserCalls())
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		assert.IsType(t, tbapi.ApproveChatJoinRequestConfig{}, mockAPI.RequestCalls()[0].C)
+		assert.IsType(t, tbapi.ApproveChatJoinRequestConfig{}, mockAPI.RequestCalls()[1].C)
+	})
+
+	t.Run("suspicious user declined and reported", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := makeListener().procJoinRequest(context.Background(), joinReq(123, 200, "spammer"))
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		req := mockAPI.RequestCalls()[0].C.(tbapi.DeclineChatJoinRequest)
+		assert.Equal(t, int64(200), req.UserID)
+		require.Len(t, mockAPI.SendCalls(), 1)
+		msg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+		assert.Equal(t, int64(456), msg.ChatID)
+		assert.Contains(t, msg.Text, "declined join request from")
+		assert.Contains(t, msg.Text, "stopword: spam, buy")
+		assert.Nil(t, msg.ReplyMarkup, "no buttons for declined request")
+	})
+
+	t.Run("user with detected spam declined", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		err := makeListener().procJoinRequest(context.Background(), joinReq(123, 300, "good_user"))
+		require.NoError(t, err)
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		assert.IsType(t, tbapi.DeclineChatJoinRequest{}, mockAPI.RequestCalls()[0].C)
+		require.Len(t, mockAPI.SendCalls(), 1)
+		assert.Contains(t, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text, "detected-spam")
+	})
+
+	t.Run("suspicious user forwarded for review", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		l := makeListener()
+		l.JoinRequests.Review = true
+		err := l.procJoinRequest(context.Background(), joinReq(123, 200, "spammer"))
+		require.NoError(t, err)
+		assert.Empty(t, mockAPI.RequestCalls(), "request left pending")
+		require.Len(t, mockAPI.SendCalls(), 1)
+		msg := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+		assert.Contains(t, msg.Text, "suspicious join request from")
+		markup := msg.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+		require.Len(t, markup.InlineKeyboard[0], 2)
+		assert.Equal(t, "A200:0:123", *markup.InlineKeyboard[0][0].CallbackData)
+		assert.Equal(t, "D200:0:123", *markup.InlineKeyboard[0][1].CallbackData)
+	})
+
+	t.Run("dry mode, no action", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		l := makeListener()
+		l.Dry = true
+		require.NoError(t, l.procJoinRequest(context.Background(), joinReq(123, 200, "good_user")))
+		require.NoError(t, l.procJoinRequest(context.Background(), joinReq(123, 201, "spammer")))
+		assert.Empty(t, mockAPI.RequestCalls())
+	})
+
+	t.Run("not allowed chat ignored", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		b.ResetCalls()
+		require.NoError(t, makeListener().procJoinRequest(context.Background(), joinReq(999, 200, "good_user")))
+		assert.Empty(t, b.CheckUserCalls())
+		assert.Empty(t, mockAPI.RequestCalls())
+	})
+
+	t.Run("approve failed", func(t *testing.T) {
+		failAPI := &mocks.TbAPIMock{RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return nil, errors.New("no rights")
+		}}
+		l := makeListener()
+		l.TbAPI = failAPI
+		err := l.procJoinRequest(context.Background(), joinReq(123, 200, "good_user"))
+		assert.EqualError(t, err, "failed to handle join request from 200, approved: no rights")
+	})
+}
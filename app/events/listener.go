@@ -20,32 +20,52 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/storage"
 )
 
 // TelegramListener listens to tg update, forward to bots and send back responses
 // Not thread safe
 type TelegramListener struct {
-	TbAPI                   TbAPI         // telegram bot API
-	SpamLogger              SpamLogger    // logger to save spam to files and db
-	Bot                     Bot           // bot to handle messages
-	Group                   string        // can be int64 or public group username (without "@" prefix)
-	AdminGroup              string        // can be int64 or public group username (without "@" prefix)
-	IdleDuration            time.Duration // idle timeout to send "idle" message to bots
-	SuperUsers              SuperUsers    // list of superusers, can ban and report spam, can't be banned
-	TestingIDs              []int64       // list of chat IDs to test the bot
-	StartupMsg              string        // message to send on startup to the primary chat
-	WarnMsg                 string        // message to send on warning
-	NoSpamReply             bool          // do not reply on spam messages in the primary chat
-	SuppressJoinMessage     bool          // delete join message when kick out user
-	TrainingMode            bool          // do not ban users, just report and train spam detector
-	SoftBanMode             bool          // do not ban users, but restrict their actions
-	Locator                 Locator       // message locator to get info about messages
-	DisableAdminSpamForward bool          // disable forwarding spam reports to admin chat support
-	Dry                     bool          // dry run, do not ban or send messages
+	TbAPI                   TbAPI             // telegram bot API
+	SpamLogger              SpamLogger        // logger to save spam to files and db
+	Bot                     Bot               // bot to handle messages
+	Group                   string            // can be int64 or public group username (without "@" prefix)
+	AdminGroup              string            // can be int64 or public group username (without "@" prefix)
+	IdleDuration            time.Duration     // idle timeout to send "idle" message to bots
+	SuperUsers              SuperUsers        // list of superusers, can ban and report spam, can't be banned
+	TestingIDs              []int64           // list of chat IDs to test the bot
+	StartupMsg              string            // message to send on startup to the primary chat
+	WarnMsg                 string            // message to send on warning
+	NoSpamReply             bool              // do not reply on spam messages in the primary chat
+	SuppressJoinMessage     bool              // delete join message when kick out user
+	TrainingMode            bool              // do not ban users, just report and train spam detector
+	SoftBanMode             bool              // do not ban users, but restrict their actions
+	Locator                 Locator           // message locator to get info about messages
+	DisableAdminSpamForward bool              // disable forwarding spam reports to admin chat support
+	Dry                     bool              // dry run, do not ban or send messages
+	Strikes                 Strikes           // user strikes storage, optional. Escalation by StrikesLadder is disabled if not set
+	StrikesLadder           bot.Ladder        // escalation ladder, action for the number of active strikes
+	Groups                  []Group           // additional groups monitored by the same bot, optional
+	Captcha                 CaptchaConfig     // join challenge for new members, optional
+	JoinRequests            JoinRequestConfig // screening of join requests, optional
+	EditRecheckWindow       time.Duration     // re-check edited messages of users approved within this window, 0 - disabled
+	Topics                  TopicPolicies     // forum topic policies of the primary group, by message thread ID, optional
+	Dictionary              Dictionary        // denied and allowed domains storage, domain commands in admin chat disabled if not set
+	Profile                 ProfileConfig     // screening of user profiles, optional
+	RateLimit               RateLimitConfig   // per-user message rate limits, optional
+	Raid                    RaidConfig        // mass-join raid detection and lockdown, optional
+	Restrictions            Restrictions      // storage of pending join challenges and lockdowns, restored on start, optional
 
 	adminHandler *admin
 	chatID       int64
 	adminChatID  int64
+	groups       map[int64]*chatGroup    // additional groups by chat ID, primary group is not included
+	captcha      *captcha                // join challenge handler, nil if disabled
+	raid         *raid                   // raid detector, nil if disabled
+	campaigns    map[string]time.Time    // reported spam campaigns by id, with the time of the report
+	rates        map[rateKey][]time.Time // times of recent messages by chat and user, for rate limits
+	ratesCleanup time.Time               // time of the last cleanup of rates
+	ratesLock    sync.Mutex              // protects rates and ratesCleanup
 
 	msgs struct {
 		once sync.Once
@@ -53,6 +73,31 @@ type TelegramListener struct {
 	}
 }
 
+// Group is an additional group monitored by the listener. Each group has its own detector and storage,
+// all other settings, like admin group, superusers and modes, are shared with the primary group.
+type Group struct {
+	Name       string        // can be int64 or public group username (without "@" prefix)
+	Bot        Bot           // bot to handle messages of the group
+	SpamLogger SpamLogger    // logger to save spam of the group
+	Locator    Locator       // message locator of the group
+	Strikes    Strikes       // user strikes storage of the group, optional
+	WarnMsg    string        // message to send on warning, listener's WarnMsg used if empty
+	Topics     TopicPolicies // forum topic policies of the group, optional
+}
+
+// chatGroup is a resolved group with its chat ID and admin handler
+type chatGroup struct {
+	name       string
+	chatID     int64
+	bot        Bot
+	spamLogger SpamLogger
+	locator    Locator
+	strikes    Strikes
+	warnMsg    string
+	topics     TopicPolicies
+	admin      *admin
+}
+
 // Do process all events, blocked call
 func (l *TelegramListener) Do(ctx context.Context) error {
 	log.Printf("[INFO] start telegram listener for %q", l.Group)
@@ -72,6 +117,25 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 	}
 	log.Printf("[INFO] primary chat ID: %d", l.chatID)
 
+	// get chat IDs for additional groups
+	l.groups = make(map[int64]*chatGroup, len(l.Groups))
+	for _, g := range l.Groups {
+		chatID, err := l.getChatID(g.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get chat ID for group %q: %w", g.Name, err)
+		}
+		if _, ok := l.groups[chatID]; ok || chatID == l.chatID {
+			return fmt.Errorf("group %q (%d) is set more than once", g.Name, chatID)
+		}
+		warnMsg := g.WarnMsg
+		if warnMsg == "" {
+			warnMsg = l.WarnMsg
+		}
+		l.groups[chatID] = &chatGroup{name: g.Name, chatID: chatID, bot: g.Bot, spamLogger: g.SpamLogger,
+			locator: g.Locator, strikes: g.Strikes, warnMsg: warnMsg, topics: g.Topics}
+		log.Printf("[INFO] additional group %q, chat ID: %d", g.Name, chatID)
+	}
+
 	if err := l.updateSupers(); err != nil {
 		log.Printf("[WARN] failed to update superusers: %v", err)
 	}
@@ -93,15 +157,52 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 
 	// send startup message if any set
 	if l.StartupMsg != "" && !l.TrainingMode && !l.Dry {
-		if err := l.sendBotResponse(bot.Response{Send: true, Text: l.StartupMsg}, l.chatID, NotificationSilent); err != nil {
-			log.Printf("[WARN] failed to send startup message, %v", err)
-		} else {
-			log.Printf("[DEBUG] startup message sent")
+		for _, g := range l.allGroups() {
+			if err := l.sendBotResponse(bot.Response{Send: true, Text: l.StartupMsg}, g.chatID, NotificationSilent); err != nil {
+				log.Printf("[WARN] failed to send startup message to %d, %v", g.chatID, err)
+			} else {
+				log.Printf("[DEBUG] startup message sent to %d", g.chatID)
+			}
 		}
 	}
 
-	l.adminHandler = &admin{tbAPI: l.TbAPI, bot: l.Bot, locator: l.Locator, primChatID: l.chatID, adminChatID: l.adminChatID,
-		superUsers: l.SuperUsers, trainingMode: l.TrainingMode, softBan: l.SoftBanMode, dry: l.Dry, warnMsg: l.WarnMsg}
+	// admin handler per group, group name added to admin reports if more than one group monitored
+	primaryName := ""
+	if len(l.groups) > 0 {
+		primaryName = l.Group
+	}
+	l.adminHandler = l.makeAdmin(l.group(l.chatID), primaryName)
+	for _, g := range l.groups {
+		g.admin = l.makeAdmin(g, g.name)
+	}
+
+	if l.Captcha.Enabled {
+		l.captcha = newCaptcha(l.Captcha, l.TbAPI, l.Restrictions)
+		log.Printf("[INFO] join challenge enabled, timeout: %v, arithmetic: %v, count message: %v",
+			l.captcha.Timeout, l.captcha.Arithmetic, l.captcha.CountMessage)
+	}
+	if l.Raid.Enabled {
+		l.raid = newRaid(l.Raid, l.TbAPI, l.adminChatID, l.Restrictions)
+		log.Printf("[INFO] raid detection enabled, joins: %d, window: %v, lockdown: %v, restrict: %v, challenge: %v, paranoid: %v",
+			l.raid.Joins, l.raid.Window, l.raid.Lockdown, l.raid.Restrict, l.raid.Challenge, l.raid.Paranoid)
+		if err := l.raid.Restore(); err != nil {
+			log.Printf("[WARN] failed to restore lockdowns, %v", err)
+		}
+		if l.raid.Challenge && l.captcha == nil {
+			l.captcha = newCaptcha(l.Captcha, l.TbAPI, l.Restrictions) // used during lockdown only
+		}
+	}
+	if l.captcha != nil {
+		if l.raid != nil {
+			l.captcha.permissions = l.raid.Permissions // permissions saved before lockdown, if any
+		}
+		if err := l.captcha.Restore(func(chatID int64) Bot { return l.group(chatID).bot }); err != nil {
+			log.Printf("[WARN] failed to restore join challenges, %v", err)
+		}
+	}
+	if l.JoinRequests.Enabled {
+		log.Printf("[INFO] join requests screening enabled, review: %v", l.JoinRequests.Review)
+	}
 
 	adminForwardStatus := "enabled"
 	if l.DisableAdminSpamForward {
@@ -127,10 +228,15 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 			// handle admin chat messages. can be just messages (MsgHandler will ignore those)
 			// or forwards of undetected spam by admins to admin's chat (in this case MsgHandler will process them and ban/train)
 			if update.Message != nil && l.isAdminChat(update.Message.Chat.ID, update.Message.From.UserName, update.Message.From.ID) {
+				// domain commands, like "/deny example.com", handled even if spam forwarding is disabled
+				if l.Dictionary != nil && isDomainsCommand(update.Message.Text) {
+					l.replyDomainsCommand(ctx, update.Message.Text, update.Message.MessageID)
+					continue
+				}
 				if l.DisableAdminSpamForward {
 					continue
 				}
-				if err := l.adminHandler.MsgHandler(update); err != nil {
+				if err := l.adminGroup(ctx, update).admin.MsgHandler(ctx, update); err != nil {
 					log.Printf("[WARN] failed to process admin chat message: %v", err)
 					errResp := l.sendBotResponse(bot.Response{Send: true, Text: "error: " + err.Error()}, l.adminChatID, NotificationDefault)
 					if errResp != nil {
@@ -140,9 +246,35 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 				continue
 			}
 
+			// handle join challenge buttons pressed by new members
+			if update.CallbackQuery != nil && isCaptchaCallback(update.CallbackQuery.Data) {
+				if l.captcha == nil {
+					continue
+				}
+				if err := l.captcha.CallbackHandler(update.CallbackQuery); err != nil {
+					log.Printf("[WARN] failed to process join challenge callback: %v", err)
+				}
+				continue
+			}
+
+			// handle lockdown buttons in admin chat
+			if update.CallbackQuery != nil && isRaidCallback(update.CallbackQuery.Data) {
+				if l.raid == nil {
+					continue
+				}
+				if err := l.procRaidCallback(ctx, update.CallbackQuery); err != nil {
+					log.Printf("[WARN] failed to process lockdown callback: %v", err)
+					errResp := l.sendBotResponse(bot.Response{Send: true, Text: "error: " + err.Error()}, l.adminChatID, NotificationDefault)
+					if errResp != nil {
+						log.Printf("[WARN] failed to respond on error, %v", errResp)
+					}
+				}
+				continue
+			}
+
 			// handle admin chat inline buttons
 			if update.CallbackQuery != nil {
-				if err := l.adminHandler.InlineCallbackHandler(update.CallbackQuery); err != nil {
+				if err := l.callbackGroup(update.CallbackQuery.Data).admin.InlineCallbackHandler(ctx, update.CallbackQuery); err != nil {
 					log.Printf("[WARN] failed to process callback: %v", err)
 					errResp := l.sendBotResponse(bot.Response{Send: true, Text: "error: " + err.Error()}, l.adminChatID, NotificationDefault)
 					if errResp != nil {
@@ -152,13 +284,32 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 				continue
 			}
 
+			// screen join requests, sent by groups with "approve new members" enabled
+			if update.ChatJoinRequest != nil {
+				if !l.JoinRequests.Enabled {
+					continue
+				}
+				if err := l.procJoinRequest(ctx, update.ChatJoinRequest); err != nil {
+					log.Printf("[WARN] failed to process join request: %v", err)
+				}
+				continue
+			}
+
+			// re-check edited messages, spammers may edit an innocent message into spam after approval
+			if update.EditedMessage != nil {
+				if err := l.procEditedMessage(ctx, update); err != nil {
+					log.Printf("[WARN] failed to process edited message: %v", err)
+				}
+				continue
+			}
+
 			if update.Message == nil {
 				continue
 			}
 
 			// save join messages to locator even if SuppressJoinMessage is set to false
 			if update.Message.NewChatMembers != nil {
-				err := l.procNewChatMemberMessage(update)
+				err := l.procNewChatMemberMessage(ctx, update)
 				if err != nil {
 					log.Printf("[WARN] failed to process new chat member: %v", err)
 				}
@@ -168,7 +319,7 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 			// handle left member messages, i.e. "blah blah removed from the chat"
 			if update.Message.LeftChatMember != nil {
 				if l.SuppressJoinMessage {
-					err := l.procLeftChatMemberMessage(update)
+					err := l.procLeftChatMemberMessage(ctx, update)
 					if err != nil {
 						log.Printf("[WARN] failed to process left chat member: %v", err)
 					}
@@ -179,28 +330,30 @@ func (l *TelegramListener) Do(ctx context.Context) error {
 			// handle spam reports from superusers
 			fromSuper := l.SuperUsers.IsSuper(update.Message.From.UserName, update.Message.From.ID)
 			if update.Message.ReplyToMessage != nil && fromSuper {
-				if l.procSuperReply(update) {
+				if l.procSuperReply(ctx, update) {
 					// superuser command processed, skip the rest
 					continue
 				}
 			}
 
 			// process regular messages, the main part of the bot
-			if err := l.procEvents(update); err != nil {
+			if err := l.procEvents(ctx, update); err != nil {
 				log.Printf("[WARN] failed to process update: %v", err)
 				continue
 			}
 
 		case <-time.After(l.IdleDuration): // hit bots on idle timeout
-			resp := l.Bot.OnMessage(bot.Message{Text: "idle"}, false)
-			if err := l.sendBotResponse(resp, l.chatID, NotificationSilent); err != nil {
-				log.Printf("[WARN] failed to respond on idle, %v", err)
+			for _, g := range l.allGroups() {
+				resp := g.bot.OnMessage(ctx, bot.Message{Text: "idle"}, false)
+				if err := l.sendBotResponse(resp, g.chatID, NotificationSilent); err != nil {
+					log.Printf("[WARN] failed to respond on idle, %v", err)
+				}
 			}
 		}
 	}
 }
 
-func (l *TelegramListener) procEvents(update tbapi.Update) error {
+func (l *TelegramListener) procEvents(ctx context.Context, update tbapi.Update) error {
 	msgJSON, errJSON := json.Marshal(update.Message)
 	if errJSON != nil {
 		return fmt.Errorf("failed to marshal update.Message to json: %w", errJSON)
@@ -213,25 +366,61 @@ func (l *TelegramListener) procEvents(update tbapi.Update) error {
 
 	log.Printf("[DEBUG] %s", string(msgJSON))
 	msg := transform(update.Message)
+	g := l.group(fromChat)
 
-	// ignore messages with empty text, no media, no video, no video note
-	if strings.TrimSpace(msg.Text) == "" && msg.Image == nil && !msg.WithVideoNote && !msg.WithVideo {
+	// ignore messages with empty text and no media
+	if msg.IsEmpty() {
 		return nil
 	}
-	ctx := context.TODO()
 	log.Printf("[DEBUG] incoming msg: %+v", strings.ReplaceAll(msg.Text, "\n", " "))
 	log.Printf("[DEBUG] incoming msg details: %+v", msg)
-	if err := l.Locator.AddMessage(ctx, msg.Text, fromChat, msg.From.ID, msg.From.Username, msg.ID); err != nil {
-		log.Printf("[WARN] failed to add message to locator: %v", err)
+	topic := g.topics[msg.ThreadID]
+	count, limit := 0, 0
+	if !topic.Exempt && !msg.Edited {
+		// counted before the message added to locator, as the locator is used to restore recent messages
+		count, limit = l.rateLimit(ctx, g, msg)
+	}
+	// edits are neither counted nor added to locator again, the original message is already there
+	if !msg.Edited {
+		if err := g.locator.AddMessage(ctx, msg.Text, fromChat, msg.From.ID, msg.From.Username, msg.ID); err != nil {
+			log.Printf("[WARN] failed to add message to locator: %v", err)
+		}
 	}
-	resp := l.Bot.OnMessage(*msg, false)
-
-	if !resp.Send { // not spam
+	if topic.Exempt {
+		log.Printf("[DEBUG] message %d in exempt topic %d, not checked", msg.ID, msg.ThreadID)
 		return nil
 	}
+	if limit > 0 && count > limit {
+		return l.procRateLimit(g, msg, count, limit)
+	}
+	// during lockdown all messages are checked, including messages of approved users
+	msg.Strict = topic.Strict || l.raid != nil && l.raid.Paranoid && l.raid.Active(fromChat)
+	// bio is fetched for not approved users only, profile of approved users is not checked
+	if l.Profile.FetchBio && msg.From.ID != 0 && msg.SenderChat.ID == 0 && !g.bot.IsApprovedUser(msg.From.ID) &&
+		!l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
+		msg.From.Bio = l.userBio(ctx, msg.From.ID)
+	}
+	resp := g.bot.OnMessage(ctx, *msg, false)
+	if resp.Send && topic.Action.MoreSevere(resp.SpamAction()) {
+		log.Printf("[DEBUG] spam action in topic %d changed from %s to %s", msg.ThreadID, resp.SpamAction(), topic.Action)
+		resp = resp.WithSpamAction(topic.Action)
+	}
+
+	if !resp.Send { // not spam, apply escalation for strikes added outside the listener, e.g. by web UI
+		return l.procPendingStrikes(ctx, g, msg, fromChat)
+	}
 
-	// send response to the channel if allowed
-	if resp.Send && !l.NoSpamReply && !l.TrainingMode {
+	// add strike for detected spam and escalate the action if the user has enough active strikes
+	if g.strikes != nil && !l.TrainingMode && !resp.ReportOnly && resp.ChannelID == 0 && msg.From.ID != 0 &&
+		!l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
+		resp = l.escalate(ctx, g, msg, resp)
+	}
+	if resp.Warn {
+		resp.Text = warnText(msg.From, g.warnMsg)
+	}
+
+	// send response to the channel if allowed, report-only spam is not announced in the chat, warnings are always sent
+	if resp.Send && (!l.NoSpamReply || resp.Warn) && !l.TrainingMode && !resp.ReportOnly {
 		if err := l.sendBotResponse(resp, fromChat, NotificationSilent); err != nil {
 			log.Printf("[WARN] failed to respond on update, %v", err)
 		}
@@ -239,63 +428,198 @@ func (l *TelegramListener) procEvents(update tbapi.Update) error {
 
 	errs := new(multierror.Error)
 
-	// ban user if requested by bot
-	if resp.Send && resp.BanInterval > 0 {
-		log.Printf("[DEBUG] ban initiated for %+v", resp)
-		l.SpamLogger.Save(msg, &resp)
-		if err := l.Locator.AddSpam(ctx, msg.From.ID, resp.CheckResults); err != nil {
+	// spam detected, the action is defined by the bot's response: ban, mute, warn, delete or report only
+	if resp.Send && (resp.BanInterval > 0 || resp.DeleteReplyTo || resp.ReportOnly) {
+		log.Printf("[DEBUG] spam action initiated for %+v", resp)
+		g.spamLogger.Save(msg, &resp)
+		if err := g.locator.AddSpam(ctx, msg.From.ID, resp.CheckResults); err != nil {
 			log.Printf("[WARN] failed to add spam to locator: %v", err)
 		}
 		banUserStr := l.getBanUsername(resp, update)
 
 		if l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
 			if l.TrainingMode {
-				l.adminHandler.ReportBan(banUserStr, msg)
+				g.admin.ReportBan(banUserStr, msg)
 			}
 			log.Printf("[DEBUG] superuser %s requested ban, ignored", banUserStr)
 			return nil
 		}
 
+		if err := l.applySpamAction(g, msg, resp, banUserStr, fromChat); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		l.reportCampaign(g, msg, resp)
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// procEditedMessage re-checks the edited message as a new one. Edits of approved users are skipped,
+// unless the user was approved within EditRecheckWindow. Edits of superusers are never checked.
+// Edits don't count toward rate limits and are not added to locator, as the message was counted and added when posted.
+func (l *TelegramListener) procEditedMessage(ctx context.Context, update tbapi.Update) error {
+	msg := update.EditedMessage
+	if msg.From == nil || !l.isChatAllowed(msg.Chat.ID) {
+		return nil
+	}
+	if l.SuperUsers.IsSuper(msg.From.UserName, msg.From.ID) {
+		return nil
+	}
+	if approvedAt, ok := l.group(msg.Chat.ID).bot.ApprovedAt(msg.From.ID); ok {
+		if l.EditRecheckWindow <= 0 || time.Since(approvedAt) > l.EditRecheckWindow {
+			log.Printf("[DEBUG] skip edited message %d of approved user %q (%d)", msg.MessageID, msg.From.UserName, msg.From.ID)
+			return nil
+		}
+		log.Printf("[DEBUG] re-check edited message %d of user %q (%d) approved at %s",
+			msg.MessageID, msg.From.UserName, msg.From.ID, approvedAt.Format(time.RFC3339))
+	}
+	// edited message has EditDate set, so it is checked as edited and doesn't count toward approval
+	return l.procEvents(ctx, tbapi.Update{UpdateID: update.UpdateID, Message: msg})
+}
+
+// applySpamAction bans, mutes or reports the user as defined by the response and deletes the message if requested
+func (l *TelegramListener) applySpamAction(g *chatGroup, msg *bot.Message, resp bot.Response, banUserStr string, fromChat int64) error {
+	errs := new(multierror.Error)
+	switch {
+	case resp.BanInterval > 0: // ban or mute user
 		banReq := banRequest{duration: resp.BanInterval, userID: resp.User.ID, channelID: resp.ChannelID, userName: banUserStr,
-			chatID: fromChat, dry: l.Dry, training: l.TrainingMode, tbAPI: l.TbAPI, restrict: l.SoftBanMode}
+			chatID: fromChat, dry: l.Dry, training: l.TrainingMode, tbAPI: l.TbAPI, restrict: l.SoftBanMode || resp.Restrict}
 		if err := banUserOrChannel(banReq); err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("failed to ban %s: %w", banUserStr, err))
 		} else if l.adminChatID != 0 && msg.From.ID != 0 {
-			l.adminHandler.ReportBan(banUserStr, msg)
+			g.admin.ReportSpam(banUserStr, msg, spamActionText(resp))
 		}
+	case l.adminChatID != 0 && msg.From.ID != 0: // warn, delete only or report only
+		g.admin.ReportSpam(banUserStr, msg, spamActionText(resp))
 	}
 
 	// delete message if requested by bot
-	if resp.DeleteReplyTo && resp.ReplyTo != 0 && !l.Dry && !l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) && !l.TrainingMode {
-		if _, err := l.TbAPI.Request(tbapi.DeleteMessageConfig{BaseChatMessage: tbapi.BaseChatMessage{
-			MessageID:  resp.ReplyTo,
-			ChatConfig: tbapi.ChatConfig{ChatID: l.chatID},
-		}}); err != nil {
-			errs = multierror.Append(errs, fmt.Errorf("failed to delete message %d: %w", resp.ReplyTo, err))
+	if resp.DeleteReplyTo && resp.ReplyTo != 0 {
+		if err := l.deleteMessage(g, msg, resp.ReplyTo); err != nil {
+			errs = multierror.Append(errs, err)
 		}
 	}
-
 	return errs.ErrorOrNil()
 }
 
+// deleteMessage deletes the message of the group, messages of superusers are not deleted, as well as anything
+// in dry and training modes
+func (l *TelegramListener) deleteMessage(g *chatGroup, msg *bot.Message, msgID int) error {
+	if l.Dry || l.TrainingMode || l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
+		return nil
+	}
+	if _, err := l.TbAPI.Request(tbapi.DeleteMessageConfig{BaseChatMessage: tbapi.BaseChatMessage{
+		MessageID:  msgID,
+		ChatConfig: tbapi.ChatConfig{ChatID: g.chatID},
+	}}); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", msgID, err)
+	}
+	return nil
+}
+
+// escalate adds a strike for detected spam and escalates the response to the ladder step
+// for the number of active strikes, if the step is more severe than the detected action
+func (l *TelegramListener) escalate(ctx context.Context, g *chatGroup, msg *bot.Message, resp bot.Response) bot.Response {
+	count, err := g.strikes.Add(ctx, storage.StrikeInfo{UserID: msg.From.ID, UserName: msg.From.Username,
+		Source: "detector", Reason: "spam detected, " + resp.SpamAction().String(), Applied: true})
+	if err != nil {
+		log.Printf("[WARN] failed to add strike for %d: %v", msg.From.ID, err)
+		return resp
+	}
+	// strikes added by web UI are covered by this escalation as well
+	if err := g.strikes.MarkApplied(ctx, msg.From.ID); err != nil {
+		log.Printf("[WARN] failed to mark strikes applied for %d: %v", msg.From.ID, err)
+	}
+
+	step := l.StrikesLadder.Step(count)
+	if !step.MoreSevere(resp.SpamAction()) {
+		return resp
+	}
+	log.Printf("[INFO] escalate %s for %q (%d) to %s, active strikes: %d",
+		resp.SpamAction(), msg.From.Username, msg.From.ID, step, count)
+	return resp.WithSpamAction(step)
+}
+
+// procPendingStrikes applies the ladder step for strikes not applied yet, i.e. added by web UI.
+// the message itself is not spam and is not deleted. Strikes storage keeps users with pending strikes in memory,
+// so messages of other users don't make a query.
+func (l *TelegramListener) procPendingStrikes(ctx context.Context, g *chatGroup, msg *bot.Message, fromChat int64) error {
+	if g.strikes == nil || l.TrainingMode || msg.From.ID == 0 || l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
+		return nil
+	}
+	count, pending, err := g.strikes.Pending(ctx, msg.From.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get pending strikes: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+	if err := g.strikes.MarkApplied(ctx, msg.From.ID); err != nil {
+		return fmt.Errorf("failed to mark strikes applied: %w", err)
+	}
+
+	step := l.StrikesLadder.Step(count)
+	log.Printf("[INFO] pending strikes for %q (%d), active strikes: %d, action: %s", msg.From.Username, msg.From.ID, count, step)
+	resp := bot.Response{Send: true, User: msg.From, ReplyTo: msg.ID, ThreadID: msg.ThreadID}.WithSpamAction(step)
+	resp.DeleteReplyTo = false
+	if resp.Warn {
+		resp.Text = warnText(msg.From, g.warnMsg)
+		if err := l.sendBotResponse(resp, fromChat, NotificationSilent); err != nil {
+			log.Printf("[WARN] failed to send warning, %v", err)
+		}
+	}
+	if resp.SpamAction().Kind == bot.ActionNone {
+		return nil
+	}
+	return l.applySpamAction(g, msg, resp, fmt.Sprintf("%v", msg.From), fromChat)
+}
+
+// warnText makes a warning message for the user
+func warnText(user bot.User, warnMsg string) string {
+	name := user.Username
+	if name == "" {
+		name = user.DisplayName
+	}
+	return escapeMarkDownV1Text(fmt.Sprintf("@%s %s", name, warnMsg))
+}
+
+// spamActionText returns a description of the action taken on detected spam, used in admin reports
+func spamActionText(resp bot.Response) string {
+	switch {
+	case resp.BanInterval > 0 && resp.Restrict:
+		return fmt.Sprintf("muted for %v", resp.BanInterval)
+	case resp.BanInterval >= bot.PermanentBanDuration:
+		return "permanently banned"
+	case resp.BanInterval > 0:
+		return fmt.Sprintf("banned for %v", resp.BanInterval)
+	case resp.Warn:
+		return "warned"
+	case resp.DeleteReplyTo:
+		return "deleted message from"
+	default:
+		return "reported spam from"
+	}
+}
+
 // procSuperReply processes superuser commands (reply) /spam, /ban, /warn
-func (l *TelegramListener) procSuperReply(update tbapi.Update) (handled bool) {
+func (l *TelegramListener) procSuperReply(ctx context.Context, update tbapi.Update) (handled bool) {
+	adm := l.group(update.Message.Chat.ID).admin
 	switch {
 	case strings.EqualFold(update.Message.Text, "/spam") || strings.EqualFold(update.Message.Text, "spam"):
 		log.Printf("[DEBUG] superuser %s reported spam", update.Message.From.UserName)
-		if err := l.adminHandler.DirectSpamReport(update); err != nil {
+		if err := adm.DirectSpamReport(ctx, update); err != nil {
 			log.Printf("[WARN] failed to process direct spam report: %v", err)
 		}
 		return true
 	case strings.EqualFold(update.Message.Text, "/ban") || strings.EqualFold(update.Message.Text, "ban"):
 		log.Printf("[DEBUG] superuser %s requested ban", update.Message.From.UserName)
-		if err := l.adminHandler.DirectBanReport(update); err != nil {
+		if err := adm.DirectBanReport(ctx, update); err != nil {
 			log.Printf("[WARN] failed to process direct ban request: %v", err)
 		}
 		return true
 	case strings.EqualFold(update.Message.Text, "/warn") || strings.EqualFold(update.Message.Text, "warn"):
 		log.Printf("[DEBUG] superuser %s requested warning", update.Message.From.UserName)
-		if err := l.adminHandler.DirectWarnReport(update); err != nil {
+		if err := adm.DirectWarnReport(ctx, update); err != nil {
 			log.Printf("[WARN] failed to process direct warning request: %v", err)
 		}
 		return true
@@ -303,8 +627,11 @@ func (l *TelegramListener) procSuperReply(update tbapi.Update) (handled bool) {
 	return false
 }
 
-// procNewChatMemberMessage saves new chat member message to locator. It is used to delete the message if the user kicked out
-func (l *TelegramListener) procNewChatMemberMessage(update tbapi.Update) error {
+// procNewChatMemberMessage saves new chat member message to locator. It is used to delete the message if the user kicked out.
+// If profile check is enabled, the new member with spam profile is banned. Otherwise, if join challenge is enabled,
+// the new member is restricted until the challenge is solved. If raid detection is enabled, the join is counted,
+// and the chat is switched to lockdown on too many joins.
+func (l *TelegramListener) procNewChatMemberMessage(ctx context.Context, update tbapi.Update) error {
 	fromChat := update.Message.Chat.ID
 	// ignore messages from other chats except the one we are monitor and ones from the test list
 	if !l.isChatAllowed(fromChat) {
@@ -319,16 +646,50 @@ func (l *TelegramListener) procNewChatMemberMessage(update tbapi.Update) error {
 	errs := new(multierror.Error)
 
 	member := update.Message.NewChatMembers[0]
-	msg := fmt.Sprintf("new_%d_%d", fromChat, member.ID)
-	if err := l.Locator.AddMessage(context.TODO(), msg, fromChat, member.ID, "", update.Message.MessageID); err != nil {
+	msg := storage.JoinRecord(fromChat, member.ID)
+	g := l.group(fromChat)
+	if err := g.locator.AddMessage(ctx, msg, fromChat, member.ID, "", update.Message.MessageID); err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("failed to add new chat member message to locator: %w", err))
 	}
+	if l.raid != nil && !l.Dry {
+		if err := l.raid.Join(fromChat, g.name); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to start lockdown: %w", err))
+		}
+	}
+
+	spam, err := l.procNewMemberProfile(ctx, g, update.Message.From, member, update.Message.MessageID)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("failed to check new chat member profile: %w", err))
+	}
+
+	challenge := l.Captcha.Enabled || l.raid != nil && l.raid.Challenge && l.raid.Active(fromChat)
+	if !spam && challenge && l.needsChallenge(g, update.Message.From, member) {
+		if err := l.captcha.Challenge(fromChat, g.bot, member, update.Message.MessageID); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to challenge new chat member: %w", err))
+		}
+	}
 
 	return errs.ErrorOrNil()
 }
 
+// needsChallenge checks if the new member should solve the join challenge. Bots, superusers, approved users
+// and members added by superusers are not challenged, as well as anyone in dry and training modes.
+func (l *TelegramListener) needsChallenge(g *chatGroup, from *tbapi.User, member tbapi.User) bool {
+	if l.captcha == nil || l.Dry || l.TrainingMode || member.IsBot {
+		return false
+	}
+	if l.SuperUsers.IsSuper(member.UserName, member.ID) {
+		return false
+	}
+	if from != nil && from.ID != member.ID && l.SuperUsers.IsSuper(from.UserName, from.ID) {
+		log.Printf("[DEBUG] %q (%d) added by superuser %q, not challenged", member.UserName, member.ID, from.UserName)
+		return false
+	}
+	return !g.bot.IsApprovedUser(member.ID)
+}
+
 // procLeftChatMemberMessage deletes the message about new chat member if the user kicked out
-func (l *TelegramListener) procLeftChatMemberMessage(update tbapi.Update) error {
+func (l *TelegramListener) procLeftChatMemberMessage(ctx context.Context, update tbapi.Update) error {
 	fromChat := update.Message.Chat.ID
 	// ignore messages from other chats except the one we are monitor and ones from the test list
 	if !l.isChatAllowed(fromChat) {
@@ -339,7 +700,7 @@ func (l *TelegramListener) procLeftChatMemberMessage(update tbapi.Update) error
 		log.Printf("[DEBUG] left chat member is the same as the message sender, ignored")
 		return nil
 	}
-	msg, found := l.Locator.Message(context.TODO(), fmt.Sprintf("new_%d_%d", fromChat, update.Message.LeftChatMember.ID))
+	msg, found := l.group(fromChat).locator.Message(ctx, storage.JoinRecord(fromChat, update.Message.LeftChatMember.ID))
 	if !found {
 		log.Printf("[DEBUG] no new chat member message found for %d in chat %d", update.Message.LeftChatMember.ID, fromChat)
 		return nil
@@ -357,6 +718,9 @@ func (l *TelegramListener) isChatAllowed(fromChat int64) bool {
 	if fromChat == l.chatID {
 		return true
 	}
+	if _, ok := l.groups[fromChat]; ok {
+		return true
+	}
 	for _, id := range l.TestingIDs {
 		if id == fromChat {
 			return true
@@ -365,6 +729,54 @@ func (l *TelegramListener) isChatAllowed(fromChat int64) bool {
 	return false
 }
 
+// makeAdmin creates admin handler for the group, groupName is set for multi-group setup only
+func (l *TelegramListener) makeAdmin(g *chatGroup, groupName string) *admin {
+	return &admin{tbAPI: l.TbAPI, bot: g.bot, locator: g.locator, primChatID: g.chatID, adminChatID: l.adminChatID,
+		superUsers: l.SuperUsers, trainingMode: l.TrainingMode, softBan: l.SoftBanMode, dry: l.Dry, warnMsg: g.warnMsg,
+		strikes: g.strikes, strikesLadder: l.StrikesLadder, groupName: groupName}
+}
+
+// group returns the group for the chat ID, primary group is returned for unknown chats, e.g. testing ones
+func (l *TelegramListener) group(chatID int64) *chatGroup {
+	if g, ok := l.groups[chatID]; ok {
+		return g
+	}
+	return &chatGroup{name: l.Group, chatID: l.chatID, bot: l.Bot, spamLogger: l.SpamLogger, locator: l.Locator,
+		strikes: l.Strikes, warnMsg: l.WarnMsg, topics: l.Topics, admin: l.adminHandler}
+}
+
+// allGroups returns the primary group followed by additional groups
+func (l *TelegramListener) allGroups() []*chatGroup {
+	res := []*chatGroup{l.group(l.chatID)}
+	for _, g := range l.groups {
+		res = append(res, g)
+	}
+	return res
+}
+
+// adminGroup returns the group a message forwarded to admin chat belongs to.
+// the group is detected by the locator, primary group is used if the message is not found in additional groups.
+func (l *TelegramListener) adminGroup(ctx context.Context, update tbapi.Update) *chatGroup {
+	if len(l.groups) == 0 || update.Message == nil {
+		return l.group(l.chatID)
+	}
+	msgTxt := update.Message.Text
+	if msgTxt == "" {
+		msgTxt = transform(update.Message).Text
+	}
+	for _, g := range l.groups {
+		if _, found := g.locator.Message(ctx, msgTxt); found {
+			return g
+		}
+	}
+	return l.group(l.chatID)
+}
+
+// callbackGroup returns the group of admin chat callback by the chat ID in callback data, primary group if not set
+func (l *TelegramListener) callbackGroup(data string) *chatGroup {
+	return l.group(callbackChatID(data))
+}
+
 func (l *TelegramListener) isAdminChat(fromChat int64, from string, fromID int64) bool {
 	if fromChat == l.adminChatID {
 		log.Printf("[DEBUG] message in admin chat %d, from %s (%d)", fromChat, from, fromID)
@@ -423,6 +835,7 @@ func (l *TelegramListener) sendBotResponse(resp bot.Response, chatID int64, noti
 	tbMsg.ParseMode = tbapi.ModeMarkdown
 	tbMsg.LinkPreviewOptions = tbapi.LinkPreviewOptions{IsDisabled: true}
 	tbMsg.ReplyParameters = tbapi.ReplyParameters{MessageID: resp.ReplyTo}
+	tbMsg.MessageThreadID = resp.ThreadID
 	tbMsg.DisableNotification = notifyType == NotificationSilent
 
 	if err := send(tbMsg, l.TbAPI); err != nil {
@@ -448,6 +861,7 @@ func (l *TelegramListener) getChatID(group string) (int64, error) {
 
 // updateSupers updates the list of super-users based on the chat administrators fetched from the Telegram API.
 // it uses the user ID first, but can match by username if set in the list of super-users.
+// admins of additional groups are added as well, superusers are shared by all groups.
 func (l *TelegramListener) updateSupers() error {
 	isSuper := func(username string, id int64) bool {
 		for _, super := range l.SuperUsers {
@@ -465,6 +879,13 @@ func (l *TelegramListener) updateSupers() error {
 	if err != nil {
 		return fmt.Errorf("failed to get chat administrators: %w", err)
 	}
+	for chatID := range l.groups {
+		groupAdmins, gErr := l.TbAPI.GetChatAdministrators(tbapi.ChatAdministratorsConfig{ChatConfig: tbapi.ChatConfig{ChatID: chatID}})
+		if gErr != nil {
+			return fmt.Errorf("failed to get chat administrators for %d: %w", chatID, gErr)
+		}
+		admins = append(admins, groupAdmins...)
+	}
 
 	for _, admin := range admins {
 		if admin.User.UserName == "" && admin.User.ID == 0 {
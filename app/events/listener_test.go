@@ -40,7 +40,7 @@ func TestTelegramListener_Do(t *testing.T) {
 			}, nil
 		},
 	}
-	botMock := &mocks.BotMock{OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+	botMock := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 		t.Logf("on-message: %+v", msg)
 		if msg.Text == "text 123" && msg.From.Username == "user" {
 			return bot.Response{Send: true, Text: "bot's answer"}
@@ -93,7 +93,7 @@ func TestTelegramListener_Do(t *testing.T) {
 	assert.Equal(t, "text 123", botMock.OnMessageCalls()[0].Msg.Text)
 	assert.Equal(t, "user", botMock.OnMessageCalls()[0].Msg.From.Username)
 	assert.False(t, botMock.OnMessageCalls()[0].CheckOnly)
-
+	assert.Equal(t, ctx, botMock.OnMessageCalls()[0].Ctx, "checks get the context of Do")
 }
 
 func TestTelegramListener_DoWithBotBan(t *testing.T) {
@@ -112,7 +112,7 @@ func TestTelegramListener_DoWithBotBan(t *testing.T) {
 			return nil, nil
 		},
 	}
-	botMock := &mocks.BotMock{OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+	botMock := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 		t.Logf("on-message: %+v", msg)
 		if msg.Text == "text 123" && msg.From.Username == "user" {
 			return bot.Response{Send: true, Text: "bot's answer", BanInterval: 2 * time.Minute,
@@ -303,7 +303,7 @@ func TestTelegramListener_DoWithBotSoftBan(t *testing.T) {
 			return nil, nil
 		},
 	}
-	botMock := &mocks.BotMock{OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+	botMock := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 		t.Logf("on-message: %+v", msg)
 		if msg.Text == "text 123" && msg.From.Username == "user" {
 			return bot.Response{Send: true, Text: "bot's answer", BanInterval: 2 * time.Minute,
@@ -383,7 +383,7 @@ func TestTelegramListener_DoWithTraining(t *testing.T) {
 			return nil, nil
 		},
 	}
-	botMock := &mocks.BotMock{OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+	botMock := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 		t.Logf("on-message: %+v", msg)
 		return bot.Response{DeleteReplyTo: true, ReplyTo: msg.ID, ChannelID: msg.ChatID, BanInterval: time.Hour,
 			Send: true, Text: "bot's answer", User: bot.User{Username: "user", ID: 1, DisplayName: "First Last"}}
@@ -452,7 +452,7 @@ func TestTelegramListener_DoDeleteMessages(t *testing.T) {
 			return nil, nil
 		},
 	}
-	b := &mocks.BotMock{OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+	b := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 		t.Logf("on-message: %+v", msg)
 		if msg.Text == "text 123" && msg.From.Username == "user" {
 			return bot.Response{DeleteReplyTo: true, ReplyTo: msg.ID, ChannelID: msg.ChatID, BanInterval: time.Hour,
@@ -507,6 +507,564 @@ func TestTelegramListener_DoDeleteMessages(t *testing.T) {
 	assert.Equal(t, int64(123), mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).ChatID)
 }
 
+func TestTelegramListener_DoWithEditedMessages(t *testing.T) {
+	mockLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+			return nil, nil
+		},
+	}
+	approvedAt := map[int64]time.Time{20: time.Now().Add(-48 * time.Hour), 30: time.Now().Add(-time.Hour)}
+	b := &mocks.BotMock{
+		OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
+			if msg.Text == "spam edit" {
+				return bot.Response{Send: true, Text: "spam", BanInterval: bot.PermanentBanDuration, DeleteReplyTo: true,
+					ReplyTo: msg.ID, User: msg.From}
+			}
+			return bot.Response{}
+		},
+		ApprovedAtFunc: func(userID int64) (time.Time, bool) {
+			ts, ok := approvedAt[userID]
+			return ts, ok
+		},
+	}
+
+	locator, teardown := prepTestLocator(t)
+	defer teardown()
+
+	l := TelegramListener{
+		SpamLogger:        mockLogger,
+		TbAPI:             mockAPI,
+		Bot:               b,
+		SuperUsers:        SuperUsers{"admin"},
+		Group:             "gr",
+		Locator:           locator,
+		NoSpamReply:       true,
+		EditRecheckWindow: 24 * time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	edit := func(userID int64, userName string, msgID int) tbapi.Update {
+		return tbapi.Update{EditedMessage: &tbapi.Message{MessageID: msgID, Chat: tbapi.Chat{ID: 123}, Text: "spam edit",
+			From: &tbapi.User{ID: userID, UserName: userName}, EditDate: int(time.Now().Unix())}}
+	}
+	updChan := make(chan tbapi.Update, 5)
+	updChan <- edit(10, "not_approved", 101)
+	updChan <- edit(20, "approved_long_ago", 102)
+	updChan <- edit(30, "approved_recently", 103)
+	updChan <- edit(1, "admin", 104)
+	close(updChan)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	err := l.Do(ctx)
+	assert.EqualError(t, err, "telegram update chan closed")
+
+	require.Len(t, b.OnMessageCalls(), 2, "edits of approved long ago user and superuser are not checked")
+	assert.Equal(t, int64(10), b.OnMessageCalls()[0].Msg.From.ID)
+	assert.Equal(t, int64(30), b.OnMessageCalls()[1].Msg.From.ID)
+	for _, call := range b.OnMessageCalls() {
+		assert.True(t, call.Msg.Edited)
+		assert.False(t, call.CheckOnly)
+	}
+
+	// ban and delete for each re-checked edit
+	require.Len(t, mockAPI.RequestCalls(), 4)
+	assert.Equal(t, int64(10), mockAPI.RequestCalls()[0].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.Equal(t, 101, mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).MessageID)
+	assert.Equal(t, int64(30), mockAPI.RequestCalls()[2].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.Equal(t, 103, mockAPI.RequestCalls()[3].C.(tbapi.DeleteMessageConfig).MessageID)
+	require.Len(t, mockLogger.SaveCalls(), 2)
+
+	_, found := locator.Message(ctx, "spam edit")
+	assert.False(t, found, "edited message not added to locator")
+
+	t.Run("window disabled", func(t *testing.T) {
+		b.ResetCalls()
+		l.EditRecheckWindow = 0
+		updChan = make(chan tbapi.Update, 2)
+		updChan <- edit(30, "approved_recently", 105)
+		updChan <- edit(40, "new_user", 106)
+		close(updChan)
+		err := l.Do(ctx)
+		assert.EqualError(t, err, "telegram update chan closed")
+		require.Len(t, b.OnMessageCalls(), 1)
+		assert.Equal(t, int64(40), b.OnMessageCalls()[0].Msg.From.ID)
+	})
+}
+
+func TestTelegramListener_DoWithTopics(t *testing.T) {
+	mockLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+			return nil, nil
+		},
+	}
+	b := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
+		if msg.Text == "spam" {
+			return bot.Response{Send: true, Text: "spam detected", ReplyTo: msg.ID, ThreadID: msg.ThreadID,
+				User: msg.From}.WithSpamAction(bot.Action{Kind: bot.ActionDelete})
+		}
+		return bot.Response{}
+	}}
+
+	locator, teardown := prepTestLocator(t)
+	defer teardown()
+
+	l := TelegramListener{
+		SpamLogger: mockLogger,
+		TbAPI:      mockAPI,
+		Bot:        b,
+		Group:      "gr",
+		Locator:    locator,
+		Topics: TopicPolicies{
+			10: {Exempt: true},
+			20: {Strict: true, Action: bot.Action{Kind: bot.ActionBan}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	topicMsg := func(msgID, topicID int, userID int64, text string) tbapi.Update {
+		return tbapi.Update{Message: &tbapi.Message{MessageID: msgID, Chat: tbapi.Chat{ID: 123}, Text: text,
+			From: &tbapi.User{ID: userID, UserName: fmt.Sprintf("user%d", userID)}, MessageThreadID: topicID,
+			IsTopicMessage: topicID != 0}}
+	}
+	updChan := make(chan tbapi.Update, 5)
+	updChan <- topicMsg(101, 10, 1, "spam") // exempt topic
+	updChan <- topicMsg(102, 20, 2, "spam") // strict topic, ban
+	updChan <- topicMsg(103, 30, 3, "spam") // topic without policy, delete
+	updChan <- topicMsg(104, 20, 4, "ham")  // strict topic, not spam
+	close(updChan)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	err := l.Do(ctx)
+	assert.EqualError(t, err, "telegram update chan closed")
+
+	require.Len(t, b.OnMessageCalls(), 3, "message in exempt topic not checked")
+	assert.Equal(t, 102, b.OnMessageCalls()[0].Msg.ID)
+	assert.Equal(t, 20, b.OnMessageCalls()[0].Msg.ThreadID)
+	assert.True(t, b.OnMessageCalls()[0].Msg.Strict)
+	assert.Equal(t, 103, b.OnMessageCalls()[1].Msg.ID)
+	assert.False(t, b.OnMessageCalls()[1].Msg.Strict)
+	assert.True(t, b.OnMessageCalls()[2].Msg.Strict)
+
+	// replies sent to the topics of the messages
+	require.Len(t, mockAPI.SendCalls(), 2)
+	assert.Equal(t, 20, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).MessageThreadID)
+	assert.Equal(t, 102, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).ReplyParameters.MessageID)
+	assert.Equal(t, 30, mockAPI.SendCalls()[1].C.(tbapi.MessageConfig).MessageThreadID)
+
+	// spam in strict topic banned, spam in the topic without policy deleted only
+	require.Len(t, mockAPI.RequestCalls(), 3)
+	assert.Equal(t, int64(2), mockAPI.RequestCalls()[0].C.(tbapi.BanChatMemberConfig).UserID)
+	assert.Equal(t, 102, mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).MessageID)
+	assert.Equal(t, 103, mockAPI.RequestCalls()[2].C.(tbapi.DeleteMessageConfig).MessageID)
+}
+
+func TestTelegramListener_DoWithPolicyActions(t *testing.T) {
+	tbl := []struct {
+		name         string
+		resp         bot.Response
+		wantRequests []string // types of requests in order
+		wantReply    bool
+		wantReport   string
+	}{
+		{
+			name:         "mute",
+			resp:         bot.Response{BanInterval: 24 * time.Hour, Restrict: true, DeleteReplyTo: true},
+			wantRequests: []string{"tgbotapi.RestrictChatMemberConfig", "tgbotapi.DeleteMessageConfig"},
+			wantReply:    true, wantReport: "muted for 24h0m0s",
+		},
+		{
+			name:         "delete only",
+			resp:         bot.Response{DeleteReplyTo: true},
+			wantRequests: []string{"tgbotapi.DeleteMessageConfig"},
+			wantReply:    true, wantReport: "deleted message from",
+		},
+		{
+			name:         "report only",
+			resp:         bot.Response{ReportOnly: true},
+			wantRequests: []string{},
+			wantReply:    false, wantReport: "reported spam from",
+		},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+			mockAPI := &mocks.TbAPIMock{
+				GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+					return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+				},
+				SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+					return tbapi.Message{Text: c.(tbapi.MessageConfig).Text}, nil
+				},
+				RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+					return &tbapi.APIResponse{Ok: true}, nil
+				},
+				GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+					return nil, nil
+				},
+			}
+			b := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
+				if msg.Text != "text 123" {
+					return bot.Response{}
+				}
+				resp := tt.resp
+				resp.Send, resp.Text, resp.ReplyTo = true, "bot's answer", msg.ID
+				resp.User = bot.User{Username: "user", ID: 1}
+				resp.CheckResults = []spamcheck.Response{{Name: "check", Spam: true}}
+				return resp
+			}}
+
+			locator, teardown := prepTestLocator(t)
+			defer teardown()
+
+			l := TelegramListener{
+				SpamLogger: mockLogger,
+				TbAPI:      mockAPI,
+				Bot:        b,
+				Group:      "gr",
+				AdminGroup: "456",
+				Locator:    locator,
+			}
+
+			updChan := make(chan tbapi.Update, 1)
+			updChan <- tbapi.Update{Message: &tbapi.Message{MessageID: 321, Chat: tbapi.Chat{ID: 123}, Text: "text 123",
+				From: &tbapi.User{UserName: "user", ID: 1}, Date: int(time.Now().Unix())}}
+			close(updChan)
+			mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+			err := l.Do(context.Background())
+			assert.EqualError(t, err, "telegram update chan closed")
+			require.Len(t, mockLogger.SaveCalls(), 1, "spam is logged for any action")
+
+			requests := []string{}
+			for _, c := range mockAPI.RequestCalls() {
+				requests = append(requests, fmt.Sprintf("%T", c.C))
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+
+			sent := []tbapi.MessageConfig{}
+			for _, c := range mockAPI.SendCalls() {
+				sent = append(sent, c.C.(tbapi.MessageConfig))
+			}
+			wantSent := 1
+			if tt.wantReply {
+				wantSent = 2
+				assert.Equal(t, "bot's answer", sent[0].Text)
+				assert.Equal(t, int64(123), sent[0].ChatID)
+			}
+			require.Len(t, sent, wantSent)
+			report := sent[len(sent)-1]
+			assert.Equal(t, int64(456), report.ChatID)
+			assert.Contains(t, report.Text, tt.wantReport)
+		})
+	}
+}
+
+func TestTelegramListener_DoWithStrikes(t *testing.T) {
+	ladder := bot.Ladder{{Kind: bot.ActionWarn}, {Kind: bot.ActionMute, Duration: time.Hour}, {Kind: bot.ActionBan}}
+	tbl := []struct {
+		name         string
+		resp         bot.Response // bot's response, not spam if Send is false
+		strikes      int          // active strikes, returned by Add or Pending
+		pending      bool
+		wantRequests []string // types of requests in order
+		wantReply    string
+		wantReport   string
+		wantAdded    int
+	}{
+		{
+			name:         "first strike escalates delete to warn",
+			resp:         bot.Response{Send: true, DeleteReplyTo: true},
+			strikes:      1,
+			wantRequests: []string{"tgbotapi.DeleteMessageConfig"},
+			wantReply:    "@user be nice", wantReport: "warned", wantAdded: 1,
+		},
+		{
+			name:         "third strike escalates delete to ban",
+			resp:         bot.Response{Send: true, DeleteReplyTo: true},
+			strikes:      3,
+			wantRequests: []string{"tgbotapi.BanChatMemberConfig", "tgbotapi.DeleteMessageConfig"},
+			wantReply:    "bot's answer", wantReport: "permanently banned", wantAdded: 1,
+		},
+		{
+			name:         "detected action more severe than ladder step",
+			resp:         bot.Response{Send: true, BanInterval: bot.PermanentBanDuration, DeleteReplyTo: true},
+			strikes:      1,
+			wantRequests: []string{"tgbotapi.BanChatMemberConfig", "tgbotapi.DeleteMessageConfig"},
+			wantReply:    "bot's answer", wantReport: "permanently banned", wantAdded: 1,
+		},
+		{
+			name:         "pending strike for ham message",
+			resp:         bot.Response{},
+			strikes:      2,
+			pending:      true,
+			wantRequests: []string{"tgbotapi.RestrictChatMemberConfig"},
+			wantReport:   "muted for 1h0m0s",
+		},
+		{
+			name:         "no pending strikes for ham message",
+			resp:         bot.Response{},
+			strikes:      2,
+			wantRequests: []string{},
+		},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+			mockAPI := &mocks.TbAPIMock{
+				GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+					return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+				},
+				SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+					return tbapi.Message{Text: c.(tbapi.MessageConfig).Text}, nil
+				},
+				RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+					return &tbapi.APIResponse{Ok: true}, nil
+				},
+				GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+					return nil, nil
+				},
+			}
+			b := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
+				if msg.Text != "text 123" || !tt.resp.Send {
+					return bot.Response{}
+				}
+				resp := tt.resp
+				resp.Text, resp.ReplyTo = "bot's answer", msg.ID
+				resp.User = bot.User{Username: "user", ID: 1}
+				return resp
+			}}
+			strikes := &mocks.StrikesMock{
+				AddFunc:         func(ctx context.Context, strike storage.StrikeInfo) (int, error) { return tt.strikes, nil },
+				PendingFunc:     func(ctx context.Context, userID int64) (int, bool, error) { return tt.strikes, tt.pending, nil },
+				MarkAppliedFunc: func(ctx context.Context, userID int64) error { return nil },
+			}
+
+			locator, teardown := prepTestLocator(t)
+			defer teardown()
+
+			l := TelegramListener{
+				SpamLogger:    mockLogger,
+				TbAPI:         mockAPI,
+				Bot:           b,
+				Group:         "gr",
+				AdminGroup:    "456",
+				Locator:       locator,
+				WarnMsg:       "be nice",
+				Strikes:       strikes,
+				StrikesLadder: ladder,
+			}
+
+			updChan := make(chan tbapi.Update, 1)
+			updChan <- tbapi.Update{Message: &tbapi.Message{MessageID: 321, Chat: tbapi.Chat{ID: 123}, Text: "text 123",
+				From: &tbapi.User{UserName: "user", ID: 1}, Date: int(time.Now().Unix())}}
+			close(updChan)
+			mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+			err := l.Do(context.Background())
+			assert.EqualError(t, err, "telegram update chan closed")
+
+			require.Len(t, strikes.AddCalls(), tt.wantAdded)
+			if tt.wantAdded > 0 {
+				assert.Equal(t, int64(1), strikes.AddCalls()[0].Strike.UserID)
+				assert.Equal(t, "detector", strikes.AddCalls()[0].Strike.Source)
+				assert.True(t, strikes.AddCalls()[0].Strike.Applied)
+				assert.Empty(t, strikes.PendingCalls())
+			}
+			if tt.pending {
+				require.Len(t, strikes.MarkAppliedCalls(), 1)
+				assert.Equal(t, int64(1), strikes.MarkAppliedCalls()[0].UserID)
+			}
+
+			requests := []string{}
+			for _, c := range mockAPI.RequestCalls() {
+				requests = append(requests, fmt.Sprintf("%T", c.C))
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+
+			sent := []tbapi.MessageConfig{}
+			for _, c := range mockAPI.SendCalls() {
+				sent = append(sent, c.C.(tbapi.MessageConfig))
+			}
+			if tt.wantReport == "" {
+				assert.Empty(t, sent)
+				return
+			}
+			wantSent := 1
+			if tt.wantReply != "" {
+				wantSent = 2
+				assert.Equal(t, tt.wantReply, sent[0].Text)
+				assert.Equal(t, int64(123), sent[0].ChatID)
+			}
+			require.Len(t, sent, wantSent)
+			report := sent[len(sent)-1]
+			assert.Equal(t, int64(456), report.ChatID)
+			assert.Contains(t, report.Text, tt.wantReport)
+		})
+	}
+}
+
+func TestTelegramListener_DoWithMultipleGroups(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) {
+			if mc, ok := c.(tbapi.MessageConfig); ok {
+				return tbapi.Message{Text: mc.Text, From: &tbapi.User{UserName: "user"}}, nil
+			}
+			return tbapi.Message{}, nil
+		},
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+			if config.ChatID == 456 {
+				return []tbapi.ChatMember{{User: &tbapi.User{UserName: "group_admin", ID: 55}}}, nil
+			}
+			return nil, nil
+		},
+	}
+	primaryBot := &mocks.BotMock{OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response { return bot.Response{} }}
+	groupBot := &mocks.BotMock{
+		OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
+			if msg.Text == "spam text" {
+				return bot.Response{Send: true, Text: "bot's answer", BanInterval: bot.PermanentBanDuration,
+					User: bot.User{Username: "user", ID: 1}, ReplyTo: msg.ID, DeleteReplyTo: true}
+			}
+			if msg.Text == "warn text" {
+				return bot.Response{Send: true, Text: "bot's answer", User: bot.User{Username: "user", ID: 1},
+					ReplyTo: msg.ID, DeleteReplyTo: true, Warn: true}
+			}
+			return bot.Response{}
+		},
+		UpdateHamFunc:       func(msg string) error { return nil },
+		AddApprovedUserFunc: func(id int64, name string) error { return nil },
+	}
+	primaryLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+	groupLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+
+	primaryLocator, teardown := prepTestLocator(t)
+	defer teardown()
+	groupLocator, teardown2 := prepTestLocator(t)
+	defer teardown2()
+
+	l := TelegramListener{
+		SpamLogger: primaryLogger,
+		TbAPI:      mockAPI,
+		Bot:        primaryBot,
+		SuperUsers: SuperUsers{"admin"},
+		Group:      "gr",
+		AdminGroup: "789",
+		Locator:    primaryLocator,
+		WarnMsg:    "default warning",
+		Groups: []Group{{Name: "456", Bot: groupBot, SpamLogger: groupLogger, Locator: groupLocator,
+			WarnMsg: "group warning"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Minute)
+	defer cancel()
+
+	t.Run("spam in additional group", func(t *testing.T) {
+		updChan := make(chan tbapi.Update, 1)
+		updChan <- tbapi.Update{Message: &tbapi.Message{MessageID: 10, Chat: tbapi.Chat{ID: 456}, Text: "spam text",
+			From: &tbapi.User{UserName: "user", ID: 1}}}
+		close(updChan)
+		mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+		err := l.Do(ctx)
+		assert.EqualError(t, err, "telegram update chan closed")
+		assert.Empty(t, primaryBot.OnMessageCalls())
+		assert.Empty(t, primaryLogger.SaveCalls())
+		require.Len(t, groupBot.OnMessageCalls(), 1)
+		require.Len(t, groupLogger.SaveCalls(), 1)
+		assert.Contains(t, l.SuperUsers, "55", "admins of additional group are superusers")
+
+		_, found := groupLocator.Message(ctx, "spam text")
+		assert.True(t, found, "message saved to the group's locator")
+		_, found = primaryLocator.Message(ctx, "spam text")
+		assert.False(t, found)
+
+		require.Len(t, mockAPI.SendCalls(), 2)
+		assert.Equal(t, int64(456), mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).ChatID)
+		report := mockAPI.SendCalls()[1].C.(tbapi.MessageConfig)
+		assert.Equal(t, int64(789), report.ChatID)
+		assert.Contains(t, report.Text, "in 456**")
+		kb := report.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+		assert.Equal(t, "?1:10:456", *kb.InlineKeyboard[0][0].CallbackData)
+
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		assert.Equal(t, int64(456), mockAPI.RequestCalls()[0].C.(tbapi.BanChatMemberConfig).ChatID)
+		assert.Equal(t, int64(456), mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).ChatID)
+	})
+
+	t.Run("unban callback routed to the group", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		updChan := make(chan tbapi.Update, 1)
+		updChan <- tbapi.Update{CallbackQuery: &tbapi.CallbackQuery{
+			Data: "1:10:456",
+			Message: &tbapi.Message{MessageID: 987654, Chat: tbapi.Chat{ID: 789},
+				Text: "permanently banned user in 456\n\nspam text", From: &tbapi.User{UserName: "user", ID: 999}},
+			From: &tbapi.User{UserName: "admin", ID: 1000},
+		}}
+		close(updChan)
+		mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+		err := l.Do(ctx)
+		assert.EqualError(t, err, "telegram update chan closed")
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		unban := mockAPI.RequestCalls()[1].C.(tbapi.UnbanChatMemberConfig)
+		assert.Equal(t, int64(1), unban.UserID)
+		assert.Equal(t, int64(456), unban.ChatID)
+		require.Len(t, groupBot.AddApprovedUserCalls(), 1)
+		assert.Empty(t, primaryBot.AddApprovedUserCalls())
+		require.Len(t, groupBot.UpdateHamCalls(), 1)
+		assert.Equal(t, "spam text", groupBot.UpdateHamCalls()[0].Msg)
+	})
+
+	t.Run("warning with group message", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		updChan := make(chan tbapi.Update, 1)
+		updChan <- tbapi.Update{Message: &tbapi.Message{MessageID: 11, Chat: tbapi.Chat{ID: 456}, Text: "warn text",
+			From: &tbapi.User{UserName: "user", ID: 1}}}
+		close(updChan)
+		mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+		err := l.Do(ctx)
+		assert.EqualError(t, err, "telegram update chan closed")
+		require.NotEmpty(t, mockAPI.SendCalls())
+		assert.Equal(t, "@user group warning", mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text)
+	})
+
+	t.Run("duplicate group", func(t *testing.T) {
+		dup := TelegramListener{TbAPI: mockAPI, Bot: primaryBot, Group: "gr",
+			Groups: []Group{{Name: "456", Bot: groupBot}, {Name: "456", Bot: groupBot}}}
+		err := dup.Do(ctx)
+		assert.EqualError(t, err, `group "456" (456) is set more than once`)
+	})
+}
+
 func TestTelegramListener_DoWithForwarded(t *testing.T) {
 	mockLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
 	mockAPI := &mocks.TbAPIMock{
@@ -522,7 +1080,7 @@ func TestTelegramListener_DoWithForwarded(t *testing.T) {
 		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) { return nil, nil },
 	}
 	b := &mocks.BotMock{
-		OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+		OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 			t.Logf("on-message: %+v", msg)
 			if msg.Text == "text 123" && msg.From.Username == "user" {
 				return bot.Response{Send: true, Text: "bot's answer"}
@@ -612,7 +1170,7 @@ func TestTelegramListener_DoWithDirectSpamReport(t *testing.T) {
 		RemoveApprovedUserFunc: func(id int64) error {
 			return nil
 		},
-		OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+		OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 			t.Logf("on-message: %+v", msg)
 			if msg.Text == "text 123" && msg.From.Username == "user" {
 				return bot.Response{Send: true, Text: "bot's answer"}
@@ -705,7 +1263,7 @@ func TestTelegramListener_DoWithDirectWarnReport(t *testing.T) {
 		RemoveApprovedUserFunc: func(id int64) error {
 			return nil
 		},
-		OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+		OnMessageFunc: func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response {
 			t.Logf("on-message: %+v", msg)
 			if msg.Text == "text 123" && msg.From.Username == "user" {
 				return bot.Response{Send: true, Text: "bot's answer"}
@@ -1935,7 +2493,7 @@ func TestProcNewChatMemberMessage(t *testing.T) {
 				chatID:  123,
 			}
 
-			err := l.procNewChatMemberMessage(tt.update)
+			err := l.procNewChatMemberMessage(context.Background(), tt.update)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -2077,7 +2635,7 @@ func TestProcLeftChatMemberMessage(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			err := l.procLeftChatMemberMessage(tt.update)
+			err := l.procLeftChatMemberMessage(context.Background(), tt.update)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
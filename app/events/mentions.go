@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+
+	"github.com/umputun/tg-spam/app/storage"
+	"github.com/umputun/tg-spam/lib/tgspam"
+)
+
+// MentionCache is an interface for the cache of resolved mention targets
+type MentionCache interface {
+	Read(ctx context.Context, username string) (storage.MentionTarget, bool, error)
+	Write(ctx context.Context, target storage.MentionTarget) error
+}
+
+// MentionResolver resolves mentioned usernames with telegram getChat call, implements tgspam.MentionResolver.
+// Public channels and groups are resolved to the chat, users are not visible to the bot, so mentions of users
+// are unknown, and mentions with "bot" suffix are bots, as telegram requires it for bot usernames.
+type MentionResolver struct {
+	TbAPI TbAPI        // telegram bot API
+	Cache MentionCache // cache of resolved targets, optional
+}
+
+// ResolveMention returns the target of mentioned username, from the cache if resolved before
+func (r *MentionResolver) ResolveMention(ctx context.Context, username string) (tgspam.MentionTarget, error) {
+	username = strings.TrimPrefix(username, "@")
+	if username == "" {
+		return tgspam.MentionTarget{}, errors.New("empty username")
+	}
+	if r.Cache != nil {
+		cached, found, err := r.Cache.Read(ctx, username)
+		if err != nil {
+			log.Printf("[WARN] failed to read cached mention target @%s: %v", username, err)
+		}
+		if found {
+			return tgspam.MentionTarget{Username: username, ID: cached.TargetID, Type: cached.TargetType}, nil
+		}
+	}
+
+	res := tgspam.MentionTarget{Username: username, Type: tgspam.MentionTypeUnknown}
+	chat, err := r.TbAPI.GetChat(tbapi.ChatInfoConfig{ChatConfig: tbapi.ChatConfig{SuperGroupUsername: "@" + username}})
+	var tbErr *tbapi.Error
+	switch {
+	case err == nil:
+		res.ID, res.Type = chat.ID, chat.Type
+	case errors.As(err, &tbErr) && tbErr.Code == http.StatusBadRequest:
+		// chat not found, i.e. user or bot, or the username doesn't exist
+	default:
+		return tgspam.MentionTarget{}, fmt.Errorf("failed to get chat @%s: %w", username, err)
+	}
+	if (res.Type == tgspam.MentionTypeUnknown || res.Type == tgspam.MentionTypePrivate) &&
+		strings.HasSuffix(strings.ToLower(username), "bot") {
+		res.Type = tgspam.MentionTypeBot
+	}
+	log.Printf("[DEBUG] mention @%s resolved to %q (%d)", username, res.Type, res.ID)
+
+	if r.Cache != nil {
+		target := storage.MentionTarget{Username: username, TargetID: res.ID, TargetType: res.Type}
+		if err := r.Cache.Write(ctx, target); err != nil {
+			log.Printf("[WARN] failed to cache mention target @%s: %v", username, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+	"github.com/umputun/tg-spam/lib/tgspam"
+)
+
+func TestMentionResolver_ResolveMention(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			switch config.SuperGroupUsername {
+			case "@promo":
+				return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: -1001234567890, Type: "channel"}}, nil
+			case "@robotics":
+				return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: -1001234567891, Type: "supergroup"}}, nil
+			case "@broken":
+				return tbapi.ChatFullInfo{}, errors.New("network error")
+			}
+			return tbapi.ChatFullInfo{}, &tbapi.Error{Code: 400, Message: "Bad Request: chat not found"}
+		},
+	}
+	cached := map[string]storage.MentionTarget{"cached": {Username: "cached", TargetID: 123, TargetType: "channel"}}
+	cache := &mocks.MentionCacheMock{
+		ReadFunc: func(ctx context.Context, username string) (storage.MentionTarget, bool, error) {
+			res, ok := cached[username]
+			return res, ok, nil
+		},
+		WriteFunc: func(ctx context.Context, target storage.MentionTarget) error { return nil },
+	}
+	r := &MentionResolver{TbAPI: mockAPI, Cache: cache}
+
+	tbl := []struct {
+		username string
+		want     tgspam.MentionTarget
+	}{
+		{"@promo", tgspam.MentionTarget{Username: "promo", ID: -1001234567890, Type: tgspam.MentionTypeChannel}},
+		{"robotics", tgspam.MentionTarget{Username: "robotics", ID: -1001234567891, Type: tgspam.MentionTypeSupergroup}},
+		{"SomeBot", tgspam.MentionTarget{Username: "SomeBot", Type: tgspam.MentionTypeBot}},
+		{"someone", tgspam.MentionTarget{Username: "someone", Type: tgspam.MentionTypeUnknown}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.username, func(t *testing.T) {
+			mockAPI.ResetCalls()
+			cache.ResetCalls()
+			res, err := r.ResolveMention(context.Background(), tt.username)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res)
+			assert.Len(t, mockAPI.GetChatCalls(), 1)
+			require.Len(t, cache.WriteCalls(), 1)
+			assert.Equal(t, storage.MentionTarget{Username: tt.want.Username, TargetID: tt.want.ID, TargetType: tt.want.Type},
+				cache.WriteCalls()[0].Target)
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		cache.ResetCalls()
+		res, err := r.ResolveMention(context.Background(), "cached")
+		require.NoError(t, err)
+		assert.Equal(t, tgspam.MentionTarget{Username: "cached", ID: 123, Type: tgspam.MentionTypeChannel}, res)
+		assert.Empty(t, mockAPI.GetChatCalls())
+		assert.Empty(t, cache.WriteCalls())
+	})
+
+	t.Run("failed, not cached", func(t *testing.T) {
+		cache.ResetCalls()
+		_, err := r.ResolveMention(context.Background(), "broken")
+		require.EqualError(t, err, "failed to get chat @broken: network error")
+		assert.Empty(t, cache.WriteCalls())
+
+		_, err = r.ResolveMention(context.Background(), "@")
+		require.Error(t, err)
+	})
+
+	t.Run("without cache", func(t *testing.T) {
+		res, err := (&MentionResolver{TbAPI: mockAPI}).ResolveMention(context.Background(), "promo")
+		require.NoError(t, err)
+		assert.Equal(t, tgspam.MentionTypeChannel, res.Type)
+	})
+}
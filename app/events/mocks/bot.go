@@ -4,8 +4,10 @@
 package mocks
 
 import (
+	"context"
 	"github.com/umputun/tg-spam/app/bot"
 	"sync"
+	"time"
 )
 
 // BotMock is a mock implementation of events.Bot.
@@ -17,12 +19,24 @@ import (
 //			AddApprovedUserFunc: func(id int64, name string) error {
 //				panic("mock out the AddApprovedUser method")
 //			},
+//			ApprovedAtFunc: func(userID int64) (time.Time, bool) {
+//				panic("mock out the ApprovedAt method")
+//			},
+//			CheckUserFunc: func(ctx context.Context, user bot.User) bot.Response {
+//				panic("mock out the CheckUser method")
+//			},
+//			CountCleanMessageFunc: func(id int64, name string) {
+//				panic("mock out the CountCleanMessage method")
+//			},
 //			IsApprovedUserFunc: func(userID int64) bool {
 //				panic("mock out the IsApprovedUser method")
 //			},
-//			OnMessageFunc: func(msg bot.Message, checkOnly bool) bot.Response {
+//			OnMessageFunc: func(ctx context.Context, msg bot.Message, checkOnly bool) bot.Response {
 //				panic("mock out the OnMessage method")
 //			},
+//			ReloadDomainsFunc: func() error {
+//				panic("mock out the ReloadDomains method")
+//			},
 //			RemoveApprovedUserFunc: func(id int64) error {
 //				panic("mock out the RemoveApprovedUser method")
 //			},
@@ -42,11 +56,23 @@ type BotMock struct {
 	// AddApprovedUserFunc mocks the AddApprovedUser method.
 	AddApprovedUserFunc func(id int64, name string) error
 
+	// ApprovedAtFunc mocks the ApprovedAt method.
+	ApprovedAtFunc func(userID int64) (time.Time, bool)
+
+	// CheckUserFunc mocks the CheckUser method.
+	CheckUserFunc func(ctx context.Context, user bot.User) bot.Response
+
+	// CountCleanMessageFunc mocks the CountCleanMessage method.
+	CountCleanMessageFunc func(id int64, name string)
+
 	// IsApprovedUserFunc mocks the IsApprovedUser method.
 	IsApprovedUserFunc func(userID int64) bool
 
 	// OnMessageFunc mocks the OnMessage method.
-	OnMessageFunc func(msg bot.Message, checkOnly bool) bot.Response
+	OnMessageFunc func(ctx context.Context, msg bot.Message, checkOnly bool) bot.Response
+
+	// ReloadDomainsFunc mocks the ReloadDomains method.
+	ReloadDomainsFunc func() error
 
 	// RemoveApprovedUserFunc mocks the RemoveApprovedUser method.
 	RemoveApprovedUserFunc func(id int64) error
@@ -66,6 +92,25 @@ type BotMock struct {
 			// Name is the name argument value.
 			Name string
 		}
+		// ApprovedAt holds details about calls to the ApprovedAt method.
+		ApprovedAt []struct {
+			// UserID is the userID argument value.
+			UserID int64
+		}
+		// CheckUser holds details about calls to the CheckUser method.
+		CheckUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// User is the user argument value.
+			User bot.User
+		}
+		// CountCleanMessage holds details about calls to the CountCleanMessage method.
+		CountCleanMessage []struct {
+			// ID is the id argument value.
+			ID int64
+			// Name is the name argument value.
+			Name string
+		}
 		// IsApprovedUser holds details about calls to the IsApprovedUser method.
 		IsApprovedUser []struct {
 			// UserID is the userID argument value.
@@ -73,11 +118,16 @@ type BotMock struct {
 		}
 		// OnMessage holds details about calls to the OnMessage method.
 		OnMessage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Msg is the msg argument value.
 			Msg bot.Message
 			// CheckOnly is the checkOnly argument value.
 			CheckOnly bool
 		}
+		// ReloadDomains holds details about calls to the ReloadDomains method.
+		ReloadDomains []struct {
+		}
 		// RemoveApprovedUser holds details about calls to the RemoveApprovedUser method.
 		RemoveApprovedUser []struct {
 			// ID is the id argument value.
@@ -95,8 +145,12 @@ type BotMock struct {
 		}
 	}
 	lockAddApprovedUser    sync.RWMutex
+	lockApprovedAt         sync.RWMutex
+	lockCheckUser          sync.RWMutex
+	lockCountCleanMessage  sync.RWMutex
 	lockIsApprovedUser     sync.RWMutex
 	lockOnMessage          sync.RWMutex
+	lockReloadDomains      sync.RWMutex
 	lockRemoveApprovedUser sync.RWMutex
 	lockUpdateHam          sync.RWMutex
 	lockUpdateSpam         sync.RWMutex
@@ -145,6 +199,131 @@ func (mock *BotMock) ResetAddApprovedUserCalls() {
 	mock.lockAddApprovedUser.Unlock()
 }
 
+// ApprovedAt calls ApprovedAtFunc.
+func (mock *BotMock) ApprovedAt(userID int64) (time.Time, bool) {
+	if mock.ApprovedAtFunc == nil {
+		panic("BotMock.ApprovedAtFunc: method is nil but Bot.ApprovedAt was just called")
+	}
+	callInfo := struct {
+		UserID int64
+	}{
+		UserID: userID,
+	}
+	mock.lockApprovedAt.Lock()
+	mock.calls.ApprovedAt = append(mock.calls.ApprovedAt, callInfo)
+	mock.lockApprovedAt.Unlock()
+	return mock.ApprovedAtFunc(userID)
+}
+
+// ApprovedAtCalls gets all the calls that were made to ApprovedAt.
+// Check the length with:
+//
+//	len(mockedBot.ApprovedAtCalls())
+func (mock *BotMock) ApprovedAtCalls() []struct {
+	UserID int64
+} {
+	var calls []struct {
+		UserID int64
+	}
+	mock.lockApprovedAt.RLock()
+	calls = mock.calls.ApprovedAt
+	mock.lockApprovedAt.RUnlock()
+	return calls
+}
+
+// ResetApprovedAtCalls reset all the calls that were made to ApprovedAt.
+func (mock *BotMock) ResetApprovedAtCalls() {
+	mock.lockApprovedAt.Lock()
+	mock.calls.ApprovedAt = nil
+	mock.lockApprovedAt.Unlock()
+}
+
+// CheckUser calls CheckUserFunc.
+func (mock *BotMock) CheckUser(ctx context.Context, user bot.User) bot.Response {
+	if mock.CheckUserFunc == nil {
+		panic("BotMock.CheckUserFunc: method is nil but Bot.CheckUser was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		User bot.User
+	}{
+		Ctx:  ctx,
+		User: user,
+	}
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = append(mock.calls.CheckUser, callInfo)
+	mock.lockCheckUser.Unlock()
+	return mock.CheckUserFunc(ctx, user)
+}
+
+// CheckUserCalls gets all the calls that were made to CheckUser.
+// Check the length with:
+//
+//	len(mockedBot.CheckUserCalls())
+func (mock *BotMock) CheckUserCalls() []struct {
+	Ctx  context.Context
+	User bot.User
+} {
+	var calls []struct {
+		Ctx  context.Context
+		User bot.User
+	}
+	mock.lockCheckUser.RLock()
+	calls = mock.calls.CheckUser
+	mock.lockCheckUser.RUnlock()
+	return calls
+}
+
+// ResetCheckUserCalls reset all the calls that were made to CheckUser.
+func (mock *BotMock) ResetCheckUserCalls() {
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = nil
+	mock.lockCheckUser.Unlock()
+}
+
+// CountCleanMessage calls CountCleanMessageFunc.
+func (mock *BotMock) CountCleanMessage(id int64, name string) {
+	if mock.CountCleanMessageFunc == nil {
+		panic("BotMock.CountCleanMessageFunc: method is nil but Bot.CountCleanMessage was just called")
+	}
+	callInfo := struct {
+		ID   int64
+		Name string
+	}{
+		ID:   id,
+		Name: name,
+	}
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = append(mock.calls.CountCleanMessage, callInfo)
+	mock.lockCountCleanMessage.Unlock()
+	mock.CountCleanMessageFunc(id, name)
+}
+
+// CountCleanMessageCalls gets all the calls that were made to CountCleanMessage.
+// Check the length with:
+//
+//	len(mockedBot.CountCleanMessageCalls())
+func (mock *BotMock) CountCleanMessageCalls() []struct {
+	ID   int64
+	Name string
+} {
+	var calls []struct {
+		ID   int64
+		Name string
+	}
+	mock.lockCountCleanMessage.RLock()
+	calls = mock.calls.CountCleanMessage
+	mock.lockCountCleanMessage.RUnlock()
+	return calls
+}
+
+// ResetCountCleanMessageCalls reset all the calls that were made to CountCleanMessage.
+func (mock *BotMock) ResetCountCleanMessageCalls() {
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = nil
+	mock.lockCountCleanMessage.Unlock()
+}
+
 // IsApprovedUser calls IsApprovedUserFunc.
 func (mock *BotMock) IsApprovedUser(userID int64) bool {
 	if mock.IsApprovedUserFunc == nil {
@@ -185,21 +364,23 @@ func (mock *BotMock) ResetIsApprovedUserCalls() {
 }
 
 // OnMessage calls OnMessageFunc.
-func (mock *BotMock) OnMessage(msg bot.Message, checkOnly bool) bot.Response {
+func (mock *BotMock) OnMessage(ctx context.Context, msg bot.Message, checkOnly bool) bot.Response {
 	if mock.OnMessageFunc == nil {
 		panic("BotMock.OnMessageFunc: method is nil but Bot.OnMessage was just called")
 	}
 	callInfo := struct {
+		Ctx       context.Context
 		Msg       bot.Message
 		CheckOnly bool
 	}{
+		Ctx:       ctx,
 		Msg:       msg,
 		CheckOnly: checkOnly,
 	}
 	mock.lockOnMessage.Lock()
 	mock.calls.OnMessage = append(mock.calls.OnMessage, callInfo)
 	mock.lockOnMessage.Unlock()
-	return mock.OnMessageFunc(msg, checkOnly)
+	return mock.OnMessageFunc(ctx, msg, checkOnly)
 }
 
 // OnMessageCalls gets all the calls that were made to OnMessage.
@@ -207,10 +388,12 @@ func (mock *BotMock) OnMessage(msg bot.Message, checkOnly bool) bot.Response {
 //
 //	len(mockedBot.OnMessageCalls())
 func (mock *BotMock) OnMessageCalls() []struct {
+	Ctx       context.Context
 	Msg       bot.Message
 	CheckOnly bool
 } {
 	var calls []struct {
+		Ctx       context.Context
 		Msg       bot.Message
 		CheckOnly bool
 	}
@@ -227,6 +410,40 @@ func (mock *BotMock) ResetOnMessageCalls() {
 	mock.lockOnMessage.Unlock()
 }
 
+// ReloadDomains calls ReloadDomainsFunc.
+func (mock *BotMock) ReloadDomains() error {
+	if mock.ReloadDomainsFunc == nil {
+		panic("BotMock.ReloadDomainsFunc: method is nil but Bot.ReloadDomains was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = append(mock.calls.ReloadDomains, callInfo)
+	mock.lockReloadDomains.Unlock()
+	return mock.ReloadDomainsFunc()
+}
+
+// ReloadDomainsCalls gets all the calls that were made to ReloadDomains.
+// Check the length with:
+//
+//	len(mockedBot.ReloadDomainsCalls())
+func (mock *BotMock) ReloadDomainsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockReloadDomains.RLock()
+	calls = mock.calls.ReloadDomains
+	mock.lockReloadDomains.RUnlock()
+	return calls
+}
+
+// ResetReloadDomainsCalls reset all the calls that were made to ReloadDomains.
+func (mock *BotMock) ResetReloadDomainsCalls() {
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = nil
+	mock.lockReloadDomains.Unlock()
+}
+
 // RemoveApprovedUser calls RemoveApprovedUserFunc.
 func (mock *BotMock) RemoveApprovedUser(id int64) error {
 	if mock.RemoveApprovedUserFunc == nil {
@@ -350,6 +567,18 @@ func (mock *BotMock) ResetCalls() {
 	mock.calls.AddApprovedUser = nil
 	mock.lockAddApprovedUser.Unlock()
 
+	mock.lockApprovedAt.Lock()
+	mock.calls.ApprovedAt = nil
+	mock.lockApprovedAt.Unlock()
+
+	mock.lockCheckUser.Lock()
+	mock.calls.CheckUser = nil
+	mock.lockCheckUser.Unlock()
+
+	mock.lockCountCleanMessage.Lock()
+	mock.calls.CountCleanMessage = nil
+	mock.lockCountCleanMessage.Unlock()
+
 	mock.lockIsApprovedUser.Lock()
 	mock.calls.IsApprovedUser = nil
 	mock.lockIsApprovedUser.Unlock()
@@ -358,6 +587,10 @@ func (mock *BotMock) ResetCalls() {
 	mock.calls.OnMessage = nil
 	mock.lockOnMessage.Unlock()
 
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = nil
+	mock.lockReloadDomains.Unlock()
+
 	mock.lockRemoveApprovedUser.Lock()
 	mock.calls.RemoveApprovedUser = nil
 	mock.lockRemoveApprovedUser.Unlock()
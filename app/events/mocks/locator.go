@@ -8,6 +8,7 @@ import (
 	"github.com/umputun/tg-spam/app/storage"
 	"github.com/umputun/tg-spam/lib/spamcheck"
 	"sync"
+	"time"
 )
 
 // LocatorMock is a mock implementation of events.Locator.
@@ -22,6 +23,9 @@ import (
 //			AddSpamFunc: func(ctx context.Context, userID int64, checks []spamcheck.Response) error {
 //				panic("mock out the AddSpam method")
 //			},
+//			JoinsFunc: func(ctx context.Context, chatID int64, since time.Time) ([]storage.MsgMeta, error) {
+//				panic("mock out the Joins method")
+//			},
 //			MessageFunc: func(ctx context.Context, msg string) (storage.MsgMeta, bool) {
 //				panic("mock out the Message method")
 //			},
@@ -31,6 +35,9 @@ import (
 //			SpamFunc: func(ctx context.Context, userID int64) (storage.SpamData, bool) {
 //				panic("mock out the Spam method")
 //			},
+//			UserMessagesFunc: func(ctx context.Context, chatID int64, userID int64, since time.Time) ([]storage.MsgMeta, error) {
+//				panic("mock out the UserMessages method")
+//			},
 //			UserNameByIDFunc: func(ctx context.Context, userID int64) string {
 //				panic("mock out the UserNameByID method")
 //			},
@@ -47,6 +54,9 @@ type LocatorMock struct {
 	// AddSpamFunc mocks the AddSpam method.
 	AddSpamFunc func(ctx context.Context, userID int64, checks []spamcheck.Response) error
 
+	// JoinsFunc mocks the Joins method.
+	JoinsFunc func(ctx context.Context, chatID int64, since time.Time) ([]storage.MsgMeta, error)
+
 	// MessageFunc mocks the Message method.
 	MessageFunc func(ctx context.Context, msg string) (storage.MsgMeta, bool)
 
@@ -56,6 +66,9 @@ type LocatorMock struct {
 	// SpamFunc mocks the Spam method.
 	SpamFunc func(ctx context.Context, userID int64) (storage.SpamData, bool)
 
+	// UserMessagesFunc mocks the UserMessages method.
+	UserMessagesFunc func(ctx context.Context, chatID int64, userID int64, since time.Time) ([]storage.MsgMeta, error)
+
 	// UserNameByIDFunc mocks the UserNameByID method.
 	UserNameByIDFunc func(ctx context.Context, userID int64) string
 
@@ -85,6 +98,15 @@ type LocatorMock struct {
 			// Checks is the checks argument value.
 			Checks []spamcheck.Response
 		}
+		// Joins holds details about calls to the Joins method.
+		Joins []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ChatID is the chatID argument value.
+			ChatID int64
+			// Since is the since argument value.
+			Since time.Time
+		}
 		// Message holds details about calls to the Message method.
 		Message []struct {
 			// Ctx is the ctx argument value.
@@ -104,6 +126,17 @@ type LocatorMock struct {
 			// UserID is the userID argument value.
 			UserID int64
 		}
+		// UserMessages holds details about calls to the UserMessages method.
+		UserMessages []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ChatID is the chatID argument value.
+			ChatID int64
+			// UserID is the userID argument value.
+			UserID int64
+			// Since is the since argument value.
+			Since time.Time
+		}
 		// UserNameByID holds details about calls to the UserNameByID method.
 		UserNameByID []struct {
 			// Ctx is the ctx argument value.
@@ -114,9 +147,11 @@ type LocatorMock struct {
 	}
 	lockAddMessage   sync.RWMutex
 	lockAddSpam      sync.RWMutex
+	lockJoins        sync.RWMutex
 	lockMessage      sync.RWMutex
 	lockMsgHash      sync.RWMutex
 	lockSpam         sync.RWMutex
+	lockUserMessages sync.RWMutex
 	lockUserNameByID sync.RWMutex
 }
 
@@ -226,6 +261,53 @@ func (mock *LocatorMock) ResetAddSpamCalls() {
 	mock.lockAddSpam.Unlock()
 }
 
+// Joins calls JoinsFunc.
+func (mock *LocatorMock) Joins(ctx context.Context, chatID int64, since time.Time) ([]storage.MsgMeta, error) {
+	if mock.JoinsFunc == nil {
+		panic("LocatorMock.JoinsFunc: method is nil but Locator.Joins was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		ChatID int64
+		Since  time.Time
+	}{
+		Ctx:    ctx,
+		ChatID: chatID,
+		Since:  since,
+	}
+	mock.lockJoins.Lock()
+	mock.calls.Joins = append(mock.calls.Joins, callInfo)
+	mock.lockJoins.Unlock()
+	return mock.JoinsFunc(ctx, chatID, since)
+}
+
+// JoinsCalls gets all the calls that were made to Joins.
+// Check the length with:
+//
+//	len(mockedLocator.JoinsCalls())
+func (mock *LocatorMock) JoinsCalls() []struct {
+	Ctx    context.Context
+	ChatID int64
+	Since  time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		ChatID int64
+		Since  time.Time
+	}
+	mock.lockJoins.RLock()
+	calls = mock.calls.Joins
+	mock.lockJoins.RUnlock()
+	return calls
+}
+
+// ResetJoinsCalls reset all the calls that were made to Joins.
+func (mock *LocatorMock) ResetJoinsCalls() {
+	mock.lockJoins.Lock()
+	mock.calls.Joins = nil
+	mock.lockJoins.Unlock()
+}
+
 // Message calls MessageFunc.
 func (mock *LocatorMock) Message(ctx context.Context, msg string) (storage.MsgMeta, bool) {
 	if mock.MessageFunc == nil {
@@ -351,6 +433,57 @@ func (mock *LocatorMock) ResetSpamCalls() {
 	mock.lockSpam.Unlock()
 }
 
+// UserMessages calls UserMessagesFunc.
+func (mock *LocatorMock) UserMessages(ctx context.Context, chatID int64, userID int64, since time.Time) ([]storage.MsgMeta, error) {
+	if mock.UserMessagesFunc == nil {
+		panic("LocatorMock.UserMessagesFunc: method is nil but Locator.UserMessages was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		ChatID int64
+		UserID int64
+		Since  time.Time
+	}{
+		Ctx:    ctx,
+		ChatID: chatID,
+		UserID: userID,
+		Since:  since,
+	}
+	mock.lockUserMessages.Lock()
+	mock.calls.UserMessages = append(mock.calls.UserMessages, callInfo)
+	mock.lockUserMessages.Unlock()
+	return mock.UserMessagesFunc(ctx, chatID, userID, since)
+}
+
+// UserMessagesCalls gets all the calls that were made to UserMessages.
+// Check the length with:
+//
+//	len(mockedLocator.UserMessagesCalls())
+func (mock *LocatorMock) UserMessagesCalls() []struct {
+	Ctx    context.Context
+	ChatID int64
+	UserID int64
+	Since  time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		ChatID int64
+		UserID int64
+		Since  time.Time
+	}
+	mock.lockUserMessages.RLock()
+	calls = mock.calls.UserMessages
+	mock.lockUserMessages.RUnlock()
+	return calls
+}
+
+// ResetUserMessagesCalls reset all the calls that were made to UserMessages.
+func (mock *LocatorMock) ResetUserMessagesCalls() {
+	mock.lockUserMessages.Lock()
+	mock.calls.UserMessages = nil
+	mock.lockUserMessages.Unlock()
+}
+
 // UserNameByID calls UserNameByIDFunc.
 func (mock *LocatorMock) UserNameByID(ctx context.Context, userID int64) string {
 	if mock.UserNameByIDFunc == nil {
@@ -404,6 +537,10 @@ func (mock *LocatorMock) ResetCalls() {
 	mock.calls.AddSpam = nil
 	mock.lockAddSpam.Unlock()
 
+	mock.lockJoins.Lock()
+	mock.calls.Joins = nil
+	mock.lockJoins.Unlock()
+
 	mock.lockMessage.Lock()
 	mock.calls.Message = nil
 	mock.lockMessage.Unlock()
@@ -416,6 +553,10 @@ func (mock *LocatorMock) ResetCalls() {
 	mock.calls.Spam = nil
 	mock.lockSpam.Unlock()
 
+	mock.lockUserMessages.Lock()
+	mock.calls.UserMessages = nil
+	mock.lockUserMessages.Unlock()
+
 	mock.lockUserNameByID.Lock()
 	mock.calls.UserNameByID = nil
 	mock.lockUserNameByID.Unlock()
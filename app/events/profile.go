@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+// BioCache is an interface for the cache of fetched user bios
+type BioCache interface {
+	Read(ctx context.Context, userID int64) (storage.UserBio, bool, error)
+	Write(ctx context.Context, bio storage.UserBio) error
+}
+
+// ProfileConfig defines screening of user profiles, i.e. display name and bio, on join and on messages
+// of not approved users. Profile checks themselves are done by the detector.
+type ProfileConfig struct {
+	Enabled  bool     // check profiles of new members on join
+	FetchBio bool     // fetch bio of not approved users with getChat call, display name only checked if not set
+	Cache    BioCache // cache of fetched bios, optional, bio fetched for each message if not set
+}
+
+// userBio returns the bio of the user, from the cache if fetched before. Empty bio returned on errors.
+func (l *TelegramListener) userBio(ctx context.Context, userID int64) string {
+	if !l.Profile.FetchBio || userID == 0 {
+		return ""
+	}
+	if l.Profile.Cache != nil {
+		cached, found, err := l.Profile.Cache.Read(ctx, userID)
+		if err != nil {
+			log.Printf("[WARN] failed to read cached bio of %d: %v", userID, err)
+		}
+		if found {
+			return cached.Bio
+		}
+	}
+
+	info, err := l.TbAPI.GetChat(tbapi.ChatInfoConfig{ChatConfig: tbapi.ChatConfig{ChatID: userID}})
+	if err != nil {
+		log.Printf("[WARN] failed to get bio of %d: %v", userID, err)
+		return ""
+	}
+	log.Printf("[DEBUG] bio of %d: %q", userID, info.Bio)
+	if l.Profile.Cache != nil {
+		if err := l.Profile.Cache.Write(ctx, storage.UserBio{UserID: userID, Bio: info.Bio}); err != nil {
+			log.Printf("[WARN] failed to cache bio of %d: %v", userID, err)
+		}
+	}
+	return info.Bio
+}
+
+// procNewMemberProfile checks the profile of the new member and bans the member if the profile is spam,
+// the join message is deleted as well. Returns true if the profile is spam. Bots, superusers, approved users
+// and members added by superusers are not checked.
+func (l *TelegramListener) procNewMemberProfile(ctx context.Context, g *chatGroup, from *tbapi.User, member tbapi.User,
+	joinMsgID int) (bool, error) {
+	if !l.Profile.Enabled || member.IsBot || l.SuperUsers.IsSuper(member.UserName, member.ID) || g.bot.IsApprovedUser(member.ID) {
+		return false, nil
+	}
+	if from != nil && from.ID != member.ID && l.SuperUsers.IsSuper(from.UserName, from.ID) {
+		return false, nil
+	}
+
+	user := bot.User{ID: member.ID, Username: member.UserName,
+		DisplayName: strings.TrimSpace(member.FirstName + " " + member.LastName), Bio: l.userBio(ctx, member.ID)}
+	resp := g.bot.CheckUser(ctx, user)
+	if !resp.Send {
+		return false, nil
+	}
+
+	log.Printf("[INFO] spam profile of new member %v in %d: %v", user, g.chatID, resp.CheckResults)
+	msg := &bot.Message{ID: joinMsgID, From: user, Text: strings.TrimSpace(user.DisplayName + "\n" + user.Bio)}
+	resp.BanInterval = bot.PermanentBanDuration
+	resp.DeleteReplyTo = true
+	resp.ReplyTo = joinMsgID
+	g.spamLogger.Save(msg, &resp)
+	if err := g.locator.AddSpam(ctx, user.ID, resp.CheckResults); err != nil {
+		log.Printf("[WARN] failed to add spam to locator: %v", err)
+	}
+	if err := l.applySpamAction(g, msg, resp, fmt.Sprintf("%v", user), g.chatID); err != nil {
+		return true, fmt.Errorf("failed to apply spam action to new member %d: %w", member.ID, err)
+	}
+	return true, nil
+}
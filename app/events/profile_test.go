@@ -0,0 +1,205 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestTelegramListener_userBio(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			if config.ChatID == 500 {
+				return tbapi.ChatFullInfo{}, errors.New("network error")
+			}
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: config.ChatID}, Bio: "earn 500$ daily"}, nil
+		},
+	}
+	cache := &mocks.BioCacheMock{
+		ReadFunc: func(ctx context.Context, userID int64) (storage.UserBio, bool, error) {
+			if userID == 100 {
+				return storage.UserBio{UserID: 100, Bio: "cached bio"}, true, nil
+			}
+			return storage.UserBio{}, false, nil
+		},
+		WriteFunc: func(ctx context.Context, bio storage.UserBio) error { return nil },
+	}
+	l := &TelegramListener{TbAPI: mockAPI, Profile: ProfileConfig{Enabled: true, FetchBio: true, Cache: cache}}
+
+	t.Run("fetched and cached", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		cache.ResetCalls()
+		assert.Equal(t, "earn 500$ daily", l.userBio(context.Background(), 200))
+		require.Len(t, mockAPI.GetChatCalls(), 1)
+		assert.Equal(t, int64(200), mockAPI.GetChatCalls()[0].Config.ChatID)
+		require.Len(t, cache.WriteCalls(), 1)
+		assert.Equal(t, storage.UserBio{UserID: 200, Bio: "earn 500$ daily"}, cache.WriteCalls()[0].Bio)
+	})
+
+	t.Run("from cache", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		cache.ResetCalls()
+		assert.Equal(t, "cached bio", l.userBio(context.Background(), 100))
+		assert.Empty(t, mockAPI.GetChatCalls())
+		assert.Empty(t, cache.WriteCalls())
+	})
+
+	t.Run("fetch failed", func(t *testing.T) {
+		cache.ResetCalls()
+		assert.Empty(t, l.userBio(context.Background(), 500))
+		assert.Empty(t, cache.WriteCalls())
+	})
+
+	t.Run("fetch disabled", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		l := &TelegramListener{TbAPI: mockAPI, Profile: ProfileConfig{Enabled: true}}
+		assert.Empty(t, l.userBio(context.Background(), 200))
+		assert.Empty(t, mockAPI.GetChatCalls())
+	})
+}
+
+func TestTelegramListener_procNewMemberProfile(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Bio: "dm me for signals"}, nil
+		},
+	}
+	b := &mocks.BotMock{
+		IsApprovedUserFunc: func(userID int64) bool { return userID == 100 },
+		CheckUserFunc: func(_ context.Context, user bot.User) bot.Response {
+			if user.DisplayName == "Earn 500$ daily" {
+				return bot.Response{Send: true, User: user, CheckResults: []spamcheck.Response{
+					{Name: "profile-stopword", Spam: true, Details: "name: earn"}}}
+			}
+			return bot.Response{User: user, CheckResults: []spamcheck.Response{{Name: "profile-stopword", Spam: false}}}
+		},
+	}
+	spamLogger := &mocks.SpamLoggerMock{SaveFunc: func(msg *bot.Message, response *bot.Response) {}}
+	locator := &mocks.LocatorMock{
+		AddSpamFunc: func(ctx context.Context, userID int64, checks []spamcheck.Response) error { return nil },
+	}
+	reset := func() {
+		mockAPI.ResetCalls()
+		b.ResetCalls()
+		spamLogger.ResetCalls()
+		locator.ResetCalls()
+	}
+	makeListener := func() *TelegramListener {
+		l := &TelegramListener{TbAPI: mockAPI, Bot: b, SpamLogger: spamLogger, Locator: locator, SuperUsers: SuperUsers{"admin"},
+			chatID: 123, adminChatID: 456, Profile: ProfileConfig{Enabled: true, FetchBio: true}}
+		l.adminHandler = &admin{tbAPI: mockAPI, bot: b, locator: locator, primChatID: 123, adminChatID: 456}
+		return l
+	}
+
+	t.Run("spam profile banned", func(t *testing.T) {
+		reset()
+		l := makeListener()
+		member := tbapi.User{ID: 200, UserName: "earner", FirstName: "Earn 500$", LastName: "daily"}
+		spam, err := l.procNewMemberProfile(context.Background(), l.group(123), &member, member, 22)
+		require.NoError(t, err)
+		assert.True(t, spam)
+
+		require.Len(t, b.CheckUserCalls(), 1)
+		assert.Equal(t, bot.User{ID: 200, Username: "earner", DisplayName: "Earn 500$ daily", Bio: "dm me for signals"},
+			b.CheckUserCalls()[0].User)
+		require.Len(t, spamLogger.SaveCalls(), 1)
+		assert.Equal(t, "Earn 500$ daily\ndm me for signals", spamLogger.SaveCalls()[0].Msg.Text)
+		assert.Equal(t, bot.PermanentBanDuration, spamLogger.SaveCalls()[0].Response.BanInterval)
+		require.Len(t, locator.AddSpamCalls(), 1)
+		assert.Equal(t, int64(200), locator.AddSpamCalls()[0].UserID)
+
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		ban := mockAPI.RequestCalls()[0].C.(tbapi.BanChatMemberConfig)
+		assert.Equal(t, int64(123), ban.ChatID)
+		assert.Equal(t, int64(200), ban.UserID)
+		del := mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig)
+		assert.Equal(t, 22, del.MessageID)
+		require.Len(t, mockAPI.SendCalls(), 1, "reported to admin chat")
+		assert.Equal(t, int64(456), mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).ChatID)
+	})
+
+	t.Run("clean profile", func(t *testing.T) {
+		reset()
+		l := makeListener()
+		member := tbapi.User{ID: 200, UserName: "good", FirstName: "John"}
+		spam, err := l.procNewMemberProfile(context.Background(), l.group(123), &member, member, 22)
+		require.NoError(t, err)
+		assert.False(t, spam)
+		assert.Len(t, b.CheckUserCalls(), 1)
+		assert.Empty(t, mockAPI.RequestCalls())
+		assert.Empty(t, spamLogger.SaveCalls())
+	})
+
+	t.Run("not checked", func(t *testing.T) {
+		reset()
+		l := makeListener()
+		super := tbapi.User{ID: 1, UserName: "admin"}
+		members := []tbapi.User{
+			{ID: 100, UserName: "approved", FirstName: "Earn 500$", LastName: "daily"},
+			{ID: 200, UserName: "somebot", FirstName: "Earn 500$", LastName: "daily", IsBot: true},
+			super,
+		}
+		for _, m := range members {
+			spam, err := l.procNewMemberProfile(context.Background(), l.group(123), &m, m, 22)
+			require.NoError(t, err)
+			assert.False(t, spam)
+		}
+		added := tbapi.User{ID: 300, UserName: "added", FirstName: "Earn 500$", LastName: "daily"}
+		spam, err := l.procNewMemberProfile(context.Background(), l.group(123), &super, added, 22)
+		require.NoError(t, err)
+		assert.False(t, spam)
+
+		l.Profile.Enabled = false
+		spam, err = l.procNewMemberProfile(context.Background(), l.group(123), &added, added, 22)
+		require.NoError(t, err)
+		assert.False(t, spam)
+		assert.Empty(t, b.CheckUserCalls())
+		assert.Empty(t, mockAPI.RequestCalls())
+	})
+}
+
+func TestTelegramListener_procEventsWithBio(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Bio: "dm me for signals"}, nil
+		},
+	}
+	b := &mocks.BotMock{
+		IsApprovedUserFunc: func(userID int64) bool { return userID == 100 },
+		OnMessageFunc:      func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response { return bot.Response{} },
+	}
+	locator := &mocks.LocatorMock{
+		AddMessageFunc: func(ctx context.Context, msg string, chatID, userID int64, userName string, msgID int) error {
+			return nil
+		},
+	}
+	l := &TelegramListener{TbAPI: mockAPI, Bot: b, Locator: locator, SuperUsers: SuperUsers{"admin"}, chatID: 123,
+		Profile: ProfileConfig{Enabled: true, FetchBio: true}}
+	update := func(userID int64, userName string) tbapi.Update {
+		return tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, Text: "hello",
+			From: &tbapi.User{ID: userID, UserName: userName}}}
+	}
+
+	require.NoError(t, l.procEvents(context.Background(), update(200, "new_user")))
+	require.NoError(t, l.procEvents(context.Background(), update(100, "approved")))
+	require.NoError(t, l.procEvents(context.Background(), update(1, "admin")))
+	require.Len(t, mockAPI.GetChatCalls(), 1, "bio fetched for not approved user only")
+	assert.Equal(t, int64(200), mockAPI.GetChatCalls()[0].Config.ChatID)
+	require.Len(t, b.OnMessageCalls(), 3)
+	assert.Equal(t, "dm me for signals", b.OnMessageCalls()[0].Msg.From.Bio)
+	assert.Empty(t, b.OnMessageCalls()[1].Msg.From.Bio)
+	assert.Empty(t, b.OnMessageCalls()[2].Msg.From.Bio)
+}
@@ -0,0 +1,377 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+// raidPrefix is a prefix of callback data for lockdown buttons in admin chat
+const raidPrefix = "raid:"
+
+// RaidConfig defines detection of mass-join raids. A raid is detected if the number of members joined the chat
+// within the window reaches the threshold, and the chat is switched to lockdown for the configured period.
+type RaidConfig struct {
+	Enabled   bool          // enable raid detection
+	Joins     int           // number of joins within the window to detect a raid
+	Window    time.Duration // window to count joins within
+	Lockdown  time.Duration // duration of lockdown, can be ended earlier from admin chat
+	Restrict  bool          // restrict sending messages for all members but admins during lockdown
+	Challenge bool          // challenge new members during lockdown, even if join challenge is disabled
+	Paranoid  bool          // check all messages during lockdown, including messages of approved users
+}
+
+// raid detects mass-join raids and handles lockdowns of chats. Thread safe, lockdowns are ended by timers.
+// Active lockdowns are persisted if the store is set, and restored on start, as timers are lost on restart.
+type raid struct {
+	RaidConfig
+	tbAPI       TbAPI
+	adminChatID int64
+	store       Restrictions // active lockdowns storage, optional
+
+	mu        sync.Mutex
+	joins     map[int64][]time.Time // times of recent joins by chat ID
+	lockdowns map[int64]*lockdown   // active lockdowns by chat ID
+}
+
+// lockdown is an active lockdown of a chat
+type lockdown struct {
+	chatID      int64
+	groupName   string
+	since       time.Time              // time of the first join of the raid
+	until       time.Time              // end of the lockdown, planned or actual if ended earlier
+	permissions *tbapi.ChatPermissions // chat permissions before the lockdown, restored when it ends
+	alert       string                 // text of the alert sent to admin chat
+	msgID       int                    // alert message in admin chat
+	timer       *time.Timer
+}
+
+// lockdownState is a persisted state of the active lockdown, the end of the lockdown is kept as expiration time
+type lockdownState struct {
+	GroupName   string                 `json:"group_name"`
+	Since       time.Time              `json:"since"`
+	Permissions *tbapi.ChatPermissions `json:"permissions,omitempty"`
+	Alert       string                 `json:"alert"`
+	MsgID       int                    `json:"msg_id"`
+}
+
+// newRaid makes raid detector with default thresholds and lockdown duration if not set.
+// Store is optional, active lockdowns are not persisted if nil.
+func newRaid(cfg RaidConfig, tbAPI TbAPI, adminChatID int64, store Restrictions) *raid {
+	if cfg.Joins <= 0 {
+		cfg.Joins = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Lockdown <= 0 {
+		cfg.Lockdown = 30 * time.Minute
+	}
+	return &raid{RaidConfig: cfg, tbAPI: tbAPI, adminChatID: adminChatID, store: store,
+		joins: map[int64][]time.Time{}, lockdowns: map[int64]*lockdown{}}
+}
+
+// Join counts a new member of the chat and starts the lockdown if the number of joins within the window
+// reaches the threshold. Joins during the lockdown are not counted.
+func (r *raid) Join(chatID int64, groupName string) error {
+	now := time.Now()
+	r.mu.Lock()
+	if _, ok := r.lockdowns[chatID]; ok {
+		r.mu.Unlock()
+		return nil
+	}
+	joins := append(dropExpired(r.joins[chatID], now.Add(-r.Window)), now)
+	if len(joins) < r.Joins {
+		r.joins[chatID] = joins
+		r.mu.Unlock()
+		return nil
+	}
+	delete(r.joins, chatID)
+	ld := &lockdown{chatID: chatID, groupName: groupName, since: joins[0], until: now.Add(r.Lockdown)}
+	r.lockdowns[chatID] = ld
+	r.mu.Unlock()
+
+	log.Printf("[INFO] raid detected in %q (%d), %d joins within %v, lockdown for %v", groupName, chatID, len(joins),
+		r.Window, r.Lockdown)
+	return r.start(ld, len(joins))
+}
+
+// Active checks if the chat is in lockdown
+func (r *raid) Active(chatID int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.lockdowns[chatID]
+	return ok
+}
+
+// End ends the lockdown of the chat, restores chat permissions and marks the alert in admin chat.
+// Returns false if the chat is not in lockdown.
+func (r *raid) End(chatID int64) (bool, error) {
+	r.mu.Lock()
+	ld, ok := r.lockdowns[chatID]
+	if !ok {
+		r.mu.Unlock()
+		return false, nil
+	}
+	delete(r.lockdowns, chatID)
+	if ld.timer != nil {
+		ld.timer.Stop()
+	}
+	if now := time.Now(); now.Before(ld.until) {
+		ld.until = now // ended earlier, members joined after the lockdown are not raiders
+	}
+	r.mu.Unlock()
+
+	log.Printf("[INFO] lockdown of %q (%d) ended", ld.groupName, ld.chatID)
+	errs := new(multierror.Error)
+	if r.store != nil {
+		if err := r.store.Delete(context.TODO(), storage.RestrictionLockdown, ld.chatID, 0); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to delete lockdown of %d: %w", ld.chatID, err))
+		}
+	}
+	if r.Restrict {
+		if _, err := r.tbAPI.Request(tbapi.SetChatPermissionsConfig{ChatConfig: tbapi.ChatConfig{ChatID: ld.chatID},
+			Permissions: ld.permissions}); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to restore permissions of %d: %w", ld.chatID, err))
+		}
+	}
+	if ld.msgID != 0 {
+		// only "ban all joined" button left, the raiders can be banned after the lockdown as well
+		text := ld.alert + "\n\n_lockdown ended_"
+		editMsg := tbapi.NewEditMessageTextAndMarkup(r.adminChatID, ld.msgID, text, r.keyboard(ld, false))
+		editMsg.ParseMode = tbapi.ModeMarkdown
+		if _, err := r.tbAPI.Send(editMsg); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to update lockdown alert: %w", err))
+		}
+	}
+	return true, errs.ErrorOrNil()
+}
+
+// start restricts the chat if enabled, sends the alert to admin chat and sets the timer to end the lockdown
+func (r *raid) start(ld *lockdown, joins int) error {
+	errs := new(multierror.Error)
+	if r.Restrict {
+		ld.permissions = chatPermissions(r.tbAPI, ld.chatID)
+		if _, err := r.tbAPI.Request(tbapi.SetChatPermissionsConfig{ChatConfig: tbapi.ChatConfig{ChatID: ld.chatID},
+			Permissions: &tbapi.ChatPermissions{}}); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to restrict %d: %w", ld.chatID, err))
+		}
+	}
+
+	var modes []string
+	if r.Restrict {
+		modes = append(modes, "messages restricted")
+	}
+	if r.Challenge {
+		modes = append(modes, "new members challenged")
+	}
+	if r.Paranoid {
+		modes = append(modes, "all messages checked")
+	}
+	ld.alert = fmt.Sprintf("**raid detected in %s**\n\n%d members joined within %v, lockdown for %v",
+		escapeMarkDownV1Text(ld.groupName), joins, r.Window, r.Lockdown)
+	if len(modes) > 0 {
+		ld.alert += ": " + strings.Join(modes, ", ")
+	}
+	if r.adminChatID != 0 {
+		tbMsg := tbapi.NewMessage(r.adminChatID, ld.alert)
+		tbMsg.ParseMode = tbapi.ModeMarkdown
+		tbMsg.ReplyMarkup = r.keyboard(ld, true)
+		resp, err := r.tbAPI.Send(tbMsg)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to send lockdown alert: %w", err))
+		}
+		ld.msgID = resp.MessageID
+	}
+
+	if err := r.save(ld); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	r.arm(ld, r.Lockdown)
+	return errs.ErrorOrNil()
+}
+
+// Restore restores lockdowns active before restart from the store. Lockdowns ended during the downtime
+// are ended right away, i.e. chat permissions are restored and the alert in admin chat is updated.
+func (r *raid) Restore() error {
+	if r.store == nil {
+		return nil
+	}
+	recs, err := r.store.List(context.TODO(), storage.RestrictionLockdown)
+	if err != nil {
+		return fmt.Errorf("failed to load active lockdowns: %w", err)
+	}
+	for _, rec := range recs {
+		var state lockdownState
+		if err := json.Unmarshal([]byte(rec.Data), &state); err != nil {
+			log.Printf("[WARN] failed to parse lockdown of %d: %v", rec.ChatID, err)
+		}
+		ld := &lockdown{chatID: rec.ChatID, groupName: state.GroupName, since: state.Since, until: rec.ExpiresAt,
+			permissions: state.Permissions, alert: state.Alert, msgID: state.MsgID}
+		r.mu.Lock()
+		r.lockdowns[ld.chatID] = ld
+		r.mu.Unlock()
+		r.arm(ld, max(time.Until(ld.until), 0))
+		log.Printf("[INFO] lockdown of %q (%d) restored, ends at %s", ld.groupName, ld.chatID, ld.until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// arm sets the timer to end the lockdown
+func (r *raid) arm(ld *lockdown, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ld.timer = time.AfterFunc(timeout, func() {
+		if _, err := r.End(ld.chatID); err != nil {
+			log.Printf("[WARN] failed to end lockdown of %d: %v", ld.chatID, err)
+		}
+	})
+}
+
+// save persists the active lockdown, if the store is set, to restore it after restart
+func (r *raid) save(ld *lockdown) error {
+	if r.store == nil {
+		return nil
+	}
+	state, err := json.Marshal(lockdownState{GroupName: ld.groupName, Since: ld.since, Permissions: ld.permissions,
+		Alert: ld.alert, MsgID: ld.msgID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockdown of %d: %w", ld.chatID, err)
+	}
+	rs := storage.Restriction{Kind: storage.RestrictionLockdown, ChatID: ld.chatID, Data: string(state), ExpiresAt: ld.until}
+	if err := r.store.Write(context.TODO(), rs); err != nil {
+		return fmt.Errorf("failed to save lockdown of %d: %w", ld.chatID, err)
+	}
+	return nil
+}
+
+// Permissions returns permissions of the chat members. For the chat restricted by lockdown it's the permissions
+// saved before the lockdown, as the current ones are revoked till the lockdown ends.
+func (r *raid) Permissions(chatID int64) *tbapi.ChatPermissions {
+	r.mu.Lock()
+	ld, ok := r.lockdowns[chatID]
+	r.mu.Unlock()
+	if ok && ld.permissions != nil {
+		return ld.permissions
+	}
+	return chatPermissions(r.tbAPI, chatID)
+}
+
+// keyboard returns buttons of the lockdown alert, "end lockdown" button is included for active lockdown only.
+// "ban all joined" is limited by the time of the first join of the raid and the end of the lockdown.
+func (r *raid) keyboard(ld *lockdown, active bool) tbapi.InlineKeyboardMarkup {
+	var buttons []tbapi.InlineKeyboardButton
+	if active {
+		buttons = append(buttons, tbapi.NewInlineKeyboardButtonData("end lockdown", fmt.Sprintf("%send:%d", raidPrefix, ld.chatID)))
+	}
+	buttons = append(buttons, tbapi.NewInlineKeyboardButtonData("ban all joined",
+		fmt.Sprintf("%sban:%d:%d:%d", raidPrefix, ld.chatID, ld.since.Unix(), ld.until.Unix())))
+	return tbapi.NewInlineKeyboardMarkup(buttons)
+}
+
+// isRaidCallback checks if the callback data is from lockdown buttons
+func isRaidCallback(data string) bool {
+	return strings.HasPrefix(data, raidPrefix)
+}
+
+// parseRaidData parses callback data of lockdown buttons, "raid:end:chatID" or "raid:ban:chatID:since:until"
+func parseRaidData(data string) (action string, chatID int64, since, until time.Time, err error) {
+	parts := strings.Split(strings.TrimPrefix(data, raidPrefix), ":")
+	if len(parts) < 2 {
+		return "", 0, time.Time{}, time.Time{}, fmt.Errorf("unexpected data format")
+	}
+	if chatID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return "", 0, time.Time{}, time.Time{}, fmt.Errorf("failed to parse chat id: %w", err)
+	}
+	switch {
+	case parts[0] == "end" && len(parts) == 2:
+		return parts[0], chatID, time.Time{}, time.Time{}, nil
+	case parts[0] == "ban" && len(parts) == 4:
+		ts := make([]time.Time, 2)
+		for i, p := range parts[2:] {
+			unix, err := strconv.ParseInt(p, 10, 64)
+			if err != nil {
+				return "", 0, time.Time{}, time.Time{}, fmt.Errorf("failed to parse time: %w", err)
+			}
+			ts[i] = time.Unix(unix, 0)
+		}
+		return parts[0], chatID, ts[0], ts[1], nil
+	}
+	return "", 0, time.Time{}, time.Time{}, fmt.Errorf("unknown action %q", parts[0])
+}
+
+// procRaidCallback handles lockdown buttons pressed in admin chat. "end lockdown" ends the lockdown of the chat,
+// "ban all joined" bans all members joined since the raid started till the lockdown ended, found by join records
+// in the locator. Superusers and approved users are not banned.
+func (l *TelegramListener) procRaidCallback(ctx context.Context, query *tbapi.CallbackQuery) error {
+	if query.Message == nil || query.Message.Chat.ID != l.adminChatID {
+		return nil // only admin chat is allowed
+	}
+	action, chatID, since, until, err := parseRaidData(query.Data)
+	if err != nil {
+		return fmt.Errorf("failed to parse lockdown callback %q: %w", query.Data, err)
+	}
+
+	if action == "end" {
+		ended, err := l.raid.End(chatID)
+		if !ended {
+			l.raid.answerCallback(query.ID, "lockdown is not active")
+			return nil
+		}
+		l.raid.answerCallback(query.ID, "lockdown ended")
+		return err
+	}
+
+	g := l.group(chatID)
+	allJoins, err := g.locator.Joins(ctx, chatID, since)
+	if err != nil {
+		return fmt.Errorf("failed to get joined members: %w", err)
+	}
+	joins := make([]storage.MsgMeta, 0, len(allJoins))
+	for _, j := range allJoins {
+		// the lockdown end is in seconds, joins in the same second are included
+		if !j.Time.After(until.Add(time.Second)) {
+			joins = append(joins, j)
+		}
+	}
+	errs := new(multierror.Error)
+	banned := 0
+	for _, j := range joins {
+		if l.SuperUsers.IsSuper(j.UserName, j.UserID) || g.bot.IsApprovedUser(j.UserID) {
+			continue
+		}
+		banReq := banRequest{duration: bot.PermanentBanDuration, userID: j.UserID, chatID: chatID, tbAPI: l.TbAPI,
+			dry: l.Dry, training: l.TrainingMode, restrict: l.SoftBanMode}
+		if err := banUserOrChannel(banReq); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to ban %d: %w", j.UserID, err))
+			continue
+		}
+		banned++
+	}
+	log.Printf("[INFO] %d of %d members joined %q (%d) from %s to %s banned", banned, len(joins), g.name, chatID,
+		since.Format(time.RFC3339), until.Format(time.RFC3339))
+	l.raid.answerCallback(query.ID, fmt.Sprintf("banned %d users", banned))
+	text := fmt.Sprintf("%d of %d members joined %s from %s to %s banned", banned, len(joins), escapeMarkDownV1Text(g.name),
+		since.Format("15:04:05"), until.Format("15:04:05"))
+	if err := l.sendBotResponse(bot.Response{Send: true, Text: text}, l.adminChatID, NotificationSilent); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("failed to report banned members: %w", err))
+	}
+	return errs.ErrorOrNil()
+}
+
+func (r *raid) answerCallback(queryID, text string) {
+	if _, err := r.tbAPI.Request(tbapi.NewCallback(queryID, text)); err != nil {
+		log.Printf("[WARN] failed to answer lockdown callback: %v", err)
+	}
+}
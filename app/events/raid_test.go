@@ -0,0 +1,356 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+func TestRaid_JoinAndEnd(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 777}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: config.ChatID},
+				Permissions: &tbapi.ChatPermissions{CanSendMessages: true, CanSendPhotos: true}}, nil
+		},
+	}
+	store := &mocks.RestrictionsMock{
+		WriteFunc:  func(ctx context.Context, rs storage.Restriction) error { return nil },
+		DeleteFunc: func(ctx context.Context, kind storage.RestrictionKind, chatID, userID int64) error { return nil },
+	}
+	r := newRaid(RaidConfig{Enabled: true, Joins: 3, Window: time.Minute, Lockdown: time.Hour, Restrict: true,
+		Challenge: true}, mockAPI, 456, store)
+
+	require.NoError(t, r.Join(123, "gr"))
+	require.NoError(t, r.Join(123, "gr"))
+	require.NoError(t, r.Join(124, "other"))
+	assert.False(t, r.Active(123))
+	assert.Empty(t, mockAPI.SendCalls())
+
+	require.NoError(t, r.Join(123, "gr"))
+	assert.True(t, r.Active(123))
+	assert.False(t, r.Active(124))
+
+	require.Len(t, mockAPI.RequestCalls(), 1)
+	restrictCfg := mockAPI.RequestCalls()[0].C.(tbapi.SetChatPermissionsConfig)
+	assert.Equal(t, int64(123), restrictCfg.ChatID)
+	assert.False(t, restrictCfg.Permissions.CanSendMessages)
+
+	require.Len(t, mockAPI.SendCalls(), 1)
+	alert := mockAPI.SendCalls()[0].C.(tbapi.MessageConfig)
+	assert.Equal(t, int64(456), alert.ChatID)
+	assert.Equal(t, "**raid detected in gr**\n\n3 members joined within 1m0s, lockdown for 1h0m0s: "+
+		"messages restricted, new members challenged", alert.Text)
+	markup := alert.ReplyMarkup.(tbapi.InlineKeyboardMarkup)
+	require.Len(t, markup.InlineKeyboard[0], 2)
+	assert.Equal(t, "raid:end:123", *markup.InlineKeyboard[0][0].CallbackData)
+	assert.True(t, strings.HasPrefix(*markup.InlineKeyboard[0][1].CallbackData, "raid:ban:123:"))
+
+	require.Len(t, store.WriteCalls(), 1, "lockdown persisted")
+	rs := store.WriteCalls()[0].Rs
+	assert.Equal(t, storage.RestrictionLockdown, rs.Kind)
+	assert.Equal(t, int64(123), rs.ChatID)
+	assert.Equal(t, int64(0), rs.UserID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), rs.ExpiresAt, time.Second)
+	assert.Contains(t, rs.Data, `"permissions":{"can_send_messages":true,"can_send_photos":true}`)
+	assert.Contains(t, rs.Data, `"msg_id":777`)
+
+	t.Run("joins during lockdown not counted", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		for range 5 {
+			require.NoError(t, r.Join(123, "gr"))
+		}
+		assert.Empty(t, mockAPI.SendCalls())
+		assert.Empty(t, mockAPI.RequestCalls())
+	})
+
+	t.Run("permissions saved before lockdown", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		assert.Equal(t, &tbapi.ChatPermissions{CanSendMessages: true, CanSendPhotos: true}, r.Permissions(123))
+		assert.Empty(t, mockAPI.GetChatCalls(), "saved permissions used")
+		assert.Equal(t, &tbapi.ChatPermissions{CanSendMessages: true, CanSendPhotos: true}, r.Permissions(124))
+		assert.Len(t, mockAPI.GetChatCalls(), 1, "current permissions of the chat not in lockdown")
+	})
+
+	t.Run("end", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		ended, err := r.End(123)
+		require.NoError(t, err)
+		assert.True(t, ended)
+		assert.False(t, r.Active(123))
+
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		restoreCfg := mockAPI.RequestCalls()[0].C.(tbapi.SetChatPermissionsConfig)
+		assert.Equal(t, &tbapi.ChatPermissions{CanSendMessages: true, CanSendPhotos: true}, restoreCfg.Permissions)
+
+		require.Len(t, mockAPI.SendCalls(), 1)
+		edit := mockAPI.SendCalls()[0].C.(tbapi.EditMessageTextConfig)
+		assert.Equal(t, 777, edit.MessageID)
+		assert.True(t, strings.HasSuffix(edit.Text, "\n\n_lockdown ended_"))
+		require.Len(t, edit.ReplyMarkup.InlineKeyboard[0], 1, "only ban button left")
+		assert.Equal(t, "ban all joined", edit.ReplyMarkup.InlineKeyboard[0][0].Text)
+		_, _, _, until, err := parseRaidData(*edit.ReplyMarkup.InlineKeyboard[0][0].CallbackData)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), until, 2*time.Second, "ban limited by the actual end of the lockdown")
+
+		require.Len(t, store.DeleteCalls(), 1, "persisted lockdown deleted")
+		assert.Equal(t, storage.RestrictionLockdown, store.DeleteCalls()[0].Kind)
+		assert.Equal(t, int64(123), store.DeleteCalls()[0].ChatID)
+	})
+
+	t.Run("end again", func(t *testing.T) {
+		mockAPI.ResetCalls()
+		ended, err := r.End(123)
+		require.NoError(t, err)
+		assert.False(t, ended)
+		assert.Empty(t, mockAPI.RequestCalls())
+	})
+}
+
+func TestRaid_LockdownExpired(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 777}, nil },
+	}
+	r := newRaid(RaidConfig{Enabled: true, Joins: 1, Lockdown: 10 * time.Millisecond, Paranoid: true}, mockAPI, 456, nil)
+	assert.Equal(t, time.Minute, r.Window, "default window")
+
+	require.NoError(t, r.Join(123, "gr"))
+	assert.True(t, r.Active(123))
+	assert.Eventually(t, func() bool { return !r.Active(123) }, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return len(mockAPI.SendCalls()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text, "lockdown for 10ms: all messages checked")
+}
+
+func TestRaid_Restore(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	store := &mocks.RestrictionsMock{
+		ListFunc: func(ctx context.Context, kind storage.RestrictionKind) ([]storage.Restriction, error) {
+			return []storage.Restriction{
+				{Kind: kind, ChatID: 123, ExpiresAt: time.Now().Add(-time.Minute),
+					Data: `{"group_name":"ended","permissions":{"can_send_messages":true},"alert":"raid","msg_id":777}`},
+				{Kind: kind, ChatID: 124, ExpiresAt: time.Now().Add(time.Hour),
+					Data: `{"group_name":"active","permissions":{"can_send_photos":true},"alert":"raid","msg_id":778}`},
+			}, nil
+		},
+		DeleteFunc: func(ctx context.Context, kind storage.RestrictionKind, chatID, userID int64) error { return nil },
+	}
+	r := newRaid(RaidConfig{Enabled: true, Restrict: true}, mockAPI, 456, store)
+	require.NoError(t, r.Restore())
+
+	// lockdown ended during downtime is ended right away, permissions restored and alert updated
+	require.Eventually(t, func() bool { return !r.Active(123) }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return len(mockAPI.SendCalls()) == 1 }, time.Second, 5*time.Millisecond)
+	require.Len(t, mockAPI.RequestCalls(), 1)
+	restoreCfg := mockAPI.RequestCalls()[0].C.(tbapi.SetChatPermissionsConfig)
+	assert.Equal(t, int64(123), restoreCfg.ChatID)
+	assert.Equal(t, &tbapi.ChatPermissions{CanSendMessages: true}, restoreCfg.Permissions)
+	assert.Equal(t, 777, mockAPI.SendCalls()[0].C.(tbapi.EditMessageTextConfig).MessageID)
+	require.Len(t, store.DeleteCalls(), 1)
+	assert.Equal(t, int64(123), store.DeleteCalls()[0].ChatID)
+
+	// active lockdown is still active, with permissions saved before restart
+	assert.True(t, r.Active(124))
+	assert.Equal(t, &tbapi.ChatPermissions{CanSendPhotos: true}, r.Permissions(124))
+	ended, err := r.End(124)
+	require.NoError(t, err)
+	assert.True(t, ended)
+	assert.Equal(t, &tbapi.ChatPermissions{CanSendPhotos: true},
+		mockAPI.RequestCalls()[1].C.(tbapi.SetChatPermissionsConfig).Permissions)
+
+	t.Run("store error", func(t *testing.T) {
+		store := &mocks.RestrictionsMock{
+			ListFunc: func(ctx context.Context, kind storage.RestrictionKind) ([]storage.Restriction, error) {
+				return nil, fmt.Errorf("db error")
+			},
+		}
+		r := newRaid(RaidConfig{Enabled: true}, mockAPI, 456, store)
+		require.EqualError(t, r.Restore(), "failed to load active lockdowns: db error")
+	})
+}
+
+func TestParseRaidData(t *testing.T) {
+	tbl := []struct {
+		data   string
+		action string
+		chatID int64
+		since  time.Time
+		until  time.Time
+		err    string
+	}{
+		{data: "raid:end:-100123", action: "end", chatID: -100123},
+		{data: "raid:ban:-100123:1700000000:1700001800", action: "ban", chatID: -100123, since: time.Unix(1700000000, 0),
+			until: time.Unix(1700001800, 0)},
+		{data: "raid:end", err: "unexpected data format"},
+		{data: "raid:end:abc", err: `failed to parse chat id: strconv.ParseInt: parsing "abc": invalid syntax`},
+		{data: "raid:ban:123", err: `unknown action "ban"`},
+		{data: "raid:ban:123:1700000000", err: `unknown action "ban"`},
+		{data: "raid:ban:123:abc:1700001800", err: `failed to parse time: strconv.ParseInt: parsing "abc": invalid syntax`},
+		{data: "raid:ban:123:1700000000:abc", err: `failed to parse time: strconv.ParseInt: parsing "abc": invalid syntax`},
+		{data: "raid:blah:123", err: `unknown action "blah"`},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.data, func(t *testing.T) {
+			action, chatID, since, until, err := parseRaidData(tt.data)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.action, action)
+			assert.Equal(t, tt.chatID, chatID)
+			assert.Equal(t, tt.since, since)
+			assert.Equal(t, tt.until, until)
+		})
+	}
+}
+
+func TestTelegramListener_RaidLockdown(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+			return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: 123}}, nil
+		},
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{MessageID: 555}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+		GetChatAdministratorsFunc: func(config tbapi.ChatAdministratorsConfig) ([]tbapi.ChatMember, error) {
+			return nil, nil
+		},
+	}
+	b := &mocks.BotMock{
+		OnMessageFunc:      func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response { return bot.Response{} },
+		IsApprovedUserFunc: func(userID int64) bool { return userID == 100 },
+	}
+
+	locator, teardown := prepTestLocator(t)
+	defer teardown()
+
+	l := TelegramListener{
+		TbAPI:      mockAPI,
+		Bot:        b,
+		SuperUsers: SuperUsers{"admin"},
+		Group:      "gr",
+		AdminGroup: "456",
+		Locator:    locator,
+		Raid:       RaidConfig{Enabled: true, Joins: 3, Window: time.Minute, Lockdown: time.Hour, Challenge: true, Paranoid: true},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	joinMsg := func(member tbapi.User, msgID int) tbapi.Update {
+		return tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, From: &member,
+			NewChatMembers: []tbapi.User{member}, MessageID: msgID}}
+	}
+	adminCallback := func(id, data string) tbapi.Update {
+		return tbapi.Update{CallbackQuery: &tbapi.CallbackQuery{ID: id, Data: data, From: &tbapi.User{ID: 1, UserName: "admin"},
+			Message: &tbapi.Message{Chat: tbapi.Chat{ID: 456}}}}
+	}
+	updChan := make(chan tbapi.Update, 10)
+	updChan <- joinMsg(tbapi.User{ID: 41, UserName: "raider1"}, 21)
+	updChan <- joinMsg(tbapi.User{ID: 42, UserName: "raider2"}, 22)
+	updChan <- joinMsg(tbapi.User{ID: 100, UserName: "approved"}, 23) // raid detected
+	updChan <- joinMsg(tbapi.User{ID: 43, UserName: "raider3"}, 24)   // challenged
+	updChan <- tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, From: &tbapi.User{ID: 100, UserName: "approved"},
+		Text: "hello", MessageID: 25}}
+	updChan <- adminCallback("q1", fmt.Sprintf("raid:ban:123:%d:%d", time.Now().Add(-time.Minute).Unix(),
+		time.Now().Add(time.Hour).Unix()))
+	updChan <- adminCallback("q2", "raid:end:123")
+	updChan <- tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, From: &tbapi.User{ID: 100, UserName: "approved"},
+		Text: "hello again", MessageID: 26}}
+	close(updChan)
+	mockAPI.GetUpdatesChanFunc = func(config tbapi.UpdateConfig) tbapi.UpdatesChannel { return updChan }
+
+	err := l.Do(ctx)
+	assert.EqualError(t, err, "telegram update chan closed")
+
+	var sent []string
+	for _, c := range mockAPI.SendCalls() {
+		switch m := c.C.(type) {
+		case tbapi.MessageConfig:
+			sent = append(sent, m.Text)
+		case tbapi.EditMessageTextConfig:
+			sent = append(sent, "edit: "+m.Text)
+		}
+	}
+	require.Len(t, sent, 4)
+	assert.Equal(t, "**raid detected in gr**\n\n3 members joined within 1m0s, lockdown for 1h0m0s: "+
+		"new members challenged, all messages checked", sent[0])
+	assert.True(t, strings.HasPrefix(sent[1], "@raider3 please confirm you are not a bot"), sent[1])
+	assert.True(t, strings.HasPrefix(sent[2], "3 of 4 members joined gr from"), sent[2])
+	assert.True(t, strings.HasSuffix(sent[3], "_lockdown ended_"), sent[3])
+
+	var banned []int64
+	var answers []string
+	for _, c := range mockAPI.RequestCalls() {
+		switch r := c.C.(type) {
+		case tbapi.BanChatMemberConfig:
+			banned = append(banned, r.UserID)
+		case tbapi.CallbackConfig:
+			answers = append(answers, r.Text)
+		}
+	}
+	assert.Equal(t, []int64{41, 42, 43}, banned, "approved user not banned")
+	assert.Equal(t, []string{"banned 3 users", "lockdown ended"}, answers)
+
+	require.Len(t, b.OnMessageCalls(), 2)
+	assert.True(t, b.OnMessageCalls()[0].Msg.Strict, "paranoid check during lockdown")
+	assert.False(t, b.OnMessageCalls()[1].Msg.Strict, "regular check after lockdown")
+}
+
+func TestTelegramListener_procRaidCallbackBanJoinedDuringLockdown(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	since, until := time.Unix(1700000000, 0), time.Unix(1700001800, 0)
+	locator := &mocks.LocatorMock{
+		JoinsFunc: func(ctx context.Context, chatID int64, from time.Time) ([]storage.MsgMeta, error) {
+			return []storage.MsgMeta{
+				{Time: since.Add(time.Second), ChatID: chatID, UserID: 41},
+				{Time: until.Add(500 * time.Millisecond), ChatID: chatID, UserID: 42}, // same second as the end
+				{Time: until.Add(time.Hour), ChatID: chatID, UserID: 43},              // joined after the lockdown
+			}, nil
+		},
+	}
+	b := &mocks.BotMock{IsApprovedUserFunc: func(userID int64) bool { return false }}
+	l := TelegramListener{TbAPI: mockAPI, Bot: b, Locator: locator, Group: "gr", adminChatID: 456,
+		raid: newRaid(RaidConfig{Enabled: true}, mockAPI, 456, nil)}
+
+	err := l.procRaidCallback(context.Background(), &tbapi.CallbackQuery{ID: "q1",
+		Data:    fmt.Sprintf("raid:ban:123:%d:%d", since.Unix(), until.Unix()),
+		Message: &tbapi.Message{Chat: tbapi.Chat{ID: 456}}})
+	require.NoError(t, err)
+
+	require.Len(t, locator.JoinsCalls(), 1)
+	assert.Equal(t, since, locator.JoinsCalls()[0].Since)
+	var banned []int64
+	for _, c := range mockAPI.RequestCalls() {
+		if r, ok := c.C.(tbapi.BanChatMemberConfig); ok {
+			banned = append(banned, r.UserID)
+		}
+	}
+	assert.Equal(t, []int64{41, 42}, banned, "member joined after the lockdown not banned")
+	require.Len(t, mockAPI.SendCalls(), 1)
+	assert.True(t, strings.HasPrefix(mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text, "2 of 2 members joined gr from"))
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// RateLimitConfig defines per-user message rate limits within a sliding window. Messages above the limit
+// are not checked by the detector, the action is applied to them instead.
+type RateLimitConfig struct {
+	Enabled  bool          // limit message rate of users
+	Window   time.Duration // sliding window to count messages within
+	NewUsers int           // max messages of not approved users within the window, 0 - unlimited
+	Approved int           // max messages of approved users within the window, 0 - unlimited
+	Action   bot.Action    // action on messages above the limit, delete, mute or report. Delete if not set
+}
+
+// rateKey is a key of the user's messages in the chat
+type rateKey struct {
+	chatID int64
+	userID int64
+}
+
+// rateLimit adds the message to the user's messages within the window and returns the number of messages
+// and the limit for the user. Messages sent before the restart are loaded from the locator on the first message
+// of the user. Zero limit means the user is not limited. Thread safe, rates are guarded by ratesLock.
+func (l *TelegramListener) rateLimit(ctx context.Context, g *chatGroup, msg *bot.Message) (count, limit int) {
+	if !l.RateLimit.Enabled || l.RateLimit.Window <= 0 || msg.From.ID == 0 || msg.SenderChat.ID != 0 ||
+		l.SuperUsers.IsSuper(msg.From.Username, msg.From.ID) {
+		return 0, 0
+	}
+	limit = l.RateLimit.NewUsers
+	if g.bot.IsApprovedUser(msg.From.ID) {
+		limit = l.RateLimit.Approved
+	}
+	if limit <= 0 {
+		return 0, 0
+	}
+
+	l.ratesLock.Lock()
+	defer l.ratesLock.Unlock()
+	now := time.Now()
+	if l.rates == nil || now.Sub(l.ratesCleanup) > l.RateLimit.Window {
+		l.cleanupRates(now)
+	}
+	key := rateKey{chatID: msg.ChatID, userID: msg.From.ID}
+	times, ok := l.rates[key]
+	if !ok {
+		// the first message of the user since the start, previous messages are loaded from the locator
+		msgs, err := g.locator.UserMessages(ctx, msg.ChatID, msg.From.ID, now.Add(-l.RateLimit.Window))
+		if err != nil {
+			log.Printf("[WARN] failed to get recent messages of %d: %v", msg.From.ID, err)
+		}
+		for _, m := range msgs {
+			times = append(times, m.Time)
+		}
+	}
+	times = append(dropExpired(times, now.Add(-l.RateLimit.Window)), now)
+	l.rates[key] = times
+	return len(times), limit
+}
+
+// procRateLimit applies the rate limit action to the message above the limit. The first message above the limit
+// gets the full action, including the report to admin chat, the next ones within the window are deleted only,
+// unless the action is report, to avoid flooding admin chat.
+func (l *TelegramListener) procRateLimit(g *chatGroup, msg *bot.Message, count, limit int) error {
+	action := l.RateLimit.Action
+	if action.Kind == bot.ActionNone {
+		action = bot.Action{Kind: bot.ActionDelete}
+	}
+	log.Printf("[INFO] rate limit for %q (%d) exceeded, %d messages within %v, limit: %d, action: %s",
+		msg.From.Username, msg.From.ID, count, l.RateLimit.Window, limit, action)
+
+	resp := bot.Response{Send: true, User: msg.From, ReplyTo: msg.ID, CheckResults: []spamcheck.Response{{Name: "rate-limit",
+		Spam: true, Details: fmt.Sprintf("%d messages within %v, limit %d", count, l.RateLimit.Window, limit)}}}
+	resp = resp.WithSpamAction(action)
+	if count == limit+1 {
+		return l.applySpamAction(g, msg, resp, fmt.Sprintf("%v", msg.From), msg.ChatID)
+	}
+	if !resp.DeleteReplyTo {
+		return nil
+	}
+	return l.deleteMessage(g, msg, resp.ReplyTo)
+}
+
+// cleanupRates removes users without messages within the window, called with ratesLock held
+func (l *TelegramListener) cleanupRates(now time.Time) {
+	if l.rates == nil {
+		l.rates = map[rateKey][]time.Time{}
+	}
+	for k, times := range l.rates {
+		if times = dropExpired(times, now.Add(-l.RateLimit.Window)); len(times) == 0 {
+			delete(l.rates, k)
+			continue
+		}
+		l.rates[k] = times
+	}
+	l.ratesCleanup = now
+}
+
+// dropExpired returns times after the cutoff, times are expected to be sorted
+func dropExpired(times []time.Time, cutoff time.Time) []time.Time {
+	idx := 0
+	for idx < len(times) && times[idx].Before(cutoff) {
+		idx++
+	}
+	return times[idx:]
+}
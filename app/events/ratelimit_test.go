@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events/mocks"
+	"github.com/umputun/tg-spam/app/storage"
+)
+
+func TestTelegramListener_procEventsWithRateLimit(t *testing.T) {
+	mockAPI := &mocks.TbAPIMock{
+		SendFunc: func(c tbapi.Chattable) (tbapi.Message, error) { return tbapi.Message{}, nil },
+		RequestFunc: func(c tbapi.Chattable) (*tbapi.APIResponse, error) {
+			return &tbapi.APIResponse{Ok: true}, nil
+		},
+	}
+	b := &mocks.BotMock{
+		IsApprovedUserFunc: func(userID int64) bool { return userID == 100 },
+		OnMessageFunc:      func(_ context.Context, msg bot.Message, checkOnly bool) bot.Response { return bot.Response{} },
+	}
+	locator := &mocks.LocatorMock{
+		AddMessageFunc: func(ctx context.Context, msg string, chatID, userID int64, userName string, msgID int) error {
+			return nil
+		},
+		UserMessagesFunc: func(ctx context.Context, chatID, userID int64, since time.Time) ([]storage.MsgMeta, error) {
+			if userID == 300 { // messages sent before the restart
+				return []storage.MsgMeta{{Time: time.Now().Add(-time.Second)}, {Time: time.Now().Add(-time.Second)}}, nil
+			}
+			return nil, nil
+		},
+	}
+	reset := func() {
+		mockAPI.ResetCalls()
+		b.ResetCalls()
+		locator.ResetCalls()
+	}
+	makeListener := func(action bot.Action) *TelegramListener {
+		l := &TelegramListener{TbAPI: mockAPI, Bot: b, Locator: locator, SuperUsers: SuperUsers{"admin"},
+			chatID: 123, adminChatID: 456, RateLimit: RateLimitConfig{Enabled: true, Window: time.Minute, NewUsers: 2,
+				Approved: 3, Action: action}}
+		l.adminHandler = &admin{tbAPI: mockAPI, bot: b, locator: locator, primChatID: 123, adminChatID: 456}
+		return l
+	}
+	update := func(userID int64, userName string, msgID int) tbapi.Update {
+		return tbapi.Update{Message: &tbapi.Message{Chat: tbapi.Chat{ID: 123}, Text: "hello", MessageID: msgID,
+			From: &tbapi.User{ID: userID, UserName: userName}}}
+	}
+
+	t.Run("new user, delete by default", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		for i := 1; i <= 4; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", i)))
+		}
+		assert.Len(t, b.OnMessageCalls(), 2, "messages above the limit are not checked")
+		assert.Len(t, locator.AddMessageCalls(), 4, "all messages added to locator")
+		assert.Len(t, locator.UserMessagesCalls(), 1, "recent messages loaded once")
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		assert.Equal(t, 3, mockAPI.RequestCalls()[0].C.(tbapi.DeleteMessageConfig).MessageID)
+		assert.Equal(t, 4, mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).MessageID)
+		require.Len(t, mockAPI.SendCalls(), 1, "reported once")
+		assert.Contains(t, mockAPI.SendCalls()[0].C.(tbapi.MessageConfig).Text, "deleted message from")
+	})
+
+	t.Run("approved user, mute", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{Kind: bot.ActionMute, Duration: time.Hour})
+		for i := 1; i <= 4; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(100, "approved", i)))
+		}
+		assert.Len(t, b.OnMessageCalls(), 3)
+		require.Len(t, mockAPI.RequestCalls(), 2)
+		restrict := mockAPI.RequestCalls()[0].C.(tbapi.RestrictChatMemberConfig)
+		assert.Equal(t, int64(100), restrict.UserID)
+		assert.Equal(t, 4, mockAPI.RequestCalls()[1].C.(tbapi.DeleteMessageConfig).MessageID)
+		require.Len(t, mockAPI.SendCalls(), 1)
+	})
+
+	t.Run("edits not counted", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 1)))
+		for range 3 {
+			upd := update(200, "new_user", 1)
+			upd.Message.EditDate = int(time.Now().Unix())
+			require.NoError(t, l.procEvents(context.Background(), upd))
+		}
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 2)))
+		assert.Len(t, b.OnMessageCalls(), 5, "all messages and edits checked")
+		assert.Len(t, locator.AddMessageCalls(), 2, "edits not added to locator")
+		assert.Empty(t, mockAPI.RequestCalls(), "limit not reached by edits")
+	})
+
+	t.Run("report only", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{Kind: bot.ActionReport})
+		for i := 1; i <= 5; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", i)))
+		}
+		assert.Empty(t, mockAPI.RequestCalls())
+		assert.Len(t, mockAPI.SendCalls(), 1, "reported once")
+	})
+
+	t.Run("messages before restart counted", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		require.NoError(t, l.procEvents(context.Background(), update(300, "restarted", 1)))
+		assert.Empty(t, b.OnMessageCalls())
+		require.Len(t, mockAPI.RequestCalls(), 1)
+		require.Len(t, locator.UserMessagesCalls(), 1)
+		assert.Equal(t, int64(123), locator.UserMessagesCalls()[0].ChatID)
+		assert.WithinDuration(t, time.Now().Add(-time.Minute), locator.UserMessagesCalls()[0].Since, time.Second)
+	})
+
+	t.Run("expired messages not counted", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 1)))
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 2)))
+		key := rateKey{chatID: 123, userID: 200}
+		l.rates[key][0] = time.Now().Add(-2 * time.Minute)
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 3)))
+		assert.Len(t, b.OnMessageCalls(), 3)
+		assert.Empty(t, mockAPI.RequestCalls())
+
+		l.ratesCleanup = time.Now().Add(-2 * time.Minute)
+		l.rates[rateKey{chatID: 123, userID: 500}] = []time.Time{time.Now().Add(-2 * time.Minute)}
+		require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", 4)))
+		assert.NotContains(t, l.rates, rateKey{chatID: 123, userID: 500}, "removed on cleanup")
+	})
+
+	t.Run("concurrent calls", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		l.RateLimit.NewUsers = 100
+		g := l.group(123)
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				msg := &bot.Message{ChatID: 123, From: bot.User{ID: int64(1000 + i%2), Username: "new_user"}}
+				l.rateLimit(context.Background(), g, msg)
+			}()
+		}
+		wg.Wait()
+		count, limit := l.rateLimit(context.Background(), g, &bot.Message{ChatID: 123, From: bot.User{ID: 1000}})
+		assert.Equal(t, 6, count, "all messages of the user counted")
+		assert.Equal(t, 100, limit)
+	})
+
+	t.Run("not limited", func(t *testing.T) {
+		reset()
+		l := makeListener(bot.Action{})
+		for i := 1; i <= 4; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(1, "admin", i)))
+		}
+		l.RateLimit.Approved = 0
+		for i := 1; i <= 4; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(100, "approved", i)))
+		}
+		l.RateLimit.Enabled = false
+		for i := 1; i <= 4; i++ {
+			require.NoError(t, l.procEvents(context.Background(), update(200, "new_user", i)))
+		}
+		assert.Len(t, b.OnMessageCalls(), 12)
+		assert.Empty(t, mockAPI.RequestCalls())
+		assert.Empty(t, locator.UserMessagesCalls())
+	})
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+
+	"github.com/umputun/tg-spam/app/bot"
+)
+
+// TopicPolicy defines processing of messages posted to a forum topic
+type TopicPolicy struct {
+	Exempt bool       // messages in the topic are not checked
+	Strict bool       // all messages in the topic are checked, including messages of approved users
+	Action bot.Action // minimal action on spam detected in the topic, e.g. ban in marketplace topic
+}
+
+// TopicPolicies is a set of topic policies by message thread ID
+type TopicPolicies map[int]TopicPolicy
+
+// ParseTopicRule parses topic rule from "topicID:rule" string. Rule is "exempt", "strict", or an action
+// like "ban", "mute:24h" or "delete", see bot.ParseAction. The rule is set in the returned policy.
+func ParseTopicRule(s string) (topicID int, p TopicPolicy, err error) {
+	idStr, rule, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || rule == "" {
+		return 0, TopicPolicy{}, fmt.Errorf("invalid topic rule %q, expected topic:rule", s)
+	}
+	if topicID, err = strconv.Atoi(idStr); err != nil || topicID <= 0 {
+		return 0, TopicPolicy{}, fmt.Errorf("invalid topic id in rule %q", s)
+	}
+	switch strings.ToLower(rule) {
+	case "exempt":
+		p.Exempt = true
+	case "strict":
+		p.Strict = true
+	default:
+		action, aErr := bot.ParseAction(rule)
+		if aErr != nil {
+			return 0, TopicPolicy{}, fmt.Errorf("invalid topic rule %q: %w", s, aErr)
+		}
+		p.Action = action
+	}
+	return topicID, p, nil
+}
+
+// Add adds the topic policy, combined with the policy already set for the topic.
+// The more severe action wins if both set.
+func (tp TopicPolicies) Add(topicID int, p TopicPolicy) {
+	res := tp[topicID]
+	res.Exempt = res.Exempt || p.Exempt
+	res.Strict = res.Strict || p.Strict
+	if p.Action.MoreSevere(res.Action) {
+		res.Action = p.Action
+	}
+	tp[topicID] = res
+}
+
+// threadID returns forum topic of the message, 0 for general topic and non-forum chats.
+// message thread id of non-forum chats refers to the replies thread, not to a topic.
+func threadID(msg *tbapi.Message) int {
+	if msg == nil || !msg.IsTopicMessage {
+		return 0
+	}
+	return msg.MessageThreadID
+}
+
+// topicLink returns the link to the forum topic of the supergroup, empty if the topic or chat is not set
+func topicLink(chatID int64, topicID int) string {
+	if topicID == 0 || chatID == 0 {
+		return ""
+	}
+	// supergroup ids are -100xxxxxxxxxx, links use the xxxxxxxxxx part
+	internalID := strings.TrimPrefix(strconv.FormatInt(chatID, 10), "-100")
+	return fmt.Sprintf("https://t.me/c/%s/%d", internalID, topicID)
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	tbapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/app/bot"
+)
+
+func TestParseTopicRule(t *testing.T) {
+	tbl := []struct {
+		rule    string
+		topicID int
+		policy  TopicPolicy
+		wantErr string
+	}{
+		{rule: "42:exempt", topicID: 42, policy: TopicPolicy{Exempt: true}},
+		{rule: " 7:Strict ", topicID: 7, policy: TopicPolicy{Strict: true}},
+		{rule: "12:ban", topicID: 12, policy: TopicPolicy{Action: bot.Action{Kind: bot.ActionBan}}},
+		{rule: "12:mute:24h", topicID: 12, policy: TopicPolicy{Action: bot.Action{Kind: bot.ActionMute, Duration: 24 * time.Hour}}},
+		{rule: "12", wantErr: `invalid topic rule "12", expected topic:rule`},
+		{rule: "12:", wantErr: `invalid topic rule "12:", expected topic:rule`},
+		{rule: "general:strict", wantErr: `invalid topic id in rule "general:strict"`},
+		{rule: "-1:strict", wantErr: `invalid topic id in rule "-1:strict"`},
+		{rule: "12:kick", wantErr: `invalid topic rule "12:kick": unknown action "kick"`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.rule, func(t *testing.T) {
+			topicID, p, err := ParseTopicRule(tt.rule)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.topicID, topicID)
+			assert.Equal(t, tt.policy, p)
+		})
+	}
+}
+
+func TestTopicPolicies_Add(t *testing.T) {
+	tp := TopicPolicies{}
+	tp.Add(12, TopicPolicy{Strict: true})
+	tp.Add(12, TopicPolicy{Action: bot.Action{Kind: bot.ActionMute, Duration: time.Hour}})
+	tp.Add(12, TopicPolicy{Action: bot.Action{Kind: bot.ActionDelete}})
+	tp.Add(42, TopicPolicy{Exempt: true})
+
+	assert.Equal(t, TopicPolicies{
+		12: {Strict: true, Action: bot.Action{Kind: bot.ActionMute, Duration: time.Hour}},
+		42: {Exempt: true},
+	}, tp)
+}
+
+func TestThreadID(t *testing.T) {
+	assert.Equal(t, 0, threadID(nil))
+	assert.Equal(t, 0, threadID(&tbapi.Message{MessageThreadID: 12}), "reply thread of non-forum chat")
+	assert.Equal(t, 12, threadID(&tbapi.Message{MessageThreadID: 12, IsTopicMessage: true}))
+}
+
+func TestTopicLink(t *testing.T) {
+	assert.Equal(t, "https://t.me/c/1234567890/12", topicLink(-1001234567890, 12))
+	assert.Empty(t, topicLink(-1001234567890, 0))
+	assert.Empty(t, topicLink(0, 12))
+}
@@ -41,10 +41,12 @@ type options struct {
 	DataBaseURL string `long:"db" env:"DB" default:"tg-spam.db" description:"database URL, if empty uses sqlite"`
 
 	Telegram struct {
-		Token        string        `long:"token" env:"TOKEN" description:"telegram bot token"`
-		Group        string        `long:"group" env:"GROUP" description:"group name/id"`
-		Timeout      time.Duration `long:"timeout" env:"TIMEOUT" default:"30s" description:"http client timeout for telegram" `
-		IdleDuration time.Duration `long:"idle" env:"IDLE" default:"30s" description:"idle duration"`
+		Token         string        `long:"token" env:"TOKEN" description:"telegram bot token"`
+		Group         string        `long:"group" env:"GROUP" description:"group name/id"`
+		Timeout       time.Duration `long:"timeout" env:"TIMEOUT" default:"30s" description:"http client timeout for telegram" `
+		IdleDuration  time.Duration `long:"idle" env:"IDLE" default:"30s" description:"idle duration"`
+		Groups        []string      `long:"groups" env:"GROUPS" env-delim:"," description:"additional group names/ids, as group[:gid]"`
+		SharedSamples bool          `long:"shared-samples" env:"SHARED_SAMPLES" description:"share samples of the primary group with additional groups"`
 	} `group:"telegram" namespace:"telegram" env-namespace:"TELEGRAM"`
 
 	AdminGroup              string `long:"admin.group" env:"ADMIN_GROUP" description:"admin group name, or channel id"`
@@ -74,28 +76,33 @@ type options struct {
 	} `group:"cas" namespace:"cas" env-namespace:"CAS"`
 
 	Meta struct {
-		LinksLimit      int    `long:"links-limit" env:"LINKS_LIMIT" default:"-1" description:"max links in message, disabled by default"`
-		MentionsLimit   int    `long:"mentions-limit" env:"MENTIONS_LIMIT" default:"-1" description:"max mentions in message, disabled by default"`
-		ImageOnly       bool   `long:"image-only" env:"IMAGE_ONLY" description:"enable image only check"`
-		LinksOnly       bool   `long:"links-only" env:"LINKS_ONLY" description:"enable links only check"`
-		VideosOnly      bool   `long:"video-only" env:"VIDEO_ONLY" description:"enable video only check"`
-		AudiosOnly      bool   `long:"audio-only" env:"AUDIO_ONLY" description:"enable audio only check"`
-		Forward         bool   `long:"forward" env:"FORWARD" description:"enable forward check"`
-		Keyboard        bool   `long:"keyboard" env:"KEYBOARD" description:"enable keyboard check"`
-		UsernameSymbols string `long:"username-symbols" env:"USERNAME_SYMBOLS" description:"prohibited symbols in username, disabled by default"`
+		LinksLimit      int      `long:"links-limit" env:"LINKS_LIMIT" default:"-1" description:"max links in message, disabled by default"`
+		MentionsLimit   int      `long:"mentions-limit" env:"MENTIONS_LIMIT" default:"-1" description:"max mentions in message, disabled by default"`
+		ImageOnly       bool     `long:"image-only" env:"IMAGE_ONLY" description:"enable image only check"`
+		LinksOnly       bool     `long:"links-only" env:"LINKS_ONLY" description:"enable links only check"`
+		VideosOnly      bool     `long:"video-only" env:"VIDEO_ONLY" description:"enable video only check"`
+		AudiosOnly      bool     `long:"audio-only" env:"AUDIO_ONLY" description:"enable audio only check"`
+		Forward         bool     `long:"forward" env:"FORWARD" description:"enable forward check"`
+		Keyboard        bool     `long:"keyboard" env:"KEYBOARD" description:"enable keyboard check"`
+		UsernameSymbols string   `long:"username-symbols" env:"USERNAME_SYMBOLS" description:"prohibited symbols in username, disabled by default"`
+		Documents       []string `long:"documents" env:"DOCUMENTS" env-delim:"," description:"prohibited document extensions or file name patterns, disabled by default"`
+		Contact         bool     `long:"contact" env:"CONTACT" description:"enable contact card check"`
+		ViaBot          bool     `long:"via-bot" env:"VIA_BOT" description:"enable inline bot (via bot) check"`
+		ViaBotAllowed   []string `long:"via-bot-allowed" env:"VIA_BOT_ALLOWED" env-delim:"," description:"inline bots allowed by via-bot check"`
 	} `group:"meta" namespace:"meta" env-namespace:"META"`
 
 	OpenAI struct {
-		Token                            string `long:"token" env:"TOKEN" description:"openai token, disabled if not set"`
-		APIBase                          string `long:"apibase" env:"API_BASE" description:"custom openai API base, default is https://api.openai.com/v1"`
-		Veto                             bool   `long:"veto" env:"VETO" description:"veto mode, confirm detected spam"`
-		Prompt                           string `long:"prompt" env:"PROMPT" default:"" description:"openai system prompt, if empty uses builtin default"`
-		Model                            string `long:"model" env:"MODEL" default:"gpt-4o-mini" description:"openai model"`
-		MaxTokensResponse                int    `long:"max-tokens-response" env:"MAX_TOKENS_RESPONSE" default:"1024" description:"openai max tokens in response"`
-		MaxTokensRequestMaxTokensRequest int    `long:"max-tokens-request" env:"MAX_TOKENS_REQUEST" default:"2048" description:"openai max tokens in request"`
-		MaxSymbolsRequest                int    `long:"max-symbols-request" env:"MAX_SYMBOLS_REQUEST" default:"16000" description:"openai max symbols in request, failback if tokenizer failed"`
-		RetryCount                       int    `long:"retry-count" env:"RETRY_COUNT" default:"1" description:"openai retry count"`
-		HistorySize                      int    `long:"history-size" env:"HISTORY_SIZE" default:"0" description:"openai history size"`
+		Token                            string        `long:"token" env:"TOKEN" description:"openai token, disabled if not set"`
+		APIBase                          string        `long:"apibase" env:"API_BASE" description:"custom openai API base, default is https://api.openai.com/v1"`
+		Veto                             bool          `long:"veto" env:"VETO" description:"veto mode, confirm detected spam"`
+		Prompt                           string        `long:"prompt" env:"PROMPT" default:"" description:"openai system prompt, if empty uses builtin default"`
+		Model                            string        `long:"model" env:"MODEL" default:"gpt-4o-mini" description:"openai model"`
+		MaxTokensResponse                int           `long:"max-tokens-response" env:"MAX_TOKENS_RESPONSE" default:"1024" description:"openai max tokens in response"`
+		MaxTokensRequestMaxTokensRequest int           `long:"max-tokens-request" env:"MAX_TOKENS_REQUEST" default:"2048" description:"openai max tokens in request"`
+		MaxSymbolsRequest                int           `long:"max-symbols-request" env:"MAX_SYMBOLS_REQUEST" default:"16000" description:"openai max symbols in request, failback if tokenizer failed"`
+		RetryCount                       int           `long:"retry-count" env:"RETRY_COUNT" default:"1" description:"openai retry count"`
+		HistorySize                      int           `long:"history-size" env:"HISTORY_SIZE" default:"0" description:"openai history size"`
+		Timeout                          time.Duration `long:"timeout" env:"TIMEOUT" default:"0s" description:"openai check timeout, including retries, 0 to use check-timeout"`
 	} `group:"openai" namespace:"openai" env-namespace:"OPENAI"`
 
 	AbnormalSpacing struct {
@@ -106,6 +113,118 @@ type options struct {
 		MinWords                int     `long:"min-words" env:"MIN_WORDS" default:"5" description:"the minimum number of words in the message to check"`
 	} `group:"space" namespace:"space" env-namespace:"SPACE"`
 
+	Score struct {
+		Threshold float64            `long:"threshold" env:"THRESHOLD" default:"0" description:"total score to consider message as spam, 0 to disable scoring"`
+		Weights   map[string]float64 `long:"weight" env:"WEIGHT" env-delim:"," description:"score weight of a check, as name:weight"`
+		Hard      []string           `long:"hard" env:"HARD" env-delim:"," description:"checks detecting spam regardless of the total score"`
+	} `group:"score" namespace:"score" env-namespace:"SCORE"`
+
+	Policy struct {
+		Rules   []string `long:"rule" env:"RULE" env-delim:"," description:"spam action rule, as check[<score|>=score]:action[:duration]"`
+		Default string   `long:"default" env:"DEFAULT" default:"ban" description:"spam action if no rule matched, ban|mute:duration|warn|delete|report"`
+	} `group:"policy" namespace:"policy" env-namespace:"POLICY"`
+
+	Topics struct {
+		Rules []string `long:"rule" env:"RULE" env-delim:"," description:"forum topic rule, as [group/]topic:exempt|strict|action[:duration]"`
+	} `group:"topics" namespace:"topics" env-namespace:"TOPICS"`
+
+	Strikes struct {
+		Enabled bool          `long:"enabled" env:"ENABLED" description:"enable strikes with escalation ladder"`
+		Ladder  []string      `long:"ladder" env:"LADDER" env-delim:"," default:"warn" default:"mute:1h" default:"mute:24h" default:"ban" description:"escalation ladder, action for each strike"`
+		TTL     time.Duration `long:"ttl" env:"TTL" default:"720h" description:"strikes expiration period, 0 - never expire"`
+	} `group:"strikes" namespace:"strikes" env-namespace:"STRIKES"`
+
+	Domains struct {
+		Enabled        bool          `long:"enabled" env:"ENABLED" description:"enable domain reputation check with denied and allowed domains"`
+		DenyShorteners bool          `long:"deny-shorteners" env:"DENY_SHORTENERS" description:"consider links via url shorteners as spam unless resolved to allowed domain"`
+		Resolve        bool          `long:"resolve" env:"RESOLVE" description:"resolve shortened links to the final url"`
+		ResolveTimeout time.Duration `long:"resolve-timeout" env:"RESOLVE_TIMEOUT" default:"5s" description:"timeout to resolve shortened link"`
+	} `group:"domains" namespace:"domains" env-namespace:"DOMAINS"`
+
+	Mentions struct {
+		Enabled  bool          `long:"enabled" env:"ENABLED" description:"enable check of mentioned channels, bots and recently created accounts"`
+		Allowed  []string      `long:"allowed" env:"ALLOWED" env-delim:"," description:"usernames allowed to be mentioned"`
+		RecentID int64         `long:"recent-id" env:"RECENT_ID" default:"0" description:"mentioned targets with id above are recently created, 0 - disabled"`
+		TTL      time.Duration `long:"ttl" env:"TTL" default:"24h" description:"cache ttl of resolved mentions"`
+	} `group:"mentions" namespace:"mentions" env-namespace:"MENTIONS"`
+
+	ProfileCheck struct {
+		Enabled bool          `long:"enabled" env:"ENABLED" description:"enable check of user's display name and bio, on join and first messages"`
+		Bio     bool          `long:"bio" env:"BIO" description:"fetch and check user's bio"`
+		TTL     time.Duration `long:"ttl" env:"TTL" default:"24h" description:"cache ttl of fetched bios"`
+	} `group:"profile-check" namespace:"profile-check" env-namespace:"PROFILE_CHECK"`
+
+	Campaign struct {
+		Enabled    bool          `long:"enabled" env:"ENABLED" description:"enable detection of near-duplicate messages sent by different new users"`
+		MinUsers   int           `long:"min-users" env:"MIN_USERS" default:"3" description:"number of different users to consider messages a campaign"`
+		Window     time.Duration `long:"window" env:"WINDOW" default:"10m" description:"time window to count near-duplicate messages within"`
+		Similarity float64       `long:"similarity" env:"SIMILARITY" default:"0.9" description:"similarity of near-duplicate messages, 0 - same text only"`
+		MinTokens  int           `long:"min-tokens" env:"MIN_TOKENS" default:"5" description:"min number of words of the message to be counted"`
+	} `group:"campaign" namespace:"campaign" env-namespace:"CAMPAIGN"`
+
+	Normalize struct {
+		Enabled     bool `long:"enabled" env:"ENABLED" description:"normalize homoglyphs, fancy letters, leetspeak and repeated letters"`
+		Obfuscation bool `long:"obfuscation" env:"OBFUSCATION" description:"mark messages with obfuscated words as spam"`
+	} `group:"normalize" namespace:"normalize" env-namespace:"NORMALIZE"`
+
+	Stemming struct {
+		Enabled bool `long:"enabled" env:"ENABLED" description:"reduce words to their stems, english and russian"`
+	} `group:"stemming" namespace:"stemming" env-namespace:"STEMMING"`
+
+	NGrams struct {
+		Words       int `long:"words" env:"WORDS" default:"0" description:"max length of word n-grams for classifier, 2 or 3, 0 - disabled"`
+		Chars       int `long:"chars" env:"CHARS" default:"0" description:"length of character n-grams for classifier, 0 - disabled"`
+		MinCount    int `long:"min-count" env:"MIN_COUNT" default:"2" description:"prune n-grams found in fewer samples"`
+		MaxFeatures int `long:"max-features" env:"MAX_FEATURES" default:"100000" description:"max number of n-grams kept, 0 - unlimited"`
+	} `group:"ngrams" namespace:"ngrams" env-namespace:"NGRAMS"`
+
+	Explain struct {
+		Tokens int `long:"tokens" env:"TOKENS" default:"5" description:"number of top classifier tokens reported as evidence, 0 - none"`
+	} `group:"explain" namespace:"explain" env-namespace:"EXPLAIN"`
+
+	Formatting struct {
+		Enabled            bool    `long:"enabled" env:"ENABLED" description:"enable check of invisible characters and formatting tricks"`
+		InvisibleRatio     float64 `long:"invisible-ratio" env:"INVISIBLE_RATIO" default:"0.05" description:"max ratio of invisible characters"`
+		BidiOverrides      int     `long:"bidi-overrides" env:"BIDI_OVERRIDES" default:"0" description:"max number of bidi override characters"`
+		CombiningMarks     int     `long:"combining-marks" env:"COMBINING_MARKS" default:"3" description:"max combining marks stacked on a character"`
+		VariationSelectors int     `long:"variation-selectors" env:"VARIATION_SELECTORS" default:"2" description:"max variation selectors not attached to emoji"`
+	} `group:"formatting" namespace:"formatting" env-namespace:"FORMATTING"`
+
+	RateLimit struct {
+		Enabled  bool          `long:"enabled" env:"ENABLED" description:"enable per-user message rate limits"`
+		Window   time.Duration `long:"window" env:"WINDOW" default:"1m" description:"time window to count messages within"`
+		NewUsers int           `long:"new-users" env:"NEW_USERS" default:"5" description:"max messages of new users within the window, 0 - unlimited"`
+		Approved int           `long:"approved" env:"APPROVED" default:"20" description:"max messages of approved users within the window, 0 - unlimited"`
+		Action   string        `long:"action" env:"ACTION" default:"delete" description:"action on messages above the limit, delete, mute:duration or report"`
+	} `group:"rate-limit" namespace:"rate-limit" env-namespace:"RATE_LIMIT"`
+
+	Raid struct {
+		Enabled   bool          `long:"enabled" env:"ENABLED" description:"enable detection of mass-join raids"`
+		Joins     int           `long:"joins" env:"JOINS" default:"10" description:"number of joins within the window to detect a raid"`
+		Window    time.Duration `long:"window" env:"WINDOW" default:"1m" description:"time window to count joins within"`
+		Lockdown  time.Duration `long:"lockdown" env:"LOCKDOWN" default:"30m" description:"lockdown duration after the raid detected"`
+		Restrict  bool          `long:"restrict" env:"RESTRICT" description:"restrict sending messages in the chat during lockdown"`
+		Challenge bool          `long:"challenge" env:"CHALLENGE" description:"challenge new members during lockdown"`
+		Paranoid  bool          `long:"paranoid" env:"PARANOID" description:"check all messages during lockdown, including approved users"`
+	} `group:"raid" namespace:"raid" env-namespace:"RAID"`
+
+	Captcha struct {
+		Enabled      bool          `long:"enabled" env:"ENABLED" description:"enable join challenge for new members"`
+		Timeout      time.Duration `long:"timeout" env:"TIMEOUT" default:"2m" description:"time to solve the challenge, kicked out if not solved"`
+		Arithmetic   bool          `long:"arithmetic" env:"ARITHMETIC" description:"ask to solve arithmetic task instead of pressing a button"`
+		Message      string        `long:"msg" env:"MSG" default:"please confirm you are not a bot" description:"challenge message"`
+		CountMessage bool          `long:"count-message" env:"COUNT_MESSAGE" description:"count passed challenge as one of first messages"`
+	} `group:"captcha" namespace:"captcha" env-namespace:"CAPTCHA"`
+
+	JoinRequests struct {
+		Enabled bool `long:"enabled" env:"ENABLED" description:"screen join requests, approve clean and decline suspicious users"`
+		Review  bool `long:"review" env:"REVIEW" description:"forward suspicious join requests to admin chat instead of declining"`
+	} `group:"join-requests" namespace:"join-requests" env-namespace:"JOIN_REQUESTS"`
+
+	Profiles struct {
+		Assign map[string]string `long:"assign" env:"ASSIGN" env-delim:"," description:"detector profile of a group, as group:profile"`
+	} `group:"profiles" namespace:"profiles" env-namespace:"PROFILES"`
+
 	Files struct {
 		SamplesDataPath string        `long:"samples" env:"SAMPLES" default:"preset" description:"samples data path, deprecated"`
 		DynamicDataPath string        `long:"dynamic" env:"DYNAMIC" default:"data" description:"dynamic data path"`
@@ -118,9 +237,14 @@ type options struct {
 	MinSpamProbability  float64 `long:"min-probability" env:"MIN_PROBABILITY" default:"50" description:"min spam probability percent to ban"`
 	MultiLangWords      int     `long:"multi-lang" env:"MULTI_LANG" default:"0" description:"number of words in different languages to consider as spam"`
 
+	CheckTimeout time.Duration `long:"check-timeout" env:"CHECK_TIMEOUT" default:"0s" description:"timeout for a single check, 0 to disable"`
+	StopOnSpam   bool          `long:"stop-on-spam" env:"STOP_ON_SPAM" description:"skip the remaining checks once spam is detected"`
+
 	ParanoidMode       bool `long:"paranoid" env:"PARANOID" description:"paranoid mode, check all messages"`
 	FirstMessagesCount int  `long:"first-messages-count" env:"FIRST_MESSAGES_COUNT" default:"1" description:"number of first messages to check"`
 
+	EditRecheckWindow time.Duration `long:"edit-recheck-window" env:"EDIT_RECHECK_WINDOW" default:"0s" description:"re-check edited messages of users approved within this window, 0 to disable"`
+
 	Message struct {
 		Startup string `long:"startup" env:"STARTUP" default:"" description:"startup message"`
 		Spam    string `long:"spam" env:"SPAM" default:"this is spam" description:"spam message"`
@@ -230,11 +354,18 @@ func execute(ctx context.Context, opts options) error {
 		return fmt.Errorf("can't make db, %w", err)
 	}
 
+	// make profiles store and apply the profile assigned to the primary group
+	profilesStore, err := storage.NewProfiles(ctx, dataDB)
+	if err != nil {
+		return fmt.Errorf("can't make profiles store, %w", err)
+	}
+	primaryOpts := profileOptions(ctx, opts, profilesStore, opts.Telegram.Group)
+
 	// make detector with all sample files loaded
-	detector := makeDetector(opts)
+	detector := makeDetector(primaryOpts)
 
 	// make spam bot
-	spamBot, err := makeSpamBot(ctx, opts, dataDB, detector)
+	spamBot, err := makeSpamBot(ctx, primaryOpts, dataDB, detector)
 	if err != nil {
 		return fmt.Errorf("can't make spam bot, %w", err)
 	}
@@ -261,10 +392,16 @@ func execute(ctx context.Context, opts options) error {
 		return fmt.Errorf("can't make locator, %w", err)
 	}
 
+	// make strikes store and escalation ladder, store is nil if strikes disabled
+	strikesStore, strikesLadder, err := makeStrikes(ctx, opts, dataDB)
+	if err != nil {
+		return fmt.Errorf("can't make strikes, %w", err)
+	}
+
 	// activate web server if enabled
 	if opts.Server.Enabled {
 		// server starts in background goroutine
-		if srvErr := activateServer(ctx, opts, spamBot, locator, dataDB); srvErr != nil {
+		if srvErr := activateServer(ctx, opts, spamBot, locator, strikesStore, profilesStore, dataDB); srvErr != nil {
 			return fmt.Errorf("can't activate web server, %w", srvErr)
 		}
 		// if no telegram token and group set, just run the server
@@ -295,6 +432,21 @@ func execute(ctx context.Context, opts options) error {
 		return fmt.Errorf("can't make spam logger, %w", err)
 	}
 
+	// make additional groups with own detectors and storage
+	groups, err := makeGroups(ctx, opts, dataDB, profilesStore, loggerWr)
+	if err != nil {
+		return fmt.Errorf("can't make groups, %w", err)
+	}
+	topics, err := makeTopicPolicies(opts)
+	if err != nil {
+		return fmt.Errorf("can't make topic policies, %w", err)
+	}
+
+	rateLimit, err := makeRateLimit(opts)
+	if err != nil {
+		return fmt.Errorf("can't make rate limit, %w", err)
+	}
+
 	// make telegram listener
 	tgListener := events.TelegramListener{
 		TbAPI:                   tbAPI,
@@ -303,7 +455,7 @@ func execute(ctx context.Context, opts options) error {
 		SuperUsers:              opts.SuperUsers,
 		Bot:                     spamBot,
 		StartupMsg:              opts.Message.Startup,
-		WarnMsg:                 opts.Message.Warn,
+		WarnMsg:                 primaryOpts.Message.Warn,
 		NoSpamReply:             opts.NoSpamReply,
 		SuppressJoinMessage:     opts.SuppressJoinMessage,
 		SpamLogger:              spamLogger,
@@ -314,6 +466,41 @@ func execute(ctx context.Context, opts options) error {
 		SoftBanMode:             opts.SoftBan,
 		DisableAdminSpamForward: opts.DisableAdminSpamForward,
 		Dry:                     opts.Dry,
+		StrikesLadder:           strikesLadder,
+		Groups:                  groups,
+		Captcha: events.CaptchaConfig{Enabled: opts.Captcha.Enabled, Timeout: opts.Captcha.Timeout,
+			Arithmetic: opts.Captcha.Arithmetic, Message: opts.Captcha.Message, CountMessage: opts.Captcha.CountMessage},
+		JoinRequests:      events.JoinRequestConfig{Enabled: opts.JoinRequests.Enabled, Review: opts.JoinRequests.Review},
+		EditRecheckWindow: opts.EditRecheckWindow,
+		Topics:            topics[""],
+		RateLimit:         rateLimit,
+		Raid: events.RaidConfig{Enabled: opts.Raid.Enabled, Joins: opts.Raid.Joins, Window: opts.Raid.Window,
+			Lockdown: opts.Raid.Lockdown, Restrict: opts.Raid.Restrict, Challenge: opts.Raid.Challenge, Paranoid: opts.Raid.Paranoid},
+	}
+	if strikesStore != nil {
+		tgListener.Strikes = strikesStore
+	}
+	if err := activateMentionResolver(ctx, opts, dataDB, tbAPI, spamBot, groups); err != nil {
+		return fmt.Errorf("can't activate mention resolver, %w", err)
+	}
+	if opts.Domains.Enabled {
+		// dictionary is common for all groups, domain commands in admin chat update denied and allowed domains
+		dictionaryStore, dictErr := storage.NewDictionary(ctx, dataDB)
+		if dictErr != nil {
+			return fmt.Errorf("can't make dictionary store, %w", dictErr)
+		}
+		tgListener.Dictionary = dictionaryStore
+	}
+	if opts.Captcha.Enabled || opts.Raid.Enabled {
+		// pending join challenges and lockdowns are kept in db, restrictions set by them are lifted after restart
+		restrictionsStore, rsErr := storage.NewRestrictions(ctx, dataDB)
+		if rsErr != nil {
+			return fmt.Errorf("can't make restrictions store, %w", rsErr)
+		}
+		tgListener.Restrictions = restrictionsStore
+	}
+	if tgListener.Profile, err = makeProfileConfig(ctx, opts, dataDB); err != nil {
+		return fmt.Errorf("can't make profile check config, %w", err)
 	}
 
 	log.Printf("[DEBUG] telegram listener config: {group: %s, idle: %v, super: %v, admin: %s, testing: %v, no-reply: %v,"+
@@ -405,7 +592,8 @@ func checkVolumeMount(opts options) (ok bool) {
 	return false
 }
 
-func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *storage.Locator, db *engine.SQL) (err error) {
+func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *storage.Locator, strikes *storage.Strikes,
+	profiles *storage.Profiles, db *engine.SQL) (err error) {
 	authPassswd := opts.Server.AuthPasswd
 	if opts.Server.AuthPasswd == "auto" {
 		authPassswd, err = webapi.GenerateRandomPassword(20)
@@ -425,6 +613,14 @@ func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *
 		return fmt.Errorf("can't make detected spam store, %w", dsErr)
 	}
 
+	// make dictionary store to manage denied and allowed domains, common for all groups
+	var dictionaryStore *storage.Dictionary
+	if opts.Domains.Enabled {
+		if dictionaryStore, err = storage.NewDictionary(ctx, db); err != nil {
+			return fmt.Errorf("can't make dictionary store, %w", err)
+		}
+	}
+
 	settings := webapi.Settings{
 		InstanceID:              opts.InstanceID,
 		PrimaryGroup:            opts.Telegram.Group,
@@ -435,38 +631,44 @@ func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *
 		StorageTimeout:          opts.StorageTimeout,
 		NoSpamReply:             opts.NoSpamReply,
 		CasEnabled:              opts.CAS.API != "",
-		MetaEnabled:             opts.Meta.ImageOnly || opts.Meta.LinksLimit >= 0 || opts.Meta.MentionsLimit >= 0 || opts.Meta.LinksOnly || opts.Meta.VideosOnly || opts.Meta.AudiosOnly || opts.Meta.Forward || opts.Meta.Keyboard || opts.Meta.UsernameSymbols != "",
-		MetaLinksLimit:          opts.Meta.LinksLimit,
-		MetaMentionsLimit:       opts.Meta.MentionsLimit,
-		MetaLinksOnly:           opts.Meta.LinksOnly,
-		MetaImageOnly:           opts.Meta.ImageOnly,
-		MetaVideoOnly:           opts.Meta.VideosOnly,
-		MetaAudioOnly:           opts.Meta.AudiosOnly,
-		MetaForwarded:           opts.Meta.Forward,
-		MetaKeyboard:            opts.Meta.Keyboard,
-		MetaUsernameSymbols:     opts.Meta.UsernameSymbols,
-		MultiLangLimit:          opts.MultiLangWords,
-		OpenAIEnabled:           opts.OpenAI.Token != "" || opts.OpenAI.APIBase != "",
-		OpenAIVeto:              opts.OpenAI.Veto,
-		OpenAIHistorySize:       opts.OpenAI.HistorySize,
-		OpenAIModel:             opts.OpenAI.Model,
-		SamplesDataPath:         opts.Files.SamplesDataPath,
-		DynamicDataPath:         opts.Files.DynamicDataPath,
-		WatchIntervalSecs:       int(opts.Files.WatchInterval.Seconds()),
-		SimilarityThreshold:     opts.SimilarityThreshold,
-		MinMsgLen:               opts.MinMsgLen,
-		MaxEmoji:                opts.MaxEmoji,
-		MinSpamProbability:      opts.MinSpamProbability,
-		ParanoidMode:            opts.ParanoidMode,
-		FirstMessagesCount:      opts.FirstMessagesCount,
-		StartupMessageEnabled:   opts.Message.Startup != "",
-		TrainingEnabled:         opts.Training,
-		SoftBanEnabled:          opts.SoftBan,
-		AbnormalSpacingEnabled:  opts.AbnormalSpacing.Enabled,
-		HistorySize:             opts.HistorySize,
-		DebugModeEnabled:        opts.Dbg,
-		DryModeEnabled:          opts.Dry,
-		TGDebugModeEnabled:      opts.TGDbg,
+		MetaEnabled: opts.Meta.ImageOnly || opts.Meta.LinksLimit >= 0 || opts.Meta.MentionsLimit >= 0 || opts.Meta.LinksOnly || opts.Meta.VideosOnly || opts.Meta.AudiosOnly || opts.Meta.Forward || opts.Meta.Keyboard || opts.Meta.UsernameSymbols != "" ||
+			len(opts.Meta.Documents) > 0 || opts.Meta.Contact || opts.Meta.ViaBot,
+		MetaLinksLimit:         opts.Meta.LinksLimit,
+		MetaMentionsLimit:      opts.Meta.MentionsLimit,
+		MetaLinksOnly:          opts.Meta.LinksOnly,
+		MetaImageOnly:          opts.Meta.ImageOnly,
+		MetaVideoOnly:          opts.Meta.VideosOnly,
+		MetaAudioOnly:          opts.Meta.AudiosOnly,
+		MetaForwarded:          opts.Meta.Forward,
+		MetaKeyboard:           opts.Meta.Keyboard,
+		MetaUsernameSymbols:    opts.Meta.UsernameSymbols,
+		MetaDocuments:          opts.Meta.Documents,
+		MetaContact:            opts.Meta.Contact,
+		MetaViaBot:             opts.Meta.ViaBot,
+		MultiLangLimit:         opts.MultiLangWords,
+		OpenAIEnabled:          opts.OpenAI.Token != "" || opts.OpenAI.APIBase != "",
+		OpenAIVeto:             opts.OpenAI.Veto,
+		OpenAIHistorySize:      opts.OpenAI.HistorySize,
+		OpenAIModel:            opts.OpenAI.Model,
+		SamplesDataPath:        opts.Files.SamplesDataPath,
+		DynamicDataPath:        opts.Files.DynamicDataPath,
+		WatchIntervalSecs:      int(opts.Files.WatchInterval.Seconds()),
+		SimilarityThreshold:    opts.SimilarityThreshold,
+		MinMsgLen:              opts.MinMsgLen,
+		MaxEmoji:               opts.MaxEmoji,
+		MinSpamProbability:     opts.MinSpamProbability,
+		ParanoidMode:           opts.ParanoidMode,
+		FirstMessagesCount:     opts.FirstMessagesCount,
+		StartupMessageEnabled:  opts.Message.Startup != "",
+		TrainingEnabled:        opts.Training,
+		SoftBanEnabled:         opts.SoftBan,
+		AbnormalSpacingEnabled: opts.AbnormalSpacing.Enabled,
+		DomainsEnabled:         opts.Domains.Enabled,
+		HistorySize:            opts.HistorySize,
+		DebugModeEnabled:       opts.Dbg,
+		DryModeEnabled:         opts.Dry,
+		TGDebugModeEnabled:     opts.TGDbg,
+		ProfileAssignments:     opts.Profiles.Assign,
 	}
 
 	srv := webapi.Server{Config: webapi.Config{
@@ -475,6 +677,7 @@ func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *
 		SpamFilter:    sf,
 		Locator:       loc,
 		DetectedSpam:  detectedSpamStore,
+		Profiles:      profiles,
 		StorageEngine: db, // add database engine for backup functionality
 		AuthPasswd:    authPassswd,
 		AuthHash:      opts.Server.AuthHash,
@@ -482,6 +685,12 @@ func activateServer(ctx context.Context, opts options, sf *bot.SpamFilter, loc *
 		Dbg:           opts.Dbg,
 		Settings:      settings,
 	}}
+	if strikes != nil {
+		srv.Strikes = strikes
+	}
+	if dictionaryStore != nil {
+		srv.Dictionary = dictionaryStore
+	}
 
 	go func() {
 		if err := srv.Run(ctx); err != nil {
@@ -507,7 +716,40 @@ func makeDetector(opts options) *tgspam.Detector {
 		OpenAIHistorySize:   opts.OpenAI.HistorySize, // how many last requests sent to openai
 		MultiLangWords:      opts.MultiLangWords,
 		HistorySize:         opts.HistorySize, // how many last request stored in memory
-	}
+		CheckTimeout:        opts.CheckTimeout,
+		CheckTimeouts:       map[string]time.Duration{"cas": opts.CAS.Timeout},
+		StopOnSpam:          opts.StopOnSpam,
+	}
+	if opts.OpenAI.Timeout > 0 {
+		detectorConfig.CheckTimeouts["openai"] = opts.OpenAI.Timeout
+	}
+	detectorConfig.Scoring.Threshold = opts.Score.Threshold
+	detectorConfig.Scoring.Weights = opts.Score.Weights
+	detectorConfig.Scoring.HardChecks = opts.Score.Hard
+	detectorConfig.Domains.Enabled = opts.Domains.Enabled
+	detectorConfig.Domains.DenyShorteners = opts.Domains.DenyShorteners
+	detectorConfig.Mentions.Enabled = opts.Mentions.Enabled
+	detectorConfig.Mentions.Allowed = opts.Mentions.Allowed
+	detectorConfig.Mentions.RecentID = opts.Mentions.RecentID
+	detectorConfig.Profile.Enabled = opts.ProfileCheck.Enabled
+	detectorConfig.Campaign.Enabled = opts.Campaign.Enabled
+	detectorConfig.Campaign.MinUsers = opts.Campaign.MinUsers
+	detectorConfig.Campaign.Window = opts.Campaign.Window
+	detectorConfig.Campaign.Similarity = opts.Campaign.Similarity
+	detectorConfig.Campaign.MinTokens = opts.Campaign.MinTokens
+	detectorConfig.Normalize.Enabled = opts.Normalize.Enabled
+	detectorConfig.Normalize.Obfuscation = opts.Normalize.Obfuscation
+	detectorConfig.Stemming.Enabled = opts.Stemming.Enabled
+	detectorConfig.NGrams.Words = opts.NGrams.Words
+	detectorConfig.NGrams.Chars = opts.NGrams.Chars
+	detectorConfig.NGrams.MinCount = opts.NGrams.MinCount
+	detectorConfig.NGrams.MaxFeatures = opts.NGrams.MaxFeatures
+	detectorConfig.Explain.Tokens = opts.Explain.Tokens
+	detectorConfig.Formatting.Enabled = opts.Formatting.Enabled
+	detectorConfig.Formatting.InvisibleRatio = opts.Formatting.InvisibleRatio
+	detectorConfig.Formatting.BidiOverrides = opts.Formatting.BidiOverrides
+	detectorConfig.Formatting.CombiningMarks = opts.Formatting.CombiningMarks
+	detectorConfig.Formatting.VariationSelectors = opts.Formatting.VariationSelectors
 
 	// FirstMessagesCount and ParanoidMode are mutually exclusive.
 	// ParanoidMode still here for backward compatibility only.
@@ -553,40 +795,83 @@ func makeDetector(opts options) *tgspam.Detector {
 		detector.AbnormalSpacing.MinWordsCount = opts.AbnormalSpacing.MinWords
 	}
 
-	metaChecks := []tgspam.MetaCheck{}
+	if opts.Domains.Enabled {
+		log.Printf("[INFO] domain check enabled, deny shorteners: %v, resolve: %v", opts.Domains.DenyShorteners, opts.Domains.Resolve)
+		if opts.Domains.Resolve {
+			detector.WithURLResolver(tgspam.HTTPResolver{Client: &http.Client{Timeout: opts.Domains.ResolveTimeout}})
+		}
+	}
+
+	if opts.Campaign.Enabled {
+		log.Printf("[INFO] campaign check enabled, min users: %d, window: %v, similarity: %.2f, min tokens: %d",
+			opts.Campaign.MinUsers, opts.Campaign.Window, opts.Campaign.Similarity, opts.Campaign.MinTokens)
+	}
+
+	if opts.Normalize.Enabled || opts.Normalize.Obfuscation {
+		log.Printf("[INFO] text normalization: %v, obfuscation check: %v", opts.Normalize.Enabled, opts.Normalize.Obfuscation)
+	}
+
+	if opts.Stemming.Enabled {
+		log.Printf("[INFO] stemming enabled")
+	}
+
+	if opts.NGrams.Words > 1 || opts.NGrams.Chars > 0 {
+		log.Printf("[INFO] classifier n-grams enabled, words: %d, chars: %d, min count: %d, max features: %d",
+			opts.NGrams.Words, opts.NGrams.Chars, opts.NGrams.MinCount, opts.NGrams.MaxFeatures)
+	}
+
+	if opts.Formatting.Enabled {
+		log.Printf("[INFO] formatting check enabled, invisible ratio: %.2f, bidi overrides: %d, combining marks: %d, "+
+			"variation selectors: %d", opts.Formatting.InvisibleRatio, opts.Formatting.BidiOverrides,
+			opts.Formatting.CombiningMarks, opts.Formatting.VariationSelectors)
+	}
+
+	metaChecks := []tgspam.Checker{}
 	if opts.Meta.ImageOnly {
 		log.Printf("[INFO] image only check enabled")
-		metaChecks = append(metaChecks, tgspam.ImagesCheck())
+		metaChecks = append(metaChecks, tgspam.MetaChecker("images", tgspam.ImagesCheck()))
 	}
 	if opts.Meta.VideosOnly {
 		log.Printf("[INFO] videos only check enabled")
-		metaChecks = append(metaChecks, tgspam.VideosCheck())
+		metaChecks = append(metaChecks, tgspam.MetaChecker("videos", tgspam.VideosCheck()))
 	}
 	if opts.Meta.LinksLimit >= 0 {
 		log.Printf("[INFO] links check enabled, limit: %d", opts.Meta.LinksLimit)
-		metaChecks = append(metaChecks, tgspam.LinksCheck(opts.Meta.LinksLimit))
+		metaChecks = append(metaChecks, tgspam.MetaChecker("links", tgspam.LinksCheck(opts.Meta.LinksLimit)))
 	}
 	if opts.Meta.MentionsLimit >= 0 {
 		log.Printf("[INFO] mentions check enabled, limit: %d", opts.Meta.MentionsLimit)
-		metaChecks = append(metaChecks, tgspam.MentionsCheck(opts.Meta.MentionsLimit))
+		metaChecks = append(metaChecks, tgspam.MetaChecker("mentions", tgspam.MentionsCheck(opts.Meta.MentionsLimit)))
 	}
 	if opts.Meta.LinksOnly {
 		log.Printf("[INFO] links only check enabled")
-		metaChecks = append(metaChecks, tgspam.LinkOnlyCheck())
+		metaChecks = append(metaChecks, tgspam.MetaChecker("link-only", tgspam.LinkOnlyCheck()))
 	}
 	if opts.Meta.Forward {
 		log.Printf("[INFO] forward check enabled")
-		metaChecks = append(metaChecks, tgspam.ForwardedCheck())
+		metaChecks = append(metaChecks, tgspam.MetaChecker("forward", tgspam.ForwardedCheck()))
 	}
 	if opts.Meta.Keyboard {
 		log.Printf("[INFO] keyboard check enabled")
-		metaChecks = append(metaChecks, tgspam.KeyboardCheck())
+		metaChecks = append(metaChecks, tgspam.MetaChecker("keyboard", tgspam.KeyboardCheck()))
 	}
 	if opts.Meta.UsernameSymbols != "" {
 		log.Printf("[INFO] username symbols check enabled, prohibited symbols: %q", opts.Meta.UsernameSymbols)
-		metaChecks = append(metaChecks, tgspam.UsernameSymbolsCheck(opts.Meta.UsernameSymbols))
+		metaChecks = append(metaChecks, tgspam.MetaChecker("username-symbols", tgspam.UsernameSymbolsCheck(opts.Meta.UsernameSymbols)))
+	}
+	if len(opts.Meta.Documents) > 0 {
+		log.Printf("[INFO] document check enabled, prohibited: %v", opts.Meta.Documents)
+		metaChecks = append(metaChecks, tgspam.MetaChecker("document", tgspam.DocumentCheck(opts.Meta.Documents)))
+	}
+	if opts.Meta.Contact {
+		log.Printf("[INFO] contact check enabled")
+		metaChecks = append(metaChecks, tgspam.MetaChecker("contact", tgspam.ContactCheck()))
+	}
+	if opts.Meta.ViaBot {
+		log.Printf("[INFO] via bot check enabled, allowed bots: %v", opts.Meta.ViaBotAllowed)
+		metaChecks = append(metaChecks, tgspam.MetaChecker("via-bot", tgspam.ViaBotCheck(opts.Meta.ViaBotAllowed)))
 	}
-	detector.WithMetaChecks(metaChecks...)
+	detector.WithCheckers(metaChecks...)
 
 	log.Printf("[DEBUG] detector config: %+v", detectorConfig)
 	return detector
@@ -615,6 +900,11 @@ func makeSpamBot(ctx context.Context, opts options, dataDB *engine.SQL, detector
 		return nil, fmt.Errorf("can't migrate dictionary, %w", err)
 	}
 
+	policy, err := makeSpamPolicy(opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't make spam policy, %w", err)
+	}
+
 	spamBotParams := bot.SpamConfig{
 		GroupID:      opts.InstanceID,
 		SamplesStore: samplesStore,
@@ -622,6 +912,7 @@ func makeSpamBot(ctx context.Context, opts options, dataDB *engine.SQL, detector
 		SpamMsg:      opts.Message.Spam,
 		SpamDryMsg:   opts.Message.Dry,
 		Dry:          opts.Dry,
+		Policy:       policy,
 	}
 	spamBot := bot.NewSpamFilter(detector, spamBotParams)
 	log.Printf("[DEBUG] spam bot config: %+v", spamBotParams)
@@ -637,6 +928,323 @@ func makeSpamBot(ctx context.Context, opts options, dataDB *engine.SQL, detector
 	return spamBot, nil
 }
 
+// makeSpamPolicy makes the policy mapping check results to the action on detected spam
+func makeSpamPolicy(opts options) (bot.Policy, error) {
+	res := bot.Policy{}
+	if opts.Policy.Default != "" {
+		def, err := bot.ParseAction(opts.Policy.Default)
+		if err != nil {
+			return bot.Policy{}, fmt.Errorf("invalid default action: %w", err)
+		}
+		res.Default = def
+	}
+	for _, r := range opts.Policy.Rules {
+		rule, err := bot.ParsePolicyRule(r)
+		if err != nil {
+			return bot.Policy{}, err
+		}
+		log.Printf("[INFO] spam action rule: %s -> %s", r, rule.Action)
+		res.Rules = append(res.Rules, rule)
+	}
+	return res, nil
+}
+
+// makeTopicPolicies makes forum topic policies by group name, primary group policies are set with the empty name.
+// Rules without group prefix are for the primary group.
+func makeTopicPolicies(opts options) (map[string]events.TopicPolicies, error) {
+	groups := map[string]bool{"": true, opts.Telegram.Group: true}
+	for _, g := range opts.Telegram.Groups {
+		name, _, _ := strings.Cut(strings.TrimSpace(g), ":")
+		groups[name] = true
+	}
+
+	res := map[string]events.TopicPolicies{}
+	for _, r := range opts.Topics.Rules {
+		group, rule, found := strings.Cut(r, "/")
+		if !found {
+			group, rule = "", r
+		}
+		if !groups[group] {
+			return nil, fmt.Errorf("unknown group %q in topic rule %q", group, r)
+		}
+		if group == opts.Telegram.Group {
+			group = ""
+		}
+		topicID, p, err := events.ParseTopicRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		if res[group] == nil {
+			res[group] = events.TopicPolicies{}
+		}
+		res[group].Add(topicID, p)
+		log.Printf("[INFO] topic rule: %s", r)
+	}
+	return res, nil
+}
+
+// makeRateLimit makes per-user message rate limit config, the action is limited to delete, mute and report
+func makeRateLimit(opts options) (events.RateLimitConfig, error) {
+	if !opts.RateLimit.Enabled {
+		return events.RateLimitConfig{}, nil
+	}
+	if opts.RateLimit.Window <= 0 {
+		return events.RateLimitConfig{}, fmt.Errorf("invalid window %v", opts.RateLimit.Window)
+	}
+	action, err := bot.ParseAction(opts.RateLimit.Action)
+	if err != nil {
+		return events.RateLimitConfig{}, fmt.Errorf("invalid action: %w", err)
+	}
+	if action.Kind != bot.ActionDelete && action.Kind != bot.ActionMute && action.Kind != bot.ActionReport {
+		return events.RateLimitConfig{}, fmt.Errorf("unsupported action %q, only delete, mute and report allowed", action)
+	}
+	log.Printf("[INFO] rate limit enabled, window: %v, new users: %d, approved: %d, action: %s",
+		opts.RateLimit.Window, opts.RateLimit.NewUsers, opts.RateLimit.Approved, action)
+	return events.RateLimitConfig{Enabled: true, Window: opts.RateLimit.Window, NewUsers: opts.RateLimit.NewUsers,
+		Approved: opts.RateLimit.Approved, Action: action}, nil
+}
+
+// makeStrikes makes strikes store and escalation ladder. Returns nil store if strikes disabled
+func makeStrikes(ctx context.Context, opts options, db *engine.SQL) (*storage.Strikes, bot.Ladder, error) {
+	if !opts.Strikes.Enabled {
+		return nil, nil, nil
+	}
+	ladder, err := bot.ParseLadder(opts.Strikes.Ladder)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ladder) == 0 {
+		return nil, nil, errors.New("empty strikes ladder")
+	}
+	store, err := storage.NewStrikes(ctx, db, opts.Strikes.TTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't make strikes store, %w", err)
+	}
+	log.Printf("[INFO] strikes enabled, ladder: %v, ttl: %v", ladder, opts.Strikes.TTL)
+	return store, ladder, nil
+}
+
+// activateMentionResolver sets the resolver of mentioned usernames for detectors of all groups, if mentions check enabled.
+// Resolved targets are cached in the database, common for all groups.
+func activateMentionResolver(ctx context.Context, opts options, db *engine.SQL, tbAPI events.TbAPI, sf *bot.SpamFilter,
+	groups []events.Group) error {
+	if !opts.Mentions.Enabled {
+		return nil
+	}
+	cache, err := storage.NewMentionTargets(ctx, db, opts.Mentions.TTL)
+	if err != nil {
+		return fmt.Errorf("can't make mention targets store, %w", err)
+	}
+	resolver := &events.MentionResolver{TbAPI: tbAPI, Cache: cache}
+	filters := []*bot.SpamFilter{sf}
+	for _, g := range groups {
+		if gsf, ok := g.Bot.(*bot.SpamFilter); ok {
+			filters = append(filters, gsf)
+		}
+	}
+	for _, f := range filters {
+		if d, ok := f.Detector.(*tgspam.Detector); ok {
+			d.WithMentionResolver(resolver)
+		}
+	}
+	log.Printf("[INFO] mention target check enabled, allowed: %v, recent id: %d", opts.Mentions.Allowed, opts.Mentions.RecentID)
+	return nil
+}
+
+// makeProfileConfig makes the listener's profile check config. Fetched bios are cached in the database,
+// common for all groups.
+func makeProfileConfig(ctx context.Context, opts options, db *engine.SQL) (events.ProfileConfig, error) {
+	if !opts.ProfileCheck.Enabled {
+		return events.ProfileConfig{}, nil
+	}
+	res := events.ProfileConfig{Enabled: true, FetchBio: opts.ProfileCheck.Bio}
+	if opts.ProfileCheck.Bio {
+		cache, err := storage.NewUserBios(ctx, db, opts.ProfileCheck.TTL)
+		if err != nil {
+			return events.ProfileConfig{}, fmt.Errorf("can't make user bios store, %w", err)
+		}
+		res.Cache = cache
+	}
+	log.Printf("[INFO] profile check enabled, bio: %v", opts.ProfileCheck.Bio)
+	return res, nil
+}
+
+// makeGroups makes additional groups monitored by the same bot. Each group has its own detector, and samples,
+// approved users, detected spam, locator and strikes stored in the same database with the group's gid.
+// Samples of the primary group are shared with all groups if enabled, otherwise used to seed new groups.
+func makeGroups(ctx context.Context, opts options, dataDB *engine.SQL, profiles *storage.Profiles,
+	loggerWr io.Writer) ([]events.Group, error) {
+	if len(opts.Telegram.Groups) == 0 {
+		return nil, nil
+	}
+
+	primarySamples, psErr := storage.NewSamples(ctx, dataDB)
+	if psErr != nil {
+		return nil, fmt.Errorf("can't make primary samples store, %w", psErr)
+	}
+	dictionaryStore, dsErr := storage.NewDictionary(ctx, dataDB) // dictionary is common for all groups
+	if dsErr != nil {
+		return nil, fmt.Errorf("can't make dictionary store, %w", dsErr)
+	}
+	policy, polErr := makeSpamPolicy(opts)
+	if polErr != nil {
+		return nil, fmt.Errorf("can't make spam policy, %w", polErr)
+	}
+	topics, tpErr := makeTopicPolicies(opts)
+	if tpErr != nil {
+		return nil, fmt.Errorf("can't make topic policies, %w", tpErr)
+	}
+
+	res := make([]events.Group, 0, len(opts.Telegram.Groups))
+	gids := map[string]bool{opts.InstanceID: true}
+	for _, g := range opts.Telegram.Groups {
+		name, gid, _ := strings.Cut(strings.TrimSpace(g), ":")
+		if name == "" {
+			return nil, fmt.Errorf("empty group name in %q", g)
+		}
+		if gid == "" {
+			gid = name
+		}
+		if gids[gid] {
+			return nil, fmt.Errorf("duplicate gid %q for group %q", gid, name)
+		}
+		gids[gid] = true
+		gdb := dataDB.WithGID(gid)
+		gopts := profileOptions(ctx, opts, profiles, name)
+
+		samplesStore, err := storage.NewSamples(ctx, gdb)
+		if err != nil {
+			return nil, fmt.Errorf("can't make samples store for %q, %w", name, err)
+		}
+		spamBotParams := bot.SpamConfig{
+			GroupID:      gid,
+			SamplesStore: samplesStore,
+			DictStore:    dictionaryStore,
+			SpamMsg:      gopts.Message.Spam,
+			SpamDryMsg:   gopts.Message.Dry,
+			Dry:          opts.Dry,
+			Policy:       policy,
+		}
+		if opts.Telegram.SharedSamples {
+			spamBotParams.SharedSamplesStore = primarySamples
+		} else if err = seedGroupSamples(ctx, primarySamples, samplesStore); err != nil {
+			return nil, fmt.Errorf("can't seed samples for %q, %w", name, err)
+		}
+
+		detector := makeDetector(gopts)
+		spamBot := bot.NewSpamFilter(detector, spamBotParams)
+		if err = spamBot.ReloadSamples(); err != nil {
+			return nil, fmt.Errorf("can't reload samples for %q, %w", name, err)
+		}
+		detector.WithSpamUpdater(storage.NewSampleUpdater(samplesStore, storage.SampleTypeSpam, opts.StorageTimeout))
+		detector.WithHamUpdater(storage.NewSampleUpdater(samplesStore, storage.SampleTypeHam, opts.StorageTimeout))
+
+		approvedUsersStore, err := storage.NewApprovedUsers(ctx, gdb)
+		if err != nil {
+			return nil, fmt.Errorf("can't make approved users store for %q, %w", name, err)
+		}
+		if _, err = detector.WithUserStorage(approvedUsersStore); err != nil {
+			return nil, fmt.Errorf("can't load approved users for %q, %w", name, err)
+		}
+
+		locator, err := storage.NewLocator(ctx, opts.HistoryDuration, opts.HistoryMinSize, gdb)
+		if err != nil {
+			return nil, fmt.Errorf("can't make locator for %q, %w", name, err)
+		}
+		spamLogger, err := makeSpamLogger(ctx, gid, loggerWr, gdb)
+		if err != nil {
+			return nil, fmt.Errorf("can't make spam logger for %q, %w", name, err)
+		}
+
+		group := events.Group{Name: name, Bot: spamBot, SpamLogger: spamLogger, Locator: locator, WarnMsg: gopts.Message.Warn,
+			Topics: topics[name]}
+		if opts.Strikes.Enabled {
+			strikesStore, stErr := storage.NewStrikes(ctx, gdb, opts.Strikes.TTL)
+			if stErr != nil {
+				return nil, fmt.Errorf("can't make strikes store for %q, %w", name, stErr)
+			}
+			group.Strikes = strikesStore
+		}
+		log.Printf("[INFO] additional group %q, gid: %q, shared samples: %v", name, gid, opts.Telegram.SharedSamples)
+		res = append(res, group)
+	}
+	return res, nil
+}
+
+// profileOptions returns options with the profile assigned to the group applied.
+// Options are returned as is if no profile assigned or the assigned profile can't be read.
+func profileOptions(ctx context.Context, opts options, profiles *storage.Profiles, group string) options {
+	name, ok := opts.Profiles.Assign[group]
+	if !ok || profiles == nil {
+		return opts
+	}
+	profile, err := profiles.Read(ctx, name)
+	if err != nil {
+		log.Printf("[WARN] can't read profile %q for group %q, defaults used: %v", name, group, err)
+		return opts
+	}
+	log.Printf("[INFO] profile %q applied to group %q", name, group)
+	return applyProfile(opts, *profile)
+}
+
+// applyProfile returns a copy of options with settings set in the profile
+func applyProfile(opts options, p storage.Profile) options {
+	setIfPresent(&opts.SimilarityThreshold, p.SimilarityThreshold)
+	setIfPresent(&opts.MinSpamProbability, p.MinSpamProbability)
+	setIfPresent(&opts.FirstMessagesCount, p.FirstMessagesCount)
+	setIfPresent(&opts.MinMsgLen, p.MinMsgLen)
+	setIfPresent(&opts.MaxEmoji, p.MaxEmoji)
+	setIfPresent(&opts.OpenAI.Veto, p.OpenAIVeto)
+	setIfPresent(&opts.Meta.LinksLimit, p.Meta.LinksLimit)
+	setIfPresent(&opts.Meta.MentionsLimit, p.Meta.MentionsLimit)
+	setIfPresent(&opts.Meta.ImageOnly, p.Meta.ImageOnly)
+	setIfPresent(&opts.Meta.LinksOnly, p.Meta.LinksOnly)
+	setIfPresent(&opts.Meta.VideosOnly, p.Meta.VideosOnly)
+	setIfPresent(&opts.Meta.AudiosOnly, p.Meta.AudiosOnly)
+	setIfPresent(&opts.Meta.Forward, p.Meta.Forward)
+	setIfPresent(&opts.Meta.Keyboard, p.Meta.Keyboard)
+	setIfPresent(&opts.Meta.UsernameSymbols, p.Meta.UsernameSymbols)
+	setIfPresent(&opts.Meta.Documents, p.Meta.Documents)
+	setIfPresent(&opts.Meta.Contact, p.Meta.Contact)
+	setIfPresent(&opts.Meta.ViaBot, p.Meta.ViaBot)
+	setIfPresent(&opts.Meta.ViaBotAllowed, p.Meta.ViaBotAllowed)
+	setIfPresent(&opts.Message.Spam, p.Messages.Spam)
+	setIfPresent(&opts.Message.Dry, p.Messages.Dry)
+	setIfPresent(&opts.Message.Warn, p.Messages.Warn)
+	return opts
+}
+
+// setIfPresent sets dst to the value of src if src is not nil
+func setIfPresent[T any](dst, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// seedGroupSamples copies preset samples from the primary group to a new group without own preset samples
+func seedGroupSamples(ctx context.Context, primary, group *storage.Samples) error {
+	st, stErr := group.Stats(ctx)
+	if stErr != nil {
+		return fmt.Errorf("can't get samples stats, %w", stErr)
+	}
+	if st.PresetSpam > 0 && st.PresetHam > 0 {
+		return nil
+	}
+	for _, t := range []storage.SampleType{storage.SampleTypeSpam, storage.SampleTypeHam} {
+		r, err := primary.Reader(ctx, t, storage.SampleOriginPreset)
+		if err != nil {
+			return fmt.Errorf("can't read primary %s samples, %w", t, err)
+		}
+		_, err = group.Import(ctx, t, storage.SampleOriginPreset, r, true)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("can't import %s samples, %w", t, err)
+		}
+	}
+	log.Printf("[INFO] preset samples seeded from the primary group")
+	return nil
+}
+
 // expandPath expands ~ to home dir and makes the absolute path
 func expandPath(path string) string {
 	if path == "" {
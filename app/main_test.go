@@ -15,13 +15,17 @@ import (
 	"testing"
 	"time"
 
+	tbapi "github.com/OvyFlash/telegram-bot-api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/umputun/tg-spam/app/bot"
+	"github.com/umputun/tg-spam/app/events"
+	"github.com/umputun/tg-spam/app/events/mocks"
 	"github.com/umputun/tg-spam/app/storage"
 	"github.com/umputun/tg-spam/app/storage/engine"
 	"github.com/umputun/tg-spam/lib/spamcheck"
+	"github.com/umputun/tg-spam/lib/tgspam"
 )
 
 func TestMakeSpamLogger(t *testing.T) {
@@ -172,6 +176,109 @@ func Test_makeDetector(t *testing.T) {
 		assert.Equal(t, 0, res.FirstMessagesCount)
 		assert.Equal(t, false, res.FirstMessageOnly)
 	})
+
+	t.Run("with domains", func(t *testing.T) {
+		var opts options
+		opts.Domains.Enabled = true
+		opts.Domains.DenyShorteners = true
+		opts.Domains.Resolve = true
+		res := makeDetector(opts)
+		assert.True(t, res.Domains.Enabled)
+		assert.True(t, res.Domains.DenyShorteners)
+	})
+
+	t.Run("with profile check", func(t *testing.T) {
+		var opts options
+		opts.ProfileCheck.Enabled = true
+		res := makeDetector(opts)
+		assert.True(t, res.Profile.Enabled)
+	})
+
+	t.Run("with campaign", func(t *testing.T) {
+		var opts options
+		opts.Campaign.Enabled = true
+		opts.Campaign.MinUsers = 5
+		opts.Campaign.Window = time.Minute
+		opts.Campaign.Similarity = 0.8
+		opts.Campaign.MinTokens = 4
+		res := makeDetector(opts)
+		assert.True(t, res.Campaign.Enabled)
+		assert.Equal(t, 5, res.Campaign.MinUsers)
+		assert.Equal(t, time.Minute, res.Campaign.Window)
+		assert.InDelta(t, 0.8, res.Campaign.Similarity, 0.0001)
+		assert.Equal(t, 4, res.Campaign.MinTokens)
+	})
+
+	t.Run("with normalization", func(t *testing.T) {
+		var opts options
+		opts.Normalize.Enabled = true
+		opts.Normalize.Obfuscation = true
+		res := makeDetector(opts)
+		assert.True(t, res.Normalize.Enabled)
+		assert.True(t, res.Normalize.Obfuscation)
+		names := []string{}
+		for _, c := range res.Checkers() {
+			names = append(names, c.Name())
+		}
+		assert.Contains(t, names, "obfuscation")
+	})
+
+	t.Run("with meta checks", func(t *testing.T) {
+		var opts options
+		opts.Meta.LinksLimit, opts.Meta.MentionsLimit = 1, -1
+		opts.Meta.Forward = true
+		res := makeDetector(opts)
+		names := []string{}
+		for _, c := range res.Checkers() {
+			if c.Phase() == tgspam.PhaseMeta {
+				names = append(names, c.Name())
+			}
+		}
+		assert.Equal(t, []string{"links", "forward"}, names)
+	})
+
+	t.Run("with stemming", func(t *testing.T) {
+		var opts options
+		opts.Stemming.Enabled = true
+		res := makeDetector(opts)
+		assert.True(t, res.Stemming.Enabled)
+		assert.Empty(t, res.Stemming.Stemmers, "default stemmers")
+	})
+
+	t.Run("with n-grams", func(t *testing.T) {
+		var opts options
+		opts.NGrams.Words = 3
+		opts.NGrams.Chars = 4
+		opts.NGrams.MinCount = 2
+		opts.NGrams.MaxFeatures = 1000
+		res := makeDetector(opts)
+		assert.Equal(t, 3, res.NGrams.Words)
+		assert.Equal(t, 4, res.NGrams.Chars)
+		assert.Equal(t, 2, res.NGrams.MinCount)
+		assert.Equal(t, 1000, res.NGrams.MaxFeatures)
+	})
+
+	t.Run("with explain", func(t *testing.T) {
+		var opts options
+		opts.Explain.Tokens = 3
+		res := makeDetector(opts)
+		assert.Equal(t, 3, res.Explain.Tokens)
+	})
+
+	t.Run("with formatting check", func(t *testing.T) {
+		var opts options
+		opts.Formatting.Enabled = true
+		opts.Formatting.InvisibleRatio = 0.1
+		opts.Formatting.BidiOverrides = 1
+		opts.Formatting.CombiningMarks = 4
+		opts.Formatting.VariationSelectors = 3
+		res := makeDetector(opts)
+		assert.True(t, res.Formatting.Enabled)
+		assert.InDelta(t, 0.1, res.Formatting.InvisibleRatio, 0.0001)
+		assert.Equal(t, 1, res.Formatting.BidiOverrides)
+		assert.Equal(t, 4, res.Formatting.CombiningMarks)
+		assert.Equal(t, 3, res.Formatting.VariationSelectors)
+	})
 }
 
 func Test_makeSpamBot(t *testing.T) {
@@ -209,6 +316,239 @@ func Test_makeSpamBot(t *testing.T) {
 	})
 }
 
+func Test_makeSpamPolicy(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		var opts options
+		res, err := makeSpamPolicy(opts)
+		require.NoError(t, err)
+		assert.Equal(t, bot.Policy{}, res)
+	})
+
+	t.Run("with rules", func(t *testing.T) {
+		var opts options
+		opts.Policy.Default = "delete"
+		opts.Policy.Rules = []string{"cas:ban", "stopword:mute:24h", "classifier<0.8:report"}
+		res, err := makeSpamPolicy(opts)
+		require.NoError(t, err)
+		assert.Equal(t, bot.Action{Kind: bot.ActionDelete}, res.Default)
+		require.Len(t, res.Rules, 3)
+		assert.Equal(t, bot.PolicyRule{Check: "stopword", Action: bot.Action{Kind: bot.ActionMute, Duration: 24 * time.Hour}}, res.Rules[1])
+		assert.Equal(t, bot.PolicyRule{Check: "classifier", MaxScore: 0.8, Action: bot.Action{Kind: bot.ActionReport}}, res.Rules[2])
+	})
+
+	t.Run("invalid default", func(t *testing.T) {
+		var opts options
+		opts.Policy.Default = "kick"
+		_, err := makeSpamPolicy(opts)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		var opts options
+		opts.Policy.Rules = []string{"cas"}
+		_, err := makeSpamPolicy(opts)
+		assert.Error(t, err)
+	})
+}
+
+func Test_makeTopicPolicies(t *testing.T) {
+	t.Run("no rules", func(t *testing.T) {
+		var opts options
+		res, err := makeTopicPolicies(opts)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("primary and additional groups", func(t *testing.T) {
+		var opts options
+		opts.Telegram.Group = "main"
+		opts.Telegram.Groups = []string{"second:gr2"}
+		opts.Topics.Rules = []string{"10:exempt", "main/20:strict", "20:mute:1h", "second/5:ban"}
+		res, err := makeTopicPolicies(opts)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]events.TopicPolicies{
+			"":       {10: {Exempt: true}, 20: {Strict: true, Action: bot.Action{Kind: bot.ActionMute, Duration: time.Hour}}},
+			"second": {5: {Action: bot.Action{Kind: bot.ActionBan}}},
+		}, res)
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		var opts options
+		opts.Telegram.Group = "main"
+		opts.Topics.Rules = []string{"other/10:exempt"}
+		_, err := makeTopicPolicies(opts)
+		assert.EqualError(t, err, `unknown group "other" in topic rule "other/10:exempt"`)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		var opts options
+		opts.Topics.Rules = []string{"10:kick"}
+		_, err := makeTopicPolicies(opts)
+		assert.Error(t, err)
+	})
+}
+
+func Test_activateMentionResolver(t *testing.T) {
+	ctx := context.Background()
+	db, err := engine.NewSqlite(":memory:", "gr1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tbAPI := &mocks.TbAPIMock{GetChatFunc: func(config tbapi.ChatInfoConfig) (tbapi.ChatFullInfo, error) {
+		return tbapi.ChatFullInfo{Chat: tbapi.Chat{ID: -1001234567890, Type: "channel"}}, nil
+	}}
+	req := spamcheck.Request{Msg: "join @promo", Meta: spamcheck.MetaData{MentionedUsernames: []string{"promo"}}}
+
+	t.Run("disabled", func(t *testing.T) {
+		var opts options
+		detector := makeDetector(opts)
+		require.NoError(t, activateMentionResolver(ctx, opts, db, tbAPI, bot.NewSpamFilter(detector, bot.SpamConfig{}), nil))
+		_, cr := detector.Check(req)
+		for _, r := range cr {
+			assert.NotEqual(t, "mention-target", r.Name)
+		}
+	})
+
+	t.Run("enabled for all groups", func(t *testing.T) {
+		var opts options
+		opts.Mentions.Enabled = true
+		opts.Mentions.TTL = time.Hour
+		detector, groupDetector := makeDetector(opts), makeDetector(opts)
+		groups := []events.Group{{Name: "group2", Bot: bot.NewSpamFilter(groupDetector, bot.SpamConfig{})}}
+		require.NoError(t, activateMentionResolver(ctx, opts, db, tbAPI, bot.NewSpamFilter(detector, bot.SpamConfig{}), groups))
+
+		for _, d := range []*tgspam.Detector{detector, groupDetector} {
+			spam, cr := d.Check(req)
+			assert.True(t, spam)
+			assert.Contains(t, cr, spamcheck.Response{Name: "mention-target", Spam: true, Details: "mention of channel @promo", Score: 1})
+		}
+		assert.Len(t, tbAPI.GetChatCalls(), 1, "second resolution is cached")
+	})
+}
+
+func Test_makeRateLimit(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var opts options
+		opts.RateLimit.Action = "kick"
+		res, err := makeRateLimit(opts)
+		require.NoError(t, err)
+		assert.Equal(t, events.RateLimitConfig{}, res)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		var opts options
+		opts.RateLimit.Enabled = true
+		opts.RateLimit.Window = time.Minute
+		opts.RateLimit.NewUsers = 5
+		opts.RateLimit.Approved = 20
+		opts.RateLimit.Action = "mute:1h"
+		res, err := makeRateLimit(opts)
+		require.NoError(t, err)
+		assert.Equal(t, events.RateLimitConfig{Enabled: true, Window: time.Minute, NewUsers: 5, Approved: 20,
+			Action: bot.Action{Kind: bot.ActionMute, Duration: time.Hour}}, res)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts options
+		opts.RateLimit.Enabled = true
+		opts.RateLimit.Window = time.Minute
+		for _, action := range []string{"kick", "ban", "warn", "mute"} {
+			opts.RateLimit.Action = action
+			_, err := makeRateLimit(opts)
+			assert.Error(t, err, action)
+		}
+		opts.RateLimit.Action = "delete"
+		opts.RateLimit.Window = 0
+		_, err := makeRateLimit(opts)
+		assert.EqualError(t, err, "invalid window 0s")
+	})
+}
+
+func Test_makeStrikes(t *testing.T) {
+	ctx := context.Background()
+	db, err := engine.NewSqlite(":memory:", "gr1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("disabled", func(t *testing.T) {
+		var opts options
+		opts.Strikes.Ladder = []string{"warn", "ban"}
+		store, ladder, err := makeStrikes(ctx, opts, db)
+		require.NoError(t, err)
+		assert.Nil(t, store)
+		assert.Nil(t, ladder)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		var opts options
+		opts.Strikes.Enabled = true
+		opts.Strikes.Ladder = []string{"warn", "mute:1h", "ban"}
+		opts.Strikes.TTL = time.Hour
+		store, ladder, err := makeStrikes(ctx, opts, db)
+		require.NoError(t, err)
+		assert.NotNil(t, store)
+		assert.Equal(t, bot.Ladder{{Kind: bot.ActionWarn}, {Kind: bot.ActionMute, Duration: time.Hour}, {Kind: bot.ActionBan}}, ladder)
+	})
+
+	t.Run("invalid ladder", func(t *testing.T) {
+		var opts options
+		opts.Strikes.Enabled = true
+		opts.Strikes.Ladder = []string{"warn", "kick"}
+		_, _, err := makeStrikes(ctx, opts, db)
+		assert.Error(t, err)
+	})
+
+	t.Run("empty ladder", func(t *testing.T) {
+		var opts options
+		opts.Strikes.Enabled = true
+		_, _, err := makeStrikes(ctx, opts, db)
+		assert.EqualError(t, err, "empty strikes ladder")
+	})
+}
+
+func Test_makeProfileConfig(t *testing.T) {
+	ctx := context.Background()
+	db, err := engine.NewSqlite(":memory:", "gr1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("disabled", func(t *testing.T) {
+		var opts options
+		opts.ProfileCheck.Bio = true
+		res, err := makeProfileConfig(ctx, opts, db)
+		require.NoError(t, err)
+		assert.Equal(t, events.ProfileConfig{}, res)
+	})
+
+	t.Run("enabled without bio", func(t *testing.T) {
+		var opts options
+		opts.ProfileCheck.Enabled = true
+		res, err := makeProfileConfig(ctx, opts, db)
+		require.NoError(t, err)
+		assert.Equal(t, events.ProfileConfig{Enabled: true}, res)
+	})
+
+	t.Run("enabled with bio", func(t *testing.T) {
+		var opts options
+		opts.ProfileCheck.Enabled = true
+		opts.ProfileCheck.Bio = true
+		opts.ProfileCheck.TTL = time.Hour
+		res, err := makeProfileConfig(ctx, opts, db)
+		require.NoError(t, err)
+		assert.True(t, res.Enabled)
+		assert.True(t, res.FetchBio)
+		assert.IsType(t, &storage.UserBios{}, res.Cache)
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		var opts options
+		opts.ProfileCheck.Enabled = true
+		opts.ProfileCheck.Bio = true
+		_, err := makeProfileConfig(ctx, opts, db)
+		assert.Error(t, err)
+	})
+}
+
 func Test_activateServerOnly(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -782,3 +1122,151 @@ func TestBackupDB(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func Test_makeGroups(t *testing.T) {
+	ctx := context.Background()
+	db, err := engine.NewSqlite(filepath.Join(t.TempDir(), "tg-spam.db"), "gr1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	primary, err := storage.NewSamples(ctx, db)
+	require.NoError(t, err)
+	_, err = primary.Import(ctx, storage.SampleTypeSpam, storage.SampleOriginPreset, strings.NewReader("spam1\nspam2"), true)
+	require.NoError(t, err)
+	_, err = primary.Import(ctx, storage.SampleTypeHam, storage.SampleOriginPreset, strings.NewReader("ham1\nham2\nham3"), true)
+	require.NoError(t, err)
+
+	var opts options
+	opts.InstanceID = "gr1"
+	opts.MaxEmoji = -1
+	opts.Meta.LinksLimit, opts.Meta.MentionsLimit = -1, -1
+	opts.HistoryDuration, opts.HistoryMinSize = time.Hour, 10
+
+	t.Run("no groups", func(t *testing.T) {
+		res, err := makeGroups(ctx, opts, db, nil, io.Discard)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("groups with seeded samples", func(t *testing.T) {
+		o := opts
+		o.Telegram.Groups = []string{"group2", "-100123:g3"}
+		o.Strikes.Enabled = true
+		res, err := makeGroups(ctx, o, db, nil, io.Discard)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		assert.Equal(t, "group2", res[0].Name)
+		assert.Equal(t, "-100123", res[1].Name)
+		assert.NotNil(t, res[0].Strikes)
+
+		for _, gid := range []string{"group2", "g3"} {
+			st, err := storage.NewSamples(ctx, db.WithGID(gid))
+			require.NoError(t, err)
+			stats, err := st.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, stats.PresetSpam, "preset spam seeded for %s", gid)
+			assert.Equal(t, 3, stats.PresetHam, "preset ham seeded for %s", gid)
+		}
+	})
+
+	t.Run("shared samples", func(t *testing.T) {
+		o := opts
+		o.Telegram.Groups = []string{"group4"}
+		o.Telegram.SharedSamples = true
+		res, err := makeGroups(ctx, o, db, nil, io.Discard)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Nil(t, res[0].Strikes)
+
+		st, err := storage.NewSamples(ctx, db.WithGID("group4"))
+		require.NoError(t, err)
+		stats, err := st.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, stats.PresetSpam, "shared samples not copied")
+	})
+
+	t.Run("group with profile", func(t *testing.T) {
+		profiles, err := storage.NewProfiles(ctx, db)
+		require.NoError(t, err)
+		warn := "profile warning"
+		require.NoError(t, profiles.Write(ctx, storage.Profile{Name: "strict", Messages: storage.ProfileMessages{Warn: &warn}}))
+
+		o := opts
+		o.Message.Warn = "default warning"
+		o.Telegram.Groups = []string{"group9", "group10"}
+		o.Profiles.Assign = map[string]string{"group9": "strict"}
+		res, err := makeGroups(ctx, o, db, profiles, io.Discard)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		assert.Equal(t, "profile warning", res[0].WarnMsg)
+		assert.Equal(t, "default warning", res[1].WarnMsg)
+	})
+
+	t.Run("duplicate gid", func(t *testing.T) {
+		o := opts
+		o.Telegram.Groups = []string{"group5", "group6:group5"}
+		_, err := makeGroups(ctx, o, db, nil, io.Discard)
+		assert.EqualError(t, err, `duplicate gid "group5" for group "group6"`)
+
+		o.Telegram.Groups = []string{"group7:gr1"}
+		_, err = makeGroups(ctx, o, db, nil, io.Discard)
+		assert.EqualError(t, err, `duplicate gid "gr1" for group "group7"`)
+	})
+
+	t.Run("empty group name", func(t *testing.T) {
+		o := opts
+		o.Telegram.Groups = []string{":g8"}
+		_, err := makeGroups(ctx, o, db, nil, io.Discard)
+		assert.EqualError(t, err, `empty group name in ":g8"`)
+	})
+}
+
+func Test_applyProfile(t *testing.T) {
+	var opts options
+	opts.SimilarityThreshold, opts.MinSpamProbability, opts.FirstMessagesCount = 0.5, 50, 1
+	opts.Meta.LinksLimit, opts.Meta.ImageOnly = -1, false
+	opts.Message.Spam, opts.Message.Warn = "spam detected", "warning"
+
+	threshold, count, linksLimit, imageOnly, veto := 0.8, 3, 2, true, true
+	spamMsg := "custom spam"
+	documents := []string{"apk", "exe"}
+	res := applyProfile(opts, storage.Profile{Name: "strict", SimilarityThreshold: &threshold, FirstMessagesCount: &count,
+		OpenAIVeto: &veto, Meta: storage.ProfileMeta{LinksLimit: &linksLimit, ImageOnly: &imageOnly, Documents: &documents},
+		Messages: storage.ProfileMessages{Spam: &spamMsg}})
+
+	assert.InDelta(t, 0.8, res.SimilarityThreshold, 0.0001)
+	assert.Equal(t, 3, res.FirstMessagesCount)
+	assert.True(t, res.OpenAI.Veto)
+	assert.Equal(t, 2, res.Meta.LinksLimit)
+	assert.True(t, res.Meta.ImageOnly)
+	assert.Equal(t, []string{"apk", "exe"}, res.Meta.Documents)
+	assert.Equal(t, "custom spam", res.Message.Spam)
+
+	// not set in the profile, defaults kept
+	assert.InDelta(t, 50.0, res.MinSpamProbability, 0.0001)
+	assert.Equal(t, "warning", res.Message.Warn)
+
+	// original options not changed
+	assert.InDelta(t, 0.5, opts.SimilarityThreshold, 0.0001)
+	assert.Equal(t, -1, opts.Meta.LinksLimit)
+}
+
+func Test_profileOptions(t *testing.T) {
+	ctx := context.Background()
+	db, err := engine.NewSqlite(filepath.Join(t.TempDir(), "tg-spam.db"), "gr1")
+	require.NoError(t, err)
+	defer db.Close()
+	profiles, err := storage.NewProfiles(ctx, db)
+	require.NoError(t, err)
+	count := 5
+	require.NoError(t, profiles.Write(ctx, storage.Profile{Name: "strict", FirstMessagesCount: &count}))
+
+	var opts options
+	opts.FirstMessagesCount = 1
+	opts.Profiles.Assign = map[string]string{"group1": "strict", "group2": "unknown"}
+
+	assert.Equal(t, 5, profileOptions(ctx, opts, profiles, "group1").FirstMessagesCount, "profile applied")
+	assert.Equal(t, 1, profileOptions(ctx, opts, profiles, "group2").FirstMessagesCount, "missing profile, defaults")
+	assert.Equal(t, 1, profileOptions(ctx, opts, profiles, "group3").FirstMessagesCount, "no profile assigned")
+	assert.Equal(t, 1, profileOptions(ctx, opts, nil, "group1").FirstMessagesCount, "no profiles store")
+}
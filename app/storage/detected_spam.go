@@ -33,6 +33,7 @@ type DetectedSpamInfo struct {
 	UserName   string               `db:"user_name"`
 	Timestamp  time.Time            `db:"timestamp"`
 	Added      bool                 `db:"added"`  // added to samples
+	Score      float64              `db:"score"`  // total score of the checks
 	ChecksJSON string               `db:"checks"` // store as JSON
 	Checks     []spamcheck.Response `db:"-"`      // don't store in DB directly
 }
@@ -41,6 +42,7 @@ type DetectedSpamInfo struct {
 const (
 	CmdCreateDetectedSpamTable engine.DBCmd = iota + 200
 	CmdCreateDetectedSpamIndexes
+	CmdAddDetectedSpamScoreColumn
 )
 
 // queries holds all detected spam queries
@@ -54,7 +56,8 @@ var detectedSpamQueries = engine.NewQueryMap().
             user_name TEXT,
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
             added BOOLEAN DEFAULT 0,
-            checks TEXT
+            checks TEXT,
+            score REAL DEFAULT 0
         )`,
 		Postgres: `CREATE TABLE IF NOT EXISTS detected_spam (
             id SERIAL PRIMARY KEY,
@@ -64,7 +67,8 @@ var detectedSpamQueries = engine.NewQueryMap().
             user_name TEXT,
             timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             added BOOLEAN DEFAULT false,
-            checks TEXT
+            checks TEXT,
+            score DOUBLE PRECISION DEFAULT 0
         )`,
 	}).
 	AddSame(CmdCreateDetectedSpamIndexes, `
@@ -76,6 +80,10 @@ var detectedSpamQueries = engine.NewQueryMap().
 	Add(CmdAddGIDColumn, engine.Query{
 		Sqlite:   "ALTER TABLE detected_spam ADD COLUMN gid TEXT DEFAULT ''",
 		Postgres: "ALTER TABLE detected_spam ADD COLUMN IF NOT EXISTS gid TEXT DEFAULT ''",
+	}).
+	Add(CmdAddDetectedSpamScoreColumn, engine.Query{
+		Sqlite:   "ALTER TABLE detected_spam ADD COLUMN score REAL DEFAULT 0",
+		Postgres: "ALTER TABLE detected_spam ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION DEFAULT 0",
 	})
 
 // NewDetectedSpam creates a new DetectedSpam storage
@@ -111,8 +119,9 @@ func (ds *DetectedSpam) Write(ctx context.Context, entry DetectedSpamInfo, check
 		return fmt.Errorf("failed to marshal checks: %w", err)
 	}
 
-	query := ds.Adopt("INSERT INTO detected_spam (gid, text, user_id, user_name, timestamp, checks) VALUES (?, ?, ?, ?, ?, ?)")
-	_, err = ds.ExecContext(ctx, query, entry.GID, entry.Text, entry.UserID, entry.UserName, entry.Timestamp, string(checksJSON))
+	query := ds.Adopt("INSERT INTO detected_spam (gid, text, user_id, user_name, timestamp, checks, score) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	_, err = ds.ExecContext(ctx, query, entry.GID, entry.Text, entry.UserID, entry.UserName, entry.Timestamp, string(checksJSON),
+		spamcheck.TotalScore(checks))
 	if err != nil {
 		return fmt.Errorf("failed to insert detected spam entry: %w", err)
 	}
@@ -182,6 +191,22 @@ func (ds *DetectedSpam) FindByUserID(ctx context.Context, userID int64) (*Detect
 }
 
 func (ds *DetectedSpam) migrate(ctx context.Context, tx *sqlx.Tx, gid string) error {
+	if err := ds.migrateGID(ctx, tx, gid); err != nil {
+		return err
+	}
+
+	// add score column, no-op if already exists
+	addScoreQuery, err := detectedSpamQueries.Pick(ds.Type(), CmdAddDetectedSpamScoreColumn)
+	if err != nil {
+		return fmt.Errorf("failed to get add score query: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, addScoreQuery); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("failed to add score column: %w", err)
+	}
+	return nil
+}
+
+func (ds *DetectedSpam) migrateGID(ctx context.Context, tx *sqlx.Tx, gid string) error {
 	// try to select with new structure, if works - already migrated
 	var count int
 	err := tx.GetContext(ctx, &count, "SELECT COUNT(*) FROM detected_spam WHERE gid = ''")
@@ -44,6 +44,7 @@ func (s *StorageTestSuite) TestNewDetectedSpam() {
 				s.Equal("TEXT", colMap["text"])
 				s.Equal("INTEGER", colMap["user_id"])
 				s.Equal("TEXT", colMap["user_name"])
+				s.Equal("REAL", colMap["score"])
 			})
 
 			s.Run("with existing old schema", func() {
@@ -83,6 +84,7 @@ func (s *StorageTestSuite) TestNewDetectedSpam() {
 				s.Equal("test spam", entries[0].Text)
 				s.Equal(int64(123), entries[0].UserID)
 				s.Equal("test_user", entries[0].UserName)
+				s.InDelta(0.0, entries[0].Score, 0.0001)
 			})
 
 			s.Run("with nil db", func() {
@@ -126,6 +128,11 @@ func (s *StorageTestSuite) TestDetectedSpam_Write() {
 					Name:    "Check1",
 					Spam:    true,
 					Details: "Details 1",
+					Score:   0.75,
+				},
+				{
+					Name:  "Check2",
+					Score: 0.5,
 				},
 			}
 
@@ -136,6 +143,11 @@ func (s *StorageTestSuite) TestDetectedSpam_Write() {
 			err = db.Get(&count, "SELECT COUNT(*) FROM detected_spam")
 			s.Require().NoError(err)
 			s.Equal(1, count)
+
+			var score float64
+			err = db.Get(&score, "SELECT score FROM detected_spam")
+			s.Require().NoError(err)
+			s.InDelta(1.25, score, 0.0001)
 		})
 	}
 }
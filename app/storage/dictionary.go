@@ -2,10 +2,13 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
+	"log"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
@@ -19,6 +22,7 @@ const (
 	CmdCreateDictionaryIndexes
 	CmdAddDictionaryEntry
 	CmdImportDictionaryEntry
+	CmdMigrateDictionaryTypes
 )
 
 // all dictionary-related queries
@@ -28,7 +32,7 @@ var dictionaryQueries = engine.NewQueryMap().
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             gid TEXT DEFAULT '',
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-            type TEXT CHECK (type IN ('stop_phrase', 'ignored_word')),
+            type TEXT CHECK (type IN ('stop_phrase', 'ignored_word', 'denied_domain', 'allowed_domain')),
             data TEXT NOT NULL,
             UNIQUE(gid, data)
         )`,
@@ -36,7 +40,7 @@ var dictionaryQueries = engine.NewQueryMap().
             id SERIAL PRIMARY KEY,
             gid TEXT DEFAULT '',
             timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            type TEXT CHECK (type IN ('stop_phrase', 'ignored_word')),
+            type TEXT CHECK (type IN ('stop_phrase', 'ignored_word', 'denied_domain', 'allowed_domain')),
             data TEXT NOT NULL,
             UNIQUE(gid, data)
         )`,
@@ -62,9 +66,28 @@ var dictionaryQueries = engine.NewQueryMap().
 	Add(CmdImportDictionaryEntry, engine.Query{
 		Sqlite:   `INSERT OR REPLACE INTO dictionary (type, data, gid) VALUES (?, ?, ?)`,
 		Postgres: `INSERT INTO dictionary (type, data, gid) VALUES ($1, $2, $3) ON CONFLICT (gid, data) DO UPDATE SET type = EXCLUDED.type`,
+	}).
+	// sqlite can't alter check constraint, the table is recreated with the new list of types
+	Add(CmdMigrateDictionaryTypes, engine.Query{
+		Sqlite: `
+			CREATE TABLE dictionary_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				gid TEXT DEFAULT '',
+				timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+				type TEXT CHECK (type IN ('stop_phrase', 'ignored_word', 'denied_domain', 'allowed_domain')),
+				data TEXT NOT NULL,
+				UNIQUE(gid, data)
+			);
+			INSERT INTO dictionary_new (id, gid, timestamp, type, data) SELECT id, gid, timestamp, type, data FROM dictionary;
+			DROP TABLE dictionary;
+			ALTER TABLE dictionary_new RENAME TO dictionary`,
+		Postgres: `
+			ALTER TABLE dictionary DROP CONSTRAINT IF EXISTS dictionary_type_check;
+			ALTER TABLE dictionary ADD CONSTRAINT dictionary_type_check
+				CHECK (type IN ('stop_phrase', 'ignored_word', 'denied_domain', 'allowed_domain'))`,
 	})
 
-// Dictionary is a storage for stop words/phrases and ignored words
+// Dictionary is a storage for stop words/phrases, ignored words and denied/allowed domains
 type Dictionary struct {
 	*engine.SQL
 	engine.RWLocker
@@ -75,8 +98,10 @@ type DictionaryType string
 
 // enum for dictionary types
 const (
-	DictionaryTypeStopPhrase  DictionaryType = "stop_phrase"
-	DictionaryTypeIgnoredWord DictionaryType = "ignored_word"
+	DictionaryTypeStopPhrase    DictionaryType = "stop_phrase"
+	DictionaryTypeIgnoredWord   DictionaryType = "ignored_word"
+	DictionaryTypeDeniedDomain  DictionaryType = "denied_domain"
+	DictionaryTypeAllowedDomain DictionaryType = "allowed_domain"
 )
 
 // NewDictionary creates a new Dictionary storage
@@ -123,6 +148,47 @@ func (d *Dictionary) Add(ctx context.Context, t DictionaryType, data string) err
 	return nil
 }
 
+// Set adds an entry to the dictionary, or changes the type of the existing entry with the same data between
+// denied and allowed domains, e.g. moves a domain from denied to allowed domains. The entry of other type,
+// e.g. a stop phrase with the same data, is not changed and an error is returned.
+func (d *Dictionary) Set(ctx context.Context, t DictionaryType, data string) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if data == "" {
+		return fmt.Errorf("data cannot be empty")
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	var current DictionaryType
+	err := d.GetContext(ctx, &current, d.Adopt(`SELECT type FROM dictionary WHERE gid = ? AND data = ?`), d.GID(), data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query, qErr := dictionaryQueries.Pick(d.Type(), CmdAddDictionaryEntry)
+		if qErr != nil {
+			return fmt.Errorf("failed to get insert query: %w", qErr)
+		}
+		if _, err = d.ExecContext(ctx, query, t, data, d.GID()); err != nil {
+			return fmt.Errorf("failed to set data: %w", err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get existing entry: %w", err)
+	case current == t:
+		return nil
+	case !current.isDomain() || !t.isDomain():
+		return fmt.Errorf("%q already exists as %s", data, current)
+	}
+
+	query := d.Adopt(`UPDATE dictionary SET type = ? WHERE gid = ? AND data = ?`)
+	if _, err = d.ExecContext(ctx, query, t, d.GID(), data); err != nil {
+		return fmt.Errorf("failed to set data: %w", err)
+	}
+	return nil
+}
+
 // Delete removes an entry from the dictionary by its ID
 func (d *Dictionary) Delete(ctx context.Context, id int64) error {
 	d.Lock()
@@ -143,6 +209,30 @@ func (d *Dictionary) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+// Remove removes an entry of the given type from the dictionary by its data
+func (d *Dictionary) Remove(ctx context.Context, t DictionaryType, data string) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	query := d.Adopt(`DELETE FROM dictionary WHERE type = ? AND data = ? AND gid = ?`)
+	result, err := d.ExecContext(ctx, query, t, data, d.GID())
+	if err != nil {
+		return fmt.Errorf("failed to remove %s %q: %w", t, data, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s %q not found", t, data)
+	}
+	return nil
+}
+
 // Read reads all entries from the dictionary by type
 func (d *Dictionary) Read(ctx context.Context, t DictionaryType) ([]string, error) {
 	d.RLock()
@@ -293,21 +383,29 @@ func (t DictionaryType) String() string { return string(t) }
 // Validate checks if the dictionary type is valid
 func (t DictionaryType) Validate() error {
 	switch t {
-	case DictionaryTypeStopPhrase, DictionaryTypeIgnoredWord:
+	case DictionaryTypeStopPhrase, DictionaryTypeIgnoredWord, DictionaryTypeDeniedDomain, DictionaryTypeAllowedDomain:
 		return nil
 	}
 	return fmt.Errorf("invalid dictionary type: %s", t)
 }
 
+// isDomain checks if the type is one of the domain types, i.e. denied or allowed domain
+func (t DictionaryType) isDomain() bool {
+	return t == DictionaryTypeDeniedDomain || t == DictionaryTypeAllowedDomain
+}
+
 // DictionaryStats returns statistics about dictionary entries
 type DictionaryStats struct {
-	TotalStopPhrases  int `db:"stop_phrases_count"`
-	TotalIgnoredWords int `db:"ignored_words_count"`
+	TotalStopPhrases    int `db:"stop_phrases_count"`
+	TotalIgnoredWords   int `db:"ignored_words_count"`
+	TotalDeniedDomains  int `db:"denied_domains_count"`
+	TotalAllowedDomains int `db:"allowed_domains_count"`
 }
 
 // String returns a string representation of the stats
 func (d *DictionaryStats) String() string {
-	return fmt.Sprintf("stop phrases: %d, ignored words: %d", d.TotalStopPhrases, d.TotalIgnoredWords)
+	return fmt.Sprintf("stop phrases: %d, ignored words: %d, denied domains: %d, allowed domains: %d",
+		d.TotalStopPhrases, d.TotalIgnoredWords, d.TotalDeniedDomains, d.TotalAllowedDomains)
 }
 
 // Stats returns statistics about dictionary entries for the given GID
@@ -318,19 +416,42 @@ func (d *Dictionary) Stats(ctx context.Context) (*DictionaryStats, error) {
 	query := d.Adopt(`
         SELECT 
             COUNT(CASE WHEN type = ? THEN 1 END) as stop_phrases_count,
-            COUNT(CASE WHEN type = ? THEN 1 END) as ignored_words_count
+            COUNT(CASE WHEN type = ? THEN 1 END) as ignored_words_count,
+            COUNT(CASE WHEN type = ? THEN 1 END) as denied_domains_count,
+            COUNT(CASE WHEN type = ? THEN 1 END) as allowed_domains_count
         FROM dictionary
         WHERE gid = ?`,
 	)
 
 	var stats DictionaryStats
-	if err := d.GetContext(ctx, &stats, query, DictionaryTypeStopPhrase, DictionaryTypeIgnoredWord, d.GID()); err != nil {
+	if err := d.GetContext(ctx, &stats, query, DictionaryTypeStopPhrase, DictionaryTypeIgnoredWord,
+		DictionaryTypeDeniedDomain, DictionaryTypeAllowedDomain, d.GID()); err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
 	return &stats, nil
 }
 
-func (d *Dictionary) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
-	// no migrations yet
+func (d *Dictionary) migrate(ctx context.Context, tx *sqlx.Tx, _ string) error {
+	// domain types added later, tables created before don't allow them in the type check
+	schemaQuery := "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'dictionary'"
+	if d.Type() == engine.Postgres {
+		schemaQuery = "SELECT COALESCE(MAX(pg_get_constraintdef(oid)), '') FROM pg_constraint WHERE conname = 'dictionary_type_check'"
+	}
+	var schema string
+	if err := tx.GetContext(ctx, &schema, schemaQuery); err != nil {
+		return fmt.Errorf("failed to get dictionary schema: %w", err)
+	}
+	if strings.Contains(schema, string(DictionaryTypeAllowedDomain)) {
+		return nil
+	}
+
+	query, err := dictionaryQueries.Pick(d.Type(), CmdMigrateDictionaryTypes)
+	if err != nil {
+		return fmt.Errorf("failed to get migrate types query: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to migrate dictionary types: %w", err)
+	}
+	log.Printf("[DEBUG] dictionary table migrated for domain types")
 	return nil
 }
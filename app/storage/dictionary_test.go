@@ -6,6 +6,8 @@ import (
 	"io"
 	"strings"
 	"sync"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
 )
 
 func (s *StorageTestSuite) TestNewDictionary() {
@@ -89,6 +91,116 @@ func (s *StorageTestSuite) TestDictionary_Delete() {
 	}
 }
 
+func (s *StorageTestSuite) TestDictionary_Remove() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			d, err := NewDictionary(ctx, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE dictionary")
+
+			s.Require().NoError(d.Add(ctx, DictionaryTypeDeniedDomain, "spam.com"))
+			s.Require().NoError(d.Add(ctx, DictionaryTypeAllowedDomain, "*.example.com"))
+
+			s.Run("remove existing domain", func() {
+				s.NoError(d.Remove(ctx, DictionaryTypeDeniedDomain, "spam.com"))
+				denied, err := d.Read(ctx, DictionaryTypeDeniedDomain)
+				s.Require().NoError(err)
+				s.Empty(denied)
+			})
+
+			s.Run("remove domain of another type", func() {
+				s.EqualError(d.Remove(ctx, DictionaryTypeDeniedDomain, "*.example.com"), `denied_domain "*.example.com" not found`)
+				allowed, err := d.Read(ctx, DictionaryTypeAllowedDomain)
+				s.Require().NoError(err)
+				s.Equal([]string{"*.example.com"}, allowed)
+			})
+
+			s.Run("invalid type", func() {
+				s.Error(d.Remove(ctx, "invalid", "spam.com"))
+			})
+
+			s.Run("stats", func() {
+				stats, err := d.Stats(ctx)
+				s.Require().NoError(err)
+				s.Equal(1, stats.TotalAllowedDomains)
+				s.Equal(0, stats.TotalDeniedDomains)
+			})
+
+			s.Run("set moves entry to another type", func() {
+				s.Require().NoError(d.Set(ctx, DictionaryTypeDeniedDomain, "*.example.com"))
+				s.Require().NoError(d.Set(ctx, DictionaryTypeDeniedDomain, "new.com"))
+				denied, err := d.Read(ctx, DictionaryTypeDeniedDomain)
+				s.Require().NoError(err)
+				s.ElementsMatch([]string{"*.example.com", "new.com"}, denied)
+				allowed, err := d.Read(ctx, DictionaryTypeAllowedDomain)
+				s.Require().NoError(err)
+				s.Empty(allowed)
+				s.Error(d.Set(ctx, DictionaryTypeDeniedDomain, ""))
+				s.Require().NoError(d.Set(ctx, DictionaryTypeDeniedDomain, "new.com"), "same type, no change")
+			})
+
+			s.Run("set doesn't change entry of other type", func() {
+				s.Require().NoError(d.Add(ctx, DictionaryTypeStopPhrase, "example.org"))
+				err := d.Set(ctx, DictionaryTypeDeniedDomain, "example.org")
+				s.Require().EqualError(err, `"example.org" already exists as stop_phrase`)
+				phrases, err := d.Read(ctx, DictionaryTypeStopPhrase)
+				s.Require().NoError(err)
+				s.Contains(phrases, "example.org")
+				denied, err := d.Read(ctx, DictionaryTypeDeniedDomain)
+				s.Require().NoError(err)
+				s.NotContains(denied, "example.org")
+
+				s.Require().Error(d.Set(ctx, DictionaryTypeStopPhrase, "new.com"), "domain not changed to stop phrase")
+			})
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestDictionary_MigrateDomainTypes() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		if db.Type() != engine.Sqlite {
+			continue
+		}
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			// table created before domain types were added
+			_, err := db.Exec(`CREATE TABLE dictionary (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				gid TEXT DEFAULT '',
+				timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+				type TEXT CHECK (type IN ('stop_phrase', 'ignored_word')),
+				data TEXT NOT NULL,
+				UNIQUE(gid, data)
+			)`)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE dictionary")
+			_, err = db.Exec("INSERT INTO dictionary (type, data, gid) VALUES ('stop_phrase', 'buy now', 'gr1')")
+			s.Require().NoError(err)
+
+			d, err := NewDictionary(ctx, db)
+			s.Require().NoError(err)
+			s.Require().NoError(d.Add(ctx, DictionaryTypeDeniedDomain, "spam.com"))
+
+			phrases, err := d.Read(ctx, DictionaryTypeStopPhrase)
+			s.Require().NoError(err)
+			s.Equal([]string{"buy now"}, phrases, "existing entries kept")
+			denied, err := d.Read(ctx, DictionaryTypeDeniedDomain)
+			s.Require().NoError(err)
+			s.Equal([]string{"spam.com"}, denied)
+
+			// already migrated table is not touched
+			_, err = NewDictionary(ctx, db)
+			s.Require().NoError(err)
+			phrases, err = d.Read(ctx, DictionaryTypeStopPhrase)
+			s.Require().NoError(err)
+			s.Equal([]string{"buy now"}, phrases)
+		})
+	}
+}
+
 func (s *StorageTestSuite) TestDictionary_Read() {
 	ctx := context.Background()
 	for _, dbt := range s.getTestDB() {
@@ -296,6 +408,8 @@ func (s *StorageTestSuite) TestDictionaryType_Validate() {
 	}{
 		{"valid stop phrase", DictionaryTypeStopPhrase, false},
 		{"valid ignored word", DictionaryTypeIgnoredWord, false},
+		{"valid denied domain", DictionaryTypeDeniedDomain, false},
+		{"valid allowed domain", DictionaryTypeAllowedDomain, false},
 		{"invalid type", "invalid", true},
 	}
 
@@ -23,7 +23,7 @@ func NewConverter(db *SQL) *Converter {
 }
 
 // SqliteToPostgres converts a SQLite database to PostgreSQL format and writes it to the provided writer.
-// It only converts the tables used by tg-spam: detected_spam, approved_users, samples, dictionary.
+// It only converts the tables used by tg-spam: detected_spam, approved_users, samples, dictionary, strikes, profiles.
 //
 // The conversion process includes:
 // 1. Converting table schemas with appropriate data type mappings
@@ -57,7 +57,7 @@ func (c *Converter) SqliteToPostgres(ctx context.Context, w io.Writer) error {
 	}
 
 	// define the tables specific to tg-spam that we want to convert
-	tables := []string{"detected_spam", "approved_users", "samples", "dictionary"}
+	tables := []string{"detected_spam", "approved_users", "samples", "dictionary", "strikes", "profiles"}
 
 	// for each table, export schema and data if it exists
 	for _, table := range tables {
@@ -177,6 +177,13 @@ func (c *Converter) convertTableSchema(tableName, sqliteStmt string) string {
 	case "dictionary":
 		// dictionary table has standard types and constraints
 		// no specific conversions are needed beyond the common ones applied to all tables
+
+	case "strikes":
+		// user_id in strikes should be BIGINT, same as in detected_spam
+		pgStmt = strings.ReplaceAll(pgStmt, "user_id INTEGER", "user_id BIGINT")
+
+	case "profiles":
+		// profile settings are kept as json text, no specific conversions needed
 	}
 
 	// final conversion of boolean defaults for any boolean columns in all tables
@@ -262,7 +269,7 @@ func (c *Converter) exportTableData(ctx context.Context, tx *sqlx.Tx, w io.Write
 			}
 
 			// special handling for boolean values in specific tables/columns
-			if table == "detected_spam" && col == "added" {
+			if (table == "detected_spam" && col == "added") || (table == "strikes" && col == "applied") {
 				// convert SQLite 0/1 integer boolean to PostgreSQL f/t text boolean
 				if v, ok := val.(int64); ok {
 					if v == 0 {
@@ -76,6 +76,35 @@ func TestConverter_SqliteToPostgres(t *testing.T) {
 	)`)
 	require.NoError(t, err)
 
+	// create strikes table
+	_, err = db.Exec(`CREATE TABLE strikes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		gid TEXT NOT NULL DEFAULT '',
+		user_id INTEGER,
+		user_name TEXT,
+		source TEXT,
+		reason TEXT,
+		applied BOOLEAN DEFAULT 0,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO strikes (gid, user_id, user_name, source, reason, applied) VALUES (?, ?, ?, ?, ?, ?)`,
+		"test", 12345, "user1", "admin", "warned by admin", 1)
+	require.NoError(t, err)
+
+	// create profiles table
+	_, err = db.Exec(`CREATE TABLE profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		gid TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		data TEXT NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(gid, name)
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO profiles (gid, name, data) VALUES (?, ?, ?)`, "test", "strict", `{"min_msg_len":10}`)
+	require.NoError(t, err)
+
 	// insert test data
 	_, err = db.Exec(`INSERT INTO detected_spam (gid, text, user_id, user_name, added, checks) 
 		VALUES (?, ?, ?, ?, ?, ?)`,
@@ -161,6 +190,17 @@ func TestConverter_SqliteToPostgres(t *testing.T) {
 	assert.Contains(t, result, "CREATE TABLE dictionary")
 	assert.Contains(t, result, "CHECK (type IN ('stop_phrase', 'ignored_word'))")
 
+	// check strikes table conversion
+	assert.Contains(t, result, "CREATE TABLE strikes")
+	assert.Contains(t, result, "applied BOOLEAN DEFAULT false")
+	assert.Contains(t, result, "COPY strikes")
+	assert.Contains(t, result, "warned by admin\tt\t")
+
+	// check profiles table conversion
+	assert.Contains(t, result, "CREATE TABLE profiles")
+	assert.Contains(t, result, "COPY profiles")
+	assert.Contains(t, result, `{"min_msg_len":10}`)
+
 	// check indices
 	assert.Contains(t, result, "CREATE INDEX idx_detected_spam_gid")
 	assert.Contains(t, result, "CREATE INDEX idx_approved_users_gid")
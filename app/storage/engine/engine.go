@@ -125,6 +125,12 @@ func (e *SQL) GID() string {
 	return e.gid
 }
 
+// WithGID returns a copy of the database engine for another group id. The copy shares the connection pool,
+// so storages of multiple groups can be kept in the same database.
+func (e *SQL) WithGID(gid string) *SQL {
+	return &SQL{DB: e.DB, gid: gid, dbType: e.dbType}
+}
+
 // Type returns the database engine type
 func (e *SQL) Type() Type {
 	return e.dbType
@@ -106,6 +106,26 @@ func TestEngine(t *testing.T) {
 		assert.Equal(t, "gr1", db.GID())
 	})
 
+	t.Run("with gid", func(t *testing.T) {
+		db, err := NewSqlite(":memory:", "gr1")
+		require.NoError(t, err)
+		defer db.Close()
+
+		db2 := db.WithGID("gr2")
+		assert.Equal(t, "gr2", db2.GID())
+		assert.Equal(t, Sqlite, db2.Type())
+		assert.Equal(t, "gr1", db.GID(), "original gid unchanged")
+
+		// both share the same connection pool
+		_, err = db.Exec("CREATE TABLE test (id INTEGER)")
+		require.NoError(t, err)
+		_, err = db2.Exec("INSERT INTO test (id) VALUES (1)")
+		require.NoError(t, err)
+		var count int
+		require.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM test"))
+		assert.Equal(t, 1, count)
+	})
+
 	t.Run("invalid file", func(t *testing.T) {
 		db, err := NewSqlite("/invalid/path", "gr1")
 		assert.Error(t, err)
@@ -309,6 +309,45 @@ func (l *Locator) UserIDByName(ctx context.Context, userName string) int64 {
 	return userID
 }
 
+// UserMessages returns messages of the user in the chat added since the given time, oldest first
+func (l *Locator) UserMessages(ctx context.Context, chatID, userID int64, since time.Time) ([]MsgMeta, error) {
+	l.RLock()
+	defer l.RUnlock()
+
+	res := []MsgMeta{}
+	query := l.Adopt(`SELECT time, chat_id, user_id, user_name, msg_id FROM messages
+		WHERE chat_id = ? AND user_id = ? AND gid = ? AND time >= ? ORDER BY time`)
+	if err := l.SelectContext(ctx, &res, query, chatID, userID, l.GID(), since); err != nil {
+		return nil, fmt.Errorf("failed to get messages of user %d: %w", userID, err)
+	}
+	return res, nil
+}
+
+// Joins returns join records of new chat members in the chat added since the given time, oldest first.
+// Join records are messages saved with JoinRecord text, only hashes are stored, so each hash is matched
+// to the record of the user.
+func (l *Locator) Joins(ctx context.Context, chatID int64, since time.Time) ([]MsgMeta, error) {
+	l.RLock()
+	defer l.RUnlock()
+
+	var recs []struct {
+		Hash string `db:"hash"`
+		MsgMeta
+	}
+	query := l.Adopt(`SELECT hash, time, chat_id, user_id, user_name, msg_id FROM messages
+		WHERE chat_id = ? AND gid = ? AND time >= ? ORDER BY time`)
+	if err := l.SelectContext(ctx, &recs, query, chatID, l.GID(), since); err != nil {
+		return nil, fmt.Errorf("failed to get joins of chat %d: %w", chatID, err)
+	}
+	res := []MsgMeta{}
+	for _, r := range recs {
+		if r.Hash == l.MsgHash(JoinRecord(chatID, r.UserID)) {
+			res = append(res, r.MsgMeta)
+		}
+	}
+	return res, nil
+}
+
 // Spam returns message SpamData for given msg within the same gid
 func (l *Locator) Spam(ctx context.Context, userID int64) (SpamData, bool) {
 	l.RLock()
@@ -328,6 +367,11 @@ func (l *Locator) Spam(ctx context.Context, userID int64) (SpamData, bool) {
 	return data, true
 }
 
+// JoinRecord returns the text of the join record saved for a new chat member, used to locate the join message
+func JoinRecord(chatID, userID int64) string {
+	return fmt.Sprintf("new_%d_%d", chatID, userID)
+}
+
 // MsgHash returns sha256 hash of a message
 // we use hash to avoid storing potentially long messages and all we need is just match
 func (l *Locator) MsgHash(msg string) string {
@@ -109,6 +109,82 @@ func (s *StorageTestSuite) TestLocator_AddAndRetrieveSpam() {
 	}
 }
 
+func (s *StorageTestSuite) TestLocator_UserMessages() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			locator, err := NewLocator(ctx, time.Hour, 1000, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE messages")
+			defer db.Exec("DROP TABLE spam")
+
+			since := time.Now().Add(-time.Second)
+			s.Require().NoError(locator.AddMessage(ctx, "msg 1", 100, 1, "user1", 11))
+			s.Require().NoError(locator.AddMessage(ctx, "msg 2", 100, 1, "user1", 12))
+			s.Require().NoError(locator.AddMessage(ctx, "msg 3", 100, 2, "user2", 13))
+			s.Require().NoError(locator.AddMessage(ctx, "msg 4", 200, 1, "user1", 14))
+			_, err = locator.Exec(db.Adopt(`INSERT INTO messages (hash, gid, time, chat_id, user_id, user_name, msg_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`), "old", locator.GID(), time.Now().Add(-time.Minute), 100, 1, "user1", 10)
+			s.Require().NoError(err)
+
+			res, err := locator.UserMessages(ctx, 100, 1, since)
+			s.Require().NoError(err)
+			s.Require().Len(res, 2)
+			s.Equal(11, res[0].MsgID)
+			s.Equal(12, res[1].MsgID)
+			s.Equal("user1", res[0].UserName)
+
+			res, err = locator.UserMessages(ctx, 100, 1, time.Now().Add(-time.Hour))
+			s.Require().NoError(err)
+			s.Len(res, 3)
+
+			res, err = locator.UserMessages(ctx, 100, 3, since)
+			s.Require().NoError(err)
+			s.Empty(res)
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestLocator_Joins() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			locator, err := NewLocator(ctx, time.Hour, 1000, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE messages")
+			defer db.Exec("DROP TABLE spam")
+
+			since := time.Now().Add(-time.Second)
+			s.Require().NoError(locator.AddMessage(ctx, JoinRecord(100, 1), 100, 1, "", 11))
+			s.Require().NoError(locator.AddMessage(ctx, "hello", 100, 1, "user1", 12))
+			s.Require().NoError(locator.AddMessage(ctx, JoinRecord(100, 2), 100, 2, "", 13))
+			s.Require().NoError(locator.AddMessage(ctx, JoinRecord(200, 3), 200, 3, "", 14))
+			_, err = locator.Exec(db.Adopt(`INSERT INTO messages (hash, gid, time, chat_id, user_id, user_name, msg_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`), locator.MsgHash(JoinRecord(100, 4)), locator.GID(), time.Now().Add(-time.Minute),
+				100, 4, "", 10)
+			s.Require().NoError(err)
+
+			res, err := locator.Joins(ctx, 100, since)
+			s.Require().NoError(err)
+			s.Require().Len(res, 2)
+			s.Equal(int64(1), res[0].UserID)
+			s.Equal(11, res[0].MsgID)
+			s.Equal(int64(2), res[1].UserID)
+			s.Equal(13, res[1].MsgID)
+
+			res, err = locator.Joins(ctx, 100, time.Now().Add(-time.Hour))
+			s.Require().NoError(err)
+			s.Len(res, 3)
+
+			res, err = locator.Joins(ctx, 300, since)
+			s.Require().NoError(err)
+			s.Empty(res)
+		})
+	}
+}
+
 func (s *StorageTestSuite) TestLocator_CleanupLogic() {
 	ctx := context.Background()
 	for _, dbt := range s.getTestDB() {
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
+)
+
+// MentionTargets is a cache of resolved targets of mentioned usernames. Resolved targets expire after ttl period,
+// so changes of the target, like a user renamed to a channel, are picked up.
+type MentionTargets struct {
+	*engine.SQL
+	engine.RWLocker
+	ttl time.Duration
+}
+
+// MentionTarget is a resolved target of @username mention
+type MentionTarget struct {
+	Username   string    `db:"username"`    // username without "@", lowercased
+	TargetID   int64     `db:"target_id"`   // chat or user id, 0 if not resolved
+	TargetType string    `db:"target_type"` // telegram chat type, "bot" or empty if not resolved
+	ResolvedAt time.Time `db:"resolved_at"` // time of the resolution
+}
+
+// mention targets query commands
+const (
+	CmdCreateMentionTargetsTable engine.DBCmd = iota + 800
+	CmdCreateMentionTargetsIndexes
+	CmdWriteMentionTarget
+)
+
+// mentionTargetsQueries holds all mention targets queries
+var mentionTargetsQueries = engine.NewQueryMap().
+	Add(CmdCreateMentionTargetsTable, engine.Query{
+		Sqlite: `CREATE TABLE IF NOT EXISTS mention_targets (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            gid TEXT NOT NULL DEFAULT '',
+            username TEXT NOT NULL,
+            target_id INTEGER NOT NULL DEFAULT 0,
+            target_type TEXT NOT NULL DEFAULT '',
+            resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, username)
+        )`,
+		Postgres: `CREATE TABLE IF NOT EXISTS mention_targets (
+            id SERIAL PRIMARY KEY,
+            gid TEXT NOT NULL DEFAULT '',
+            username TEXT NOT NULL,
+            target_id BIGINT NOT NULL DEFAULT 0,
+            target_type TEXT NOT NULL DEFAULT '',
+            resolved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, username)
+        )`,
+	}).
+	AddSame(CmdCreateMentionTargetsIndexes, `CREATE INDEX IF NOT EXISTS idx_mention_targets_resolved_at ON mention_targets(resolved_at)`).
+	Add(CmdWriteMentionTarget, engine.Query{
+		Sqlite: "INSERT OR REPLACE INTO mention_targets (gid, username, target_id, target_type, resolved_at) VALUES (?, ?, ?, ?, ?)",
+		Postgres: "INSERT INTO mention_targets (gid, username, target_id, target_type, resolved_at) VALUES ($1, $2, $3, $4, $5) " +
+			"ON CONFLICT (gid, username) DO UPDATE SET target_id=EXCLUDED.target_id, target_type=EXCLUDED.target_type, " +
+			"resolved_at=EXCLUDED.resolved_at",
+	})
+
+// NewMentionTargets creates a new MentionTargets storage. ttl defines how long resolved targets are cached
+func NewMentionTargets(ctx context.Context, db *engine.SQL, ttl time.Duration) (*MentionTargets, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db connection is nil")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %v", ttl)
+	}
+	res := &MentionTargets{SQL: db, RWLocker: db.MakeLock(), ttl: ttl}
+	cfg := engine.TableConfig{
+		Name:          "mention_targets",
+		CreateTable:   CmdCreateMentionTargetsTable,
+		CreateIndexes: CmdCreateMentionTargetsIndexes,
+		MigrateFunc:   res.migrate,
+		QueriesMap:    mentionTargetsQueries,
+	}
+	if err := engine.InitTable(ctx, db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to init mention targets storage: %w", err)
+	}
+	return res, nil
+}
+
+// Write adds or replaces the resolved target of the username. Expired targets are removed.
+func (m *MentionTargets) Write(ctx context.Context, target MentionTarget) error {
+	target.Username = strings.ToLower(strings.TrimPrefix(target.Username, "@"))
+	if target.Username == "" {
+		return fmt.Errorf("username can't be empty")
+	}
+	if target.ResolvedAt.IsZero() {
+		target.ResolvedAt = time.Now()
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	query := m.Adopt("DELETE FROM mention_targets WHERE gid = ? AND resolved_at < ?")
+	if _, err := m.ExecContext(ctx, query, m.GID(), time.Now().Add(-m.ttl)); err != nil {
+		log.Printf("[WARN] failed to cleanup expired mention targets: %v", err)
+	}
+
+	query, err := mentionTargetsQueries.Pick(m.Type(), CmdWriteMentionTarget)
+	if err != nil {
+		return fmt.Errorf("failed to get write query: %w", err)
+	}
+	if _, err = m.ExecContext(ctx, query, m.GID(), target.Username, target.TargetID, target.TargetType,
+		target.ResolvedAt); err != nil {
+		return fmt.Errorf("failed to write mention target %q: %w", target.Username, err)
+	}
+	return nil
+}
+
+// Read returns the cached target of the username, false if not cached or expired
+func (m *MentionTargets) Read(ctx context.Context, username string) (MentionTarget, bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var res MentionTarget
+	query := m.Adopt(`SELECT username, target_id, target_type, resolved_at FROM mention_targets
+		WHERE gid = ? AND username = ? AND resolved_at >= ?`)
+	err := m.GetContext(ctx, &res, query, m.GID(), strings.ToLower(strings.TrimPrefix(username, "@")), time.Now().Add(-m.ttl))
+	if errors.Is(err, sql.ErrNoRows) {
+		return MentionTarget{}, false, nil
+	}
+	if err != nil {
+		return MentionTarget{}, false, fmt.Errorf("failed to read mention target %q: %w", username, err)
+	}
+	res.ResolvedAt = res.ResolvedAt.Local()
+	return res, true, nil
+}
+
+func (m *MentionTargets) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
+	// no migrations yet
+	return nil
+}
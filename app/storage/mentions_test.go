@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *StorageTestSuite) TestNewMentionTargets() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			mt, err := NewMentionTargets(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			s.Require().NotNil(mt)
+			defer db.Exec("DROP TABLE mention_targets")
+
+			_, err = NewMentionTargets(ctx, db, 0)
+			s.Require().Error(err)
+		})
+	}
+
+	_, err := NewMentionTargets(ctx, nil, time.Hour)
+	s.Require().Error(err)
+}
+
+func (s *StorageTestSuite) TestMentionTargets_WriteAndRead() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			mt, err := NewMentionTargets(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE mention_targets")
+
+			_, found, err := mt.Read(ctx, "promo")
+			s.Require().NoError(err)
+			s.False(found)
+
+			err = mt.Write(ctx, MentionTarget{Username: "@Promo", TargetID: -1001234567890, TargetType: "channel"})
+			s.Require().NoError(err)
+			res, found, err := mt.Read(ctx, "PROMO")
+			s.Require().NoError(err)
+			s.Require().True(found)
+			s.Equal("promo", res.Username)
+			s.Equal(int64(-1001234567890), res.TargetID)
+			s.Equal("channel", res.TargetType)
+			s.WithinDuration(time.Now(), res.ResolvedAt, time.Minute)
+
+			// replace the target
+			err = mt.Write(ctx, MentionTarget{Username: "promo", TargetType: "bot"})
+			s.Require().NoError(err)
+			res, found, err = mt.Read(ctx, "promo")
+			s.Require().NoError(err)
+			s.Require().True(found)
+			s.Equal("bot", res.TargetType)
+			s.Equal(int64(0), res.TargetID)
+
+			// expired target is not returned and removed on the next write
+			err = mt.Write(ctx, MentionTarget{Username: "old", TargetType: "private", ResolvedAt: time.Now().Add(-2 * time.Hour)})
+			s.Require().NoError(err)
+			_, found, err = mt.Read(ctx, "old")
+			s.Require().NoError(err)
+			s.False(found)
+			s.Require().NoError(mt.Write(ctx, MentionTarget{Username: "another"}))
+			var count int
+			s.Require().NoError(db.Get(&count, db.Adopt("SELECT COUNT(*) FROM mention_targets WHERE username = ?"), "old"))
+			s.Equal(0, count)
+
+			err = mt.Write(ctx, MentionTarget{Username: "@"})
+			s.Require().Error(err)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
+)
+
+// Profiles is a storage for named detector profiles. Profiles are assigned to groups
+// and override the default detector settings of the group.
+type Profiles struct {
+	*engine.SQL
+	engine.RWLocker
+}
+
+// Profile is a named set of detector settings. Only set (non-nil) fields override defaults.
+type Profile struct {
+	Name                string          `json:"name"`
+	SimilarityThreshold *float64        `json:"similarity_threshold,omitempty"`
+	MinSpamProbability  *float64        `json:"min_spam_probability,omitempty"`
+	FirstMessagesCount  *int            `json:"first_messages_count,omitempty"`
+	MinMsgLen           *int            `json:"min_msg_len,omitempty"`
+	MaxEmoji            *int            `json:"max_emoji,omitempty"`
+	OpenAIVeto          *bool           `json:"openai_veto,omitempty"`
+	Meta                ProfileMeta     `json:"meta"`
+	Messages            ProfileMessages `json:"messages"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+// ProfileMeta defines meta checks of the profile
+type ProfileMeta struct {
+	LinksLimit      *int      `json:"links_limit,omitempty"`
+	MentionsLimit   *int      `json:"mentions_limit,omitempty"`
+	ImageOnly       *bool     `json:"image_only,omitempty"`
+	LinksOnly       *bool     `json:"links_only,omitempty"`
+	VideosOnly      *bool     `json:"videos_only,omitempty"`
+	AudiosOnly      *bool     `json:"audios_only,omitempty"`
+	Forward         *bool     `json:"forward,omitempty"`
+	Keyboard        *bool     `json:"keyboard,omitempty"`
+	UsernameSymbols *string   `json:"username_symbols,omitempty"`
+	Documents       *[]string `json:"documents,omitempty"`
+	Contact         *bool     `json:"contact,omitempty"`
+	ViaBot          *bool     `json:"via_bot,omitempty"`
+	ViaBotAllowed   *[]string `json:"via_bot_allowed,omitempty"`
+}
+
+// ProfileMessages defines messages sent by the bot in the group of the profile
+type ProfileMessages struct {
+	Spam *string `json:"spam,omitempty"`
+	Dry  *string `json:"dry,omitempty"`
+	Warn *string `json:"warn,omitempty"`
+}
+
+// profileRecord is a profile as stored in the database, settings kept as json
+type profileRecord struct {
+	GID       string    `db:"gid"`
+	Name      string    `db:"name"`
+	Data      string    `db:"data"`
+	Timestamp time.Time `db:"timestamp"`
+}
+
+// profiles query commands
+const (
+	CmdCreateProfilesTable engine.DBCmd = iota + 700
+	CmdCreateProfilesIndexes
+	CmdWriteProfile
+)
+
+// profilesQueries holds all profiles queries
+var profilesQueries = engine.NewQueryMap().
+	Add(CmdCreateProfilesTable, engine.Query{
+		Sqlite: `CREATE TABLE IF NOT EXISTS profiles (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            gid TEXT NOT NULL DEFAULT '',
+            name TEXT NOT NULL,
+            data TEXT NOT NULL,
+            timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, name)
+        )`,
+		Postgres: `CREATE TABLE IF NOT EXISTS profiles (
+            id SERIAL PRIMARY KEY,
+            gid TEXT NOT NULL DEFAULT '',
+            name TEXT NOT NULL,
+            data TEXT NOT NULL,
+            timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, name)
+        )`,
+	}).
+	AddSame(CmdCreateProfilesIndexes, `CREATE INDEX IF NOT EXISTS idx_profiles_gid ON profiles(gid)`).
+	Add(CmdWriteProfile, engine.Query{
+		Sqlite: "INSERT OR REPLACE INTO profiles (gid, name, data, timestamp) VALUES (?, ?, ?, ?)",
+		Postgres: "INSERT INTO profiles (gid, name, data, timestamp) VALUES ($1, $2, $3, $4) " +
+			"ON CONFLICT (gid, name) DO UPDATE SET data=EXCLUDED.data, timestamp=EXCLUDED.timestamp",
+	})
+
+var profileNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// NewProfiles creates a new Profiles storage
+func NewProfiles(ctx context.Context, db *engine.SQL) (*Profiles, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db connection is nil")
+	}
+	res := &Profiles{SQL: db, RWLocker: db.MakeLock()}
+	cfg := engine.TableConfig{
+		Name:          "profiles",
+		CreateTable:   CmdCreateProfilesTable,
+		CreateIndexes: CmdCreateProfilesIndexes,
+		MigrateFunc:   res.migrate,
+		QueriesMap:    profilesQueries,
+	}
+	if err := engine.InitTable(ctx, db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to init profiles storage: %w", err)
+	}
+	return res, nil
+}
+
+// Write adds a new profile or replaces the existing one with the same name
+func (p *Profiles) Write(ctx context.Context, profile Profile) error {
+	if !profileNameRe.MatchString(profile.Name) {
+		return fmt.Errorf("invalid profile name %q, only letters, digits, '-' and '_' allowed", profile.Name)
+	}
+	profile.UpdatedAt = time.Now()
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile %q: %w", profile.Name, err)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	query, err := profilesQueries.Pick(p.Type(), CmdWriteProfile)
+	if err != nil {
+		return fmt.Errorf("failed to get write query: %w", err)
+	}
+	if _, err = p.ExecContext(ctx, query, p.GID(), profile.Name, string(data), profile.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to write profile %q: %w", profile.Name, err)
+	}
+	log.Printf("[INFO] profile %q saved", profile.Name)
+	return nil
+}
+
+// Read returns the profile by name
+func (p *Profiles) Read(ctx context.Context, name string) (*Profile, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	var rec profileRecord
+	query := p.Adopt("SELECT gid, name, data, timestamp FROM profiles WHERE gid = ? AND name = ?")
+	if err := p.GetContext(ctx, &rec, query, p.GID(), name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("profile %q not found", name)
+		}
+		return nil, fmt.Errorf("failed to read profile %q: %w", name, err)
+	}
+	return rec.profile()
+}
+
+// List returns all profiles sorted by name
+func (p *Profiles) List(ctx context.Context) ([]Profile, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	recs := []profileRecord{}
+	query := p.Adopt("SELECT gid, name, data, timestamp FROM profiles WHERE gid = ? ORDER BY name ASC")
+	if err := p.SelectContext(ctx, &recs, query, p.GID()); err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	res := make([]Profile, 0, len(recs))
+	for _, rec := range recs {
+		profile, err := rec.profile()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *profile)
+	}
+	return res, nil
+}
+
+// Delete removes the profile by name
+func (p *Profiles) Delete(ctx context.Context, name string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	query := p.Adopt("DELETE FROM profiles WHERE gid = ? AND name = ?")
+	res, err := p.ExecContext(ctx, query, p.GID(), name)
+	if err != nil {
+		return fmt.Errorf("failed to delete profile %q: %w", name, err)
+	}
+	if n, raErr := res.RowsAffected(); raErr == nil && n == 0 {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	log.Printf("[INFO] profile %q deleted", name)
+	return nil
+}
+
+// profile unmarshals the stored profile settings
+func (r profileRecord) profile() (*Profile, error) {
+	res := Profile{}
+	if err := json.Unmarshal([]byte(r.Data), &res); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profile %q: %w", r.Name, err)
+	}
+	res.Name, res.UpdatedAt = r.Name, r.Timestamp
+	return &res, nil
+}
+
+func (p *Profiles) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
+	// no migrations yet
+	return nil
+}
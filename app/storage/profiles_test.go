@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+func (s *StorageTestSuite) TestNewProfiles() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			p, err := NewProfiles(ctx, db)
+			s.Require().NoError(err)
+			s.Require().NotNil(p)
+			defer db.Exec("DROP TABLE profiles")
+
+			// second init is fine, table already exists
+			_, err = NewProfiles(ctx, db)
+			s.Require().NoError(err)
+		})
+	}
+
+	_, err := NewProfiles(ctx, nil)
+	s.Require().Error(err)
+}
+
+func (s *StorageTestSuite) TestProfiles_WriteAndRead() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			p, err := NewProfiles(ctx, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE profiles")
+
+			threshold, count, veto, warn := 0.7, 3, true, "be nice"
+			err = p.Write(ctx, Profile{Name: "strict", SimilarityThreshold: &threshold, FirstMessagesCount: &count,
+				OpenAIVeto: &veto, Meta: ProfileMeta{LinksLimit: &count}, Messages: ProfileMessages{Warn: &warn}})
+			s.Require().NoError(err)
+
+			res, err := p.Read(ctx, "strict")
+			s.Require().NoError(err)
+			s.Equal("strict", res.Name)
+			s.Require().NotNil(res.SimilarityThreshold)
+			s.InDelta(0.7, *res.SimilarityThreshold, 0.0001)
+			s.Equal(3, *res.FirstMessagesCount)
+			s.True(*res.OpenAIVeto)
+			s.Equal(3, *res.Meta.LinksLimit)
+			s.Equal("be nice", *res.Messages.Warn)
+			s.Nil(res.MinSpamProbability, "not set fields are nil")
+			s.Nil(res.Meta.ImageOnly)
+			s.Nil(res.Messages.Spam)
+			s.False(res.UpdatedAt.IsZero())
+
+			// overwrite existing profile
+			err = p.Write(ctx, Profile{Name: "strict", MinMsgLen: &count})
+			s.Require().NoError(err)
+			res, err = p.Read(ctx, "strict")
+			s.Require().NoError(err)
+			s.Nil(res.SimilarityThreshold)
+			s.Equal(3, *res.MinMsgLen)
+
+			_, err = p.Read(ctx, "unknown")
+			s.Require().EqualError(err, `profile "unknown" not found`)
+
+			s.Require().Error(p.Write(ctx, Profile{}), "empty name")
+			s.Require().Error(p.Write(ctx, Profile{Name: "bad:name"}))
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestProfiles_ListAndDelete() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			p, err := NewProfiles(ctx, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE profiles")
+
+			res, err := p.List(ctx)
+			s.Require().NoError(err)
+			s.Empty(res)
+
+			s.Require().NoError(p.Write(ctx, Profile{Name: "relaxed"}))
+			s.Require().NoError(p.Write(ctx, Profile{Name: "default"}))
+			s.Require().NoError(p.Write(ctx, Profile{Name: "strict"}))
+
+			// profiles of other groups are not visible
+			other, err := NewProfiles(ctx, db.WithGID("other"))
+			s.Require().NoError(err)
+			s.Require().NoError(other.Write(ctx, Profile{Name: "another"}))
+
+			res, err = p.List(ctx)
+			s.Require().NoError(err)
+			s.Require().Len(res, 3)
+			s.Equal("default", res[0].Name)
+			s.Equal("relaxed", res[1].Name)
+			s.Equal("strict", res[2].Name)
+
+			s.Require().NoError(p.Delete(ctx, "relaxed"))
+			s.Require().EqualError(p.Delete(ctx, "relaxed"), `profile "relaxed" not found`)
+			res, err = p.List(ctx)
+			s.Require().NoError(err)
+			s.Require().Len(res, 2)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
+)
+
+// Restrictions is a storage for temporary restrictions set by the bot, e.g. new members restricted until
+// the join challenge is solved or chats in lockdown. Restrictions are kept to be lifted after restart,
+// as timers lifting them are lost.
+type Restrictions struct {
+	*engine.SQL
+	engine.RWLocker
+}
+
+// RestrictionKind is a kind of temporary restriction
+type RestrictionKind string
+
+// enum of restriction kinds
+const (
+	RestrictionChallenge RestrictionKind = "challenge" // new member restricted until the join challenge is solved
+	RestrictionLockdown  RestrictionKind = "lockdown"  // chat in lockdown after raid detected
+)
+
+// Restriction is a temporary restriction of the user or the whole chat
+type Restriction struct {
+	Kind      RestrictionKind `db:"kind"`
+	ChatID    int64           `db:"chat_id"`
+	UserID    int64           `db:"user_id"`    // restricted user, 0 for restriction of the whole chat
+	Data      string          `db:"data"`       // state needed to lift the restriction, json
+	ExpiresAt time.Time       `db:"expires_at"` // time to lift the restriction
+}
+
+// restrictions query commands
+const (
+	CmdCreateRestrictionsTable engine.DBCmd = iota + 1000
+	CmdCreateRestrictionsIndexes
+	CmdWriteRestriction
+)
+
+// restrictionsQueries holds all restrictions queries
+var restrictionsQueries = engine.NewQueryMap().
+	Add(CmdCreateRestrictionsTable, engine.Query{
+		Sqlite: `CREATE TABLE IF NOT EXISTS restrictions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            gid TEXT NOT NULL DEFAULT '',
+            kind TEXT NOT NULL,
+            chat_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL DEFAULT 0,
+            data TEXT NOT NULL DEFAULT '',
+            expires_at DATETIME NOT NULL,
+            UNIQUE(gid, kind, chat_id, user_id)
+        )`,
+		Postgres: `CREATE TABLE IF NOT EXISTS restrictions (
+            id SERIAL PRIMARY KEY,
+            gid TEXT NOT NULL DEFAULT '',
+            kind TEXT NOT NULL,
+            chat_id BIGINT NOT NULL,
+            user_id BIGINT NOT NULL DEFAULT 0,
+            data TEXT NOT NULL DEFAULT '',
+            expires_at TIMESTAMP NOT NULL,
+            UNIQUE(gid, kind, chat_id, user_id)
+        )`,
+	}).
+	AddSame(CmdCreateRestrictionsIndexes, `CREATE INDEX IF NOT EXISTS idx_restrictions_gid_kind ON restrictions(gid, kind)`).
+	Add(CmdWriteRestriction, engine.Query{
+		Sqlite: "INSERT OR REPLACE INTO restrictions (gid, kind, chat_id, user_id, data, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		Postgres: "INSERT INTO restrictions (gid, kind, chat_id, user_id, data, expires_at) VALUES ($1, $2, $3, $4, $5, $6) " +
+			"ON CONFLICT (gid, kind, chat_id, user_id) DO UPDATE SET data=EXCLUDED.data, expires_at=EXCLUDED.expires_at",
+	})
+
+// NewRestrictions creates a new Restrictions storage
+func NewRestrictions(ctx context.Context, db *engine.SQL) (*Restrictions, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db connection is nil")
+	}
+	res := &Restrictions{SQL: db, RWLocker: db.MakeLock()}
+	cfg := engine.TableConfig{
+		Name:          "restrictions",
+		CreateTable:   CmdCreateRestrictionsTable,
+		CreateIndexes: CmdCreateRestrictionsIndexes,
+		MigrateFunc:   res.migrate,
+		QueriesMap:    restrictionsQueries,
+	}
+	if err := engine.InitTable(ctx, db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to init restrictions storage: %w", err)
+	}
+	return res, nil
+}
+
+// Write adds or replaces the restriction of the user, or of the whole chat if user id is 0
+func (r *Restrictions) Write(ctx context.Context, rs Restriction) error {
+	if rs.Kind == "" || rs.ChatID == 0 {
+		return fmt.Errorf("kind and chat id can't be empty")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	query, err := restrictionsQueries.Pick(r.Type(), CmdWriteRestriction)
+	if err != nil {
+		return fmt.Errorf("failed to get write query: %w", err)
+	}
+	if _, err := r.ExecContext(ctx, query, r.GID(), rs.Kind, rs.ChatID, rs.UserID, rs.Data, rs.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to write %s restriction of %d in %d: %w", rs.Kind, rs.UserID, rs.ChatID, err)
+	}
+	return nil
+}
+
+// Delete removes the restriction, no error if it doesn't exist
+func (r *Restrictions) Delete(ctx context.Context, kind RestrictionKind, chatID, userID int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	query := r.Adopt("DELETE FROM restrictions WHERE gid = ? AND kind = ? AND chat_id = ? AND user_id = ?")
+	if _, err := r.ExecContext(ctx, query, r.GID(), kind, chatID, userID); err != nil {
+		return fmt.Errorf("failed to delete %s restriction of %d in %d: %w", kind, userID, chatID, err)
+	}
+	return nil
+}
+
+// List returns all restrictions of the kind, including expired ones, as they are still to be lifted
+func (r *Restrictions) List(ctx context.Context, kind RestrictionKind) ([]Restriction, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	res := []Restriction{}
+	query := r.Adopt("SELECT kind, chat_id, user_id, data, expires_at FROM restrictions WHERE gid = ? AND kind = ? ORDER BY expires_at")
+	if err := r.SelectContext(ctx, &res, query, r.GID(), kind); err != nil {
+		return nil, fmt.Errorf("failed to list %s restrictions: %w", kind, err)
+	}
+	for i := range res {
+		res[i].ExpiresAt = res[i].ExpiresAt.Local()
+	}
+	return res, nil
+}
+
+func (r *Restrictions) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
+	// no migrations yet
+	return nil
+}
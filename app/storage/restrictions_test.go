@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *StorageTestSuite) TestNewRestrictions() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			r, err := NewRestrictions(ctx, db)
+			s.Require().NoError(err)
+			s.Require().NotNil(r)
+			defer db.Exec("DROP TABLE restrictions")
+		})
+	}
+
+	_, err := NewRestrictions(ctx, nil)
+	s.Require().Error(err)
+}
+
+func (s *StorageTestSuite) TestRestrictions_WriteListDelete() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			r, err := NewRestrictions(ctx, db)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE restrictions")
+
+			res, err := r.List(ctx, RestrictionChallenge)
+			s.Require().NoError(err)
+			s.Empty(res)
+
+			ts := time.Now().Truncate(time.Second)
+			s.Require().NoError(r.Write(ctx, Restriction{Kind: RestrictionChallenge, ChatID: 100, UserID: 1,
+				Data: `{"answer": 5}`, ExpiresAt: ts.Add(time.Minute)}))
+			s.Require().NoError(r.Write(ctx, Restriction{Kind: RestrictionChallenge, ChatID: 100, UserID: 2,
+				ExpiresAt: ts.Add(-time.Minute)}))
+			s.Require().NoError(r.Write(ctx, Restriction{Kind: "other", ChatID: 100, ExpiresAt: ts}))
+
+			// replaced by the same kind, chat and user
+			s.Require().NoError(r.Write(ctx, Restriction{Kind: RestrictionChallenge, ChatID: 100, UserID: 1,
+				Data: `{"answer": 7}`, ExpiresAt: ts.Add(2 * time.Minute)}))
+
+			res, err = r.List(ctx, RestrictionChallenge)
+			s.Require().NoError(err)
+			s.Require().Len(res, 2)
+			s.Equal(int64(2), res[0].UserID, "expired restriction listed first")
+			s.Equal(int64(1), res[1].UserID)
+			s.Equal(int64(100), res[1].ChatID)
+			s.Equal(`{"answer": 7}`, res[1].Data)
+			s.WithinDuration(ts.Add(2*time.Minute), res[1].ExpiresAt, time.Second)
+
+			s.Require().NoError(r.Delete(ctx, RestrictionChallenge, 100, 1))
+			s.Require().NoError(r.Delete(ctx, RestrictionChallenge, 100, 1), "no error for missing restriction")
+			res, err = r.List(ctx, RestrictionChallenge)
+			s.Require().NoError(err)
+			s.Require().Len(res, 1)
+			s.Equal(int64(2), res[0].UserID)
+
+			res, err = r.List(ctx, "other")
+			s.Require().NoError(err)
+			s.Require().Len(res, 1)
+			s.Equal(int64(0), res[0].UserID)
+
+			s.Require().Error(r.Write(ctx, Restriction{Kind: RestrictionChallenge}))
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
+)
+
+// Strikes is a storage for user strikes (warnings). Strikes expire after ttl period and
+// the number of active strikes defines the escalation step for the user.
+type Strikes struct {
+	*engine.SQL
+	engine.RWLocker
+	ttl time.Duration
+
+	// users with strikes not applied yet, kept in memory to skip the query for each message of other users
+	pendingLock  sync.Mutex
+	pendingUsers map[int64]struct{}
+}
+
+// StrikeInfo represents a single strike issued to a user
+type StrikeInfo struct {
+	ID        int64     `db:"id"`
+	GID       string    `db:"gid"`
+	UserID    int64     `db:"user_id"`
+	UserName  string    `db:"user_name"`
+	Source    string    `db:"source"`    // who issued the strike, e.g. "admin", "detector" or "web"
+	Reason    string    `db:"reason"`    // reason of the strike, e.g. "warn by admin" or detection results
+	Applied   bool      `db:"applied"`   // escalation action for this strike was applied
+	Timestamp time.Time `db:"timestamp"` // time of the strike
+}
+
+// strikes query commands
+const (
+	CmdCreateStrikesTable engine.DBCmd = iota + 600
+	CmdCreateStrikesIndexes
+)
+
+// strikesQueries holds all strikes queries
+var strikesQueries = engine.NewQueryMap().
+	Add(CmdCreateStrikesTable, engine.Query{
+		Sqlite: `CREATE TABLE IF NOT EXISTS strikes (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            gid TEXT NOT NULL DEFAULT '',
+            user_id INTEGER,
+            user_name TEXT,
+            source TEXT,
+            reason TEXT,
+            applied BOOLEAN DEFAULT 0,
+            timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		Postgres: `CREATE TABLE IF NOT EXISTS strikes (
+            id SERIAL PRIMARY KEY,
+            gid TEXT NOT NULL DEFAULT '',
+            user_id BIGINT,
+            user_name TEXT,
+            source TEXT,
+            reason TEXT,
+            applied BOOLEAN DEFAULT false,
+            timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+	}).
+	AddSame(CmdCreateStrikesIndexes, `
+        CREATE INDEX IF NOT EXISTS idx_strikes_gid_user_id ON strikes(gid, user_id);
+        CREATE INDEX IF NOT EXISTS idx_strikes_timestamp ON strikes(timestamp)`,
+	)
+
+// NewStrikes creates a new Strikes storage. ttl defines how long strikes stay active, 0 means forever
+func NewStrikes(ctx context.Context, db *engine.SQL, ttl time.Duration) (*Strikes, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db connection is nil")
+	}
+	res := &Strikes{SQL: db, RWLocker: db.MakeLock(), ttl: ttl, pendingUsers: map[int64]struct{}{}}
+	cfg := engine.TableConfig{
+		Name:          "strikes",
+		CreateTable:   CmdCreateStrikesTable,
+		CreateIndexes: CmdCreateStrikesIndexes,
+		MigrateFunc:   res.migrate,
+		QueriesMap:    strikesQueries,
+	}
+	if err := engine.InitTable(ctx, db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to init strikes storage: %w", err)
+	}
+
+	// strikes added but not applied before restart are still pending
+	users := []int64{}
+	query := res.Adopt("SELECT DISTINCT user_id FROM strikes WHERE gid = ? AND NOT applied AND timestamp >= ?")
+	if err := res.SelectContext(ctx, &users, query, res.GID(), res.since()); err != nil {
+		return nil, fmt.Errorf("failed to read pending strikes: %w", err)
+	}
+	for _, u := range users {
+		res.pendingUsers[u] = struct{}{}
+	}
+	return res, nil
+}
+
+// Add adds a strike for the user and returns the number of active strikes, including the new one.
+// Expired strikes are removed.
+func (s *Strikes) Add(ctx context.Context, strike StrikeInfo) (int, error) {
+	if strike.UserID == 0 {
+		return 0, fmt.Errorf("user id can't be empty")
+	}
+	if strike.Timestamp.IsZero() {
+		strike.Timestamp = time.Now()
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.cleanup(ctx); err != nil {
+		log.Printf("[WARN] failed to cleanup expired strikes: %v", err)
+	}
+
+	query := s.Adopt("INSERT INTO strikes (gid, user_id, user_name, source, reason, applied, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if _, err := s.ExecContext(ctx, query, s.GID(), strike.UserID, strike.UserName, strike.Source, strike.Reason,
+		strike.Applied, strike.Timestamp); err != nil {
+		return 0, fmt.Errorf("failed to add strike for user %d: %w", strike.UserID, err)
+	}
+	if !strike.Applied {
+		s.setPending(strike.UserID, true)
+	}
+
+	count, err := s.count(ctx, strike.UserID)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("[INFO] strike added for %q (%d) by %s, active strikes: %d", strike.UserName, strike.UserID, strike.Source, count)
+	return count, nil
+}
+
+// Count returns the number of active strikes for the user
+func (s *Strikes) Count(ctx context.Context, userID int64) (int, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.count(ctx, userID)
+}
+
+// Pending returns the number of active strikes and reports if some of them are not applied yet,
+// i.e. added outside the listener, by web UI. Users without pending strikes are not queried, the count is 0 for them.
+func (s *Strikes) Pending(ctx context.Context, userID int64) (count int, pending bool, err error) {
+	s.pendingLock.Lock()
+	_, ok := s.pendingUsers[userID]
+	s.pendingLock.Unlock()
+	if !ok {
+		return 0, false, nil
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var res struct {
+		Total   int `db:"total"`
+		Pending int `db:"pending"`
+	}
+	query := s.Adopt(`SELECT COUNT(*) AS total, COALESCE(SUM(CASE WHEN applied THEN 0 ELSE 1 END), 0) AS pending
+		FROM strikes WHERE gid = ? AND user_id = ? AND timestamp >= ?`)
+	if err := s.GetContext(ctx, &res, query, s.GID(), userID, s.since()); err != nil {
+		return 0, false, fmt.Errorf("failed to get pending strikes for user %d: %w", userID, err)
+	}
+	return res.Total, res.Pending > 0, nil
+}
+
+// MarkApplied marks all active strikes of the user as applied
+func (s *Strikes) MarkApplied(ctx context.Context, userID int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	query := s.Adopt("UPDATE strikes SET applied = ? WHERE gid = ? AND user_id = ?")
+	if _, err := s.ExecContext(ctx, query, true, s.GID(), userID); err != nil {
+		return fmt.Errorf("failed to mark strikes applied for user %d: %w", userID, err)
+	}
+	s.setPending(userID, false)
+	return nil
+}
+
+// Read returns active strikes of the user, the newest first
+func (s *Strikes) Read(ctx context.Context, userID int64) ([]StrikeInfo, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	query := s.Adopt("SELECT * FROM strikes WHERE gid = ? AND user_id = ? AND timestamp >= ? ORDER BY timestamp DESC, id DESC")
+	res := []StrikeInfo{}
+	if err := s.SelectContext(ctx, &res, query, s.GID(), userID, s.since()); err != nil {
+		return nil, fmt.Errorf("failed to read strikes for user %d: %w", userID, err)
+	}
+	for i := range res {
+		res[i].Timestamp = res[i].Timestamp.Local()
+	}
+	return res, nil
+}
+
+// Clear removes all strikes of the user, e.g. when the user is unbanned
+func (s *Strikes) Clear(ctx context.Context, userID int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	query := s.Adopt("DELETE FROM strikes WHERE gid = ? AND user_id = ?")
+	if _, err := s.ExecContext(ctx, query, s.GID(), userID); err != nil {
+		return fmt.Errorf("failed to clear strikes for user %d: %w", userID, err)
+	}
+	s.setPending(userID, false)
+	log.Printf("[INFO] strikes cleared for user %d", userID)
+	return nil
+}
+
+func (s *Strikes) count(ctx context.Context, userID int64) (int, error) {
+	var count int
+	query := s.Adopt("SELECT COUNT(*) FROM strikes WHERE gid = ? AND user_id = ? AND timestamp >= ?")
+	if err := s.GetContext(ctx, &count, query, s.GID(), userID, s.since()); err != nil {
+		return 0, fmt.Errorf("failed to count strikes for user %d: %w", userID, err)
+	}
+	return count, nil
+}
+
+// setPending sets or resets the flag of strikes not applied yet for the user
+func (s *Strikes) setPending(userID int64, pending bool) {
+	s.pendingLock.Lock()
+	defer s.pendingLock.Unlock()
+	if pending {
+		s.pendingUsers[userID] = struct{}{}
+		return
+	}
+	delete(s.pendingUsers, userID)
+}
+
+// cleanup removes expired strikes, not locked, caller should lock
+func (s *Strikes) cleanup(ctx context.Context) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+	query := s.Adopt("DELETE FROM strikes WHERE gid = ? AND timestamp < ?")
+	if _, err := s.ExecContext(ctx, query, s.GID(), s.since()); err != nil {
+		return fmt.Errorf("failed to delete expired strikes: %w", err)
+	}
+	return nil
+}
+
+// since returns the time of the oldest active strike
+func (s *Strikes) since() time.Time {
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-s.ttl)
+}
+
+func (s *Strikes) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
+	// no migrations yet
+	return nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *StorageTestSuite) TestNewStrikes() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			st, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			s.Require().NotNil(st)
+			defer db.Exec("DROP TABLE strikes")
+
+			// second init is fine, table already exists
+			_, err = NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+		})
+	}
+
+	_, err := NewStrikes(ctx, nil, time.Hour)
+	s.Require().Error(err)
+}
+
+func (s *StorageTestSuite) TestStrikes_AddAndRead() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			st, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE strikes")
+
+			count, err := st.Add(ctx, StrikeInfo{UserID: 123, UserName: "user1", Source: "admin", Reason: "warned by admin", Applied: true})
+			s.Require().NoError(err)
+			s.Equal(1, count)
+
+			count, err = st.Add(ctx, StrikeInfo{UserID: 123, UserName: "user1", Source: "web", Reason: "rude"})
+			s.Require().NoError(err)
+			s.Equal(2, count)
+
+			count, err = st.Add(ctx, StrikeInfo{UserID: 456, UserName: "user2", Source: "detector"})
+			s.Require().NoError(err)
+			s.Equal(1, count, "strikes counted per user")
+
+			_, err = st.Add(ctx, StrikeInfo{UserName: "no id"})
+			s.Require().Error(err)
+
+			count, err = st.Count(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(2, count)
+
+			res, err := st.Read(ctx, 123)
+			s.Require().NoError(err)
+			s.Require().Len(res, 2)
+			s.Equal("web", res[0].Source, "newest first")
+			s.Equal("rude", res[0].Reason)
+			s.False(res[0].Applied)
+			s.Equal("admin", res[1].Source)
+			s.True(res[1].Applied)
+			s.Equal("user1", res[1].UserName)
+			s.Equal(db.GID(), res[1].GID)
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestStrikes_PendingAndMarkApplied() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			st, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE strikes")
+
+			count, pending, err := st.Pending(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(0, count)
+			s.False(pending)
+
+			_, err = st.Add(ctx, StrikeInfo{UserID: 123, Source: "detector", Applied: true})
+			s.Require().NoError(err)
+			count, pending, err = st.Pending(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(0, count, "not queried without pending strikes")
+			s.False(pending)
+
+			_, err = st.Add(ctx, StrikeInfo{UserID: 123, Source: "web"})
+			s.Require().NoError(err)
+			count, pending, err = st.Pending(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(2, count)
+			s.True(pending)
+
+			// pending strikes are restored on restart
+			_, err = st.Add(ctx, StrikeInfo{UserID: 456, Source: "web"})
+			s.Require().NoError(err)
+			restarted, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			for _, id := range []int64{123, 456} {
+				_, pending, err = restarted.Pending(ctx, id)
+				s.Require().NoError(err)
+				s.True(pending, id)
+			}
+
+			s.Require().NoError(st.MarkApplied(ctx, 123))
+			count, pending, err = st.Pending(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(0, count)
+			s.False(pending)
+			var applied int
+			s.Require().NoError(db.Get(&applied, db.Adopt("SELECT COUNT(*) FROM strikes WHERE user_id = ? AND applied"), 123))
+			s.Equal(2, applied)
+
+			s.Require().NoError(st.Clear(ctx, 456))
+			_, pending, err = st.Pending(ctx, 456)
+			s.Require().NoError(err)
+			s.False(pending, "cleared strikes are not pending")
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestStrikes_Expiration() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			st, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE strikes")
+
+			_, err = st.Add(ctx, StrikeInfo{UserID: 123, Source: "admin", Timestamp: time.Now().Add(-2 * time.Hour)})
+			s.Require().NoError(err)
+			count, err := st.Add(ctx, StrikeInfo{UserID: 123, Source: "admin"})
+			s.Require().NoError(err)
+			s.Equal(1, count, "expired strike not counted")
+
+			count, pending, err := st.Pending(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(1, count)
+			s.True(pending)
+
+			var total int
+			s.Require().NoError(db.Get(&total, "SELECT COUNT(*) FROM strikes"))
+			s.Equal(1, total, "expired strike removed on the next add")
+
+			// no ttl, strikes never expire
+			st.ttl = 0
+			_, err = st.Add(ctx, StrikeInfo{UserID: 123, Source: "admin", Timestamp: time.Now().Add(-1000 * time.Hour)})
+			s.Require().NoError(err)
+			count, err = st.Count(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(2, count)
+		})
+	}
+}
+
+func (s *StorageTestSuite) TestStrikes_Clear() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			st, err := NewStrikes(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE strikes")
+
+			_, err = st.Add(ctx, StrikeInfo{UserID: 123, Source: "admin"})
+			s.Require().NoError(err)
+			_, err = st.Add(ctx, StrikeInfo{UserID: 456, Source: "admin"})
+			s.Require().NoError(err)
+
+			s.Require().NoError(st.Clear(ctx, 123))
+			count, err := st.Count(ctx, 123)
+			s.Require().NoError(err)
+			s.Equal(0, count)
+			count, err = st.Count(ctx, 456)
+			s.Require().NoError(err)
+			s.Equal(1, count, "other users not affected")
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/umputun/tg-spam/app/storage/engine"
+)
+
+// UserBios is a cache of user bios fetched from telegram. Bios expire after ttl period, so the updated bio
+// is fetched again and not requested for each message of the user.
+type UserBios struct {
+	*engine.SQL
+	engine.RWLocker
+	ttl time.Duration
+}
+
+// UserBio is a cached bio of the user
+type UserBio struct {
+	UserID    int64     `db:"user_id"`    // telegram user id
+	Bio       string    `db:"bio"`        // user bio, empty if not set
+	FetchedAt time.Time `db:"fetched_at"` // time of the fetch
+}
+
+// user bios query commands
+const (
+	CmdCreateUserBiosTable engine.DBCmd = iota + 900
+	CmdCreateUserBiosIndexes
+	CmdWriteUserBio
+)
+
+// userBiosQueries holds all user bios queries
+var userBiosQueries = engine.NewQueryMap().
+	Add(CmdCreateUserBiosTable, engine.Query{
+		Sqlite: `CREATE TABLE IF NOT EXISTS user_bios (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            gid TEXT NOT NULL DEFAULT '',
+            user_id INTEGER NOT NULL,
+            bio TEXT NOT NULL DEFAULT '',
+            fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, user_id)
+        )`,
+		Postgres: `CREATE TABLE IF NOT EXISTS user_bios (
+            id SERIAL PRIMARY KEY,
+            gid TEXT NOT NULL DEFAULT '',
+            user_id BIGINT NOT NULL,
+            bio TEXT NOT NULL DEFAULT '',
+            fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(gid, user_id)
+        )`,
+	}).
+	AddSame(CmdCreateUserBiosIndexes, `CREATE INDEX IF NOT EXISTS idx_user_bios_fetched_at ON user_bios(fetched_at)`).
+	Add(CmdWriteUserBio, engine.Query{
+		Sqlite: "INSERT OR REPLACE INTO user_bios (gid, user_id, bio, fetched_at) VALUES (?, ?, ?, ?)",
+		Postgres: "INSERT INTO user_bios (gid, user_id, bio, fetched_at) VALUES ($1, $2, $3, $4) " +
+			"ON CONFLICT (gid, user_id) DO UPDATE SET bio=EXCLUDED.bio, fetched_at=EXCLUDED.fetched_at",
+	})
+
+// NewUserBios creates a new UserBios storage. ttl defines how long fetched bios are cached
+func NewUserBios(ctx context.Context, db *engine.SQL, ttl time.Duration) (*UserBios, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db connection is nil")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %v", ttl)
+	}
+	res := &UserBios{SQL: db, RWLocker: db.MakeLock(), ttl: ttl}
+	cfg := engine.TableConfig{
+		Name:          "user_bios",
+		CreateTable:   CmdCreateUserBiosTable,
+		CreateIndexes: CmdCreateUserBiosIndexes,
+		MigrateFunc:   res.migrate,
+		QueriesMap:    userBiosQueries,
+	}
+	if err := engine.InitTable(ctx, db, cfg); err != nil {
+		return nil, fmt.Errorf("failed to init user bios storage: %w", err)
+	}
+	return res, nil
+}
+
+// Write adds or replaces the bio of the user. Expired bios are removed.
+func (u *UserBios) Write(ctx context.Context, bio UserBio) error {
+	if bio.UserID == 0 {
+		return fmt.Errorf("user id can't be empty")
+	}
+	if bio.FetchedAt.IsZero() {
+		bio.FetchedAt = time.Now()
+	}
+
+	u.Lock()
+	defer u.Unlock()
+
+	query := u.Adopt("DELETE FROM user_bios WHERE gid = ? AND fetched_at < ?")
+	if _, err := u.ExecContext(ctx, query, u.GID(), time.Now().Add(-u.ttl)); err != nil {
+		log.Printf("[WARN] failed to cleanup expired user bios: %v", err)
+	}
+
+	query, err := userBiosQueries.Pick(u.Type(), CmdWriteUserBio)
+	if err != nil {
+		return fmt.Errorf("failed to get write query: %w", err)
+	}
+	if _, err = u.ExecContext(ctx, query, u.GID(), bio.UserID, bio.Bio, bio.FetchedAt); err != nil {
+		return fmt.Errorf("failed to write bio of user %d: %w", bio.UserID, err)
+	}
+	return nil
+}
+
+// Read returns the cached bio of the user, false if not cached or expired
+func (u *UserBios) Read(ctx context.Context, userID int64) (UserBio, bool, error) {
+	u.RLock()
+	defer u.RUnlock()
+
+	var res UserBio
+	query := u.Adopt("SELECT user_id, bio, fetched_at FROM user_bios WHERE gid = ? AND user_id = ? AND fetched_at >= ?")
+	err := u.GetContext(ctx, &res, query, u.GID(), userID, time.Now().Add(-u.ttl))
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserBio{}, false, nil
+	}
+	if err != nil {
+		return UserBio{}, false, fmt.Errorf("failed to read bio of user %d: %w", userID, err)
+	}
+	res.FetchedAt = res.FetchedAt.Local()
+	return res, true, nil
+}
+
+func (u *UserBios) migrate(_ context.Context, _ *sqlx.Tx, _ string) error {
+	// no migrations yet
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *StorageTestSuite) TestNewUserBios() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			ub, err := NewUserBios(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			s.Require().NotNil(ub)
+			defer db.Exec("DROP TABLE user_bios")
+
+			_, err = NewUserBios(ctx, db, 0)
+			s.Require().Error(err)
+		})
+	}
+
+	_, err := NewUserBios(ctx, nil, time.Hour)
+	s.Require().Error(err)
+}
+
+func (s *StorageTestSuite) TestUserBios_WriteAndRead() {
+	ctx := context.Background()
+	for _, dbt := range s.getTestDB() {
+		db := dbt.DB
+		s.Run(fmt.Sprintf("with %s", db.Type()), func() {
+			ub, err := NewUserBios(ctx, db, time.Hour)
+			s.Require().NoError(err)
+			defer db.Exec("DROP TABLE user_bios")
+
+			_, found, err := ub.Read(ctx, 123)
+			s.Require().NoError(err)
+			s.False(found)
+
+			err = ub.Write(ctx, UserBio{UserID: 123, Bio: "earn 500$ daily, dm me"})
+			s.Require().NoError(err)
+			res, found, err := ub.Read(ctx, 123)
+			s.Require().NoError(err)
+			s.Require().True(found)
+			s.Equal(int64(123), res.UserID)
+			s.Equal("earn 500$ daily, dm me", res.Bio)
+			s.WithinDuration(time.Now(), res.FetchedAt, time.Minute)
+
+			// replace the bio, empty bio is cached too
+			err = ub.Write(ctx, UserBio{UserID: 123})
+			s.Require().NoError(err)
+			res, found, err = ub.Read(ctx, 123)
+			s.Require().NoError(err)
+			s.Require().True(found)
+			s.Empty(res.Bio)
+
+			// expired bio is not returned and removed on the next write
+			err = ub.Write(ctx, UserBio{UserID: 456, Bio: "old", FetchedAt: time.Now().Add(-2 * time.Hour)})
+			s.Require().NoError(err)
+			_, found, err = ub.Read(ctx, 456)
+			s.Require().NoError(err)
+			s.False(found)
+			s.Require().NoError(ub.Write(ctx, UserBio{UserID: 789}))
+			var count int
+			s.Require().NoError(db.Get(&count, db.Adopt("SELECT COUNT(*) FROM user_bios WHERE user_id = ?"), 456))
+			s.Equal(0, count)
+
+			err = ub.Write(ctx, UserBio{Bio: "no user"})
+			s.Require().Error(err)
+		})
+	}
+}
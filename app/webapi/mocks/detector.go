@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	"context"
 	"github.com/umputun/tg-spam/lib/approved"
 	"github.com/umputun/tg-spam/lib/spamcheck"
 	"sync"
@@ -21,8 +22,8 @@ import (
 //			ApprovedUsersFunc: func() []approved.UserInfo {
 //				panic("mock out the ApprovedUsers method")
 //			},
-//			CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
-//				panic("mock out the Check method")
+//			CheckContextFunc: func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+//				panic("mock out the CheckContext method")
 //			},
 //			RemoveApprovedUserFunc: func(id string) error {
 //				panic("mock out the RemoveApprovedUser method")
@@ -40,8 +41,8 @@ type DetectorMock struct {
 	// ApprovedUsersFunc mocks the ApprovedUsers method.
 	ApprovedUsersFunc func() []approved.UserInfo
 
-	// CheckFunc mocks the Check method.
-	CheckFunc func(req spamcheck.Request) (bool, []spamcheck.Response)
+	// CheckContextFunc mocks the CheckContext method.
+	CheckContextFunc func(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response)
 
 	// RemoveApprovedUserFunc mocks the RemoveApprovedUser method.
 	RemoveApprovedUserFunc func(id string) error
@@ -56,8 +57,10 @@ type DetectorMock struct {
 		// ApprovedUsers holds details about calls to the ApprovedUsers method.
 		ApprovedUsers []struct {
 		}
-		// Check holds details about calls to the Check method.
-		Check []struct {
+		// CheckContext holds details about calls to the CheckContext method.
+		CheckContext []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Req is the req argument value.
 			Req spamcheck.Request
 		}
@@ -69,7 +72,7 @@ type DetectorMock struct {
 	}
 	lockAddApprovedUser    sync.RWMutex
 	lockApprovedUsers      sync.RWMutex
-	lockCheck              sync.RWMutex
+	lockCheckContext       sync.RWMutex
 	lockRemoveApprovedUser sync.RWMutex
 }
 
@@ -146,43 +149,47 @@ func (mock *DetectorMock) ResetApprovedUsersCalls() {
 	mock.lockApprovedUsers.Unlock()
 }
 
-// Check calls CheckFunc.
-func (mock *DetectorMock) Check(req spamcheck.Request) (bool, []spamcheck.Response) {
-	if mock.CheckFunc == nil {
-		panic("DetectorMock.CheckFunc: method is nil but Detector.Check was just called")
+// CheckContext calls CheckContextFunc.
+func (mock *DetectorMock) CheckContext(ctx context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+	if mock.CheckContextFunc == nil {
+		panic("DetectorMock.CheckContextFunc: method is nil but Detector.CheckContext was just called")
 	}
 	callInfo := struct {
+		Ctx context.Context
 		Req spamcheck.Request
 	}{
+		Ctx: ctx,
 		Req: req,
 	}
-	mock.lockCheck.Lock()
-	mock.calls.Check = append(mock.calls.Check, callInfo)
-	mock.lockCheck.Unlock()
-	return mock.CheckFunc(req)
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = append(mock.calls.CheckContext, callInfo)
+	mock.lockCheckContext.Unlock()
+	return mock.CheckContextFunc(ctx, req)
 }
 
-// CheckCalls gets all the calls that were made to Check.
+// CheckContextCalls gets all the calls that were made to CheckContext.
 // Check the length with:
 //
-//	len(mockedDetector.CheckCalls())
-func (mock *DetectorMock) CheckCalls() []struct {
+//	len(mockedDetector.CheckContextCalls())
+func (mock *DetectorMock) CheckContextCalls() []struct {
+	Ctx context.Context
 	Req spamcheck.Request
 } {
 	var calls []struct {
+		Ctx context.Context
 		Req spamcheck.Request
 	}
-	mock.lockCheck.RLock()
-	calls = mock.calls.Check
-	mock.lockCheck.RUnlock()
+	mock.lockCheckContext.RLock()
+	calls = mock.calls.CheckContext
+	mock.lockCheckContext.RUnlock()
 	return calls
 }
 
-// ResetCheckCalls reset all the calls that were made to Check.
-func (mock *DetectorMock) ResetCheckCalls() {
-	mock.lockCheck.Lock()
-	mock.calls.Check = nil
-	mock.lockCheck.Unlock()
+// ResetCheckContextCalls reset all the calls that were made to CheckContext.
+func (mock *DetectorMock) ResetCheckContextCalls() {
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = nil
+	mock.lockCheckContext.Unlock()
 }
 
 // RemoveApprovedUser calls RemoveApprovedUserFunc.
@@ -234,9 +241,9 @@ func (mock *DetectorMock) ResetCalls() {
 	mock.calls.ApprovedUsers = nil
 	mock.lockApprovedUsers.Unlock()
 
-	mock.lockCheck.Lock()
-	mock.calls.Check = nil
-	mock.lockCheck.Unlock()
+	mock.lockCheckContext.Lock()
+	mock.calls.CheckContext = nil
+	mock.lockCheckContext.Unlock()
 
 	mock.lockRemoveApprovedUser.Lock()
 	mock.calls.RemoveApprovedUser = nil
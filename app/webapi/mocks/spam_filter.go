@@ -16,6 +16,9 @@ import (
 //			DynamicSamplesFunc: func() ([]string, []string, error) {
 //				panic("mock out the DynamicSamples method")
 //			},
+//			ReloadDomainsFunc: func() error {
+//				panic("mock out the ReloadDomains method")
+//			},
 //			ReloadSamplesFunc: func() error {
 //				panic("mock out the ReloadSamples method")
 //			},
@@ -41,6 +44,9 @@ type SpamFilterMock struct {
 	// DynamicSamplesFunc mocks the DynamicSamples method.
 	DynamicSamplesFunc func() ([]string, []string, error)
 
+	// ReloadDomainsFunc mocks the ReloadDomains method.
+	ReloadDomainsFunc func() error
+
 	// ReloadSamplesFunc mocks the ReloadSamples method.
 	ReloadSamplesFunc func() error
 
@@ -61,6 +67,9 @@ type SpamFilterMock struct {
 		// DynamicSamples holds details about calls to the DynamicSamples method.
 		DynamicSamples []struct {
 		}
+		// ReloadDomains holds details about calls to the ReloadDomains method.
+		ReloadDomains []struct {
+		}
 		// ReloadSamples holds details about calls to the ReloadSamples method.
 		ReloadSamples []struct {
 		}
@@ -86,6 +95,7 @@ type SpamFilterMock struct {
 		}
 	}
 	lockDynamicSamples          sync.RWMutex
+	lockReloadDomains           sync.RWMutex
 	lockReloadSamples           sync.RWMutex
 	lockRemoveDynamicHamSample  sync.RWMutex
 	lockRemoveDynamicSpamSample sync.RWMutex
@@ -127,6 +137,40 @@ func (mock *SpamFilterMock) ResetDynamicSamplesCalls() {
 	mock.lockDynamicSamples.Unlock()
 }
 
+// ReloadDomains calls ReloadDomainsFunc.
+func (mock *SpamFilterMock) ReloadDomains() error {
+	if mock.ReloadDomainsFunc == nil {
+		panic("SpamFilterMock.ReloadDomainsFunc: method is nil but SpamFilter.ReloadDomains was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = append(mock.calls.ReloadDomains, callInfo)
+	mock.lockReloadDomains.Unlock()
+	return mock.ReloadDomainsFunc()
+}
+
+// ReloadDomainsCalls gets all the calls that were made to ReloadDomains.
+// Check the length with:
+//
+//	len(mockedSpamFilter.ReloadDomainsCalls())
+func (mock *SpamFilterMock) ReloadDomainsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockReloadDomains.RLock()
+	calls = mock.calls.ReloadDomains
+	mock.lockReloadDomains.RUnlock()
+	return calls
+}
+
+// ResetReloadDomainsCalls reset all the calls that were made to ReloadDomains.
+func (mock *SpamFilterMock) ResetReloadDomainsCalls() {
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = nil
+	mock.lockReloadDomains.Unlock()
+}
+
 // ReloadSamples calls ReloadSamplesFunc.
 func (mock *SpamFilterMock) ReloadSamples() error {
 	if mock.ReloadSamplesFunc == nil {
@@ -323,6 +367,10 @@ func (mock *SpamFilterMock) ResetCalls() {
 	mock.calls.DynamicSamples = nil
 	mock.lockDynamicSamples.Unlock()
 
+	mock.lockReloadDomains.Lock()
+	mock.calls.ReloadDomains = nil
+	mock.lockReloadDomains.Unlock()
+
 	mock.lockReloadSamples.Lock()
 	mock.calls.ReloadSamples = nil
 	mock.lockReloadSamples.Unlock()
@@ -17,6 +17,7 @@ import (
 	"math/big"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +39,9 @@ import (
 //go:generate moq --out mocks/locator.go --pkg mocks --with-resets --skip-ensure . Locator
 //go:generate moq --out mocks/detected_spam.go --pkg mocks --with-resets --skip-ensure . DetectedSpam
 //go:generate moq --out mocks/storage_engine.go --pkg mocks --with-resets --skip-ensure . StorageEngine
+//go:generate moq --out mocks/strikes.go --pkg mocks --with-resets --skip-ensure . Strikes
+//go:generate moq --out mocks/profiles.go --pkg mocks --with-resets --skip-ensure . Profiles
+//go:generate moq --out mocks/dictionary.go --pkg mocks --with-resets --skip-ensure . Dictionary
 
 //go:embed assets/* assets/components/*
 var templateFS embed.FS
@@ -60,6 +64,9 @@ type Config struct {
 	DetectedSpam  DetectedSpam  // detected spam accessor
 	Locator       Locator       // locator for user info
 	StorageEngine StorageEngine // database engine access for backups
+	Strikes       Strikes       // user strikes storage, optional
+	Profiles      Profiles      // detector profiles storage, optional
+	Dictionary    Dictionary    // dictionary storage for denied and allowed domains, optional
 	AuthPasswd    string        // basic auth password for user "tg-spam"
 	AuthHash      string        // basic auth hash for user "tg-spam". If both AuthPasswd and AuthHash are provided, AuthHash is used
 	Dbg           bool          // debug mode
@@ -68,52 +75,57 @@ type Config struct {
 
 // Settings contains all application settings
 type Settings struct {
-	InstanceID              string        `json:"instance_id"`
-	PrimaryGroup            string        `json:"primary_group"`
-	AdminGroup              string        `json:"admin_group"`
-	DisableAdminSpamForward bool          `json:"disable_admin_spam_forward"`
-	LoggerEnabled           bool          `json:"logger_enabled"`
-	SuperUsers              []string      `json:"super_users"`
-	NoSpamReply             bool          `json:"no_spam_reply"`
-	CasEnabled              bool          `json:"cas_enabled"`
-	MetaEnabled             bool          `json:"meta_enabled"`
-	MetaLinksLimit          int           `json:"meta_links_limit"`
-	MetaMentionsLimit       int           `json:"meta_mentions_limit"`
-	MetaLinksOnly           bool          `json:"meta_links_only"`
-	MetaImageOnly           bool          `json:"meta_image_only"`
-	MetaVideoOnly           bool          `json:"meta_video_only"`
-	MetaAudioOnly           bool          `json:"meta_audio_only"`
-	MetaForwarded           bool          `json:"meta_forwarded"`
-	MetaKeyboard            bool          `json:"meta_keyboard"`
-	MetaUsernameSymbols     string        `json:"meta_username_symbols"`
-	MultiLangLimit          int           `json:"multi_lang_limit"`
-	OpenAIEnabled           bool          `json:"openai_enabled"`
-	SamplesDataPath         string        `json:"samples_data_path"`
-	DynamicDataPath         string        `json:"dynamic_data_path"`
-	WatchIntervalSecs       int           `json:"watch_interval_secs"`
-	SimilarityThreshold     float64       `json:"similarity_threshold"`
-	MinMsgLen               int           `json:"min_msg_len"`
-	MaxEmoji                int           `json:"max_emoji"`
-	MinSpamProbability      float64       `json:"min_spam_probability"`
-	ParanoidMode            bool          `json:"paranoid_mode"`
-	FirstMessagesCount      int           `json:"first_messages_count"`
-	StartupMessageEnabled   bool          `json:"startup_message_enabled"`
-	TrainingEnabled         bool          `json:"training_enabled"`
-	StorageTimeout          time.Duration `json:"storage_timeout"`
-	OpenAIVeto              bool          `json:"openai_veto"`
-	OpenAIHistorySize       int           `json:"openai_history_size"`
-	OpenAIModel             string        `json:"openai_model"`
-	SoftBanEnabled          bool          `json:"soft_ban_enabled"`
-	AbnormalSpacingEnabled  bool          `json:"abnormal_spacing_enabled"`
-	HistorySize             int           `json:"history_size"`
-	DebugModeEnabled        bool          `json:"debug_mode_enabled"`
-	DryModeEnabled          bool          `json:"dry_mode_enabled"`
-	TGDebugModeEnabled      bool          `json:"tg_debug_mode_enabled"`
+	InstanceID              string            `json:"instance_id"`
+	PrimaryGroup            string            `json:"primary_group"`
+	AdminGroup              string            `json:"admin_group"`
+	DisableAdminSpamForward bool              `json:"disable_admin_spam_forward"`
+	LoggerEnabled           bool              `json:"logger_enabled"`
+	SuperUsers              []string          `json:"super_users"`
+	NoSpamReply             bool              `json:"no_spam_reply"`
+	CasEnabled              bool              `json:"cas_enabled"`
+	MetaEnabled             bool              `json:"meta_enabled"`
+	MetaLinksLimit          int               `json:"meta_links_limit"`
+	MetaMentionsLimit       int               `json:"meta_mentions_limit"`
+	MetaLinksOnly           bool              `json:"meta_links_only"`
+	MetaImageOnly           bool              `json:"meta_image_only"`
+	MetaVideoOnly           bool              `json:"meta_video_only"`
+	MetaAudioOnly           bool              `json:"meta_audio_only"`
+	MetaForwarded           bool              `json:"meta_forwarded"`
+	MetaKeyboard            bool              `json:"meta_keyboard"`
+	MetaUsernameSymbols     string            `json:"meta_username_symbols"`
+	MetaDocuments           []string          `json:"meta_documents"`
+	MetaContact             bool              `json:"meta_contact"`
+	MetaViaBot              bool              `json:"meta_via_bot"`
+	MultiLangLimit          int               `json:"multi_lang_limit"`
+	OpenAIEnabled           bool              `json:"openai_enabled"`
+	SamplesDataPath         string            `json:"samples_data_path"`
+	DynamicDataPath         string            `json:"dynamic_data_path"`
+	WatchIntervalSecs       int               `json:"watch_interval_secs"`
+	SimilarityThreshold     float64           `json:"similarity_threshold"`
+	MinMsgLen               int               `json:"min_msg_len"`
+	MaxEmoji                int               `json:"max_emoji"`
+	MinSpamProbability      float64           `json:"min_spam_probability"`
+	ParanoidMode            bool              `json:"paranoid_mode"`
+	FirstMessagesCount      int               `json:"first_messages_count"`
+	StartupMessageEnabled   bool              `json:"startup_message_enabled"`
+	TrainingEnabled         bool              `json:"training_enabled"`
+	StorageTimeout          time.Duration     `json:"storage_timeout"`
+	OpenAIVeto              bool              `json:"openai_veto"`
+	OpenAIHistorySize       int               `json:"openai_history_size"`
+	OpenAIModel             string            `json:"openai_model"`
+	SoftBanEnabled          bool              `json:"soft_ban_enabled"`
+	AbnormalSpacingEnabled  bool              `json:"abnormal_spacing_enabled"`
+	DomainsEnabled          bool              `json:"domains_enabled"`
+	HistorySize             int               `json:"history_size"`
+	DebugModeEnabled        bool              `json:"debug_mode_enabled"`
+	DryModeEnabled          bool              `json:"dry_mode_enabled"`
+	TGDebugModeEnabled      bool              `json:"tg_debug_mode_enabled"`
+	ProfileAssignments      map[string]string `json:"profile_assignments"`
 }
 
 // Detector is a spam detector interface.
 type Detector interface {
-	Check(req spamcheck.Request) (spam bool, cr []spamcheck.Response)
+	CheckContext(ctx context.Context, req spamcheck.Request) (spam bool, cr []spamcheck.Response)
 	ApprovedUsers() []approved.UserInfo
 	AddApprovedUser(user approved.UserInfo) error
 	RemoveApprovedUser(id string) error
@@ -125,6 +137,7 @@ type SpamFilter interface {
 	UpdateHam(msg string) error
 	ReloadSamples() (err error)
 	DynamicSamples() (spam, ham []string, err error)
+	ReloadDomains() error
 	RemoveDynamicSpamSample(sample string) error
 	RemoveDynamicHamSample(sample string) error
 }
@@ -149,6 +162,27 @@ type StorageEngine interface {
 	BackupSqliteAsPostgres(ctx context.Context, w io.Writer) error
 }
 
+// Strikes is a storage interface used to add and read user strikes.
+type Strikes interface {
+	Add(ctx context.Context, strike storage.StrikeInfo) (int, error)
+	Read(ctx context.Context, userID int64) ([]storage.StrikeInfo, error)
+}
+
+// Profiles is a storage interface used to manage detector profiles.
+type Profiles interface {
+	Write(ctx context.Context, profile storage.Profile) error
+	Read(ctx context.Context, name string) (*storage.Profile, error)
+	List(ctx context.Context) ([]storage.Profile, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Dictionary is a storage interface used to manage denied and allowed domains.
+type Dictionary interface {
+	Set(ctx context.Context, t storage.DictionaryType, data string) error
+	Remove(ctx context.Context, t storage.DictionaryType, data string) error
+	Read(ctx context.Context, t storage.DictionaryType) ([]string, error)
+}
+
 // NewServer creates a new web API server.
 func NewServer(config Config) *Server {
 	return &Server{Config: config}
@@ -237,6 +271,24 @@ func (s *Server) routes(router *routegroup.Bundle) *routegroup.Bundle {
 			r.HandleFunc("GET /", s.getApprovedUsersHandler)
 		})
 
+		authApi.Mount("/strikes").Route(func(r *routegroup.Bundle) { // manage user strikes
+			r.HandleFunc("POST /add", s.addStrikeHandler)           // add strike, applied by the bot on the next message of the user
+			r.HandleFunc("GET /{user_id}", s.getUserStrikesHandler) // get active strikes of the user
+		})
+
+		authApi.Mount("/profiles").Route(func(r *routegroup.Bundle) { // manage detector profiles, applied on restart
+			r.HandleFunc("GET /", s.listProfilesHandler)         // list all profiles
+			r.HandleFunc("GET /{name}", s.getProfileHandler)     // get profile by name
+			r.HandleFunc("POST /add", s.writeProfileHandler)     // add or replace profile
+			r.HandleFunc("POST /delete", s.deleteProfileHandler) // delete profile by name
+		})
+
+		authApi.Mount("/domains").Route(func(r *routegroup.Bundle) { // manage denied and allowed domains of the domain check
+			r.HandleFunc("GET /", s.listDomainsHandler)                // list denied and allowed domains
+			r.HandleFunc("POST /add", s.updateDomainHandler(true))     // add domain to the list, moved from the other list if there
+			r.HandleFunc("POST /delete", s.updateDomainHandler(false)) // delete domain from the list
+		})
+
 		authApi.HandleFunc("GET /settings", func(w http.ResponseWriter, _ *http.Request) {
 			rest.RenderJSON(w, s.Settings)
 		})
@@ -275,6 +327,7 @@ func (s *Server) routes(router *routegroup.Bundle) *routegroup.Bundle {
 func (s *Server) checkMsgHandler(w http.ResponseWriter, r *http.Request) {
 	type CheckResultDisplay struct {
 		Spam   bool
+		Score  float64
 		Checks []spamcheck.Response
 	}
 
@@ -296,10 +349,10 @@ func (s *Server) checkMsgHandler(w http.ResponseWriter, r *http.Request) {
 		req.Msg = r.FormValue("msg")
 	}
 
-	spam, cr := s.Detector.Check(req)
+	spam, cr := s.Detector.CheckContext(r.Context(), req)
 	if !isHtmxRequest {
 		// for API request return JSON
-		rest.RenderJSON(w, rest.JSON{"spam": spam, "checks": cr})
+		rest.RenderJSON(w, rest.JSON{"spam": spam, "score": spamcheck.TotalScore(cr), "checks": cr})
 		return
 	}
 
@@ -312,6 +365,7 @@ func (s *Server) checkMsgHandler(w http.ResponseWriter, r *http.Request) {
 	// render result for HTMX request
 	resultDisplay := CheckResultDisplay{
 		Spam:   spam,
+		Score:  spamcheck.TotalScore(cr),
 		Checks: cr,
 	}
 
@@ -330,6 +384,7 @@ func (s *Server) checkIDHandler(w http.ResponseWriter, r *http.Request) {
 		UserName  string               `json:"user_name,omitempty"`
 		Message   string               `json:"message,omitempty"`
 		Timestamp time.Time            `json:"timestamp,omitempty"`
+		Score     float64              `json:"score,omitempty"`
 		Checks    []spamcheck.Response `json:"checks,omitempty"`
 	}
 	resp := struct {
@@ -358,6 +413,7 @@ func (s *Server) checkIDHandler(w http.ResponseWriter, r *http.Request) {
 			UserName:  si.UserName,
 			Message:   si.Text,
 			Timestamp: si.Timestamp,
+			Score:     si.Score,
 			Checks:    si.Checks,
 		}
 	}
@@ -539,6 +595,255 @@ func (s *Server) getApprovedUsersHandler(w http.ResponseWriter, _ *http.Request)
 	rest.RenderJSON(w, rest.JSON{"user_ids": s.Detector.ApprovedUsers()})
 }
 
+// addStrikeHandler handles POST /strikes/add request. It adds a strike for the user by id or name.
+// The bot applies the escalation ladder step on the next message of the user.
+func (s *Server) addStrikeHandler(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		UserID   int64  `json:"user_id"`
+		UserName string `json:"user_name"`
+		Reason   string `json:"reason"`
+	}{}
+	isHtmxRequest := r.Header.Get("HX-Request") == "true"
+	if isHtmxRequest {
+		if id := strings.TrimSpace(r.FormValue("user_id")); id != "" {
+			userID, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				w.Header().Set("HX-Retarget", "#strike-result")
+				fmt.Fprintln(w, "<div class='alert alert-danger'>Invalid user id.</div>")
+				return
+			}
+			req.UserID = userID
+		}
+		req.UserName = strings.TrimSpace(r.FormValue("user_name"))
+		req.Reason = r.FormValue("reason")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "can't decode request", "details": err.Error()})
+		return
+	}
+
+	if s.Strikes == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "strikes are disabled"})
+		return
+	}
+
+	// try to get userID from request and fallback to userName lookup if it's empty
+	if req.UserID == 0 && req.UserName != "" {
+		req.UserID = s.Locator.UserIDByName(r.Context(), req.UserName)
+	}
+	if req.UserID == 0 {
+		if isHtmxRequest {
+			w.Header().Set("HX-Retarget", "#strike-result")
+			fmt.Fprintln(w, "<div class='alert alert-danger'>Either userid or valid username required.</div>")
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "user ID is required"})
+		return
+	}
+	if req.UserName == "" {
+		req.UserName = s.Locator.UserNameByID(r.Context(), req.UserID)
+	}
+	if req.Reason == "" {
+		req.Reason = "strike from web UI"
+	}
+
+	count, err := s.Strikes.Add(r.Context(), storage.StrikeInfo{UserID: req.UserID, UserName: req.UserName,
+		Source: "web", Reason: req.Reason})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		rest.RenderJSON(w, rest.JSON{"error": "can't add strike", "details": err.Error()})
+		return
+	}
+
+	if isHtmxRequest {
+		fmt.Fprintf(w, "<div class='alert alert-success'>Strike added for %s (%d), active strikes: %d</div>",
+			template.HTMLEscapeString(req.UserName), req.UserID, count)
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"updated": true, "user_id": req.UserID, "user_name": req.UserName, "strikes": count})
+}
+
+// getUserStrikesHandler handles GET /strikes/{user_id} request. It returns active strikes of the user.
+func (s *Server) getUserStrikesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Strikes == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "strikes are disabled"})
+		return
+	}
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "can't parse user id", "details": err.Error()})
+		return
+	}
+	strikes, err := s.Strikes.Read(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		rest.RenderJSON(w, rest.JSON{"error": "can't read strikes", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"user_id": userID, "count": len(strikes), "strikes": strikes})
+}
+
+// listProfilesHandler handles GET /profiles request. It returns all profiles with groups assigned to them.
+func (s *Server) listProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Profiles == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "profiles are disabled"})
+		return
+	}
+	profiles, err := s.Profiles.List(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		rest.RenderJSON(w, rest.JSON{"error": "can't list profiles", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"profiles": profiles, "assignments": s.Settings.ProfileAssignments})
+}
+
+// getProfileHandler handles GET /profiles/{name} request. It returns the profile by name.
+func (s *Server) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Profiles == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "profiles are disabled"})
+		return
+	}
+	profile, err := s.Profiles.Read(r.Context(), r.PathValue("name"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		rest.RenderJSON(w, rest.JSON{"error": "can't read profile", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, profile)
+}
+
+// writeProfileHandler handles POST /profiles/add request. It adds a new profile or replaces the existing one.
+// Changes are applied to the groups on the next restart, reported by restart_required for assigned profiles.
+func (s *Server) writeProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Profiles == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "profiles are disabled"})
+		return
+	}
+	profile := storage.Profile{}
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "can't decode request", "details": err.Error()})
+		return
+	}
+	if err := s.Profiles.Write(r.Context(), profile); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "can't write profile", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"updated": true, "name": profile.Name, "restart_required": s.profileAssigned(profile.Name)})
+}
+
+// deleteProfileHandler handles POST /profiles/delete request. It deletes the profile by name.
+// Groups keep the deleted profile until the next restart, reported by restart_required for assigned profiles.
+func (s *Server) deleteProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Profiles == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "profiles are disabled"})
+		return
+	}
+	req := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "can't decode request", "details": err.Error()})
+		return
+	}
+	if err := s.Profiles.Delete(r.Context(), req.Name); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		rest.RenderJSON(w, rest.JSON{"error": "can't delete profile", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"deleted": true, "name": req.Name, "restart_required": s.profileAssigned(req.Name)})
+}
+
+// listDomainsHandler handles GET /domains request. It returns denied and allowed domains.
+func (s *Server) listDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Dictionary == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		rest.RenderJSON(w, rest.JSON{"error": "domains are disabled"})
+		return
+	}
+	denied, err := s.Dictionary.Read(r.Context(), storage.DictionaryTypeDeniedDomain)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		rest.RenderJSON(w, rest.JSON{"error": "can't read denied domains", "details": err.Error()})
+		return
+	}
+	allowed, err := s.Dictionary.Read(r.Context(), storage.DictionaryTypeAllowedDomain)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		rest.RenderJSON(w, rest.JSON{"error": "can't read allowed domains", "details": err.Error()})
+		return
+	}
+	rest.RenderJSON(w, rest.JSON{"denied": denied, "allowed": allowed})
+}
+
+// updateDomainHandler handles POST /domains/add and /domains/delete requests.
+// Request is {"list": "denied"|"allowed", "domain": "example.com"}. Domains are reloaded by the bot after the change.
+func (s *Server) updateDomainHandler(add bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Dictionary == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			rest.RenderJSON(w, rest.JSON{"error": "domains are disabled"})
+			return
+		}
+		req := struct {
+			List   string `json:"list"`
+			Domain string `json:"domain"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			rest.RenderJSON(w, rest.JSON{"error": "can't decode request", "details": err.Error()})
+			return
+		}
+		req.Domain = strings.ToLower(strings.TrimSpace(req.Domain))
+		if req.Domain == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			rest.RenderJSON(w, rest.JSON{"error": "empty domain"})
+			return
+		}
+		var dt storage.DictionaryType
+		switch req.List {
+		case "denied":
+			dt = storage.DictionaryTypeDeniedDomain
+		case "allowed":
+			dt = storage.DictionaryTypeAllowedDomain
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			rest.RenderJSON(w, rest.JSON{"error": "invalid list, expected denied or allowed", "details": req.List})
+			return
+		}
+
+		if add {
+			if err := s.Dictionary.Set(r.Context(), dt, req.Domain); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				rest.RenderJSON(w, rest.JSON{"error": "can't add domain", "details": err.Error()})
+				return
+			}
+		} else if err := s.Dictionary.Remove(r.Context(), dt, req.Domain); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			rest.RenderJSON(w, rest.JSON{"error": "can't delete domain", "details": err.Error()})
+			return
+		}
+
+		if err := s.SpamFilter.ReloadDomains(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			rest.RenderJSON(w, rest.JSON{"error": "can't reload domains", "details": err.Error()})
+			return
+		}
+		rest.RenderJSON(w, rest.JSON{"updated": true, "list": req.List, "domain": req.Domain})
+	}
+}
+
 // htmlSpamCheckHandler handles GET / request.
 // It returns rendered spam_check.html template with all the components.
 func (s *Server) htmlSpamCheckHandler(w http.ResponseWriter, _ *http.Request) {
@@ -566,9 +871,11 @@ func (s *Server) htmlManageUsersHandler(w http.ResponseWriter, _ *http.Request)
 	tmplData := struct {
 		ApprovedUsers      []approved.UserInfo
 		TotalApprovedUsers int
+		StrikesEnabled     bool
 	}{
 		ApprovedUsers:      users,
 		TotalApprovedUsers: len(users),
+		StrikesEnabled:     s.Strikes != nil,
 	}
 	tmplData.TotalApprovedUsers = len(tmplData.ApprovedUsers)
 
@@ -715,7 +1022,7 @@ func (s *Server) htmlAddDetectedSpamHandler(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) htmlSettingsHandler(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) htmlSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	// get database information if StorageEngine is available
 	var dbInfo struct {
 		DatabaseType   string `json:"database_type"`
@@ -747,6 +1054,7 @@ func (s *Server) htmlSettingsHandler(w http.ResponseWriter, _ *http.Request) {
 	data := struct {
 		Settings
 		Version  string
+		Profiles []profileView
 		Database struct {
 			Type   string
 			GID    string
@@ -762,6 +1070,7 @@ func (s *Server) htmlSettingsHandler(w http.ResponseWriter, _ *http.Request) {
 	}{
 		Settings: s.Settings,
 		Version:  s.Version,
+		Profiles: s.profileViews(r.Context()),
 		Database: struct {
 			Type   string
 			GID    string
@@ -792,6 +1101,81 @@ func (s *Server) htmlSettingsHandler(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// profileView is a profile as shown on the settings page
+type profileView struct {
+	Name      string
+	Groups    []string
+	Overrides []string
+	UpdatedAt time.Time
+
+	RestartRequired bool // assigned profile changed after start, not applied to the groups yet
+}
+
+// profileViews returns profiles with assigned groups and overridden settings, for the settings page
+func (s *Server) profileViews(ctx context.Context) []profileView {
+	if s.Profiles == nil {
+		return nil
+	}
+	profiles, err := s.Profiles.List(ctx)
+	if err != nil {
+		log.Printf("[WARN] can't list profiles: %v", err)
+		return nil
+	}
+	res := make([]profileView, 0, len(profiles))
+	for _, p := range profiles {
+		view := profileView{Name: p.Name, UpdatedAt: p.UpdatedAt}
+		for group, name := range s.Settings.ProfileAssignments {
+			if name == p.Name {
+				view.Groups = append(view.Groups, group)
+			}
+		}
+		sort.Strings(view.Groups)
+		view.Overrides = profileOverrides(p)
+		view.RestartRequired = len(view.Groups) > 0 && p.UpdatedAt.After(startTime)
+		res = append(res, view)
+	}
+	return res
+}
+
+// profileAssigned checks if the profile is assigned to any group
+func (s *Server) profileAssigned(name string) bool {
+	for _, assigned := range s.Settings.ProfileAssignments {
+		if assigned == name {
+			return true
+		}
+	}
+	return false
+}
+
+// profileOverrides returns settings set in the profile as sorted "key: value" strings.
+// Nested settings, like meta checks and messages, are prefixed with the section name.
+func profileOverrides(p storage.Profile) []string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	fields := map[string]any{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	res := []string{}
+	for key, val := range fields {
+		switch v := val.(type) {
+		case map[string]any:
+			for subKey, subVal := range v {
+				res = append(res, fmt.Sprintf("%s.%s: %v", key, subKey, subVal))
+			}
+		default:
+			if key == "name" || key == "updated_at" {
+				continue
+			}
+			res = append(res, fmt.Sprintf("%s: %v", key, v))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
 // formatDuration formats a duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours() / 24)
@@ -826,6 +1210,7 @@ func (s *Server) downloadDetectedSpamHandler(w http.ResponseWriter, r *http.Requ
 		UserName  string               `json:"user_name"`
 		Timestamp time.Time            `json:"timestamp"`
 		Added     bool                 `json:"added"`
+		Score     float64              `json:"score"`
 		Checks    []spamcheck.Response `json:"checks"`
 	}
 
@@ -840,6 +1225,7 @@ func (s *Server) downloadDetectedSpamHandler(w http.ResponseWriter, r *http.Requ
 			UserName:  entry.UserName,
 			Timestamp: entry.Timestamp,
 			Added:     entry.Added,
+			Score:     entry.Score,
 			Checks:    entry.Checks,
 		})
 		if err != nil {
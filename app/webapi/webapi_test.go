@@ -62,7 +62,7 @@ func TestServer_RunAuth(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	mockDetector := &mocks.DetectorMock{
-		CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
 			return false, []spamcheck.Response{{Details: "not spam"}}
 		},
 	}
@@ -193,7 +193,7 @@ func TestServer_RunAuth(t *testing.T) {
 
 func TestServer_routes(t *testing.T) {
 	detectorMock := &mocks.DetectorMock{
-		CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
 			return false, []spamcheck.Response{{Details: "not spam"}}
 		},
 		ApprovedUsersFunc: func() []approved.UserInfo {
@@ -259,9 +259,9 @@ func TestServer_routes(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 		assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
-		assert.Equal(t, 1, len(detectorMock.CheckCalls()))
-		assert.Equal(t, "spam example", detectorMock.CheckCalls()[0].Req.Msg)
-		assert.Equal(t, "user123", detectorMock.CheckCalls()[0].Req.UserID)
+		assert.Equal(t, 1, len(detectorMock.CheckContextCalls()))
+		assert.Equal(t, "spam example", detectorMock.CheckContextCalls()[0].Req.Msg)
+		assert.Equal(t, "user123", detectorMock.CheckContextCalls()[0].Req.UserID)
 	})
 
 	t.Run("check by id found", func(t *testing.T) {
@@ -462,7 +462,7 @@ func TestServer_routes(t *testing.T) {
 
 func TestServer_checkHandler(t *testing.T) {
 	mockDetector := &mocks.DetectorMock{
-		CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
 			if req.UserID == "" {
 				// for empty user ID, include a CAS check with "check disabled"
 				return false, []spamcheck.Response{
@@ -472,7 +472,9 @@ func TestServer_checkHandler(t *testing.T) {
 			}
 
 			if req.Msg == "spam example" {
-				return true, []spamcheck.Response{{Spam: true, Name: "test", Details: "this was spam"}}
+				return true, []spamcheck.Response{{Spam: true, Name: "test", Details: "this was spam", Score: 1,
+					Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceSample, ID: "abc", Text: "spam sample", Weight: 0.9}}},
+					{Name: "other", Details: "suspicious", Score: 0.5}}
 			}
 			return false, []spamcheck.Response{{Details: "not spam"}}
 		},
@@ -499,13 +501,18 @@ func TestServer_checkHandler(t *testing.T) {
 
 		var response struct {
 			Spam   bool                 `json:"spam"`
+			Score  float64              `json:"score"`
 			Checks []spamcheck.Response `json:"checks"`
 		}
 		err = json.Unmarshal(rr.Body.Bytes(), &response)
 		assert.NoError(t, err, "error unmarshalling response")
 		assert.True(t, response.Spam, "expected spam")
+		assert.InDelta(t, 1.5, response.Score, 0.0001, "unexpected total score")
 		assert.Equal(t, "test", response.Checks[0].Name, "unexpected check name")
 		assert.Equal(t, "this was spam", response.Checks[0].Details, "unexpected check result")
+		assert.Equal(t, []spamcheck.Evidence{{Kind: spamcheck.EvidenceSample, ID: "abc", Text: "spam sample", Weight: 0.9}},
+			response.Checks[0].Evidence, "evidence of the check")
+		assert.Empty(t, response.Checks[1].Evidence)
 	})
 
 	t.Run("not spam", func(t *testing.T) {
@@ -862,6 +869,393 @@ func TestServer_updateApprovedUsersHandler(t *testing.T) {
 	})
 }
 
+func TestServer_addStrikeHandler(t *testing.T) {
+	strikesMock := &mocks.StrikesMock{
+		AddFunc: func(ctx context.Context, strike storage.StrikeInfo) (int, error) {
+			if strike.UserID == 666 {
+				return 0, assert.AnError
+			}
+			return 2, nil
+		},
+	}
+	locatorMock := &mocks.LocatorMock{
+		UserIDByNameFunc: func(ctx context.Context, userName string) int64 {
+			if userName == "user1" {
+				return 12345
+			}
+			return 0
+		},
+		UserNameByIDFunc: func(ctx context.Context, userID int64) string { return fmt.Sprintf("user-%d", userID) },
+	}
+	server := NewServer(Config{Locator: locatorMock, Strikes: strikesMock})
+
+	t.Run("add by name", func(t *testing.T) {
+		strikesMock.ResetCalls()
+		req, err := http.NewRequest("POST", "/strikes/add", bytes.NewBufferString(`{"user_name": "user1", "reason": "rude"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.addStrikeHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Updated bool  `json:"updated"`
+			UserID  int64 `json:"user_id"`
+			Strikes int   `json:"strikes"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, struct {
+			Updated bool  `json:"updated"`
+			UserID  int64 `json:"user_id"`
+			Strikes int   `json:"strikes"`
+		}{Updated: true, UserID: 12345, Strikes: 2}, response)
+		require.Len(t, strikesMock.AddCalls(), 1)
+		assert.Equal(t, storage.StrikeInfo{UserID: 12345, UserName: "user1", Source: "web", Reason: "rude"},
+			strikesMock.AddCalls()[0].Strike)
+	})
+
+	t.Run("add by id from htmx", func(t *testing.T) {
+		strikesMock.ResetCalls()
+		req, err := http.NewRequest("POST", "/strikes/add", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Add("HX-Request", "true")
+		req.Form = url.Values{}
+		req.Form.Set("user_id", "123")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.addStrikeHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Strike added for user-123 (123), active strikes: 2")
+		require.Len(t, strikesMock.AddCalls(), 1)
+		assert.Equal(t, storage.StrikeInfo{UserID: 123, UserName: "user-123", Source: "web", Reason: "strike from web UI"},
+			strikesMock.AddCalls()[0].Strike)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		strikesMock.ResetCalls()
+		req, err := http.NewRequest("POST", "/strikes/add", bytes.NewBufferString(`{"user_name": "unknown"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.addStrikeHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Empty(t, strikesMock.AddCalls())
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/strikes/add", bytes.NewBufferString(`{"user_id": 666}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.addStrikeHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("strikes disabled", func(t *testing.T) {
+		srv := NewServer(Config{Locator: locatorMock})
+		req, err := http.NewRequest("POST", "/strikes/add", bytes.NewBufferString(`{"user_id": 123}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.addStrikeHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "strikes are disabled")
+	})
+}
+
+func TestServer_getUserStrikesHandler(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	strikesMock := &mocks.StrikesMock{
+		ReadFunc: func(ctx context.Context, userID int64) ([]storage.StrikeInfo, error) {
+			return []storage.StrikeInfo{{ID: 1, UserID: userID, UserName: "user1", Source: "admin", Timestamp: ts}}, nil
+		},
+	}
+	server := NewServer(Config{Strikes: strikesMock})
+
+	req, err := http.NewRequest("GET", "/strikes/123", http.NoBody)
+	require.NoError(t, err)
+	req.SetPathValue("user_id", "123")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.getUserStrikesHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		UserID  int64                `json:"user_id"`
+		Count   int                  `json:"count"`
+		Strikes []storage.StrikeInfo `json:"strikes"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, int64(123), response.UserID)
+	assert.Equal(t, 1, response.Count)
+	require.Len(t, response.Strikes, 1)
+	assert.Equal(t, "admin", response.Strikes[0].Source)
+	require.Len(t, strikesMock.ReadCalls(), 1)
+	assert.Equal(t, int64(123), strikesMock.ReadCalls()[0].UserID)
+
+	req, err = http.NewRequest("GET", "/strikes/bad", http.NoBody)
+	require.NoError(t, err)
+	req.SetPathValue("user_id", "bad")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.getUserStrikesHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestServer_profilesHandlers(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	count := 3
+	profilesMock := &mocks.ProfilesMock{
+		ListFunc: func(ctx context.Context) ([]storage.Profile, error) {
+			return []storage.Profile{{Name: "strict", FirstMessagesCount: &count, UpdatedAt: ts}}, nil
+		},
+		ReadFunc: func(ctx context.Context, name string) (*storage.Profile, error) {
+			if name != "strict" {
+				return nil, fmt.Errorf("profile %q not found", name)
+			}
+			return &storage.Profile{Name: "strict", FirstMessagesCount: &count, UpdatedAt: ts}, nil
+		},
+		WriteFunc: func(ctx context.Context, profile storage.Profile) error {
+			if profile.Name == "" {
+				return assert.AnError
+			}
+			return nil
+		},
+		DeleteFunc: func(ctx context.Context, name string) error {
+			if name != "strict" {
+				return fmt.Errorf("profile %q not found", name)
+			}
+			return nil
+		},
+	}
+	server := NewServer(Config{Profiles: profilesMock,
+		Settings: Settings{ProfileAssignments: map[string]string{"group1": "strict"}}})
+
+	t.Run("list", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/profiles", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.listProfilesHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Profiles    []storage.Profile `json:"profiles"`
+			Assignments map[string]string `json:"assignments"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Len(t, response.Profiles, 1)
+		assert.Equal(t, "strict", response.Profiles[0].Name)
+		assert.Equal(t, 3, *response.Profiles[0].FirstMessagesCount)
+		assert.Equal(t, map[string]string{"group1": "strict"}, response.Assignments)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/profiles/strict", http.NoBody)
+		require.NoError(t, err)
+		req.SetPathValue("name", "strict")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.getProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response storage.Profile
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "strict", response.Name)
+
+		req, err = http.NewRequest("GET", "/profiles/unknown", http.NoBody)
+		require.NoError(t, err)
+		req.SetPathValue("name", "unknown")
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(server.getProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("add", func(t *testing.T) {
+		profilesMock.ResetCalls()
+		body := `{"name": "lenient", "min_spam_probability": 80, "meta": {"links_limit": 5}, "messages": {"warn": "hey"}}`
+		req, err := http.NewRequest("POST", "/profiles/add", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.writeProfileHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"updated": true, "name": "lenient", "restart_required": false}`, rr.Body.String())
+		require.Len(t, profilesMock.WriteCalls(), 1)
+		written := profilesMock.WriteCalls()[0].Profile
+		assert.Equal(t, "lenient", written.Name)
+		assert.InDelta(t, 80.0, *written.MinSpamProbability, 0.0001)
+		assert.Equal(t, 5, *written.Meta.LinksLimit)
+		assert.Equal(t, "hey", *written.Messages.Warn)
+		assert.Nil(t, written.SimilarityThreshold)
+
+		req, err = http.NewRequest("POST", "/profiles/add", bytes.NewBufferString(`{"name": "strict"}`))
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(server.writeProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"updated": true, "name": "strict", "restart_required": true}`, rr.Body.String(),
+			"assigned profile applied on restart")
+	})
+
+	t.Run("add failed", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/profiles/add", bytes.NewBufferString(`{"first_messages_count": 1}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.writeProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		req, err = http.NewRequest("POST", "/profiles/add", bytes.NewBufferString(`bad json`))
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(server.writeProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/profiles/delete", bytes.NewBufferString(`{"name": "strict"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.deleteProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"deleted": true, "name": "strict", "restart_required": true}`, rr.Body.String())
+
+		req, err = http.NewRequest("POST", "/profiles/delete", bytes.NewBufferString(`{"name": "unknown"}`))
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(server.deleteProfileHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("profiles disabled", func(t *testing.T) {
+		srv := NewServer(Config{})
+		req, err := http.NewRequest("GET", "/profiles", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.listProfilesHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("settings page", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/list_settings", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.htmlSettingsHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		body := rr.Body.String()
+		assert.Contains(t, body, "Detector Profiles")
+		assert.Contains(t, body, "<td>strict</td>")
+		assert.Contains(t, body, "group1<br>")
+		assert.Contains(t, body, "first_messages_count: 3<br>")
+		assert.Contains(t, body, "2024-01-02 03:04:05")
+		assert.NotContains(t, body, "restart required", "profile not changed since start")
+	})
+
+	t.Run("settings page with profile changed after start", func(t *testing.T) {
+		srv := NewServer(Config{Settings: Settings{ProfileAssignments: map[string]string{"group1": "strict"}},
+			Profiles: &mocks.ProfilesMock{ListFunc: func(ctx context.Context) ([]storage.Profile, error) {
+				return []storage.Profile{{Name: "strict", UpdatedAt: time.Now()}, {Name: "other", UpdatedAt: time.Now()}}, nil
+			}}})
+		req, err := http.NewRequest("GET", "/list_settings", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.htmlSettingsHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 1, strings.Count(rr.Body.String(), "restart required"), "only assigned profile needs restart")
+	})
+}
+
+func TestServer_domainsHandlers(t *testing.T) {
+	dictMock := &mocks.DictionaryMock{
+		ReadFunc: func(ctx context.Context, dt storage.DictionaryType) ([]string, error) {
+			if dt == storage.DictionaryTypeDeniedDomain {
+				return []string{"spam.com", "*.casino.io"}, nil
+			}
+			return []string{"example.com"}, nil
+		},
+		SetFunc: func(ctx context.Context, dt storage.DictionaryType, data string) error { return nil },
+		RemoveFunc: func(ctx context.Context, dt storage.DictionaryType, data string) error {
+			if data != "spam.com" {
+				return fmt.Errorf("%s %q not found", dt, data)
+			}
+			return nil
+		},
+	}
+	spamFilterMock := &mocks.SpamFilterMock{ReloadDomainsFunc: func() error { return nil }}
+	server := NewServer(Config{Dictionary: dictMock, SpamFilter: spamFilterMock})
+
+	t.Run("list", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/domains", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.listDomainsHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"denied": ["spam.com", "*.casino.io"], "allowed": ["example.com"]}`, rr.Body.String())
+	})
+
+	t.Run("add", func(t *testing.T) {
+		dictMock.ResetCalls()
+		spamFilterMock.ResetCalls()
+		req, err := http.NewRequest("POST", "/domains/add", bytes.NewBufferString(`{"list": "denied", "domain": " Bad.com "}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.updateDomainHandler(true)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"updated": true, "list": "denied", "domain": "bad.com"}`, rr.Body.String())
+		require.Len(t, dictMock.SetCalls(), 1)
+		assert.Equal(t, storage.DictionaryTypeDeniedDomain, dictMock.SetCalls()[0].T)
+		assert.Equal(t, "bad.com", dictMock.SetCalls()[0].Data)
+		assert.Len(t, spamFilterMock.ReloadDomainsCalls(), 1)
+	})
+
+	t.Run("add failed", func(t *testing.T) {
+		for _, body := range []string{`bad json`, `{"list": "denied", "domain": ""}`, `{"list": "blah", "domain": "bad.com"}`} {
+			req, err := http.NewRequest("POST", "/domains/add", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.updateDomainHandler(true)).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		dictMock.ResetCalls()
+		spamFilterMock.ResetCalls()
+		req, err := http.NewRequest("POST", "/domains/delete", bytes.NewBufferString(`{"list": "allowed", "domain": "spam.com"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.updateDomainHandler(false)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		require.Len(t, dictMock.RemoveCalls(), 1)
+		assert.Equal(t, storage.DictionaryTypeAllowedDomain, dictMock.RemoveCalls()[0].T)
+		assert.Len(t, spamFilterMock.ReloadDomainsCalls(), 1)
+
+		req, err = http.NewRequest("POST", "/domains/delete", bytes.NewBufferString(`{"list": "allowed", "domain": "unknown.com"}`))
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(server.updateDomainHandler(false)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("reload failed", func(t *testing.T) {
+		srv := NewServer(Config{Dictionary: dictMock,
+			SpamFilter: &mocks.SpamFilterMock{ReloadDomainsFunc: func() error { return assert.AnError }}})
+		req, err := http.NewRequest("POST", "/domains/add", bytes.NewBufferString(`{"list": "allowed", "domain": "good.com"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.updateDomainHandler(true)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("domains disabled", func(t *testing.T) {
+		srv := NewServer(Config{})
+		req, err := http.NewRequest("GET", "/domains", http.NoBody)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.listDomainsHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func Test_profileOverrides(t *testing.T) {
+	threshold, keyboard, spam := 0.7, true, "spam!"
+	res := profileOverrides(storage.Profile{Name: "p1", SimilarityThreshold: &threshold,
+		Meta: storage.ProfileMeta{Keyboard: &keyboard}, Messages: storage.ProfileMessages{Spam: &spam}, UpdatedAt: time.Now()})
+	assert.Equal(t, []string{"messages.spam: spam!", "meta.keyboard: true", "similarity_threshold: 0.7"}, res)
+	assert.Empty(t, profileOverrides(storage.Profile{Name: "empty"}))
+}
+
 func TestServer_htmlDetectedSpamHandler(t *testing.T) {
 	t.Run("successful rendering", func(t *testing.T) {
 		ds := &mocks.DetectedSpamMock{
@@ -879,6 +1273,8 @@ func TestServer_htmlDetectedSpamHandler(t *testing.T) {
 						UserID:    67890,
 						UserName:  "user2",
 						Timestamp: ts,
+						Checks: []spamcheck.Response{{Name: "classifier", Spam: true, Details: "probability of spam: 90.00%",
+							Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceToken, Text: "lottery", Weight: 1.25}}}},
 					},
 				}, nil
 			},
@@ -907,6 +1303,8 @@ func TestServer_htmlDetectedSpamHandler(t *testing.T) {
 		assert.Contains(t, body, "spam2")
 		assert.Contains(t, body, "user2")
 		assert.Contains(t, body, "67890")
+		assert.Contains(t, body, "probability of spam: 90.00%")
+		assert.Contains(t, body, "<li>&#34;lottery&#34; &#43;1.25</li>", "evidence rendered")
 	})
 
 	t.Run("read failure", func(t *testing.T) {
@@ -1049,8 +1447,9 @@ func TestServer_GenerateRandomPassword(t *testing.T) {
 
 func TestServer_checkHandler_HTMX(t *testing.T) {
 	mockDetector := &mocks.DetectorMock{
-		CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
-			return req.Msg == "spam example", []spamcheck.Response{{Spam: req.Msg == "spam example", Name: "test", Details: "result details"}}
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
+			return req.Msg == "spam example", []spamcheck.Response{{Spam: req.Msg == "spam example", Name: "test", Details: "result details",
+				Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: "в личку"}}}}
 		},
 		RemoveApprovedUserFunc: func(id string) error {
 			return nil
@@ -1080,10 +1479,11 @@ func TestServer_checkHandler_HTMX(t *testing.T) {
 		// check if the response contains expected HTML snippet
 		assert.Contains(t, rr.Body.String(), "strong>Result:</strong> Spam detected", "response should contain spam result")
 		assert.Contains(t, rr.Body.String(), "result details")
+		assert.Contains(t, rr.Body.String(), "<li>&#34;в личку&#34;</li>", "evidence rendered")
 
-		assert.Equal(t, 1, len(mockDetector.CheckCalls()))
-		assert.Equal(t, "spam example", mockDetector.CheckCalls()[0].Req.Msg)
-		assert.Equal(t, "user123", mockDetector.CheckCalls()[0].Req.UserID)
+		assert.Equal(t, 1, len(mockDetector.CheckContextCalls()))
+		assert.Equal(t, "spam example", mockDetector.CheckContextCalls()[0].Req.Msg)
+		assert.Equal(t, "user123", mockDetector.CheckContextCalls()[0].Req.UserID)
 	})
 }
 
@@ -1347,7 +1747,7 @@ func TestServer_htmlSettingsHandler(t *testing.T) {
 func TestServer_StaticFiles(t *testing.T) {
 	// setup necessary mocks
 	mockDetector := &mocks.DetectorMock{
-		CheckFunc: func(req spamcheck.Request) (bool, []spamcheck.Response) {
+		CheckContextFunc: func(_ context.Context, req spamcheck.Request) (bool, []spamcheck.Response) {
 			return false, []spamcheck.Response{{Details: "not spam"}}
 		},
 		ApprovedUsersFunc: func() []approved.UserInfo {
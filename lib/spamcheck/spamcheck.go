@@ -7,11 +7,15 @@ import (
 
 // Request is a request to check a message for spam.
 type Request struct {
-	Msg       string   `json:"msg"`        // message to check
-	UserID    string   `json:"user_id"`    // user id
-	UserName  string   `json:"user_name"`  // user name
-	Meta      MetaData `json:"meta"`       // meta-info, provided by the client
-	CheckOnly bool     `json:"check_only"` // if true, only check the message, do not write newly approved user to the database
+	Msg             string   `json:"msg"`                         // message to check
+	UserID          string   `json:"user_id"`                     // user id
+	UserName        string   `json:"user_name"`                   // user name
+	UserDisplayName string   `json:"user_display_name,omitempty"` // user's first and last name, checked by profile checks
+	UserBio         string   `json:"user_bio,omitempty"`          // user's bio, checked by profile checks
+	Meta            MetaData `json:"meta"`                        // meta-info, provided by the client
+	CheckOnly       bool     `json:"check_only"`                  // if true, only check the message, do not write newly approved user to the database
+	Edited          bool     `json:"edited"`                      // if true, the message is an edit of already posted one, checked even for approved user
+	Strict          bool     `json:"strict"`                      // if true, the message is checked even for approved user, e.g. posted to a strict topic
 }
 
 // MetaData is a meta-info about the message, provided by the client.
@@ -23,6 +27,21 @@ type MetaData struct {
 	HasAudio    bool `json:"has_audio"`    // true if the message has an audio
 	HasForward  bool `json:"has_forward"`  // true if the message has a forward
 	HasKeyboard bool `json:"has_keyboard"` // true if the message has a keyboard (buttons)
+
+	HasDocument  bool   `json:"has_document"`  // true if the message has a document (file)
+	DocumentName string `json:"document_name"` // file name of the document, if any
+	HasSticker   bool   `json:"has_sticker"`   // true if the message has a sticker
+	HasAnimation bool   `json:"has_animation"` // true if the message has an animation (GIF)
+	HasVoice     bool   `json:"has_voice"`     // true if the message has a voice message
+	HasPoll      bool   `json:"has_poll"`      // true if the message has a poll
+	HasContact   bool   `json:"has_contact"`   // true if the message has a contact card
+	HasLocation  bool   `json:"has_location"`  // true if the message has a location
+	HasVenue     bool   `json:"has_venue"`     // true if the message has a venue
+	HasDice      bool   `json:"has_dice"`      // true if the message has a dice or other animated emoji
+	ViaBot       string `json:"via_bot"`       // username of the inline bot the message was sent via, if any
+
+	URLs               []string `json:"urls,omitempty"`                // links of the message, including hidden links and links without scheme
+	MentionedUsernames []string `json:"mentioned_usernames,omitempty"` // usernames mentioned in the message, without "@"
 }
 
 func (r *Request) String() string {
@@ -32,10 +51,47 @@ func (r *Request) String() string {
 
 // Response is a result of spam check.
 type Response struct {
-	Name    string `json:"name"`    // name of the check
-	Spam    bool   `json:"spam"`    // true if spam
-	Details string `json:"details"` // details of the check
-	Error   error  `json:"-"`       // error message, if any. Do not serialize it
+	Name     string     `json:"name"`                // name of the check
+	Spam     bool       `json:"spam"`                // true if spam
+	Details  string     `json:"details"`             // details of the check
+	Score    float64    `json:"score,omitempty"`     // spam score of the check, 0.0 - 1.0 multiplied by the check's weight, 0 for ham
+	TimedOut bool       `json:"timed_out,omitempty"` // true if the check didn't finish within its deadline
+	Evidence []Evidence `json:"evidence,omitempty"`  // evidence behind the check result, like matched sample or top tokens
+	Error    error      `json:"-"`                   // error message, if any. Do not serialize it
+}
+
+// evidence kinds
+const (
+	EvidenceToken    = "token"    // token contributing to the classifier decision, weight is its log-likelihood ratio
+	EvidenceSample   = "sample"   // spam sample matched the message, weight is the similarity
+	EvidenceStopWord = "stopword" // stop word phrase found in the message
+)
+
+// Evidence is a reason behind the check result, e.g. the best-matching spam sample
+type Evidence struct {
+	Kind   string  `json:"kind"`             // kind of evidence, one of Evidence* constants
+	Text   string  `json:"text"`             // token, sample text or stop word phrase
+	ID     string  `json:"id,omitempty"`     // id of the sample, sha1 of its text
+	Weight float64 `json:"weight,omitempty"` // log-likelihood ratio of the token, positive for spam, or similarity of the sample
+}
+
+// String returns the evidence as a short text, long sample texts are truncated
+func (e Evidence) String() string {
+	switch e.Kind {
+	case EvidenceToken:
+		return fmt.Sprintf("%q %+.2f", e.Text, e.Weight)
+	case EvidenceSample:
+		text := []rune(e.Text)
+		if len(text) > 80 {
+			text = append(text[:80], '…')
+		}
+		id := e.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		return fmt.Sprintf("sample %s (%.2f) %q", id, e.Weight, string(text))
+	}
+	return fmt.Sprintf("%q", e.Text)
 }
 
 func (r *Response) String() string {
@@ -43,7 +99,26 @@ func (r *Response) String() string {
 	if r.Spam {
 		spamOrHam = "spam"
 	}
-	return fmt.Sprintf("%s: %s, %s", r.Name, spamOrHam, r.Details)
+	if r.TimedOut {
+		spamOrHam = "timeout"
+	}
+	if len(r.Evidence) == 0 {
+		return fmt.Sprintf("%s: %s, %s", r.Name, spamOrHam, r.Details)
+	}
+	evidence := make([]string, 0, len(r.Evidence))
+	for _, e := range r.Evidence {
+		evidence = append(evidence, e.String())
+	}
+	return fmt.Sprintf("%s: %s, %s, evidence: %s", r.Name, spamOrHam, r.Details, strings.Join(evidence, ", "))
+}
+
+// TotalScore returns the sum of scores of all checks
+func TotalScore(checks []Response) float64 {
+	res := 0.0
+	for _, r := range checks {
+		res += r.Score
+	}
+	return res
 }
 
 // ChecksToString converts a slice of checks to a string
@@ -1,6 +1,7 @@
 package spamcheck
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,6 +31,26 @@ func TestResponse_String(t *testing.T) {
 			},
 			expected: "name2: ham, details",
 		},
+		{
+			name: "test timeout",
+			input: &Response{
+				Name:     "name3",
+				Details:  "timeout after 1s",
+				TimedOut: true,
+			},
+			expected: "name3: timeout, timeout after 1s",
+		},
+		{
+			name: "test evidence",
+			input: &Response{
+				Name:    "classifier",
+				Spam:    true,
+				Details: "probability of spam: 95.00%",
+				Evidence: []Evidence{{Kind: EvidenceToken, Text: "passive income", Weight: 2.314},
+					{Kind: EvidenceToken, Text: "hello", Weight: -0.5}},
+			},
+			expected: `classifier: spam, probability of spam: 95.00%, evidence: "passive income" +2.31, "hello" -0.50`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -40,6 +61,25 @@ func TestResponse_String(t *testing.T) {
 	}
 }
 
+func TestEvidence_String(t *testing.T) {
+	tests := []struct {
+		input    Evidence
+		expected string
+	}{
+		{Evidence{Kind: EvidenceToken, Text: "income", Weight: 1.5}, `"income" +1.50`},
+		{Evidence{Kind: EvidenceStopWord, Text: "пишите в лс"}, `"пишите в лс"`},
+		{Evidence{Kind: EvidenceSample, ID: "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed", Text: "earn money", Weight: 0.8},
+			`sample 2aae6c35 (0.80) "earn money"`},
+		{Evidence{Kind: EvidenceSample, ID: "abc", Text: strings.Repeat("x", 100), Weight: 1},
+			`sample abc (1.00) "` + strings.Repeat("x", 80) + `…"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.input.String())
+		})
+	}
+}
+
 func TestRequestString(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -110,4 +150,10 @@ func TestChecksToString(t *testing.T) {
 			assert.Equal(t, tt.expected, output)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestTotalScore(t *testing.T) {
+	assert.InDelta(t, 0.0, TotalScore(nil), 0.0001)
+	checks := []Response{{Name: "a", Score: 0.5}, {Name: "b"}, {Name: "c", Spam: true, Score: 1.25}}
+	assert.InDelta(t, 1.75, TotalScore(checks), 0.0001)
+}
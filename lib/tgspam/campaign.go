@@ -0,0 +1,119 @@
+package tgspam
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// maxCampaignEntries limits the number of messages kept for campaign detection, oldest messages are dropped first
+const maxCampaignEntries = 1000
+
+// defaultCampaignMinTokens is the min number of distinct tokens of the message to count it, if Campaign.MinTokens not set
+const defaultCampaignMinTokens = 3
+
+// campaigns keeps recent messages of not approved users to detect waves of near-duplicate messages
+type campaigns struct {
+	lock    sync.Mutex
+	entries []campaignEntry
+}
+
+// campaignEntry is a message kept for campaign detection
+type campaignEntry struct {
+	userID   string
+	userName string
+	hash     string         // hash of normalized message
+	tokens   map[string]int // tokenized message, used for similarity
+	ts       time.Time      // time the message was checked
+	campaign string         // id of the campaign, hash of the first message of the wave
+}
+
+// isCampaign checks if the message is a part of the spam wave, i.e. the same or nearly the same message was sent
+// by Campaign.MinUsers different users within Campaign.Window. Messages are near-duplicates if they have the same
+// normalized text or, with Campaign.Similarity set, the similarity of their tokens is above it. All messages of
+// the wave share the campaign id of the first one, details list every user of the campaign.
+// Short messages with less than Campaign.MinTokens distinct tokens, like "thanks!" or "hi all", are not counted,
+// as different users send them all the time.
+func (d *Detector) isCampaign(st *CheckState) spamcheck.Response {
+	now := time.Now()
+	tokens := st.model.tokenize(st.CleanMsg)
+	minTokens := d.Campaign.MinTokens
+	if minTokens <= 0 {
+		minTokens = defaultCampaignMinTokens
+	}
+	if len(tokens) < minTokens {
+		return spamcheck.Response{Name: "campaign", Spam: false,
+			Details: fmt.Sprintf("too short, %d/%d tokens", len(tokens), minTokens)}
+	}
+	entry := campaignEntry{userID: st.UserID, userName: st.UserName, hash: campaignHash(st.CleanMsg), tokens: tokens, ts: now}
+
+	d.campaigns.lock.Lock()
+	defer d.campaigns.lock.Unlock()
+
+	// drop messages out of the window, entries are sorted by time
+	idx := 0
+	for idx < len(d.campaigns.entries) && now.Sub(d.campaigns.entries[idx].ts) > d.Campaign.Window {
+		idx++
+	}
+	d.campaigns.entries = d.campaigns.entries[idx:]
+
+	users := []string{}
+	seen := map[string]bool{st.UserID: true}
+	for _, e := range d.campaigns.entries {
+		if !d.isNearDuplicate(entry, e) {
+			continue
+		}
+		if entry.campaign == "" {
+			entry.campaign = e.campaign
+		}
+		if !seen[e.userID] {
+			seen[e.userID] = true
+			users = append(users, campaignUser(e.userID, e.userName))
+		}
+	}
+	users = append(users, campaignUser(st.UserID, st.UserName))
+	if entry.campaign == "" {
+		entry.campaign = entry.hash[:8]
+	}
+
+	// check-only requests are not counted, e.g. admin checks of the message
+	if !st.CheckOnly {
+		d.campaigns.entries = append(d.campaigns.entries, entry)
+		if len(d.campaigns.entries) > maxCampaignEntries {
+			d.campaigns.entries = d.campaigns.entries[len(d.campaigns.entries)-maxCampaignEntries:]
+		}
+	}
+
+	if len(users) < d.Campaign.MinUsers {
+		return spamcheck.Response{Name: "campaign", Spam: false,
+			Details: fmt.Sprintf("%d/%d users within %v", len(users), d.Campaign.MinUsers, d.Campaign.Window)}
+	}
+	return spamcheck.Response{Name: "campaign", Spam: true, Details: fmt.Sprintf("campaign %s, %d users within %v: %s",
+		entry.campaign, len(users), d.Campaign.Window, strings.Join(users, ", "))}
+}
+
+// isNearDuplicate reports if two messages are the same or similar enough to be a part of the same campaign
+func (d *Detector) isNearDuplicate(a, b campaignEntry) bool {
+	if a.hash == b.hash {
+		return true
+	}
+	return d.Campaign.Similarity > 0 && d.cosineSimilarity(a.tokens, b.tokens) >= d.Campaign.Similarity
+}
+
+// campaignHash returns the hash of the message with case and spacing normalized
+func campaignHash(msg string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(msg)), " ")
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(normalized)))
+}
+
+// campaignUser returns the user for campaign details, as "name (id)" or just id if the name is not known
+func campaignUser(id, name string) string {
+	if name == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", name, id)
+}
@@ -0,0 +1,126 @@
+package tgspam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/approved"
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestDetector_CheckCampaign(t *testing.T) {
+	makeDetector := func(similarity float64) *Detector {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, FirstMessageOnly: true})
+		d.Campaign.Enabled = true
+		d.Campaign.MinUsers = 3
+		d.Campaign.Window = time.Minute
+		d.Campaign.Similarity = similarity
+		return d
+	}
+	campaignResult := func(cr []spamcheck.Response) spamcheck.Response {
+		for _, r := range cr {
+			if r.Name == "campaign" {
+				return r
+			}
+		}
+		return spamcheck.Response{}
+	}
+	msg := "Earn 500$ daily working from home, contact me in private messages"
+
+	t.Run("same text from different users", func(t *testing.T) {
+		d := makeDetector(0)
+		spam, cr := d.Check(spamcheck.Request{Msg: msg, UserID: "1", UserName: "user1", CheckOnly: true})
+		assert.False(t, spam)
+		assert.Equal(t, spamcheck.Response{Name: "campaign", Details: "1/3 users within 1m0s"}, campaignResult(cr))
+
+		spam, _ = d.Check(spamcheck.Request{Msg: msg, UserID: "1", UserName: "user1"})
+		assert.False(t, spam)
+		spam, _ = d.Check(spamcheck.Request{Msg: msg, UserID: "1", UserName: "user1"})
+		assert.False(t, spam, "same user is counted once")
+		spam, cr = d.Check(spamcheck.Request{Msg: "  EARN 500$ daily working  from home, contact me in private messages ",
+			UserID: "2", UserName: "user2"})
+		assert.False(t, spam)
+		assert.Equal(t, "2/3 users within 1m0s", campaignResult(cr).Details)
+
+		spam, cr = d.Check(spamcheck.Request{Msg: msg, UserID: "3"})
+		assert.True(t, spam)
+		res := campaignResult(cr)
+		assert.True(t, res.Spam)
+		assert.Equal(t, "campaign "+campaignHash(msg)[:8]+", 3 users within 1m0s: user1 (1), user2 (2), 3", res.Details)
+
+		spam, _ = d.Check(spamcheck.Request{Msg: "hello everyone, glad to join this group, nice to meet you", UserID: "4"})
+		assert.False(t, spam, "different message")
+	})
+
+	t.Run("similar text", func(t *testing.T) {
+		d := makeDetector(0.8)
+		spam, _ := d.Check(spamcheck.Request{Msg: msg, UserID: "1"})
+		assert.False(t, spam)
+		spam, _ = d.Check(spamcheck.Request{Msg: msg + " now", UserID: "2"})
+		assert.False(t, spam)
+		spam, cr := d.Check(spamcheck.Request{Msg: "Earn 500$ daily working from home, contact me in private", UserID: "3"})
+		assert.True(t, spam)
+		assert.Equal(t, "campaign "+campaignHash(msg)[:8]+", 3 users within 1m0s: 1, 2, 3", campaignResult(cr).Details)
+
+		d = makeDetector(0)
+		d.Check(spamcheck.Request{Msg: msg, UserID: "1"})
+		d.Check(spamcheck.Request{Msg: msg + " now", UserID: "2"})
+		spam, _ = d.Check(spamcheck.Request{Msg: msg, UserID: "3"})
+		assert.False(t, spam, "similarity is not used if not set")
+	})
+
+	t.Run("messages out of window", func(t *testing.T) {
+		d := makeDetector(0)
+		d.Check(spamcheck.Request{Msg: msg, UserID: "1"})
+		d.Check(spamcheck.Request{Msg: msg, UserID: "2"})
+		require.Len(t, d.campaigns.entries, 2)
+		d.campaigns.entries[0].ts = time.Now().Add(-2 * time.Minute)
+		spam, cr := d.Check(spamcheck.Request{Msg: msg, UserID: "3"})
+		assert.False(t, spam)
+		assert.Equal(t, "2/3 users within 1m0s", campaignResult(cr).Details)
+		assert.Len(t, d.campaigns.entries, 2)
+	})
+
+	t.Run("generic replies", func(t *testing.T) {
+		d := makeDetector(0.8)
+		for _, id := range []string{"1", "2", "3", "4"} {
+			spam, cr := d.Check(spamcheck.Request{Msg: "thanks!", UserID: id})
+			assert.False(t, spam)
+			assert.Equal(t, spamcheck.Response{Name: "campaign", Details: "too short, 1/3 tokens"}, campaignResult(cr))
+			spam, _ = d.Check(spamcheck.Request{Msg: "👍👍", UserID: id})
+			assert.False(t, spam, "no tokens")
+		}
+		assert.Empty(t, d.campaigns.entries, "short messages not kept")
+
+		d.Campaign.MinTokens = 4
+		for _, id := range []string{"5", "6", "7"} { // users sent clean messages before are approved, new ones used
+			spam, cr := d.Check(spamcheck.Request{Msg: "thanks a lot, guys", UserID: id})
+			assert.False(t, spam)
+			assert.Equal(t, "too short, 3/4 tokens", campaignResult(cr).Details)
+		}
+		assert.Empty(t, d.campaigns.entries)
+
+		d.Check(spamcheck.Request{Msg: msg, UserID: "8"})
+		d.Check(spamcheck.Request{Msg: msg, UserID: "9"})
+		spam, _ := d.Check(spamcheck.Request{Msg: msg, UserID: "10"})
+		assert.True(t, spam, "long enough messages counted")
+	})
+
+	t.Run("not applied", func(t *testing.T) {
+		d := makeDetector(0)
+		_, cr := d.Check(spamcheck.Request{Msg: msg})
+		assert.Empty(t, campaignResult(cr).Name, "no user id")
+
+		require.NoError(t, d.AddApprovedUser(approved.UserInfo{UserID: "10"}))
+		_, cr = d.Check(spamcheck.Request{Msg: msg, UserID: "10", Strict: true})
+		assert.Empty(t, campaignResult(cr).Name, "approved user")
+
+		d.Campaign.Enabled = false
+		_, cr = d.Check(spamcheck.Request{Msg: msg, UserID: "1"})
+		assert.Empty(t, campaignResult(cr).Name, "disabled")
+		assert.Empty(t, d.campaigns.entries)
+	})
+}
@@ -0,0 +1,380 @@
+package tgspam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// Phase defines the execution order of checks. Checks with lower phase are executed first.
+// Checks within the same phase are independent and executed concurrently,
+// their results are reported in the order of registration.
+type Phase int
+
+// enum of built-in phases. Custom checkers can use any value in between to position themselves.
+const (
+	PhaseText      Phase = 100 // cheap checks of the message text and user name, e.g. stop words and emojis
+	PhaseMeta      Phase = 200 // checks of the message meta-info, e.g. links, images and forwards
+	PhaseNetwork   Phase = 300 // checks calling external services about the user, e.g. CAS
+	PhaseHeuristic Phase = 400 // heuristics on the message text, e.g. multi-lingual words and abnormal spacing
+	PhaseModel     Phase = 500 // model-based checks, skipped for messages shorter than MinMsgLen
+	PhaseDecision  Phase = 600 // checks making the final decision based on the results of all previous checks, e.g. openai
+)
+
+// Checker is a single check executed by Detector. Built-in checks are registered on NewDetector,
+// custom checks can be added with Detector.WithCheckers and removed with Detector.RemoveChecker.
+// Each checker keeps its own configuration, Detector passes only the request and the results collected so far.
+// Check should respect ctx, it is canceled on the check's deadline or once the result is decided.
+type Checker interface {
+	Name() string                                                 // unique name of the check, used to replace or remove it
+	Phase() Phase                                                 // execution phase, cheap checks should go before expensive ones
+	Applies(st *CheckState) bool                                  // reports if the check should be executed for the given state
+	Check(ctx context.Context, st *CheckState) spamcheck.Response // performs the check
+}
+
+// CheckState is a state of a single Detector.Check call passed to each checker.
+type CheckState struct {
+	spamcheck.Request
+	CleanMsg string               // message with control and invisible characters removed
+	Results  []spamcheck.Response // results of all checks from the previous phases
+
+	model   *model                          // snapshot of the model taken at the start of the check
+	decide  func([]spamcheck.Response) bool // makes the decision by results, any spam result if not set
+	verdict *bool                           // final decision set by a deciding checker, overrides results
+}
+
+// SpamDetected reports if the spam is detected by the checks executed so far, with respect to Detector's scoring,
+// unless the decision was overridden with SetVerdict.
+func (st *CheckState) SpamDetected() bool {
+	if st.verdict != nil {
+		return *st.verdict
+	}
+	return st.spamIn(st.Results)
+}
+
+// spamIn makes the decision by the given results with respect to Detector's scoring, the verdict is not used
+func (st *CheckState) spamIn(results []spamcheck.Response) bool {
+	if st.decide != nil {
+		return st.decide(results)
+	}
+	for _, r := range results {
+		if r.Spam {
+			return true
+		}
+	}
+	return false
+}
+
+// Score returns the total score of the checks executed so far
+func (st *CheckState) Score() float64 {
+	return spamcheck.TotalScore(st.Results)
+}
+
+// SetVerdict overrides the decision made by the results of the checks. Used by checkers from PhaseDecision,
+// e.g. openai can veto spam detected by other checks.
+func (st *CheckState) SetVerdict(spam bool) {
+	st.verdict = &spam
+}
+
+// NewChecker makes a Checker from the given functions. If applies is nil, the check is always executed.
+func NewChecker(name string, phase Phase, applies func(st *CheckState) bool,
+	check func(ctx context.Context, st *CheckState) spamcheck.Response) Checker {
+	return &funcChecker{name: name, phase: phase, applies: applies, check: check}
+}
+
+// WithPhase returns a copy of the checker moved to another phase. Allows reordering of built-in checks.
+func WithPhase(c Checker, phase Phase) Checker {
+	return &phasedChecker{Checker: c, phase: phase}
+}
+
+// MetaChecker makes a Checker with the given name from MetaCheck. The name should match the name reported
+// by the check, e.g. "links" for LinksCheck, as weights and timeouts are set by the name of the checker.
+func MetaChecker(name string, mc MetaCheck) Checker {
+	return NewChecker(name, PhaseMeta, nil, func(_ context.Context, st *CheckState) spamcheck.Response { return mc(st.Request) })
+}
+
+// WithCheckers registers checkers. A checker with the same name as already registered one replaces it.
+func (d *Detector) WithCheckers(cs ...Checker) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, c := range cs {
+		d.registerChecker(c)
+	}
+}
+
+// RemoveChecker removes the checker with the given name. Returns false if no such checker registered.
+func (d *Detector) RemoveChecker(name string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	idx := slices.IndexFunc(d.checkers, func(c Checker) bool { return c.Name() == name })
+	if idx < 0 {
+		return false
+	}
+	d.checkers = slices.Delete(d.checkers, idx, idx+1)
+	return true
+}
+
+// Checkers returns the list of registered checkers in execution order.
+func (d *Detector) Checkers() []Checker {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return slices.Clone(d.checkers)
+}
+
+// registerChecker adds or replaces a checker and keeps the list sorted by phase, not thread-safe.
+func (d *Detector) registerChecker(c Checker) {
+	if idx := slices.IndexFunc(d.checkers, func(v Checker) bool { return v.Name() == c.Name() }); idx >= 0 {
+		d.checkers = slices.Delete(d.checkers, idx, idx+1)
+	}
+	d.checkers = append(d.checkers, c)
+	slices.SortStableFunc(d.checkers, func(a, b Checker) int { return int(a.Phase() - b.Phase()) })
+}
+
+// builtinCheckers returns the list of checkers wrapping the built-in checks.
+// All of them read the detector's Config on each call, so changes of Config made after NewDetector are respected.
+func (d *Detector) builtinCheckers() []Checker {
+	return []Checker{
+		NewChecker("stopword", PhaseText,
+			func(st *CheckState) bool { return len(st.model.stopWords) > 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.isStopWord(st.model, st.CleanMsg, st.Request)
+			}),
+		NewChecker("emoji", PhaseText,
+			func(*CheckState) bool { return d.MaxAllowedEmoji >= 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isManyEmojis(st.Msg) }),
+		NewChecker("profile-stopword", PhaseText,
+			func(st *CheckState) bool { return d.profileApplies(st) && len(st.model.stopWords) > 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.checkProfile("profile-stopword", st.Request, func(text string) spamcheck.Response {
+					return d.isStopWord(st.model, d.cleanText(text), spamcheck.Request{})
+				})
+			}),
+		NewChecker("profile-emoji", PhaseText,
+			func(st *CheckState) bool { return d.profileApplies(st) && d.MaxAllowedEmoji >= 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.checkProfile("profile-emoji", st.Request, d.isManyEmojis)
+			}),
+		NewChecker("cas", PhaseNetwork,
+			func(*CheckState) bool { return d.CasAPI != "" },
+			func(ctx context.Context, st *CheckState) spamcheck.Response { return d.isCasSpam(ctx, st.UserID) }),
+		NewChecker("domain", PhaseNetwork,
+			func(*CheckState) bool { return d.Domains.Enabled },
+			func(ctx context.Context, st *CheckState) spamcheck.Response {
+				return d.isDeniedDomain(ctx, st.model, st.Request)
+			}),
+		NewChecker("mention-target", PhaseNetwork,
+			func(*CheckState) bool { return d.Mentions.Enabled && d.mentionResolver() != nil },
+			func(ctx context.Context, st *CheckState) spamcheck.Response {
+				return d.isSuspiciousMention(ctx, st.Request)
+			}),
+		NewChecker("multi-lingual", PhaseHeuristic,
+			func(*CheckState) bool { return d.MultiLangWords > 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isMultiLang(st.Msg) }),
+		NewChecker("profile-multi-lingual", PhaseHeuristic,
+			func(st *CheckState) bool { return d.profileApplies(st) && d.MultiLangWords > 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.checkProfile("profile-multi-lingual", st.Request, d.isMultiLang)
+			}),
+		NewChecker("obfuscation", PhaseHeuristic,
+			func(*CheckState) bool { return d.Normalize.Obfuscation },
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isObfuscated(st.CleanMsg) }),
+		NewChecker("formatting", PhaseHeuristic,
+			func(*CheckState) bool { return d.Formatting.Enabled },
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isFormattingTrick(st.Msg) }),
+		NewChecker("word-spacing", PhaseHeuristic,
+			func(*CheckState) bool { return d.AbnormalSpacing.Enabled },
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isAbnormalSpacing(st.Msg) }),
+		NewChecker("similarity", PhaseModel,
+			func(st *CheckState) bool { return d.SimilarityThreshold > 0 && len(st.model.tokenizedSpam) > 0 },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.isSpamSimilarityHigh(st.model, st.CleanMsg)
+			}),
+		NewChecker("classifier", PhaseModel,
+			func(st *CheckState) bool { return st.model.classifierReady() },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.isSpamClassified(st.model, st.CleanMsg)
+			}),
+		NewChecker("campaign", PhaseModel,
+			func(st *CheckState) bool {
+				return d.Campaign.Enabled && d.Campaign.MinUsers > 1 && st.UserID != "" && !d.IsApprovedUser(st.UserID)
+			},
+			func(_ context.Context, st *CheckState) spamcheck.Response { return d.isCampaign(st) }),
+		NewChecker("profile-classifier", PhaseModel,
+			func(st *CheckState) bool { return d.profileApplies(st) && st.model.classifierReady() },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				return d.checkProfile("profile-classifier", st.Request, func(text string) spamcheck.Response {
+					return d.isSpamClassified(st.model, d.cleanText(text))
+				})
+			}),
+		NewChecker("openai", PhaseDecision, d.openAIApplies, d.openAICheck),
+	}
+}
+
+// openAIApplies reports if openai should be called. We hit openai in two cases:
+//   - all other checks passed (ham result) and OpenAIVeto is false. In this case, openai primary used to improve false negative rate
+//   - one of the checks failed (spam result) and OpenAIVeto is true. In this case, openai primary used to improve false positive rate
+//
+// FirstMessageOnly or FirstMessagesCount has to be set to use openai, because it's slow and expensive to run on all messages
+func (d *Detector) openAIApplies(st *CheckState) bool {
+	if d.openaiChecker == nil || !d.FirstMessageOnly && d.FirstMessagesCount == 0 {
+		return false
+	}
+	spamDetected := st.SpamDetected()
+	return !spamDetected && !d.OpenAIVeto || spamDetected && d.OpenAIVeto
+}
+
+// openAICheck calls openai and overrides the verdict with its result
+func (d *Detector) openAICheck(ctx context.Context, st *CheckState) spamcheck.Response {
+	var hist []spamcheck.Request // by default, openai doesn't use history
+	if d.OpenAIHistorySize > 0 && d.HistorySize > 0 {
+		// if history size is set, we use the last N messages for openai
+		hist = d.hamHistory.Last(d.OpenAIHistorySize)
+	}
+	spamDetected := st.SpamDetected()
+	spam, details := d.openaiChecker.check(ctx, st.CleanMsg, hist)
+	if spamDetected && details.Error != nil {
+		// spam detected with other checks, but openai failed. in this case, we still return spam, but log the error
+		log.Printf("[WARN] openai error: %v", details.Error)
+	} else {
+		log.Printf("[DEBUG] openai result: {%s}", details.String())
+		st.SetVerdict(spam)
+	}
+
+	// log if veto is enabled, and openai detected no spam for message that was detected as spam by other checks
+	if d.OpenAIVeto && !spam {
+		log.Printf("[DEBUG] openai vetoed ham message: %q, checks: %s", st.Msg, spamcheck.ChecksToString(append(st.Results, details)))
+	}
+	return details
+}
+
+// runPhase executes all applicable checkers of a single phase concurrently and returns their results
+// in the order of registration. If StopOnSpam is set, the rest of a non-decision phase is canceled once spam
+// is decided by the results collected so far, with respect to scoring threshold and hard checks.
+// The canceled checks are not reported.
+func (d *Detector) runPhase(ctx context.Context, checkers []Checker, st *CheckState) []spamcheck.Response {
+	applied := make([]Checker, 0, len(checkers))
+	for _, c := range checkers {
+		if c.Applies(st) {
+			applied = append(applied, c)
+		}
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var decided atomic.Bool
+	var mu sync.Mutex // guards finished
+	finished := slices.Clip(st.Results)
+	results := make([]spamcheck.Response, len(applied))
+	states := make([]*CheckState, len(applied))
+	for i, c := range applied {
+		states[i] = st.clone() // each check gets its own copy of the state to avoid races on verdict
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = d.runCheck(ctx, c, states[i])
+			if !d.StopOnSpam || c.Phase() >= PhaseDecision {
+				return
+			}
+			mu.Lock()
+			finished = append(finished, results[i])
+			spam := results[i].Spam && st.spamIn(finished)
+			mu.Unlock()
+			if spam {
+				decided.Store(true)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	res := make([]spamcheck.Response, 0, len(results))
+	for i, r := range results {
+		if decided.Load() && errors.Is(r.Error, context.Canceled) {
+			continue // canceled because the result is already decided
+		}
+		if states[i].verdict != nil {
+			st.verdict = states[i].verdict
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// runCheck executes a single check with its deadline. The check is abandoned if it doesn't
+// respond in time, even if it ignores ctx, and the response marked as timed out.
+func (d *Detector) runCheck(ctx context.Context, c Checker, st *CheckState) spamcheck.Response {
+	timeout := d.CheckTimeout
+	if t, ok := d.CheckTimeouts[c.Name()]; ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	resCh := make(chan spamcheck.Response, 1) // buffered, abandoned check should not block
+	go func() { resCh <- c.Check(ctx, st) }()
+
+	select {
+	case r := <-resCh:
+		// checks not reporting the score get the full score for spam
+		if r.Spam && r.Score == 0 {
+			r.Score = 1
+		}
+		if w, ok := d.Scoring.Weights[c.Name()]; ok {
+			r.Score *= w
+		}
+		return r
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Printf("[WARN] check %q timed out after %v", c.Name(), time.Since(start).Round(time.Millisecond))
+			return spamcheck.Response{Name: c.Name(), Spam: false, TimedOut: true,
+				Details: fmt.Sprintf("timeout after %v", timeout), Error: ctx.Err()}
+		}
+		return spamcheck.Response{Name: c.Name(), Spam: false, Details: "canceled", Error: ctx.Err()}
+	}
+}
+
+// clone makes a copy of the state. Results are clipped, so appends to the copy don't affect the original.
+func (st *CheckState) clone() *CheckState {
+	res := *st
+	res.Results = slices.Clip(st.Results)
+	return &res
+}
+
+type funcChecker struct {
+	name    string
+	phase   Phase
+	applies func(st *CheckState) bool
+	check   func(ctx context.Context, st *CheckState) spamcheck.Response
+}
+
+func (c *funcChecker) Name() string { return c.name }
+func (c *funcChecker) Phase() Phase { return c.phase }
+func (c *funcChecker) Applies(st *CheckState) bool {
+	return c.applies == nil || c.applies(st)
+}
+func (c *funcChecker) Check(ctx context.Context, st *CheckState) spamcheck.Response {
+	return c.check(ctx, st)
+}
+
+type phasedChecker struct {
+	Checker
+	phase Phase
+}
+
+func (c *phasedChecker) Phase() Phase { return c.phase }
@@ -0,0 +1,290 @@
+package tgspam
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestDetector_Checkers(t *testing.T) {
+	d := NewDetector(Config{})
+	names := func() []string {
+		res := []string{}
+		for _, c := range d.Checkers() {
+			res = append(res, c.Name())
+		}
+		return res
+	}
+	assert.Equal(t, []string{"stopword", "emoji", "profile-stopword", "profile-emoji", "cas", "domain", "mention-target",
+		"multi-lingual", "profile-multi-lingual", "obfuscation", "formatting", "word-spacing", "similarity", "classifier",
+		"campaign", "profile-classifier", "openai"}, names())
+
+	t.Run("meta-checks registered in meta phase", func(t *testing.T) {
+		d.WithCheckers(MetaChecker("links", LinksCheck(1)), MetaChecker("images", ImagesCheck()))
+		assert.Equal(t, []string{"stopword", "emoji", "profile-stopword", "profile-emoji", "links", "images", "cas", "domain",
+			"mention-target", "multi-lingual", "profile-multi-lingual", "obfuscation", "formatting", "word-spacing", "similarity",
+			"classifier", "campaign", "profile-classifier", "openai"}, names())
+	})
+
+	t.Run("remove checker", func(t *testing.T) {
+		assert.True(t, d.RemoveChecker("images"))
+		assert.False(t, d.RemoveChecker("images"))
+		assert.NotContains(t, names(), "images")
+	})
+
+	t.Run("replace checker with the same name keeps single instance", func(t *testing.T) {
+		d.WithCheckers(NewChecker("links", PhaseMeta, nil, func(context.Context, *CheckState) spamcheck.Response {
+			return spamcheck.Response{Name: "links", Details: "custom"}
+		}))
+		assert.Equal(t, []string{"stopword", "emoji", "profile-stopword", "profile-emoji", "links", "cas", "domain", "mention-target",
+			"multi-lingual", "profile-multi-lingual", "obfuscation", "formatting", "word-spacing", "similarity", "classifier",
+			"campaign", "profile-classifier", "openai"}, names())
+	})
+
+	t.Run("reorder checker", func(t *testing.T) {
+		for _, c := range d.Checkers() {
+			if c.Name() == "emoji" {
+				d.WithCheckers(WithPhase(c, PhaseHeuristic+1))
+			}
+		}
+		assert.Equal(t, []string{"stopword", "profile-stopword", "profile-emoji", "links", "cas", "domain", "mention-target",
+			"multi-lingual", "profile-multi-lingual", "obfuscation", "formatting", "word-spacing", "emoji", "similarity",
+			"classifier", "campaign", "profile-classifier", "openai"}, names())
+	})
+}
+
+func TestDetector_CheckWithCustomChecker(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1, MinMsgLen: 10})
+	_, err := d.LoadStopWords(bytes.NewBufferString("bad phrase"))
+	require.NoError(t, err)
+
+	var seen []spamcheck.Response
+	d.WithCheckers(
+		NewChecker("custom", PhaseHeuristic,
+			func(st *CheckState) bool { return st.UserName != "skip" },
+			func(_ context.Context, st *CheckState) spamcheck.Response {
+				seen = st.Results
+				return spamcheck.Response{Name: "custom", Spam: strings.Contains(st.CleanMsg, "forbidden")}
+			}),
+		NewChecker("custom-model", PhaseModel, nil, func(context.Context, *CheckState) spamcheck.Response {
+			return spamcheck.Response{Name: "custom-model", Details: "called"}
+		}),
+	)
+
+	t.Run("ham with custom checks", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "some long enough message", UserName: "user"})
+		assert.False(t, spam)
+		require.Len(t, cr, 3)
+		assert.Equal(t, "stopword", cr[0].Name)
+		assert.Equal(t, "custom", cr[1].Name)
+		assert.Equal(t, "custom-model", cr[2].Name)
+		require.Len(t, seen, 1, "custom checker gets results of the previous checks")
+		assert.Equal(t, "stopword", seen[0].Name)
+	})
+
+	t.Run("spam with custom check", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "some forbidden message", UserName: "user"})
+		assert.True(t, spam)
+		require.Len(t, cr, 3)
+		assert.Equal(t, spamcheck.Response{Name: "custom", Spam: true, Score: 1}, cr[1])
+	})
+
+	t.Run("custom check not applied", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "some forbidden message", UserName: "skip"})
+		assert.False(t, spam)
+		require.Len(t, cr, 2)
+		assert.Equal(t, "stopword", cr[0].Name)
+		assert.Equal(t, "custom-model", cr[1].Name)
+	})
+
+	t.Run("short message skips model phase", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "forbidden", UserName: "user"})
+		assert.True(t, spam)
+		require.Len(t, cr, 3)
+		assert.Equal(t, "custom", cr[1].Name)
+		assert.Equal(t, "message length", cr[2].Name)
+	})
+}
+
+func TestCheckState_SpamDetected(t *testing.T) {
+	st := &CheckState{}
+	assert.False(t, st.SpamDetected())
+	st.Results = append(st.Results, spamcheck.Response{Name: "a"}, spamcheck.Response{Name: "b", Spam: true})
+	assert.True(t, st.SpamDetected())
+	st.SetVerdict(false)
+	assert.False(t, st.SpamDetected(), "verdict overrides results")
+}
+
+func TestMetaChecker(t *testing.T) {
+	calls := 0
+	c := MetaChecker("links", func(req spamcheck.Request) spamcheck.Response {
+		calls++
+		return spamcheck.Response{Name: "links", Spam: req.Meta.Links > 1}
+	})
+	assert.Equal(t, "links", c.Name())
+	assert.Equal(t, PhaseMeta, c.Phase())
+	assert.True(t, c.Applies(&CheckState{}))
+	assert.Equal(t, 0, calls, "check is not called to make the checker")
+
+	resp := c.Check(context.Background(), &CheckState{Request: spamcheck.Request{Meta: spamcheck.MetaData{Links: 2}}})
+	assert.True(t, resp.Spam)
+	assert.Equal(t, 1, calls)
+
+	t.Run("registered with WithMetaChecks", func(t *testing.T) {
+		d := NewDetector(Config{})
+		d.WithMetaChecks(LinksCheck(1), ImagesCheck())
+		d.WithMetaChecks(VideosCheck())
+		names := []string{}
+		for _, c := range d.Checkers() {
+			if c.Phase() == PhaseMeta {
+				names = append(names, c.Name())
+			}
+		}
+		assert.Equal(t, []string{"meta-1", "meta-2", "meta-3"}, names)
+	})
+}
+
+func TestDetector_CheckContext(t *testing.T) {
+	slowCheck := func(name string, delay time.Duration, spam bool) Checker {
+		return NewChecker(name, PhaseNetwork, nil, func(ctx context.Context, _ *CheckState) spamcheck.Response {
+			select {
+			case <-time.After(delay):
+				return spamcheck.Response{Name: name, Spam: spam, Details: "done"}
+			case <-ctx.Done():
+				return spamcheck.Response{Name: name, Details: "aborted", Error: ctx.Err()}
+			}
+		})
+	}
+
+	t.Run("checks of the same phase run concurrently", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1})
+		d.WithCheckers(slowCheck("slow1", 100*time.Millisecond, false), slowCheck("slow2", 100*time.Millisecond, false))
+		st := time.Now()
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 190*time.Millisecond)
+		assert.False(t, spam)
+		require.Len(t, cr, 2)
+		assert.Equal(t, "slow1", cr[0].Name)
+		assert.Equal(t, "slow2", cr[1].Name)
+	})
+
+	t.Run("per-check timeout", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, CheckTimeout: time.Second,
+			CheckTimeouts: map[string]time.Duration{"slow": 50 * time.Millisecond}})
+		d.WithCheckers(slowCheck("slow", time.Second, true), slowCheck("fast", 10*time.Millisecond, false))
+		st := time.Now()
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 500*time.Millisecond)
+		assert.False(t, spam)
+		require.Len(t, cr, 2)
+		assert.Equal(t, "slow", cr[0].Name)
+		assert.True(t, cr[0].TimedOut)
+		assert.Equal(t, "timeout after 50ms", cr[0].Details)
+		require.ErrorIs(t, cr[0].Error, context.DeadlineExceeded)
+		assert.Equal(t, spamcheck.Response{Name: "fast", Details: "done"}, cr[1])
+	})
+
+	t.Run("timeout for check ignoring context", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, CheckTimeout: 50 * time.Millisecond})
+		d.WithCheckers(NewChecker("stuck", PhaseText, nil, func(context.Context, *CheckState) spamcheck.Response {
+			time.Sleep(300 * time.Millisecond)
+			return spamcheck.Response{Name: "stuck", Spam: true}
+		}))
+		st := time.Now()
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 250*time.Millisecond)
+		assert.False(t, spam)
+		require.Len(t, cr, 1)
+		assert.True(t, cr[0].TimedOut)
+	})
+
+	t.Run("stop on spam", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: 0, StopOnSpam: true})
+		d.WithCheckers(slowCheck("slow", time.Second, false), slowCheck("spam", 10*time.Millisecond, true),
+			WithPhase(slowCheck("later", 10*time.Millisecond, false), PhaseHeuristic))
+		st := time.Now()
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 500*time.Millisecond)
+		assert.True(t, spam)
+		require.Len(t, cr, 2, "slow check canceled, later phase skipped")
+		assert.Equal(t, "emoji", cr[0].Name)
+		assert.Equal(t, spamcheck.Response{Name: "spam", Spam: true, Details: "done", Score: 1}, cr[1])
+
+		spam, cr = d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message 😀"})
+		assert.True(t, spam)
+		require.Len(t, cr, 1, "spam detected by the first phase")
+		assert.Equal(t, "emoji", cr[0].Name)
+	})
+
+	t.Run("stop on spam with scoring", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, StopOnSpam: true})
+		d.Scoring.Threshold = 1.5
+		d.Scoring.Weights = map[string]float64{"weak": 0.5}
+		d.Scoring.HardChecks = []string{"hard"}
+		d.WithCheckers(slowCheck("weak", 10*time.Millisecond, true), slowCheck("hard", 100*time.Millisecond, true))
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.True(t, spam, cr)
+		require.Len(t, cr, 2, "weak spam below threshold doesn't cancel the hard check")
+		assert.Equal(t, spamcheck.Response{Name: "weak", Spam: true, Details: "done", Score: 0.5}, cr[0])
+		assert.Equal(t, spamcheck.Response{Name: "hard", Spam: true, Details: "done", Score: 1}, cr[1])
+
+		d.WithCheckers(slowCheck("weak", time.Second, true), slowCheck("hard", 10*time.Millisecond, true))
+		st := time.Now()
+		spam, cr = d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 500*time.Millisecond)
+		assert.True(t, spam, cr)
+		require.Len(t, cr, 1, "hard check cancels the rest of the phase")
+		assert.Equal(t, "hard", cr[0].Name)
+
+		d.WithCheckers(slowCheck("weak", 10*time.Millisecond, true), slowCheck("weak2", 20*time.Millisecond, true),
+			slowCheck("hard", time.Second, false))
+		st = time.Now()
+		spam, cr = d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message"})
+		assert.Less(t, time.Since(st), 500*time.Millisecond)
+		assert.True(t, spam, cr)
+		require.Len(t, cr, 2, "threshold reached, the rest of the phase canceled")
+		assert.InDelta(t, 1.5, spamcheck.TotalScore(cr), 0.001)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1})
+		d.WithCheckers(slowCheck("slow", time.Second, true))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		spam, cr := d.CheckContext(ctx, spamcheck.Request{Msg: "some message"})
+		assert.False(t, spam)
+		require.Len(t, cr, 1)
+		assert.Equal(t, "slow", cr[0].Name)
+		require.Error(t, cr[0].Error)
+	})
+
+	t.Run("user not approved if checks interrupted", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, FirstMessagesCount: 3, CheckTimeout: 50 * time.Millisecond})
+		d.WithCheckers(slowCheck("slow", time.Second, true))
+		spam, cr := d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message", UserID: "123"})
+		assert.False(t, spam)
+		require.Len(t, cr, 1)
+		assert.True(t, cr[0].TimedOut)
+		assert.Empty(t, d.ApprovedUsers(), "timed out check doesn't approve")
+
+		d.CheckTimeout = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		spam, _ = d.CheckContext(ctx, spamcheck.Request{Msg: "some message", UserID: "123"})
+		assert.False(t, spam)
+		assert.Empty(t, d.ApprovedUsers(), "canceled check doesn't approve")
+
+		require.True(t, d.RemoveChecker("slow"))
+		spam, _ = d.CheckContext(context.Background(), spamcheck.Request{Msg: "some message", UserID: "123"})
+		assert.False(t, spam)
+		require.Len(t, d.ApprovedUsers(), 1)
+		assert.Equal(t, 1, d.ApprovedUsers()[0].Count)
+	})
+}
@@ -2,7 +2,9 @@ package tgspam
 
 import (
 	"fmt"
+	"maps"
 	"math"
+	"sort"
 )
 
 // based on the code from https://github.com/RadhiFadlillah/go-bayesian/blob/master/classifier.go
@@ -37,6 +39,8 @@ type classifier struct {
 	nDocumentByClass   map[spamClass]int
 	nFrequencyByClass  map[spamClass]int
 	nAllDocument       int
+	pruned             map[string]map[spamClass]int // counts of n-grams removed by prune, to unlearn documents learned before
+	prunedLost         bool                         // some pruned n-grams not kept in pruned, as the limit reached
 }
 
 // newClassifier returns new classifier
@@ -46,6 +50,7 @@ func newClassifier() classifier {
 		priorProbabilities: make(map[spamClass]float64),
 		nDocumentByClass:   make(map[spamClass]int),
 		nFrequencyByClass:  make(map[spamClass]int),
+		pruned:             make(map[string]map[spamClass]int),
 	}
 }
 
@@ -90,6 +95,9 @@ func (c *classifier) unlearn(docs ...document) error {
 		tokens := c.removeDuplicate(doc.tokens...)
 
 		for _, token := range tokens {
+			if c.learningResults[token][doc.spamClass] <= 0 && c.unprune(token, doc.spamClass) {
+				continue // n-gram pruned after learning, nothing to unlearn
+			}
 			if c.nFrequencyByClass[doc.spamClass] <= 0 {
 				return fmt.Errorf("no tokens of class %v to unlearn", doc.spamClass)
 			}
@@ -123,6 +131,80 @@ func (c *classifier) unlearn(docs ...document) error {
 	return nil
 }
 
+// prune removes rare n-gram features: learned from fewer than minCount documents, and the least frequent ones
+// above maxFeatures n-grams, 0 - no limit. Single tokens are never pruned. Returns the number of removed features.
+// Counts of pruned n-grams are kept, up to maxFeatures n-grams, so documents learned before pruning can be unlearned.
+// Pruned n-grams can be learned again.
+func (c *classifier) prune(minCount, maxFeatures int) int {
+	type feature struct {
+		token string
+		count int
+	}
+	ngrams := []feature{}
+	for token, freq := range c.learningResults {
+		if !isNGram(token) {
+			continue
+		}
+		count := 0
+		for _, n := range freq {
+			count += n
+		}
+		ngrams = append(ngrams, feature{token: token, count: count})
+	}
+
+	// most frequent first, ties ordered by token to keep pruning deterministic
+	sort.Slice(ngrams, func(i, j int) bool {
+		if ngrams[i].count != ngrams[j].count {
+			return ngrams[i].count > ngrams[j].count
+		}
+		return ngrams[i].token < ngrams[j].token
+	})
+
+	removed := 0
+	for i, f := range ngrams {
+		if f.count >= minCount && (maxFeatures <= 0 || i < maxFeatures) {
+			continue
+		}
+		for class, n := range c.learningResults[f.token] {
+			c.nFrequencyByClass[class] -= n
+		}
+		c.keepPruned(f.token, c.learningResults[f.token], maxFeatures)
+		delete(c.learningResults, f.token)
+		removed++
+	}
+	return removed
+}
+
+// keepPruned adds counts of the pruned n-gram to pruned, unless the limit of pruned n-grams reached, 0 - no limit
+func (c *classifier) keepPruned(token string, freq map[spamClass]int, limit int) {
+	if _, ok := c.pruned[token]; !ok && limit > 0 && len(c.pruned) >= limit {
+		c.prunedLost = true
+		return
+	}
+	if c.pruned[token] == nil {
+		c.pruned[token] = make(map[spamClass]int, len(freq))
+	}
+	for class, n := range freq {
+		c.pruned[token][class] += n
+	}
+}
+
+// unprune removes a document of the class from counts of the pruned n-gram. Returns false if the token wasn't pruned.
+// If some pruned n-grams were not kept, any n-gram is considered pruned, as it can't be told.
+func (c *classifier) unprune(token string, class spamClass) bool {
+	if c.pruned[token][class] <= 0 {
+		return c.prunedLost && isNGram(token)
+	}
+	c.pruned[token][class]--
+	if c.pruned[token][class] == 0 {
+		delete(c.pruned[token], class)
+	}
+	if len(c.pruned[token]) == 0 {
+		delete(c.pruned, token)
+	}
+	return true
+}
+
 // reset resets all learning results
 func (c *classifier) reset() {
 	c.learningResults = make(map[string]map[spamClass]int)
@@ -130,6 +212,28 @@ func (c *classifier) reset() {
 	c.nDocumentByClass = make(map[spamClass]int)
 	c.nFrequencyByClass = make(map[spamClass]int)
 	c.nAllDocument = 0
+	c.pruned = make(map[string]map[spamClass]int)
+	c.prunedLost = false
+}
+
+// clone returns a deep copy of the classifier, changes of the copy don't affect the original
+func (c *classifier) clone() classifier {
+	res := classifier{
+		learningResults:    make(map[string]map[spamClass]int, len(c.learningResults)),
+		priorProbabilities: maps.Clone(c.priorProbabilities),
+		nDocumentByClass:   maps.Clone(c.nDocumentByClass),
+		nFrequencyByClass:  maps.Clone(c.nFrequencyByClass),
+		nAllDocument:       c.nAllDocument,
+		pruned:             make(map[string]map[spamClass]int, len(c.pruned)),
+		prunedLost:         c.prunedLost,
+	}
+	for token, freq := range c.learningResults {
+		res.learningResults[token] = maps.Clone(freq)
+	}
+	for token, freq := range c.pruned {
+		res.pruned[token] = maps.Clone(freq)
+	}
+	return res
 }
 
 // classify executes the classifying process for tokens
@@ -169,6 +273,46 @@ func (c *classifier) classify(tokens ...string) (spamClass, float64, bool) {
 	return bestClass, highestProb, certain
 }
 
+// tokenWeight is a token with its log-likelihood ratio of spam to ham
+type tokenWeight struct {
+	token  string
+	weight float64
+}
+
+// topTokens returns up to n learned tokens contributing the most to the class, ordered by the contribution.
+// The weight is log-likelihood ratio of spam to ham, smoothed the same way as classify does, positive for spam.
+// Tokens not learned, or favoring the other class, are not returned.
+func (c *classifier) topTokens(class spamClass, n int, tokens ...string) []tokenWeight {
+	nVocabulary := len(c.learningResults)
+	likelihood := func(freq map[spamClass]int, class spamClass) float64 {
+		return math.Log(float64(freq[class]+1) / float64(c.nFrequencyByClass[class]+nVocabulary))
+	}
+	res := []tokenWeight{}
+	for _, token := range c.removeDuplicate(tokens...) {
+		freq, ok := c.learningResults[token]
+		if !ok {
+			continue
+		}
+		llr := likelihood(freq, ClassSpam) - likelihood(freq, ClassHam)
+		if (class == ClassSpam && llr > 0) || (class == ClassHam && llr < 0) {
+			res = append(res, tokenWeight{token: token, weight: llr})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].weight != res[j].weight {
+			if class == ClassHam {
+				return res[i].weight < res[j].weight
+			}
+			return res[i].weight > res[j].weight
+		}
+		return res[i].token < res[j].token
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
 func (c *classifier) removeDuplicate(tokens ...string) []string {
 	mapTokens := make(map[string]struct{})
 	newTokens := []string{}
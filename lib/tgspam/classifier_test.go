@@ -328,3 +328,124 @@ func TestSoftmax(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifier_Clone(t *testing.T) {
+	c := newClassifier()
+	c.learn(newDocument(ClassSpam, "win", "free"), newDocument(ClassHam, "hello", "world"))
+
+	cl := c.clone()
+	assert.Equal(t, c, cl)
+
+	cl.learn(newDocument(ClassSpam, "win", "prize"))
+	assert.Equal(t, 2, c.nAllDocument, "original not changed")
+	assert.Equal(t, 1, c.learningResults["win"][ClassSpam], "original not changed")
+	assert.NotContains(t, c.learningResults, "prize")
+	assert.Equal(t, 3, cl.nAllDocument)
+	assert.Equal(t, 2, cl.learningResults["win"][ClassSpam])
+}
+
+func TestClassifier_Prune(t *testing.T) {
+	c := newClassifier()
+	c.learn(
+		newDocument(ClassSpam, "passive", "income", "passive income", "#pas"),
+		newDocument(ClassSpam, "passive", "income", "passive income", "income now"),
+		newDocument(ClassHam, "passive", "voice", "passive voice", "#pas"),
+	)
+	assert.Equal(t, 8, c.nFrequencyByClass[ClassSpam])
+
+	removed := c.prune(2, 0)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, map[string]map[spamClass]int{
+		"passive": {ClassSpam: 2, ClassHam: 1}, "income": {ClassSpam: 2}, "voice": {ClassHam: 1},
+		"passive income": {ClassSpam: 2}, "#pas": {ClassSpam: 1, ClassHam: 1},
+	}, c.learningResults)
+	assert.Equal(t, 7, c.nFrequencyByClass[ClassSpam])
+	assert.Equal(t, 3, c.nFrequencyByClass[ClassHam])
+
+	t.Run("max features", func(t *testing.T) {
+		c := c.clone()
+		assert.Equal(t, 1, c.prune(0, 1))
+		assert.Contains(t, c.learningResults, "#pas", "ties ordered by token")
+		assert.NotContains(t, c.learningResults, "passive income")
+		assert.Equal(t, 5, c.nFrequencyByClass[ClassSpam])
+	})
+
+	t.Run("unlearn pruned", func(t *testing.T) {
+		c := c.clone()
+		err := c.unlearn(newDocument(ClassSpam, "passive", "income", "passive income", "income now"))
+		require.NoError(t, err)
+		assert.Equal(t, 4, c.nFrequencyByClass[ClassSpam])
+		assert.Equal(t, 1, c.learningResults["passive income"][ClassSpam])
+
+		cc := c.clone()
+		err = cc.unlearn(newDocument(ClassHam, "passive income"))
+		require.EqualError(t, err, `token "passive income" not found in class ham`, "only pruned n-grams are skipped")
+
+		err = c.unlearn(newDocument(ClassHam, "passive", "unknown"))
+		require.EqualError(t, err, `token "unknown" not found in class ham`, "missing tokens are still errors")
+	})
+
+	t.Run("learn pruned", func(t *testing.T) {
+		c := c.clone()
+		c.learn(newDocument(ClassSpam, "income", "income now", "income today"))
+		assert.Equal(t, 1, c.learningResults["income now"][ClassSpam], "pruned n-gram learned again")
+		assert.Equal(t, 10, c.nFrequencyByClass[ClassSpam])
+
+		// the document learned after pruning is unlearned from learned counts, the one before from pruned counts
+		require.NoError(t, c.unlearn(newDocument(ClassSpam, "income", "income now", "income today")))
+		assert.NotContains(t, c.learningResults, "income now")
+		assert.Equal(t, map[spamClass]int{ClassSpam: 1}, c.pruned["income now"])
+		require.NoError(t, c.unlearn(newDocument(ClassSpam, "passive", "income", "passive income", "income now")))
+		assert.NotContains(t, c.pruned, "income now", "pruned counts dropped with the document")
+		assert.Equal(t, 4, c.nFrequencyByClass[ClassSpam])
+		err := c.unlearn(newDocument(ClassSpam, "passive", "income", "passive income", "income now"))
+		require.EqualError(t, err, `token "income now" not found in class spam`)
+	})
+
+	t.Run("pruned limit", func(t *testing.T) {
+		c := c.clone()
+		assert.Len(t, c.pruned, 2)
+		assert.Equal(t, 1, c.prune(0, 1))
+		assert.Len(t, c.pruned, 2, "pruned n-grams above the limit not kept")
+		assert.NotContains(t, c.pruned, "passive income")
+		assert.True(t, c.prunedLost)
+
+		cc := c.clone()
+		require.NoError(t, cc.unlearn(newDocument(ClassHam, "passive", "unknown n-gram")), "any n-gram considered pruned")
+		require.EqualError(t, c.unlearn(newDocument(ClassHam, "passive", "unknown")), `token "unknown" not found in class ham`)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		c := c.clone()
+		c.reset()
+		c.learn(newDocument(ClassSpam, "income now"))
+		assert.Equal(t, 1, c.learningResults["income now"][ClassSpam])
+	})
+}
+
+func TestClassifier_TopTokens(t *testing.T) {
+	c := newClassifier()
+	c.learn(
+		newDocument(ClassSpam, "passive", "income", "now"),
+		newDocument(ClassSpam, "passive", "income", "crypto"),
+		newDocument(ClassHam, "passive", "voice", "now"),
+		newDocument(ClassHam, "hello", "voice"),
+	)
+
+	spamTokens := c.topTokens(ClassSpam, 2, "income", "crypto", "passive", "voice", "unknown", "income")
+	require.Len(t, spamTokens, 2)
+	assert.Equal(t, "income", spamTokens[0].token)
+	assert.Equal(t, "crypto", spamTokens[1].token)
+	assert.InDelta(t, math.Log(3.0/12)-math.Log(1.0/11), spamTokens[0].weight, 0.0001) // 6 tokens in vocabulary
+	assert.Greater(t, spamTokens[0].weight, spamTokens[1].weight)
+
+	hamTokens := c.topTokens(ClassHam, 5, "income", "crypto", "passive", "voice", "hello", "now")
+	require.Len(t, hamTokens, 3, "only tokens favoring ham")
+	assert.Equal(t, "voice", hamTokens[0].token)
+	assert.Equal(t, "hello", hamTokens[1].token)
+	assert.Equal(t, "now", hamTokens[2].token, "same count in both classes, but ham has fewer tokens")
+	assert.Less(t, hamTokens[0].weight, hamTokens[1].weight)
+	assert.Less(t, hamTokens[2].weight, 0.0)
+
+	assert.Empty(t, c.topTokens(ClassSpam, 3, "unknown"))
+}
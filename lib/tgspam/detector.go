@@ -1,20 +1,20 @@
 package tgspam
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/sha1" //nolint:gosec // used for sample ids only
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"iter"
-	"log"
 	"math"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -30,26 +30,30 @@ import (
 
 // Detector is a spam detector, thread-safe.
 // It uses a set of checks to determine if a message is spam, and also keeps a list of approved users.
+// Checks run on an immutable model snapshot, updates of samples and stop words swap the snapshot atomically
+// and never wait for running checks.
 type Detector struct {
 	Config
-	classifier     classifier
-	openaiChecker  *openAIChecker
-	metaChecks     []MetaCheck
-	tokenizedSpam  []map[string]int
-	approvedUsers  map[string]approved.UserInfo
-	stopWords      []string
-	excludedTokens map[string]struct{}
+	model         atomic.Pointer[model] // current snapshot of samples, classifier and stop words
+	modelLock     sync.Mutex            // serializes model updates, checks never take it
+	openaiChecker *openAIChecker
+	checkers      []Checker
+	metaChecks    int // number of meta-checks registered with WithMetaChecks, used to name them
+	approvedUsers map[string]approved.UserInfo
 
 	spamSamplesUpd SampleUpdater
 	hamSamplesUpd  SampleUpdater
 	userStorage    UserStorage
+	urlResolver    URLResolver     // resolver for shortened links, optional
+	mentionRes     MentionResolver // resolver for mentioned usernames, mention target check disabled if not set
+	campaigns      campaigns       // recent messages of not approved users for campaign detection
 
 	// history of recent messages to keep in memory
 	// can be passed to checkers supporting history
 	hamHistory  *spamcheck.LastRequests
 	spamHistory *spamcheck.LastRequests
 
-	lock sync.RWMutex
+	lock sync.RWMutex // protects approved users and checkers
 }
 
 // Config is a set of parameters for Detector.
@@ -67,6 +71,10 @@ type Config struct {
 	MultiLangWords      int           // if true, check for number of multi-lingual words
 	StorageTimeout      time.Duration // timeout for storage operations, if not set - no timeout
 
+	CheckTimeout  time.Duration            // timeout for a single check, if not set - no timeout
+	CheckTimeouts map[string]time.Duration // per-check timeouts by check name, overrides CheckTimeout
+	StopOnSpam    bool                     // if true, skip the remaining checks once spam is detected, decision checks are still executed
+
 	AbnormalSpacing struct {
 		Enabled                 bool    // if true, enable check for abnormal spacing
 		MinWordsCount           int     // the minimum number of words in the message to be considered
@@ -75,6 +83,64 @@ type Config struct {
 		SpaceRatioThreshold     float64 // the ratio of spaces to all characters in the message
 	}
 	HistorySize int // history of recent messages to keep in memory
+
+	Domains struct {
+		Enabled        bool // if true, check domains of the message links against denied and allowed domains
+		DenyShorteners bool // if true, links via known url shorteners are spam unless resolved to a non-denied domain
+	}
+
+	Profile struct {
+		Enabled bool // if true, check user's display name and bio with stop words, emoji, multi-lingual and classifier checks
+	}
+
+	Mentions struct {
+		Enabled  bool     // if true, check targets of mentioned usernames, requires mention resolver
+		Allowed  []string // usernames allowed to be mentioned, without "@", case-insensitive
+		RecentID int64    // targets with id above are considered recently created, 0 - disabled
+	}
+
+	Campaign struct {
+		Enabled    bool          // if true, detect waves of near-duplicate messages sent by different not approved users
+		MinUsers   int           // number of different users sending near-duplicate messages to consider it a campaign
+		Window     time.Duration // sliding window to count near-duplicate messages within
+		Similarity float64       // similarity of messages to consider them near-duplicates, 0.0 - 1.0, if 0 - same text only
+		MinTokens  int           // min number of distinct tokens of the message to count it, 3 if not set
+	}
+
+	Normalize struct {
+		Enabled     bool // if true, normalize homoglyphs, fancy letters, leetspeak and repeated letters of messages and samples
+		Obfuscation bool // if true, words obfuscated with fancy letters or homoglyphs of different scripts are spam
+	}
+
+	NGrams struct {
+		Words       int // max length of word n-grams added to classifier features, 2 - bigrams, 3 - trigrams, 0 - none
+		Chars       int // length of character n-grams of words added to classifier features, 0 - none
+		MinCount    int // n-grams found in fewer samples are pruned when samples loaded, not on updates, 0 - not pruned
+		MaxFeatures int // max number of n-grams kept when samples loaded or updated, the least frequent are pruned, 0 - unlimited
+	}
+
+	Stemming struct {
+		Enabled  bool               // if true, reduce words of samples, excluded tokens and messages to their stems
+		Stemmers map[string]Stemmer // stemmers by unicode script name, like "Latin" or "Cyrillic", DefaultStemmers if empty
+	}
+
+	Formatting struct {
+		Enabled            bool    // if true, check raw message for invisible characters, bidi overrides, zalgo and variation selectors
+		InvisibleRatio     float64 // max ratio of invisible characters to all characters, 0 - any invisible character is a trick
+		BidiOverrides      int     // max number of bidirectional override and isolate characters
+		CombiningMarks     int     // max number of combining marks stacked on a single character, 0 - not checked
+		VariationSelectors int     // max number of variation selectors not attached to emoji
+	}
+
+	Explain struct {
+		Tokens int // number of top tokens by log-likelihood ratio reported as classifier evidence, 0 - none
+	}
+
+	Scoring struct {
+		Threshold  float64            // total score to consider a message spam, if 0 - any check detecting spam is enough
+		Weights    map[string]float64 // per-check weights by check name, 1.0 if not set
+		HardChecks []string           // checks deciding on their own, spam from any of them is spam regardless of the total score
+	}
 }
 
 // SampleUpdater is an interface for updating spam/ham samples on the fly.
@@ -102,15 +168,16 @@ type LoadResult struct {
 	SpamSamples    int // number of spam samples
 	HamSamples     int // number of ham samples
 	StopWords      int // number of stop words (phrases)
+	DeniedDomains  int // number of denied domains
+	AllowedDomains int // number of allowed domains
+	PrunedNGrams   int // number of rare n-grams pruned from the classifier
 }
 
 // NewDetector makes a new Detector with the given config.
 func NewDetector(p Config) *Detector {
 	res := &Detector{
 		Config:        p,
-		classifier:    newClassifier(),
 		approvedUsers: make(map[string]approved.UserInfo),
-		tokenizedSpam: []map[string]int{},
 		hamHistory:    spamcheck.NewLastRequests(p.HistorySize),
 		spamHistory:   spamcheck.NewLastRequests(p.HistorySize),
 	}
@@ -123,147 +190,153 @@ func NewDetector(p Config) *Detector {
 	if p.FirstMessageOnly && p.FirstMessagesCount == 0 {
 		res.FirstMessagesCount = 1 // default value for FirstMessagesCount if FirstMessageOnly is set
 	}
+	m := newModel()
+	m.normalized = p.Normalize.Enabled
+	m.stemmers = res.makeStemmers()
+	m.wordNGrams, m.charNGrams = p.NGrams.Words, p.NGrams.Chars
+	res.model.Store(m)
+	for _, c := range res.builtinCheckers() {
+		res.registerChecker(c)
+	}
 	return res
 }
 
 // Check checks if a given message is spam. Returns true if spam and also returns a list of check results.
 func (d *Detector) Check(req spamcheck.Request) (spam bool, cr []spamcheck.Response) {
+	return d.CheckContext(context.Background(), req)
+}
 
-	isSpamDetected := func(cr []spamcheck.Response) bool {
-		for _, r := range cr {
-			if r.Spam {
-				return true
-			}
-		}
-		return false
-	}
+// CheckContext checks if a given message is spam with the context. Returns true if spam and also returns a list of check results.
+// Registered checkers are executed phase by phase, checks of the same phase run concurrently with their own deadlines.
+func (d *Detector) CheckContext(ctx context.Context, req spamcheck.Request) (spam bool, cr []spamcheck.Response) {
+	st := &CheckState{Request: req, CleanMsg: d.cleanText(req.Msg), model: d.model.Load(), decide: d.isSpam}
 
-	cleanMsg := d.cleanText(req.Msg)
+	// the lock is not held during the checks, as some of them make network calls.
+	// checkers are copied, and the checks use the model snapshot taken above.
 	d.lock.RLock()
-	defer d.lock.RUnlock()
+	approvedUser := req.UserID != "" && d.FirstMessageOnly && d.approvedUsers[req.UserID].Count >= d.FirstMessagesCount
+	preApproved := approvedUser && !req.Edited && !req.Strict
+	checkers := slices.Clone(d.checkers)
+	d.lock.RUnlock()
 
-	// approved user don't need to be checked
-	if req.UserID != "" && d.FirstMessageOnly && d.approvedUsers[req.UserID].Count >= d.FirstMessagesCount {
+	// approved user don't need to be checked, unless the message is edited or strict check requested
+	if preApproved {
 		return false, []spamcheck.Response{{Name: "pre-approved", Spam: false, Details: "user already approved"}}
 	}
 
-	// all the checks are performed sequentially, so we can collect all the results
-
-	// check for stop words if any stop words are loaded
-	if len(d.stopWords) > 0 {
-		cr = append(cr, d.isStopWord(cleanMsg, req))
-	}
-
-	// check for emojis if max allowed emojis is set
-	if d.MaxAllowedEmoji >= 0 {
-		cr = append(cr, d.isManyEmojis(req.Msg))
-	}
-
-	// check for spam with meta-checks
-	for _, mc := range d.metaChecks {
-		cr = append(cr, mc(req))
-	}
-
-	// check for spam with CAS API if CAS API URL is set
-	if d.CasAPI != "" {
-		cr = append(cr, d.isCasSpam(req.UserID))
-	}
-
-	if d.MultiLangWords > 0 {
-		cr = append(cr, d.isMultiLang(req.Msg))
-	}
-
-	if d.AbnormalSpacing.Enabled {
-		cr = append(cr, d.isAbnormalSpacing(req.Msg))
+	// model-based checks are skipped for messages shorter than the minimum size, if min message length is set.
+	// the checks from earlier phases are done anyway, because stop words and emojis can be triggered by short messages as well.
+	tooShort := len([]rune(req.Msg)) < d.MinMsgLen
+	for start := 0; start < len(checkers); {
+		phase := checkers[start].Phase()
+		end := start + 1
+		for end < len(checkers) && checkers[end].Phase() == phase {
+			end++
+		}
+		if tooShort && phase >= PhaseModel {
+			break
+		}
+		// with StopOnSpam the result is already decided, only decision checks (e.g. openai veto) can change it
+		if !d.StopOnSpam || !st.SpamDetected() || phase >= PhaseDecision {
+			st.Results = append(st.Results, d.runPhase(ctx, checkers[start:end], st)...)
+		}
+		start = end
 	}
 
-	// check for message length exceed the minimum size, if min message length is set.
-	// the check is done after first simple checks, because stop words and emojis can be triggered by short messages as well.
-	if len([]rune(req.Msg)) < d.MinMsgLen {
-		cr = append(cr, spamcheck.Response{Name: "message length", Spam: false, Details: "too short"})
-		if isSpamDetected(cr) {
+	if tooShort {
+		st.Results = append(st.Results, spamcheck.Response{Name: "message length", Spam: false, Details: "too short"})
+		if st.SpamDetected() {
 			d.spamHistory.Push(req)
-			return true, cr // spam from the checks above
+			return true, st.Results // spam from the checks above
 		}
 		d.hamHistory.Push(req)
-		return false, cr
+		return false, st.Results
 	}
 
-	// check for spam similarity if a similarity threshold is set and spam samples are loaded
-	if d.SimilarityThreshold > 0 && len(d.tokenizedSpam) > 0 {
-		cr = append(cr, d.isSpamSimilarityHigh(cleanMsg))
+	spamDetected, cr := st.SpamDetected(), st.Results
+	if spamDetected {
+		d.spamHistory.Push(req)
+		return true, cr
 	}
 
-	// check for spam with classifier if classifier is loaded
-	if d.classifier.nAllDocument > 0 && d.classifier.nDocumentByClass["ham"] > 0 && d.classifier.nDocumentByClass["spam"] > 0 {
-		cr = append(cr, d.isSpamClassified(cleanMsg))
+	// update approved users only if it's not paranoid mode and not a check-only request.
+	// already approved user checked by strict request is not updated, to keep the time of approval.
+	// interrupted checks didn't clear the message, so the user is not approved if any of them canceled or timed out.
+	if (d.FirstMessageOnly || d.FirstMessagesCount > 0) && !req.CheckOnly && !approvedUser && !interrupted(ctx, cr) {
+		d.countCleanMessage(req.UserID, req.UserName)
 	}
+	d.hamHistory.Push(req)
+	return false, cr
+}
 
-	spamDetected := isSpamDetected(cr)
+// interrupted checks if the context is done or any of the checks was canceled or timed out
+func interrupted(ctx context.Context, cr []spamcheck.Response) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	return slices.ContainsFunc(cr, func(r spamcheck.Response) bool {
+		return r.TimedOut || errors.Is(r.Error, context.Canceled) || errors.Is(r.Error, context.DeadlineExceeded)
+	})
+}
 
-	// we hit openai in two cases:
-	//  - all other checks passed (ham result) and OpenAIVeto is false. In this case, openai primary used to improve false negative rate
-	//  - one of the checks failed (spam result) and OpenAIVeto is true. In this case, openai primary used to improve false positive rate
-	// FirstMessageOnly or FirstMessagesCount has to be set to use openai, because it's slow and expensive to run on all messages
-	if d.openaiChecker != nil && (d.FirstMessageOnly || d.FirstMessagesCount > 0) {
-		if !spamDetected && !d.OpenAIVeto || spamDetected && d.OpenAIVeto {
-			var hist []spamcheck.Request // by default, openai doesn't use history
-			if d.OpenAIHistorySize > 0 && d.HistorySize > 0 {
-				// if history size is set, we use the last N messages for openai
-				hist = d.hamHistory.Last(d.OpenAIHistorySize)
-			}
-			spam, details := d.openaiChecker.check(cleanMsg, hist)
-			cr = append(cr, details)
-			if spamDetected && details.Error != nil {
-				// spam detected with other checks, but openai failed. in this case, we still return spam, but log the error
-				log.Printf("[WARN] openai error: %v", details.Error)
-			} else {
-				log.Printf("[DEBUG] openai result: {%s}", details.String())
-				spamDetected = spam
-			}
+// UserChecks is a list of checks about the user rather than the message. These checks are used by CheckUser.
+// Stop words are checked by "stopword" only, as req.Msg of CheckUser has the same name and bio "profile-stopword" checks.
+var UserChecks = []string{"stopword", "cas", "username-symbols", "profile-emoji", "profile-multi-lingual", "profile-classifier"}
 
-			// log if veto is enabled, and openai detected no spam for message that was detected as spam by other checks
-			if d.OpenAIVeto && !spam {
-				log.Printf("[DEBUG] openai vetoed ham message: %q, checks: %s", req.Msg, spamcheck.ChecksToString(cr))
-			}
+// CheckUser checks if the user is a spammer before any message is posted, e.g. on join request.
+// Only registered checkers from UserChecks are executed, req.Msg is expected to be the user's full name and bio.
+// Unlike Check, it doesn't update approved users and message history.
+func (d *Detector) CheckUser(ctx context.Context, req spamcheck.Request) (spam bool, cr []spamcheck.Response) {
+	st := &CheckState{Request: req, CleanMsg: d.cleanText(req.Msg), model: d.model.Load(), decide: d.isSpam}
+
+	d.lock.RLock()
+	checkers := make([]Checker, 0, len(UserChecks))
+	for _, c := range d.checkers {
+		if slices.Contains(UserChecks, c.Name()) {
+			checkers = append(checkers, c)
 		}
 	}
+	d.lock.RUnlock()
 
-	if spamDetected {
-		d.spamHistory.Push(req)
-		return true, cr
+	for start := 0; start < len(checkers); {
+		end := start + 1
+		for end < len(checkers) && checkers[end].Phase() == checkers[start].Phase() {
+			end++
+		}
+		st.Results = append(st.Results, d.runPhase(ctx, checkers[start:end], st)...)
+		start = end
 	}
+	return st.SpamDetected(), st.Results
+}
 
-	// update approved users only if it's not paranoid mode and not a check-only request
-	if (d.FirstMessageOnly || d.FirstMessagesCount > 0) && !req.CheckOnly {
-		ctx, cancel := d.ctxWithStoreTimeout()
-		defer cancel()
-		au := approved.UserInfo{
-			Count:     d.approvedUsers[req.UserID].Count + 1,
-			UserID:    req.UserID,
-			UserName:  req.UserName,
-			Timestamp: time.Now(),
+// isSpam makes the decision by check results. Without scoring threshold any check detecting spam is enough,
+// otherwise the total score should reach the threshold, unless one of the hard checks detected spam.
+func (d *Detector) isSpam(cr []spamcheck.Response) bool {
+	if d.Scoring.Threshold <= 0 {
+		for _, r := range cr {
+			if r.Spam {
+				return true
+			}
 		}
-		d.approvedUsers[req.UserID] = au // update approved users status in memory
-		if d.userStorage != nil {
-			// update approved users status in storage
-			_ = d.userStorage.Write(ctx, au) // ignore error, failed to write to storage is not critical here
+		return false
+	}
+	for _, r := range cr {
+		if r.Spam && slices.Contains(d.Scoring.HardChecks, r.Name) {
+			return true
 		}
 	}
-	d.hamHistory.Push(req)
-	return false, cr
+	return spamcheck.TotalScore(cr) >= d.Scoring.Threshold
 }
 
 // Reset resets spam samples/classifier, excluded tokens, stop words and approved users.
 func (d *Detector) Reset() {
-	d.lock.Lock()
-	defer d.lock.Unlock()
+	d.modelLock.Lock()
+	d.model.Store(newModel())
+	d.modelLock.Unlock()
 
-	d.tokenizedSpam = []map[string]int{}
-	d.excludedTokens = map[string]struct{}{}
-	d.classifier.reset()
+	d.lock.Lock()
 	d.approvedUsers = make(map[string]approved.UserInfo)
-	d.stopWords = []string{}
+	d.lock.Unlock()
 }
 
 // WithOpenAIChecker sets an openAIChecker for spam checking.
@@ -292,9 +365,15 @@ func (d *Detector) WithUserStorage(storage UserStorage) (count int, err error) {
 	return len(users), nil
 }
 
-// WithMetaChecks sets a list of meta-checkers.
+// WithMetaChecks registers a list of meta-checkers under names "meta-1", "meta-2" and so on.
+// Use WithCheckers with MetaChecker to register a meta-check under its own name, e.g. to set its weight.
 func (d *Detector) WithMetaChecks(mc ...MetaCheck) {
-	d.metaChecks = append(d.metaChecks, mc...)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, m := range mc {
+		d.metaChecks++
+		d.registerChecker(MetaChecker(fmt.Sprintf("meta-%d", d.metaChecks), m))
+	}
 }
 
 // WithSpamUpdater sets a SampleUpdater for spam samples.
@@ -317,6 +396,18 @@ func (d *Detector) ApprovedUsers() (res []approved.UserInfo) {
 	return res
 }
 
+// ApprovedUser returns approved user info for a given user ID, false if the user is not approved.
+// The info's timestamp is the time the user got approved.
+func (d *Detector) ApprovedUser(userID string) (approved.UserInfo, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	ui, ok := d.approvedUsers[userID]
+	if !ok || ui.Count <= d.FirstMessagesCount {
+		return approved.UserInfo{}, false
+	}
+	return ui, true
+}
+
 // IsApprovedUser checks if a given user ID is approved.
 // It uses memory cache for approved users and compares the count of messages sent by the user.
 func (d *Detector) IsApprovedUser(userID string) bool {
@@ -330,6 +421,37 @@ func (d *Detector) IsApprovedUser(userID string) bool {
 	return ui.Count > d.FirstMessagesCount
 }
 
+// CountCleanMessage counts a clean message for the user not approved yet, the same way as a message passed all checks.
+// It does nothing if first messages check is not enabled or the user is already approved.
+func (d *Detector) CountCleanMessage(userID, userName string) {
+	if !d.FirstMessageOnly && d.FirstMessagesCount == 0 {
+		return
+	}
+	if d.IsApprovedUser(userID) {
+		return
+	}
+	d.countCleanMessage(userID, userName)
+}
+
+// countCleanMessage increments the count of clean messages of the user and updates it in storage
+func (d *Detector) countCleanMessage(userID, userName string) {
+	d.lock.Lock()
+	au := approved.UserInfo{
+		Count:     d.approvedUsers[userID].Count + 1,
+		UserID:    userID,
+		UserName:  userName,
+		Timestamp: time.Now(),
+	}
+	d.approvedUsers[userID] = au // update approved users status in memory
+	d.lock.Unlock()
+	if d.userStorage != nil {
+		ctx, cancel := d.ctxWithStoreTimeout()
+		defer cancel()
+		// update approved users status in storage
+		_ = d.userStorage.Write(ctx, au) // ignore error, failed to write to storage is not critical here
+	}
+}
+
 // AddApprovedUser adds user IDs to the list of approved users.
 func (d *Detector) AddApprovedUser(user approved.UserInfo) error {
 	d.lock.Lock()
@@ -372,59 +494,63 @@ func (d *Detector) RemoveApprovedUser(id string) error {
 }
 
 // LoadSamples loads spam samples from a reader and updates the classifier.
-// Reset spam, ham samples/classifier, and excluded tokens.
+// Reset spam, ham samples/classifier, and excluded tokens. Stop words, denied and allowed domains are kept.
+// The new model is built aside and replaces the current one at once, checks keep running on the old model meanwhile.
+// With Normalize.Enabled and Stemming.Enabled, samples and excluded tokens are normalized and stemmed the same way
+// as checked messages. With NGrams set, rare n-grams of samples are pruned once all samples learned.
 func (d *Detector) LoadSamples(exclReader io.Reader, spamReaders, hamReaders []io.Reader) (LoadResult, error) {
-	d.lock.Lock()
-	defer d.lock.Unlock()
-
-	d.tokenizedSpam = []map[string]int{}
-	d.excludedTokens = map[string]struct{}{}
-	d.classifier.reset()
+	m := newModel()
+	m.normalized = d.Normalize.Enabled
+	m.stemmers = d.makeStemmers()
+	m.wordNGrams, m.charNGrams = d.NGrams.Words, d.NGrams.Chars
 
 	// excluded tokens should be loaded before spam samples to exclude them from spam tokenization
-	for t := range d.readerIterator(exclReader) {
-		d.excludedTokens[strings.ToLower(t)] = struct{}{}
+	for t := range readerIterator(exclReader) {
+		m.excludedTokens[m.stem(strings.ToLower(m.normalize(t)))] = struct{}{}
 	}
-	lr := LoadResult{ExcludedTokens: len(d.excludedTokens)}
+	lr := LoadResult{ExcludedTokens: len(m.excludedTokens)}
 
 	// load spam samples and update the classifier with them
 	docs := []document{}
-	for token := range d.readerIterator(spamReaders...) {
-		tokenizedSpam := d.tokenize(token)
-		d.tokenizedSpam = append(d.tokenizedSpam, tokenizedSpam) // add to list of samples
-		tokens := make([]string, 0, len(tokenizedSpam))
-		for token := range tokenizedSpam {
-			tokens = append(tokens, token)
-		}
-		docs = append(docs, newDocument(ClassSpam, tokens...))
+	for token := range readerIterator(spamReaders...) {
+		m.tokenizedSpam = append(m.tokenizedSpam, m.tokenize(token)) // add to list of samples
+		m.spamSamples = append(m.spamSamples, token)
+		docs = append(docs, newDocument(ClassSpam, m.features(token)...))
 		lr.SpamSamples++
 	}
 
 	// load ham samples and update the classifier with them
-	for token := range d.readerIterator(hamReaders...) {
-		tokenizedSpam := d.tokenize(token)
-		tokens := make([]string, 0, len(tokenizedSpam))
-		for token := range tokenizedSpam {
-			tokens = append(tokens, token)
-		}
-		docs = append(docs, document{spamClass: ClassHam, tokens: tokens})
+	for token := range readerIterator(hamReaders...) {
+		docs = append(docs, newDocument(ClassHam, m.features(token)...))
 		lr.HamSamples++
 	}
+	m.classifier.learn(docs...)
+	if d.NGrams.MinCount > 1 || d.NGrams.MaxFeatures > 0 {
+		lr.PrunedNGrams = m.classifier.prune(d.NGrams.MinCount, d.NGrams.MaxFeatures)
+	}
 
-	d.classifier.learn(docs...)
+	d.modelLock.Lock()
+	defer d.modelLock.Unlock()
+	cur := d.model.Load()
+	m.stopWords, m.deniedDomains, m.allowedDomains = cur.stopWords, cur.deniedDomains, cur.allowedDomains
+	d.model.Store(m)
 	return lr, nil
 }
 
 // LoadStopWords loads stop words from a reader. Reset stop words list before loading.
+// Stop words are normalized the same way as checked messages for normalized model.
 func (d *Detector) LoadStopWords(readers ...io.Reader) (LoadResult, error) {
-	d.lock.Lock()
-	defer d.lock.Unlock()
+	d.modelLock.Lock()
+	defer d.modelLock.Unlock()
+	m := *d.model.Load() // shallow copy is enough, only stop words replaced
 
-	d.stopWords = []string{}
-	for t := range d.readerIterator(readers...) {
-		d.stopWords = append(d.stopWords, strings.ToLower(t))
+	stopWords := []string{}
+	for t := range readerIterator(readers...) {
+		stopWords = append(stopWords, strings.ToLower(m.normalize(t)))
 	}
-	return LoadResult{StopWords: len(d.stopWords)}, nil
+	m.stopWords = stopWords
+	d.model.Store(&m)
+	return LoadResult{StopWords: len(stopWords)}, nil
 }
 
 // UpdateSpam appends a message to the spam samples file and updates the classifier
@@ -448,10 +574,11 @@ func (d *Detector) RemoveHam(msg string) error {
 }
 
 // updateSample appends a message to the samples store and updates the classifier
-// doesn't reset state, update append samples
+// doesn't reset state, update append samples. N-grams above NGrams.MaxFeatures are pruned, but NGrams.MinCount
+// is not applied, as n-grams of a new sample are always rare at first.
 func (d *Detector) updateSample(msg string, upd SampleUpdater, sc spamClass) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
+	d.modelLock.Lock()
+	defer d.modelLock.Unlock()
 
 	if upd == nil {
 		return nil
@@ -463,123 +590,73 @@ func (d *Detector) updateSample(msg string, upd SampleUpdater, sc spamClass) err
 	}
 
 	// load samples and update the classifier with them
-	docs := d.buildDocs(msg, sc)
-	d.classifier.learn(docs...)
+	m := d.model.Load().clone()
+	docs := m.buildDocs(msg, sc)
+	m.classifier.learn(docs...)
+	if d.NGrams.MaxFeatures > 0 {
+		m.classifier.prune(0, d.NGrams.MaxFeatures)
+	}
 
 	// update tokenized spam samples for similarity check
 	if sc == ClassSpam {
-		tokenizedSpam := d.tokenize(msg)
-		d.tokenizedSpam = append(d.tokenizedSpam, tokenizedSpam)
+		tokenizedSpam := m.tokenize(msg)
+		m.tokenizedSpam = append(m.tokenizedSpam, tokenizedSpam)
+		m.spamSamples = append(m.spamSamples, msg)
 	}
 
+	d.model.Store(m)
 	return nil
 }
 
 // removeSample removes a message from the spam samples file and updates the classifier by unlearning
 func (d *Detector) removeSample(msg string, upd SampleUpdater, sc spamClass) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
+	d.modelLock.Lock()
+	defer d.modelLock.Unlock()
 
 	if upd == nil {
 		return nil
 	}
 
-	// first validate that we can unlearn this sample
-	docs := d.buildDocs(msg, sc)
-	if err := d.classifier.unlearn(docs...); err != nil {
+	// first validate that we can unlearn this sample, the copy is dropped on failure
+	m := d.model.Load().clone()
+	docs := m.buildDocs(msg, sc)
+	if err := m.classifier.unlearn(docs...); err != nil {
 		return fmt.Errorf("can't unlearn %s samples: %w", sc, err)
 	}
 
 	// if unlearn succeeded, remove from storage
 	if err := upd.Remove(msg); err != nil {
-		// try to relearn since storage update failed
-		d.classifier.learn(docs...)
 		return fmt.Errorf("can't remove %s samples: %w", sc, err)
 	}
+	d.model.Store(m)
 	return nil
 }
 
-// buildDocs builds a list of classifier documents from a message
-func (d *Detector) buildDocs(msg string, sc spamClass) []document {
-	docs := []document{}
-	for token := range d.readerIterator(bytes.NewBufferString(msg)) {
-		tokenizedSample := d.tokenize(token)
-		tokens := make([]string, 0, len(tokenizedSample))
-		for token := range tokenizedSample {
-			tokens = append(tokens, token)
-		}
-		docs = append(docs, document{spamClass: sc, tokens: tokens})
-	}
-	return docs
-}
-
-// readerIterator parses readers and returns an iterator of data elements, each line is an element.
-func (d *Detector) readerIterator(readers ...io.Reader) iter.Seq[string] {
-	return func(yield func(string) bool) {
-		for _, reader := range readers {
-			scanner := bufio.NewScanner(reader)
-			for scanner.Scan() {
-				line := scanner.Text()
-				// each line with a single element
-				cleanToken := strings.Trim(line, " \n\r\t")
-				if cleanToken != "" {
-					if !yield(cleanToken) {
-						return
-					}
-				}
-			}
-
-			if err := scanner.Err(); err != nil {
-				log.Printf("[WARN] failed to read tokens, error=%v", err)
-			}
+// isSpam checks if a given message is similar to any of the known bad messages
+func (d *Detector) isSpamSimilarityHigh(m *model, msg string) spamcheck.Response {
+	// check for spam similarity, the best-matching sample is the evidence
+	tokenizedMessage := m.tokenize(msg)
+	maxSimilarity, best := 0.0, -1
+	for i, spam := range m.tokenizedSpam {
+		if similarity := d.cosineSimilarity(tokenizedMessage, spam); similarity > maxSimilarity {
+			maxSimilarity, best = similarity, i
 		}
 	}
-}
-
-// tokenize takes a string and returns a map where the keys are unique words (tokens)
-// and the values are the frequencies of those words in the string.
-// exclude tokens representing common words.
-func (d *Detector) tokenize(inp string) map[string]int {
-	isExcludedToken := func(token string) bool {
-		if _, ok := d.excludedTokens[strings.ToLower(token)]; ok {
-			return true
-		}
-		return false
+	res := spamcheck.Response{Spam: maxSimilarity >= d.SimilarityThreshold, Name: "similarity",
+		Details: fmt.Sprintf("%0.2f/%0.2f", maxSimilarity, d.SimilarityThreshold)}
+	if res.Spam {
+		res.Score = maxSimilarity // ham doesn't add to the total score
 	}
-
-	tokenFrequency := make(map[string]int)
-	tokens := strings.Fields(inp)
-	for _, token := range tokens {
-		if isExcludedToken(token) {
-			continue
-		}
-		token = cleanEmoji(token)
-		token = strings.Trim(token, ".,!?-:;()#")
-		token = strings.ToLower(token)
-		if len([]rune(token)) < 3 {
-			continue
-		}
-		tokenFrequency[strings.ToLower(token)]++
+	if best >= 0 && best < len(m.spamSamples) {
+		res.Evidence = []spamcheck.Evidence{{Kind: spamcheck.EvidenceSample, ID: sampleID(m.spamSamples[best]),
+			Text: m.spamSamples[best], Weight: maxSimilarity}}
 	}
-	return tokenFrequency
+	return res
 }
 
-// isSpam checks if a given message is similar to any of the known bad messages
-func (d *Detector) isSpamSimilarityHigh(msg string) spamcheck.Response {
-	// check for spam similarity
-	tokenizedMessage := d.tokenize(msg)
-	maxSimilarity := 0.0
-	for _, spam := range d.tokenizedSpam {
-		similarity := d.cosineSimilarity(tokenizedMessage, spam)
-		if similarity > maxSimilarity {
-			maxSimilarity = similarity
-		}
-		if similarity >= d.SimilarityThreshold {
-			return spamcheck.Response{Spam: true, Name: "similarity",
-				Details: fmt.Sprintf("%0.2f/%0.2f", maxSimilarity, d.SimilarityThreshold)}
-		}
-	}
-	return spamcheck.Response{Spam: false, Name: "similarity", Details: fmt.Sprintf("%0.2f/%0.2f", maxSimilarity, d.SimilarityThreshold)}
+// sampleID returns the id of the sample, sha1 of its text, the same as used by web UI to manage samples
+func sampleID(sample string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(sample))) //nolint:gosec // not used for security
 }
 
 // cosineSimilarity calculates the cosine similarity between two token frequency maps.
@@ -608,7 +685,7 @@ func (d *Detector) cosineSimilarity(a, b map[string]int) float64 {
 }
 
 // isCasSpam checks if a given user ID is a spammer with CAS API.
-func (d *Detector) isCasSpam(msgID string) spamcheck.Response {
+func (d *Detector) isCasSpam(ctx context.Context, msgID string) spamcheck.Response {
 	if msgID == "" {
 		return spamcheck.Response{Spam: false, Name: "cas", Details: "check disabled"}
 	}
@@ -616,7 +693,7 @@ func (d *Detector) isCasSpam(msgID string) spamcheck.Response {
 		return spamcheck.Response{Spam: false, Name: "cas", Details: fmt.Sprintf("invalid user id %q", msgID)}
 	}
 	reqURL := fmt.Sprintf("%s/check?user_id=%s", d.CasAPI, msgID)
-	req, err := http.NewRequest("GET", reqURL, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, http.NoBody)
 	if err != nil {
 		return spamcheck.Response{Spam: false, Name: "cas", Details: fmt.Sprintf("failed to make request %s: %v", reqURL, err)}
 	}
@@ -653,25 +730,32 @@ func (d *Detector) isCasSpam(msgID string) spamcheck.Response {
 }
 
 // isSpamClassified classify tokens from a document
-func (d *Detector) isSpamClassified(msg string) spamcheck.Response {
-	tm := d.tokenize(msg)
-	tokens := make([]string, 0, len(tm))
-	for token := range tm {
-		tokens = append(tokens, token)
-	}
-	class, prob, certain := d.classifier.classify(tokens...)
+func (d *Detector) isSpamClassified(m *model, msg string) spamcheck.Response {
+	features := m.features(msg)
+	class, prob, certain := m.classifier.classify(features...)
 	isSpam := class == ClassSpam && certain && (d.MinSpamProbability == 0 || prob >= d.MinSpamProbability)
-	return spamcheck.Response{Name: "classifier", Spam: isSpam,
+	score := 0.0 // ham doesn't add to the total score
+	if isSpam {
+		score = prob / 100 // probability of spam
+	}
+	res := spamcheck.Response{Name: "classifier", Spam: isSpam, Score: score,
 		Details: fmt.Sprintf("probability of %s: %.2f%%", class, prob)}
+	if certain && d.Explain.Tokens > 0 {
+		for _, tw := range m.classifier.topTokens(class, d.Explain.Tokens, features...) {
+			res.Evidence = append(res.Evidence, spamcheck.Evidence{Kind: spamcheck.EvidenceToken, Text: tw.token, Weight: tw.weight})
+		}
+	}
+	return res
 }
 
 // isStopWord checks if a given message or username contains any of the stop words.
-func (d *Detector) isStopWord(msg string, req spamcheck.Request) spamcheck.Response {
+func (d *Detector) isStopWord(m *model, msg string, req spamcheck.Request) spamcheck.Response {
 	// check message text
-	cleanMsg := cleanEmoji(strings.ToLower(msg))
-	for _, word := range d.stopWords { // stop words are already lowercased
+	cleanMsg := cleanEmoji(strings.ToLower(m.normalize(msg)))
+	for _, word := range m.stopWords { // stop words are already lowercased
 		if strings.Contains(cleanMsg, strings.ToLower(word)) {
-			return spamcheck.Response{Name: "stopword", Spam: true, Details: word}
+			return spamcheck.Response{Name: "stopword", Spam: true, Details: word,
+				Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: word}}}
 		}
 	}
 
@@ -684,9 +768,11 @@ func (d *Detector) isStopWord(msg string, req spamcheck.Request) spamcheck.Respo
 		names = append(names, req.UserID)
 	}
 	for _, name := range names {
-		for _, word := range d.stopWords {
-			if strings.Contains(strings.ToLower(name), strings.ToLower(word)) {
-				return spamcheck.Response{Name: "stopword", Spam: true, Details: word}
+		name = strings.ToLower(m.normalize(name))
+		for _, word := range m.stopWords {
+			if strings.Contains(name, strings.ToLower(word)) {
+				return spamcheck.Response{Name: "stopword", Spam: true, Details: word,
+					Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: word}}}
 			}
 		}
 	}
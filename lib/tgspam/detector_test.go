@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
@@ -326,17 +327,70 @@ func TestSpam_CheckIsCasSpamEmptyUserID(t *testing.T) {
 	assert.Equal(t, 0, len(mockedHTTPClient.DoCalls()))
 }
 
+func TestDetector_CheckUser(t *testing.T) {
+	casSpam := map[string]bool{"666": true}
+	mockedHTTPClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			resp := `{"ok": false, "description": "not found"}`
+			if casSpam[req.URL.Query().Get("user_id")] {
+				resp = `{"ok": true, "description": "record found"}`
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(resp))}, nil
+		},
+	}
+	d := NewDetector(Config{CasAPI: "http://localhost", HTTPClient: mockedHTTPClient, MaxAllowedEmoji: 1,
+		FirstMessagesCount: 1, SimilarityThreshold: 0.5})
+	d.WithCheckers(MetaChecker("username-symbols", UsernameSymbolsCheck("@")), MetaChecker("links", LinksCheck(0)))
+	_, err := d.LoadStopWords(bytes.NewBufferString("crypto profit"))
+	require.NoError(t, err)
+	_, err = d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader("crypto profit now")},
+		[]io.Reader{strings.NewReader("hello")})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		req      spamcheck.Request
+		spam     bool
+		failedOn string
+	}{
+		{name: "clean user", req: spamcheck.Request{Msg: "John Doe", UserID: "123", UserName: "john"}},
+		{name: "stop word in name", req: spamcheck.Request{Msg: "Crypto Profit 💰💰", UserID: "123", UserName: "john"},
+			spam: true, failedOn: "stopword"},
+		{name: "stop word in username", req: spamcheck.Request{Msg: "John", UserID: "123", UserName: "crypto profit"},
+			spam: true, failedOn: "stopword"},
+		{name: "username symbols", req: spamcheck.Request{Msg: "John", UserID: "123", UserName: "jo@hn"},
+			spam: true, failedOn: "username-symbols"},
+		{name: "cas", req: spamcheck.Request{Msg: "John", UserID: "666", UserName: "john"}, spam: true, failedOn: "cas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, cr := d.CheckUser(context.Background(), tt.req)
+			assert.Equal(t, tt.spam, spam)
+			names := []string{}
+			for _, r := range cr {
+				names = append(names, r.Name)
+				if r.Spam {
+					assert.Equal(t, tt.failedOn, r.Name)
+				}
+			}
+			// only user checks executed, no emoji, links, similarity and classifier
+			assert.Equal(t, []string{"stopword", "username-symbols", "cas"}, names)
+		})
+	}
+	assert.Empty(t, d.ApprovedUsers(), "approved users not updated")
+}
+
 func TestDetector_CheckSimilarity(t *testing.T) {
 	d := NewDetector(Config{MaxAllowedEmoji: -1})
 	spamSamples := strings.NewReader("win free iPhone\nlottery prize xyz")
 	lr, err := d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, nil)
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{ExcludedTokens: 1, SpamSamples: 2}, lr)
-	d.classifier.reset() // we don't need a classifier for this test
-	assert.Len(t, d.tokenizedSpam, 2)
-	t.Logf("%+v", d.tokenizedSpam)
-	assert.Equal(t, map[string]int{"win": 1, "free": 1, "iphone": 1}, d.tokenizedSpam[0])
-	assert.Equal(t, map[string]int{"lottery": 1, "prize": 1}, d.tokenizedSpam[1])
+	d.model.Load().classifier.reset() // we don't need a classifier for this test
+	assert.Len(t, d.model.Load().tokenizedSpam, 2)
+	t.Logf("%+v", d.model.Load().tokenizedSpam)
+	assert.Equal(t, map[string]int{"win": 1, "free": 1, "iphone": 1}, d.model.Load().tokenizedSpam[0])
+	assert.Equal(t, map[string]int{"lottery": 1, "prize": 1}, d.model.Load().tokenizedSpam[1])
 
 	tests := []struct {
 		name      string
@@ -370,12 +424,12 @@ func TestDetector_CheckClassifier(t *testing.T) {
 	lr, err := d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, []io.Reader{hamsSamples})
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{ExcludedTokens: 1, SpamSamples: 2, HamSamples: 3}, lr)
-	d.tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
-	assert.Equal(t, 5, d.classifier.nAllDocument)
+	d.model.Load().tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
+	assert.Equal(t, 5, d.model.Load().classifier.nAllDocument)
 	exp := map[string]map[spamClass]int{"win": {"spam": 1}, "free": {"spam": 1}, "iphone": {"spam": 1}, "lottery": {"spam": 1},
 		"prize": {"spam": 1}, "hello": {"ham": 1}, "world": {"ham": 1}, "how": {"ham": 1}, "are": {"ham": 1}, "you": {"ham": 1},
 		"have": {"ham": 1}, "good": {"ham": 1}, "day": {"ham": 1}}
-	assert.Equal(t, exp, d.classifier.learningResults)
+	assert.Equal(t, exp, d.model.Load().classifier.learningResults)
 
 	tests := []struct {
 		name     string
@@ -412,19 +466,72 @@ func TestDetector_CheckClassifier(t *testing.T) {
 	})
 }
 
+func TestDetector_CheckEvidence(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.5})
+	d.Explain.Tokens = 2
+	_, err := d.LoadStopWords(strings.NewReader("в личку"))
+	require.NoError(t, err)
+	spamSamples := strings.NewReader("win free iPhone\nlottery prize xyz")
+	hamsSamples := strings.NewReader("hello world\nhow are you\nhave a good day")
+	_, err = d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, []io.Reader{hamsSamples})
+	require.NoError(t, err)
+
+	t.Run("spam", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "You won a lottery prize, win it!"})
+		assert.True(t, spam)
+		require.Len(t, cr, 3)
+		assert.Empty(t, cr[0].Evidence, "no stop word found")
+
+		assert.Equal(t, "similarity", cr[1].Name)
+		assert.Equal(t, []spamcheck.Evidence{{Kind: spamcheck.EvidenceSample, ID: "0f96ba1a409bf4f4de59ba953645775fb63431b5",
+			Text: "lottery prize xyz", Weight: cr[1].Score}}, cr[1].Evidence)
+
+		assert.Equal(t, "classifier", cr[2].Name)
+		require.Len(t, cr[2].Evidence, 2)
+		assert.Equal(t, spamcheck.EvidenceToken, cr[2].Evidence[0].Kind)
+		assert.Equal(t, "lottery", cr[2].Evidence[0].Text, "same weight as win and prize, ordered by token")
+		assert.Equal(t, "prize", cr[2].Evidence[1].Text)
+		assert.Positive(t, cr[2].Evidence[0].Weight)
+	})
+
+	t.Run("ham", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "hello, how are you?"})
+		assert.False(t, spam)
+		require.Len(t, cr, 3)
+		assert.Empty(t, cr[1].Evidence, "no sample matched at all")
+		require.Len(t, cr[2].Evidence, 2)
+		assert.Equal(t, "are", cr[2].Evidence[0].Text)
+		assert.Negative(t, cr[2].Evidence[0].Weight)
+		assert.Negative(t, cr[2].Evidence[1].Weight)
+	})
+
+	t.Run("stop word", func(t *testing.T) {
+		spam, cr := d.Check(spamcheck.Request{Msg: "пишите в личку"})
+		assert.True(t, spam)
+		assert.Equal(t, []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: "в личку"}}, cr[0].Evidence)
+	})
+
+	t.Run("no classifier tokens", func(t *testing.T) {
+		d.Explain.Tokens = 0
+		_, cr := d.Check(spamcheck.Request{Msg: "You won a lottery prize, win it!"})
+		require.Len(t, cr, 3)
+		assert.Empty(t, cr[2].Evidence)
+	})
+}
+
 func TestDetector_CheckClassifierNoHam(t *testing.T) {
 	d := NewDetector(Config{MaxAllowedEmoji: -1, MinSpamProbability: 60})
 	spamSamples := strings.NewReader("win free iPhone\nlottery prize xyz")
 	lr, err := d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, nil)
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{ExcludedTokens: 1, SpamSamples: 2, HamSamples: 0}, lr)
-	d.tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
-	assert.Equal(t, 2, d.classifier.nAllDocument)
-	assert.Equal(t, 2, d.classifier.nDocumentByClass["spam"])
-	assert.Equal(t, 0, d.classifier.nDocumentByClass["ham"])
+	d.model.Load().tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
+	assert.Equal(t, 2, d.model.Load().classifier.nAllDocument)
+	assert.Equal(t, 2, d.model.Load().classifier.nDocumentByClass["spam"])
+	assert.Equal(t, 0, d.model.Load().classifier.nDocumentByClass["ham"])
 	exp := map[string]map[spamClass]int{"win": {"spam": 1}, "free": {"spam": 1}, "iphone": {"spam": 1},
 		"lottery": {"spam": 1}, "prize": {"spam": 1}}
-	assert.Equal(t, exp, d.classifier.learningResults)
+	assert.Equal(t, exp, d.model.Load().classifier.learningResults)
 
 	tests := []string{
 		"Hello, how are you?",
@@ -903,12 +1010,12 @@ func TestDetector_UpdateSpam(t *testing.T) {
 	lr, err := d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, []io.Reader{hamsSamples})
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{ExcludedTokens: 1, SpamSamples: 2, HamSamples: 3}, lr)
-	d.tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
-	assert.Equal(t, 5, d.classifier.nAllDocument)
+	d.model.Load().tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
+	assert.Equal(t, 5, d.model.Load().classifier.nAllDocument)
 	exp := map[string]map[spamClass]int{"win": {"spam": 1}, "free": {"spam": 1}, "iphone": {"spam": 1}, "lottery": {"spam": 1},
 		"prize": {"spam": 1}, "hello": {"ham": 1}, "world": {"ham": 1}, "how": {"ham": 1}, "are": {"ham": 1}, "you": {"ham": 1},
 		"have": {"ham": 1}, "good": {"ham": 1}, "day": {"ham": 1}}
-	assert.Equal(t, exp, d.classifier.learningResults)
+	assert.Equal(t, exp, d.model.Load().classifier.learningResults)
 
 	msg := "another good world one iphone user writes good things day"
 	t.Run("initially a little bit ham", func(t *testing.T) {
@@ -922,7 +1029,7 @@ func TestDetector_UpdateSpam(t *testing.T) {
 
 	err = d.UpdateSpam("another user writes")
 	assert.NoError(t, err)
-	assert.Equal(t, 6, d.classifier.nAllDocument)
+	assert.Equal(t, 6, d.model.Load().classifier.nAllDocument)
 	assert.Equal(t, 1, len(upd.AppendCalls()))
 
 	t.Run("after update mostly spam", func(t *testing.T) {
@@ -950,12 +1057,12 @@ func TestDetector_UpdateHam(t *testing.T) {
 	lr, err := d.LoadSamples(strings.NewReader("xyz"), []io.Reader{spamSamples}, []io.Reader{hamsSamples})
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{ExcludedTokens: 1, SpamSamples: 2, HamSamples: 3}, lr)
-	d.tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
-	assert.Equal(t, 5, d.classifier.nAllDocument)
+	d.model.Load().tokenizedSpam = nil // we don't need tokenizedSpam samples for this test
+	assert.Equal(t, 5, d.model.Load().classifier.nAllDocument)
 	exp := map[string]map[spamClass]int{"win": {"spam": 1}, "free": {"spam": 1}, "iphone": {"spam": 1}, "lottery": {"spam": 1},
 		"prize": {"spam": 1}, "hello": {"ham": 1}, "world": {"ham": 1}, "how": {"ham": 1}, "are": {"ham": 1}, "you": {"ham": 1},
 		"have": {"ham": 1}, "good": {"ham": 1}, "day": {"ham": 1}}
-	assert.Equal(t, exp, d.classifier.learningResults)
+	assert.Equal(t, exp, d.model.Load().classifier.learningResults)
 
 	msg := "another free good world one iphone user writes good things day"
 	t.Run("initially a little bit spam", func(t *testing.T) {
@@ -969,7 +1076,7 @@ func TestDetector_UpdateHam(t *testing.T) {
 
 	err = d.UpdateHam("another writes things")
 	assert.NoError(t, err)
-	assert.Equal(t, 6, d.classifier.nAllDocument)
+	assert.Equal(t, 6, d.model.Load().classifier.nAllDocument)
 	assert.Equal(t, 1, len(upd.AppendCalls()))
 
 	t.Run("after update mostly ham", func(t *testing.T) {
@@ -993,16 +1100,16 @@ func TestDetector_Reset(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, LoadResult{StopWords: 2}, sr)
 
-	assert.Equal(t, 5, d.classifier.nAllDocument)
-	assert.Equal(t, 2, len(d.tokenizedSpam))
-	assert.Equal(t, 1, len(d.excludedTokens))
-	assert.Equal(t, 2, len(d.stopWords))
+	assert.Equal(t, 5, d.model.Load().classifier.nAllDocument)
+	assert.Equal(t, 2, len(d.model.Load().tokenizedSpam))
+	assert.Equal(t, 1, len(d.model.Load().excludedTokens))
+	assert.Equal(t, 2, len(d.model.Load().stopWords))
 
 	d.Reset()
-	assert.Equal(t, 0, d.classifier.nAllDocument)
-	assert.Equal(t, 0, len(d.tokenizedSpam))
-	assert.Equal(t, 0, len(d.excludedTokens))
-	assert.Equal(t, 0, len(d.stopWords))
+	assert.Equal(t, 0, d.model.Load().classifier.nAllDocument)
+	assert.Equal(t, 0, len(d.model.Load().tokenizedSpam))
+	assert.Equal(t, 0, len(d.model.Load().excludedTokens))
+	assert.Equal(t, 0, len(d.model.Load().stopWords))
 }
 
 func TestDetector_FirstMessagesCount(t *testing.T) {
@@ -1039,6 +1146,32 @@ func TestDetector_FirstMessagesCount(t *testing.T) {
 		spam, _ = d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123"})
 		assert.Equal(t, false, spam, "spam is not detected because user is approved")
 	})
+	t.Run("edited message of approved user is checked", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: 1, MinMsgLen: 5, FirstMessagesCount: 1, FirstMessageOnly: true})
+
+		spam, _ := d.Check(spamcheck.Request{Msg: "ham, no emojis", UserID: "123"})
+		assert.Equal(t, false, spam)
+		spam, _ = d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123"})
+		assert.Equal(t, false, spam, "spam is not detected because user is approved")
+
+		spam, cr := d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123", Edited: true})
+		assert.Equal(t, true, spam, "edited message checked")
+		assert.NotEqual(t, "pre-approved", cr[0].Name)
+	})
+	t.Run("strict message of approved user is checked, approval kept", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: 1, MinMsgLen: 5, FirstMessagesCount: 1, FirstMessageOnly: true})
+		ts := time.Now().Add(-time.Hour)
+		require.NoError(t, d.AddApprovedUser(approved.UserInfo{UserID: "123", Timestamp: ts}))
+
+		spam, _ := d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123", Strict: true})
+		assert.Equal(t, true, spam, "strict message checked")
+
+		spam, _ = d.Check(spamcheck.Request{Msg: "ham, no emojis", UserID: "123", Strict: true})
+		assert.Equal(t, false, spam)
+		ui, ok := d.ApprovedUser("123")
+		require.True(t, ok)
+		assert.Equal(t, ts, ui.Timestamp, "approval time not updated by strict check")
+	})
 	t.Run("first messages are ham, spam after approved, FirstMessageOnly were false", func(t *testing.T) {
 		d := NewDetector(Config{MaxAllowedEmoji: 1, MinMsgLen: 5, FirstMessagesCount: 2, FirstMessageOnly: false})
 
@@ -1053,6 +1186,27 @@ func TestDetector_FirstMessagesCount(t *testing.T) {
 		spam, _ = d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123"})
 		assert.Equal(t, false, spam)
 	})
+	t.Run("counted clean message", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: 1, MinMsgLen: 5, FirstMessagesCount: 2, FirstMessageOnly: true})
+
+		d.CountCleanMessage("123", "user")
+		spam, _ := d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123"})
+		assert.Equal(t, true, spam, "one counted message doesn't approve")
+
+		spam, _ = d.Check(spamcheck.Request{Msg: "ham, no emojis", UserID: "123"})
+		assert.Equal(t, false, spam)
+
+		spam, _ = d.Check(spamcheck.Request{Msg: "spam, too many emojis 🤣🤣🤣", UserID: "123"})
+		assert.Equal(t, false, spam, "spam is not detected because user is approved by counted and checked messages")
+	})
+	t.Run("counted clean message, first messages check disabled", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: 1, MinMsgLen: 5})
+		d.CountCleanMessage("123", "user")
+		d.lock.RLock()
+		_, ok := d.approvedUsers["123"]
+		d.lock.RUnlock()
+		assert.False(t, ok, "nothing counted")
+	})
 }
 
 func TestDetector_ApprovedUsers(t *testing.T) {
@@ -1244,23 +1398,23 @@ func TestDetector_LoadSamples(t *testing.T) {
 		assert.Equal(t, 3, lr.HamSamples)
 
 		// verify excluded tokens
-		assert.Contains(t, d.excludedTokens, "xyz")
+		assert.Contains(t, d.model.Load().excludedTokens, "xyz")
 
 		// verify tokenized spam samples
-		assert.Len(t, d.tokenizedSpam, 2)
-		assert.Contains(t, d.tokenizedSpam[0], "win")
-		assert.Contains(t, d.tokenizedSpam[1], "lottery")
+		assert.Len(t, d.model.Load().tokenizedSpam, 2)
+		assert.Contains(t, d.model.Load().tokenizedSpam[0], "win")
+		assert.Contains(t, d.model.Load().tokenizedSpam[1], "lottery")
 
 		// verify classifier learning
-		assert.Equal(t, 5, d.classifier.nAllDocument)
-		assert.Contains(t, d.classifier.learningResults, "win")
-		assert.Contains(t, d.classifier.learningResults["win"], spamClass("spam"))
-		assert.Contains(t, d.classifier.learningResults, "world")
-		assert.Contains(t, d.classifier.learningResults["world"], spamClass("ham"))
+		assert.Equal(t, 5, d.model.Load().classifier.nAllDocument)
+		assert.Contains(t, d.model.Load().classifier.learningResults, "win")
+		assert.Contains(t, d.model.Load().classifier.learningResults["win"], spamClass("spam"))
+		assert.Contains(t, d.model.Load().classifier.learningResults, "world")
+		assert.Contains(t, d.model.Load().classifier.learningResults["world"], spamClass("ham"))
 
 		// verify excluded tokens in learning results
-		assert.NotContains(t, d.classifier.learningResults, "xyz", "excluded token should not be in learning results")
-		assert.NotContains(t, d.classifier.learningResults, "XyZ", "excluded token should not be in learning results")
+		assert.NotContains(t, d.model.Load().classifier.learningResults, "xyz", "excluded token should not be in learning results")
+		assert.NotContains(t, d.model.Load().classifier.learningResults, "XyZ", "excluded token should not be in learning results")
 	})
 
 	t.Run("empty samples", func(t *testing.T) {
@@ -1275,7 +1429,7 @@ func TestDetector_LoadSamples(t *testing.T) {
 		assert.Equal(t, 0, lr.ExcludedTokens)
 		assert.Equal(t, 0, lr.SpamSamples)
 		assert.Equal(t, 0, lr.HamSamples)
-		assert.Equal(t, 0, d.classifier.nAllDocument)
+		assert.Equal(t, 0, d.model.Load().classifier.nAllDocument)
 	})
 
 	t.Run("multiple readers", func(t *testing.T) {
@@ -1296,7 +1450,7 @@ func TestDetector_LoadSamples(t *testing.T) {
 		assert.Equal(t, 3, lr.ExcludedTokens)
 
 		exTkns := []string{}
-		for k := range d.excludedTokens {
+		for k := range d.model.Load().excludedTokens {
 			exTkns = append(exTkns, k)
 		}
 		sort.Strings(exTkns)
@@ -1304,16 +1458,16 @@ func TestDetector_LoadSamples(t *testing.T) {
 		assert.Equal(t, []string{"the", "xy", "z"}, exTkns)
 		assert.Equal(t, 2, lr.SpamSamples)
 		assert.Equal(t, 5, lr.HamSamples)
-		t.Logf("Learning results: %+v", d.classifier.learningResults)
-		assert.Equal(t, 7, d.classifier.nAllDocument)
-		assert.Contains(t, d.classifier.learningResults["win"], spamClass("spam"))
-		assert.Contains(t, d.classifier.learningResults["prize"], spamClass("spam"))
-		assert.Contains(t, d.classifier.learningResults["world"], spamClass("ham"))
-		assert.Contains(t, d.classifier.learningResults["some"], spamClass("ham"))
+		t.Logf("Learning results: %+v", d.model.Load().classifier.learningResults)
+		assert.Equal(t, 7, d.model.Load().classifier.nAllDocument)
+		assert.Contains(t, d.model.Load().classifier.learningResults["win"], spamClass("spam"))
+		assert.Contains(t, d.model.Load().classifier.learningResults["prize"], spamClass("spam"))
+		assert.Contains(t, d.model.Load().classifier.learningResults["world"], spamClass("ham"))
+		assert.Contains(t, d.model.Load().classifier.learningResults["some"], spamClass("ham"))
 	})
 }
 
-func TestDetector_tokenize(t *testing.T) {
+func TestModel_tokenize(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -1328,13 +1482,13 @@ func TestDetector_tokenize(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			d := Detector{excludedTokens: map[string]struct{}{"the": {}, "she": {}}}
+			d := &model{excludedTokens: map[string]struct{}{"the": {}, "she": {}}}
 			assert.Equal(t, tt.expected, d.tokenize(tt.input))
 		})
 	}
 }
 
-func TestDetector_readerIterator(t *testing.T) {
+func Test_readerIterator(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -1350,10 +1504,9 @@ func TestDetector_readerIterator(t *testing.T) {
 		{name: "with empty lines", input: " hello blah\n\n  \n the new world \n  \n", expected: []string{"hello blah", "the new world"}},
 	}
 
-	d := Detector{}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ch := d.readerIterator(bytes.NewBufferString(tt.input))
+			ch := readerIterator(bytes.NewBufferString(tt.input))
 			res := []string{}
 			for token := range ch {
 				res = append(res, token)
@@ -1363,9 +1516,8 @@ func TestDetector_readerIterator(t *testing.T) {
 	}
 }
 
-func TestDetector_readerIteratorMultipleReaders(t *testing.T) {
-	d := Detector{}
-	ch := d.readerIterator(bytes.NewBufferString("hello\nworld"), bytes.NewBufferString("something, new"))
+func Test_readerIteratorMultipleReaders(t *testing.T) {
+	ch := readerIterator(bytes.NewBufferString("hello\nworld"), bytes.NewBufferString("something, new"))
 	res := []string{}
 	for token := range ch {
 		res = append(res, token)
@@ -1590,8 +1742,8 @@ func TestDetector_RemoveSpamHam(t *testing.T) {
 	})
 }
 
-func TestDetector_buildDocs(t *testing.T) {
-	d := &Detector{excludedTokens: map[string]struct{}{"the": {}, "and": {}}}
+func TestModel_buildDocs(t *testing.T) {
+	d := &model{excludedTokens: map[string]struct{}{"the": {}, "and": {}}}
 
 	docs := d.buildDocs("buy crypto coins now", "spam")
 	assert.Equal(t, 1, len(docs), "should create single document")
@@ -1631,7 +1783,7 @@ func TestDetector_CheckHistory(t *testing.T) {
 }
 
 func BenchmarkTokenize(b *testing.B) {
-	d := &Detector{
+	d := &model{
 		excludedTokens: map[string]struct{}{"the": {}, "and": {}, "or": {}, "but": {}, "in": {}, "on": {}, "at": {}, "to": {}},
 	}
 
@@ -1773,3 +1925,131 @@ func main() {
 		}
 	}
 }
+
+func TestDetector_UpdatesDontWaitForChecks(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1, FirstMessageOnly: true})
+	d.WithSpamUpdater(&mocks.SampleUpdaterMock{AppendFunc: func(string) error { return nil }})
+	_, err := d.LoadStopWords(strings.NewReader("old phrase"))
+	require.NoError(t, err)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	d.WithCheckers(NewChecker("slow", PhaseNetwork, nil, func(context.Context, *CheckState) spamcheck.Response {
+		close(started)
+		<-release
+		return spamcheck.Response{Name: "slow"}
+	}))
+
+	type result struct {
+		spam bool
+		cr   []spamcheck.Response
+	}
+	resCh := make(chan result)
+	go func() {
+		spam, cr := d.Check(spamcheck.Request{Msg: "some old phrase here", UserID: "123"})
+		resCh <- result{spam, cr}
+	}()
+	<-started
+
+	// all updates should complete while the check is still running
+	_, err = d.LoadStopWords(strings.NewReader("new phrase"))
+	require.NoError(t, err)
+	_, err = d.LoadSamples(strings.NewReader("xyz"), []io.Reader{strings.NewReader("win free iphone")},
+		[]io.Reader{strings.NewReader("hello world")})
+	require.NoError(t, err)
+	require.NoError(t, d.UpdateSpam("another spam message"))
+	require.NoError(t, d.AddApprovedUser(approved.UserInfo{UserID: "456"}))
+	assert.Equal(t, []string{"new phrase"}, d.model.Load().stopWords)
+	assert.Len(t, d.model.Load().tokenizedSpam, 2)
+
+	close(release)
+	res := <-resCh
+	assert.True(t, res.spam, "the running check uses the old model")
+	require.Len(t, res.cr, 2)
+	assert.Equal(t, spamcheck.Response{Name: "stopword", Spam: true, Details: "old phrase", Score: 1,
+		Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: "old phrase"}}}, res.cr[0])
+
+	require.True(t, d.RemoveChecker("slow"))
+	_, cr := d.Check(spamcheck.Request{Msg: "some old phrase here", UserID: "789"})
+	require.NotEmpty(t, cr)
+	assert.Equal(t, spamcheck.Response{Name: "stopword", Spam: false, Details: "not found"}, cr[0], "the next check uses the new model")
+}
+
+func TestDetector_CheckWithScoring(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: 1})
+	d.Scoring.Threshold = 1.5
+	d.Scoring.Weights = map[string]float64{"emoji": 0.5, "links": 0.7}
+	d.Scoring.HardChecks = []string{"stopword"}
+	d.WithCheckers(MetaChecker("links", LinksCheck(0)))
+	_, err := d.LoadStopWords(strings.NewReader("bad phrase"))
+	require.NoError(t, err)
+
+	tbl := []struct {
+		name  string
+		msg   string
+		spam  bool
+		score float64
+	}{
+		{name: "clean", msg: "hello world", spam: false, score: 0},
+		{name: "weak signal only", msg: "hello world 😀😀", spam: false, score: 0.5},
+		{name: "two weak signals", msg: "hello world 😀😀 https://example.com", spam: false, score: 1.2},
+		{name: "hard check", msg: "some bad phrase", spam: true, score: 1},
+		{name: "hard check and weak signals", msg: "some bad phrase 😀😀 https://example.com", spam: true, score: 2.2},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, cr := d.Check(spamcheck.Request{Msg: tt.msg})
+			assert.Equal(t, tt.spam, spam, cr)
+			assert.InDelta(t, tt.score, spamcheck.TotalScore(cr), 0.001)
+		})
+	}
+
+	t.Run("threshold reached without hard check", func(t *testing.T) {
+		d.Scoring.Weights["links"] = 1.0
+		spam, cr := d.Check(spamcheck.Request{Msg: "hello world 😀😀 https://example.com"})
+		assert.True(t, spam, cr)
+		assert.InDelta(t, 1.5, spamcheck.TotalScore(cr), 0.001)
+		require.Len(t, cr, 3)
+		assert.True(t, cr[1].Spam)
+		assert.InDelta(t, 0.5, cr[1].Score, 0.001, "emoji score weighted")
+	})
+
+	t.Run("ham of model checks not scored", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.9})
+		d.Scoring.Threshold = 0.5
+		_, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader("win a free prize now\nfree money now")},
+			[]io.Reader{strings.NewReader("hello there friend\nhow are you doing")})
+		require.NoError(t, err)
+
+		spam, cr := d.Check(spamcheck.Request{Msg: "hello my friend"})
+		assert.False(t, spam, cr)
+		require.Len(t, cr, 2)
+		assert.Equal(t, "similarity", cr[0].Name)
+		assert.Zero(t, cr[0].Score)
+		assert.Equal(t, "classifier", cr[1].Name)
+		assert.False(t, cr[1].Spam)
+		assert.Zero(t, cr[1].Score)
+
+		spam, cr = d.Check(spamcheck.Request{Msg: "free prize now"})
+		assert.True(t, spam, cr)
+		assert.Positive(t, spamcheck.TotalScore(cr))
+	})
+}
+
+func TestDetector_ApprovedUser(t *testing.T) {
+	d := NewDetector(Config{FirstMessagesCount: 1, FirstMessageOnly: true, MaxAllowedEmoji: -1})
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	require.NoError(t, d.AddApprovedUser(approved.UserInfo{UserID: "123", UserName: "user1", Timestamp: ts}))
+
+	ui, ok := d.ApprovedUser("123")
+	require.True(t, ok)
+	assert.Equal(t, "user1", ui.UserName)
+	assert.Equal(t, ts, ui.Timestamp)
+
+	_, ok = d.ApprovedUser("456")
+	assert.False(t, ok, "unknown user")
+
+	d.Check(spamcheck.Request{Msg: "first message", UserID: "789"})
+	_, ok = d.ApprovedUser("789")
+	assert.False(t, ok, "not enough messages to be approved")
+}
@@ -0,0 +1,211 @@
+package tgspam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// URLResolver resolves a shortened link to the final url, e.g. by following redirects.
+type URLResolver interface {
+	Resolve(ctx context.Context, link string) (string, error)
+}
+
+// HTTPResolver is URLResolver following redirects of the link with HEAD request
+type HTTPResolver struct {
+	Client HTTPClient // http client following redirects, e.g. http.Client with timeout
+}
+
+// Resolve makes HEAD request to the link and returns the url of the final request after all redirects
+func (r HTTPResolver) Resolve(ctx context.Context, link string) (string, error) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request to %s: %w", link, err)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", link, err)
+	}
+	defer resp.Body.Close()
+	if resp.Request == nil || resp.Request.URL == nil {
+		return link, nil
+	}
+	return resp.Request.URL.String(), nil
+}
+
+// knownShorteners is a list of popular url shorteners, links via them hide the real domain
+var knownShorteners = []string{"bit.ly", "bitly.com", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "v.gd",
+	"buff.ly", "cutt.ly", "rebrand.ly", "shorturl.at", "tiny.cc", "rb.gy", "clck.ru", "s.id", "lnkd.in", "tiny.one",
+	"shorte.st", "adf.ly", "bl.ink", "t.ly", "qps.ru", "u.to"}
+
+var domainLinkRe = regexp.MustCompile(`https?://\S+`)
+
+// LoadDomains loads denied and allowed domains, one per line. Resets both lists before loading.
+// An entry is a domain, like "example.com", a domain with all subdomains, like "*.example.com",
+// or a domain with the path prefix, like "t.me/+" matching all private telegram invites.
+func (d *Detector) LoadDomains(denied, allowed io.Reader) (LoadResult, error) {
+	read := func(r io.Reader) []string {
+		res := []string{}
+		if r == nil {
+			return res
+		}
+		for t := range readerIterator(r) {
+			if entry := normalizeDomainEntry(t); entry != "" {
+				res = append(res, entry)
+			}
+		}
+		return res
+	}
+	deniedDomains, allowedDomains := read(denied), read(allowed)
+
+	d.modelLock.Lock()
+	defer d.modelLock.Unlock()
+	m := *d.model.Load() // shallow copy is enough, only domain lists replaced
+	m.deniedDomains, m.allowedDomains = deniedDomains, allowedDomains
+	d.model.Store(&m)
+	return LoadResult{DeniedDomains: len(deniedDomains), AllowedDomains: len(allowedDomains)}, nil
+}
+
+// WithURLResolver sets the resolver for shortened links. Without resolver, shortened links are not followed.
+func (d *Detector) WithURLResolver(r URLResolver) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.urlResolver = r
+}
+
+// isDeniedDomain checks domains of the message links against denied and allowed lists.
+// Allowed list wins over denied one. Links via known shorteners are resolved if resolver is set,
+// and considered spam if Domains.DenyShorteners is set and the link can't be resolved to an allowed domain.
+func (d *Detector) isDeniedDomain(ctx context.Context, m *model, req spamcheck.Request) spamcheck.Response {
+	links := req.Meta.URLs
+	if len(links) == 0 {
+		links = domainLinkRe.FindAllString(req.Msg, -1)
+	}
+	d.lock.RLock()
+	resolver := d.urlResolver
+	d.lock.RUnlock()
+
+	for _, link := range links {
+		target := linkTarget(link)
+		if target == "" || matchDomain(m.allowedDomains, target) {
+			continue
+		}
+		if entry := matchedDomain(m.deniedDomains, target); entry != "" {
+			return spamcheck.Response{Name: "domain", Spam: true, Details: fmt.Sprintf("link %s matches denied %s", link, entry)}
+		}
+		if !isShortener(target) {
+			continue
+		}
+
+		if resolver != nil {
+			resolved, err := resolver.Resolve(ctx, link)
+			if err == nil {
+				resolvedTarget := linkTarget(resolved)
+				if resolvedTarget != "" && matchDomain(m.allowedDomains, resolvedTarget) {
+					continue
+				}
+				if entry := matchedDomain(m.deniedDomains, resolvedTarget); entry != "" {
+					return spamcheck.Response{Name: "domain", Spam: true,
+						Details: fmt.Sprintf("link %s resolved to %s, matches denied %s", link, resolved, entry)}
+				}
+				if resolvedTarget != "" && !isShortener(resolvedTarget) {
+					continue // resolved to a regular domain, not in any list
+				}
+			}
+		}
+		if d.Domains.DenyShorteners {
+			return spamcheck.Response{Name: "domain", Spam: true, Details: fmt.Sprintf("shortened link %s", link)}
+		}
+	}
+	return spamcheck.Response{Name: "domain", Spam: false, Details: "no denied domains"}
+}
+
+// linkTarget returns the normalized host with path of the link, e.g. "t.me/+abc" for "https://telegram.me/joinchat/abc".
+// Empty string returned for invalid links.
+func linkTarget(link string) string {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), "www.")
+	if host == "telegram.me" || host == "telegram.dog" {
+		host = "t.me"
+	}
+	path := u.EscapedPath()
+	if host == "t.me" && strings.HasPrefix(path, "/joinchat/") {
+		path = "/+" + strings.TrimPrefix(path, "/joinchat/") // both forms are private invite links
+	}
+	return host + path
+}
+
+// normalizeDomainEntry makes a list entry comparable with link targets, empty string for empty entry
+func normalizeDomainEntry(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if entry == "" {
+		return ""
+	}
+	if _, rest, ok := strings.Cut(entry, "://"); ok {
+		entry = rest
+	}
+	if strings.HasPrefix(entry, "*.") {
+		return entry
+	}
+	entry = strings.TrimPrefix(entry, "www.")
+	for _, alias := range []string{"telegram.me", "telegram.dog"} {
+		if entry == alias || strings.HasPrefix(entry, alias+"/") {
+			entry = "t.me" + strings.TrimPrefix(entry, alias)
+		}
+	}
+	if entry == "t.me/joinchat" || strings.HasPrefix(entry, "t.me/joinchat/") {
+		entry = "t.me/+" + strings.TrimPrefix(strings.TrimPrefix(entry, "t.me/joinchat"), "/")
+	}
+	return entry
+}
+
+// matchDomain reports if the link target matches any of entries
+func matchDomain(entries []string, target string) bool {
+	return matchedDomain(entries, target) != ""
+}
+
+// matchedDomain returns the first entry matching the link target, empty string if none matches.
+// "*.example.com" matches example.com and all its subdomains, "example.com" matches the domain only,
+// and "t.me/+" matches links with the path prefix.
+func matchedDomain(entries []string, target string) string {
+	host, path, _ := strings.Cut(target, "/")
+	path = strings.ToLower(path) // entries are lowercased on load
+	for _, e := range entries {
+		eHost, ePath, withPath := strings.Cut(e, "/")
+		if wildcard, ok := strings.CutPrefix(eHost, "*."); ok {
+			if host != wildcard && !strings.HasSuffix(host, "."+wildcard) {
+				continue
+			}
+		} else if host != eHost {
+			continue
+		}
+		if withPath && !strings.HasPrefix(path, ePath) {
+			continue
+		}
+		return e
+	}
+	return ""
+}
+
+// isShortener reports if the link target is on a domain of known url shortener
+func isShortener(target string) bool {
+	host, _, _ := strings.Cut(target, "/")
+	return slices.Contains(knownShorteners, host)
+}
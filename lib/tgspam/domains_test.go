@@ -0,0 +1,167 @@
+package tgspam
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// fakeResolver resolves links by the map, error for unknown links
+type fakeResolver map[string]string
+
+func (f fakeResolver) Resolve(_ context.Context, link string) (string, error) {
+	if res, ok := f[link]; ok {
+		return res, nil
+	}
+	return "", errors.New("can't resolve")
+}
+
+func TestDetector_LoadDomains(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	lr, err := d.LoadDomains(strings.NewReader("Spam.com\n*.casino.io\n\nhttps://telegram.me/joinchat"), strings.NewReader("www.example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, LoadResult{DeniedDomains: 3, AllowedDomains: 1}, lr)
+	assert.Equal(t, []string{"spam.com", "*.casino.io", "t.me/+"}, d.model.Load().deniedDomains)
+	assert.Equal(t, []string{"example.com"}, d.model.Load().allowedDomains)
+
+	// domains are kept on samples reload
+	_, err = d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader("spam text")},
+		[]io.Reader{strings.NewReader("ham text")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spam.com", "*.casino.io", "t.me/+"}, d.model.Load().deniedDomains)
+	assert.Equal(t, []string{"example.com"}, d.model.Load().allowedDomains)
+
+	lr, err = d.LoadDomains(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, LoadResult{}, lr)
+	assert.Empty(t, d.model.Load().deniedDomains)
+}
+
+func TestDetector_CheckDomains(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	d.Domains.Enabled = true
+	_, err := d.LoadDomains(strings.NewReader("spam.com\n*.casino.io\nt.me/+\nevil.org/promo"),
+		strings.NewReader("good.casino.io\nbit.ly/official"))
+	require.NoError(t, err)
+
+	tbl := []struct {
+		name    string
+		req     spamcheck.Request
+		spam    bool
+		details string
+	}{
+		{name: "no links", req: spamcheck.Request{Msg: "hello"}, details: "no denied domains"},
+		{name: "denied domain in text", req: spamcheck.Request{Msg: "go to https://www.spam.com/x now"},
+			spam: true, details: "link https://www.spam.com/x matches denied spam.com"},
+		{name: "subdomain of denied domain is not denied", req: spamcheck.Request{Msg: "https://sub.spam.com"},
+			details: "no denied domains"},
+		{name: "wildcard subdomain", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"win.casino.io/abc"}}},
+			spam: true, details: "link win.casino.io/abc matches denied *.casino.io"},
+		{name: "wildcard matches the domain itself", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"casino.io"}}},
+			spam: true, details: "link casino.io matches denied *.casino.io"},
+		{name: "allowed wins over denied", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"https://good.casino.io"}}},
+			details: "no denied domains"},
+		{name: "private invite", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"t.me/+AbCd"}}},
+			spam: true, details: "link t.me/+AbCd matches denied t.me/+"},
+		{name: "joinchat invite", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"https://telegram.me/joinchat/AbCd"}}},
+			spam: true, details: "link https://telegram.me/joinchat/AbCd matches denied t.me/+"},
+		{name: "public channel", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"t.me/somechannel"}}},
+			details: "no denied domains"},
+		{name: "path prefix", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"evil.org/promo/1", "evil.org/about"}}},
+			spam: true, details: "link evil.org/promo/1 matches denied evil.org/promo"},
+		{name: "shortener without resolver", req: spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{"bit.ly/abc"}}},
+			details: "no denied domains"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, cr := d.Check(tt.req)
+			require.Len(t, cr, 1)
+			assert.Equal(t, tt.spam, spam)
+			assert.Equal(t, "domain", cr[0].Name)
+			assert.Equal(t, tt.spam, cr[0].Spam)
+			assert.Equal(t, tt.details, cr[0].Details)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		dd := NewDetector(Config{MaxAllowedEmoji: -1})
+		_, err := dd.LoadDomains(strings.NewReader("spam.com"), nil)
+		require.NoError(t, err)
+		spam, cr := dd.Check(spamcheck.Request{Msg: "https://spam.com"})
+		assert.False(t, spam)
+		assert.Empty(t, cr)
+	})
+}
+
+func TestDetector_CheckDomainsShorteners(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	d.Domains.Enabled = true
+	d.Domains.DenyShorteners = true
+	_, err := d.LoadDomains(strings.NewReader("spam.com"), strings.NewReader("bit.ly/official"))
+	require.NoError(t, err)
+
+	check := func(link string) spamcheck.Response {
+		_, cr := d.Check(spamcheck.Request{Meta: spamcheck.MetaData{URLs: []string{link}}})
+		require.Len(t, cr, 1)
+		return spamcheck.Response{Name: cr[0].Name, Spam: cr[0].Spam, Details: cr[0].Details} // score is not checked
+	}
+
+	assert.Equal(t, spamcheck.Response{Name: "domain", Spam: true, Details: "shortened link bit.ly/abc"}, check("bit.ly/abc"))
+	assert.Equal(t, spamcheck.Response{Name: "domain", Details: "no denied domains"}, check("bit.ly/official"), "allowed shortened link")
+
+	d.WithURLResolver(fakeResolver{"bit.ly/abc": "https://spam.com/landing", "tinyurl.com/x": "https://example.com/page",
+		"t.co/loop": "https://bit.ly/other"})
+	assert.Equal(t, spamcheck.Response{Name: "domain", Spam: true,
+		Details: "link bit.ly/abc resolved to https://spam.com/landing, matches denied spam.com"}, check("bit.ly/abc"))
+	assert.Equal(t, spamcheck.Response{Name: "domain", Details: "no denied domains"}, check("tinyurl.com/x"), "resolved to regular domain")
+	assert.Equal(t, spamcheck.Response{Name: "domain", Spam: true, Details: "shortened link t.co/loop"}, check("t.co/loop"),
+		"resolved to another shortener")
+	assert.Equal(t, spamcheck.Response{Name: "domain", Spam: true, Details: "shortened link goo.gl/xyz"}, check("goo.gl/xyz"),
+		"failed to resolve")
+}
+
+func TestHTTPResolver_Resolve(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, "/final?x=1", http.StatusMovedPermanently)
+			return
+		}
+		assert.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	r := HTTPResolver{Client: ts.Client()}
+	res, err := r.Resolve(context.Background(), ts.URL+"/short")
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/final?x=1", res)
+
+	_, err = r.Resolve(context.Background(), "http://127.0.0.1:1/short")
+	assert.Error(t, err)
+}
+
+func TestLinkTarget(t *testing.T) {
+	tbl := []struct {
+		link, want string
+	}{
+		{"https://Example.com/Path", "example.com/Path"},
+		{"www.example.com", "example.com"},
+		{"t.me/+abc", "t.me/+abc"},
+		{"https://t.me/joinchat/abc", "t.me/+abc"},
+		{"telegram.dog/channel", "t.me/channel"},
+		{"http://", ""},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.want, linkTarget(tt.link), tt.link)
+	}
+}
@@ -0,0 +1,124 @@
+package tgspam
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// formattingStats are counts of formatting tricks found in the message
+type formattingStats struct {
+	runes              int // all characters of the message
+	invisible          int // zero-width, format, control and blank filler characters
+	bidiOverrides      int // bidirectional embedding, override and isolate characters
+	maxCombiningMarks  int // max number of combining marks stacked on a single character
+	variationSelectors int // variation selectors not attached to emoji or keycaps
+}
+
+// isFormattingTrick checks the raw message for invisible characters, bidirectional overrides, combining marks
+// stacked on a single character ("zalgo") and variation selectors not attached to emoji. Each trick has its own
+// threshold in Formatting config, and all tricks above thresholds are reported in details.
+func (d *Detector) isFormattingTrick(msg string) spamcheck.Response {
+	st := formattingTricks(msg)
+	var tricks []string
+	if st.invisible > 0 && st.runes > 0 {
+		if ratio := float64(st.invisible) / float64(st.runes); ratio > d.Formatting.InvisibleRatio {
+			tricks = append(tricks, fmt.Sprintf("invisible characters %d (%.0f%%)", st.invisible, ratio*100))
+		}
+	}
+	if st.bidiOverrides > d.Formatting.BidiOverrides {
+		tricks = append(tricks, fmt.Sprintf("bidi overrides %d", st.bidiOverrides))
+	}
+	if d.Formatting.CombiningMarks > 0 && st.maxCombiningMarks > d.Formatting.CombiningMarks {
+		tricks = append(tricks, fmt.Sprintf("stacked combining marks %d", st.maxCombiningMarks))
+	}
+	if st.variationSelectors > d.Formatting.VariationSelectors {
+		tricks = append(tricks, fmt.Sprintf("variation selectors %d", st.variationSelectors))
+	}
+	if len(tricks) == 0 {
+		return spamcheck.Response{Name: "formatting", Spam: false, Details: "no tricks found"}
+	}
+	return spamcheck.Response{Name: "formatting", Spam: true, Details: strings.Join(tricks, ", ")}
+}
+
+// formattingTricks counts formatting tricks of the message. Zero-width joiners and variation selectors
+// used in emoji sequences, like "👨‍👩‍👧" or "❤️", and keycaps, like "1️⃣", are legit and not counted.
+// Zero-width non-joiners and soft hyphens inside words are legit too, the first is a part of regular
+// spelling in Persian and Indic scripts, the second marks hyphenation points in long words.
+func formattingTricks(msg string) formattingStats {
+	runes := []rune(msg)
+	res := formattingStats{runes: len(runes)}
+	marks := 0
+	for i, r := range runes {
+		if unicode.In(r, unicode.Mn, unicode.Me) && !isVariationSelector(r) {
+			marks++
+			res.maxCombiningMarks = max(res.maxCombiningMarks, marks)
+			continue
+		}
+		marks = 0
+
+		switch {
+		case isBidiOverride(r):
+			res.bidiOverrides++
+		case isVariationSelector(r):
+			if !isEmojiSequence(runes, i) {
+				res.variationSelectors++
+			}
+		case r == '\u200d': // zero-width joiner
+			if !isEmojiSequence(runes, i) {
+				res.invisible++
+			}
+		case r == '\u200c' || r == '\u00ad': // zero-width non-joiner and soft hyphen
+			if !isInsideWord(runes, i) {
+				res.invisible++
+			}
+		case isInvisible(r):
+			res.invisible++
+		}
+	}
+	return res
+}
+
+// isBidiOverride checks if the character is a bidirectional embedding, override or isolate
+func isBidiOverride(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+// isVariationSelector checks if the character is a variation selector
+func isVariationSelector(r rune) bool {
+	return (r >= 0xFE00 && r <= 0xFE0F) || (r >= 0xE0100 && r <= 0xE01EF)
+}
+
+// isInvisible checks if the character is zero-width, format, control or a blank filler. Common whitespace
+// characters are visible.
+func isInvisible(r rune) bool {
+	switch r {
+	case '\n', '\r', '\t':
+		return false
+	case '\u115f', '\u1160', '\u3164', '\uffa0', '\u2800': // hangul and braille blank fillers
+		return true
+	}
+	return unicode.In(r, unicode.Cc, unicode.Cf)
+}
+
+// isInsideWord checks if the character at position i is between letters, combining marks count as letters
+func isInsideWord(runes []rune, i int) bool {
+	isLetter := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsMark(r) }
+	return i > 0 && i+1 < len(runes) && isLetter(runes[i-1]) && isLetter(runes[i+1])
+}
+
+// isEmojiSequence checks if the joiner or variation selector at position i is a part of emoji sequence or keycap,
+// i.e. follows a symbol, or followed by the keycap mark
+func isEmojiSequence(runes []rune, i int) bool {
+	if i+1 < len(runes) && runes[i+1] == '\u20e3' {
+		return true // keycap, like "1️⃣"
+	}
+	if i == 0 {
+		return false
+	}
+	prev := runes[i-1]
+	return unicode.In(prev, unicode.So, unicode.Sk) || (prev >= 0x1F3FB && prev <= 0x1F3FF) || prev == '\u200d' ||
+		isVariationSelector(prev)
+}
@@ -0,0 +1,86 @@
+package tgspam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestFormattingTricks(t *testing.T) {
+	tbl := []struct {
+		name string
+		msg  string
+		want formattingStats
+	}{
+		{"plain text", "Hello, world!\nHow are you?", formattingStats{runes: 26}},
+		{"zero-width spaces", "Ear\u200bn mo\u200bney", formattingStats{runes: 12, invisible: 2}},
+		{"blank fillers", "\u3164\u2800hi", formattingStats{runes: 4, invisible: 2}},
+		{"bidi overrides", "click \u202egnp.exe\u202c", formattingStats{runes: 15, bidiOverrides: 2}},
+		{"bidi isolates", "\u2067text\u2069", formattingStats{runes: 6, bidiOverrides: 2}},
+		{"zalgo", "z\u0300\u0301\u0302\u0303\u0304a\u0305lgo", formattingStats{runes: 11, maxCombiningMarks: 5}},
+		{"vietnamese", "Việt Nam", formattingStats{runes: 8}},
+		{"vietnamese decomposed", "Vie\u0323\u0302t", formattingStats{runes: 6, maxCombiningMarks: 2}},
+		{"variation selectors on letters", "f\ufe0fr\ufe0fe\ufe0fe", formattingStats{runes: 7, variationSelectors: 3}},
+		{"emoji with variation selector", "I ❤\ufe0f it", formattingStats{runes: 7}},
+		{"emoji zwj sequence", "\U0001F468\u200d\U0001F469\u200d\U0001F467 family", formattingStats{runes: 12}},
+		{"keycap", "call 1\ufe0f\u20e3", formattingStats{runes: 8, maxCombiningMarks: 1}},
+		{"standalone zwj", "a\u200db", formattingStats{runes: 3, invisible: 1}},
+		{"persian zwnj", "می\u200cخواهم", formattingStats{runes: 8}},
+		{"hindi zwnj after virama", "क्\u200cष", formattingStats{runes: 4, maxCombiningMarks: 1}},
+		{"soft hyphens in word", "Donau\u00addampf\u00adschiff", formattingStats{runes: 18}},
+		{"zwnj and soft hyphens outside words", "\u200c\u200cEarn\u00ad \u00admoney\u200c", formattingStats{runes: 15, invisible: 5}},
+		{"empty", "", formattingStats{}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formattingTricks(tt.msg))
+		})
+	}
+}
+
+func TestDetector_CheckFormatting(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	d.Formatting.Enabled = true
+	d.Formatting.InvisibleRatio = 0.05
+	d.Formatting.CombiningMarks = 3
+	d.Formatting.VariationSelectors = 2
+
+	tbl := []struct {
+		name    string
+		msg     string
+		spam    bool
+		details string
+	}{
+		{"clean", "Hello, how are you? I ❤\ufe0f this group", false, "no tricks found"},
+		{"few invisible", strings.Repeat("a", 40) + "\u200b", false, "no tricks found"},
+		{"many invisible", "E\u200ba\u200br\u200bn m\u200bo\u200bn\u200be\u200by", true, "invisible characters 7 (41%)"},
+		{"persian text", "من می\u200cخواهم به این گروه بپیوندم", false, "no tricks found"},
+		{"soft hyphens", "Donau\u00addampf\u00adschiff\u00adfahrt", false, "no tricks found"},
+		{"bidi override", "invoice \u202efdp.exe", true, "bidi overrides 1"},
+		{"zalgo", "he\u0300\u0301\u0302\u0303llo", true, "stacked combining marks 4"},
+		{"variation selectors", "f\ufe0fr\ufe0fe\ufe0fe", true, "variation selectors 3"},
+		{"several tricks", "\u202ez\u0300\u0301\u0302\u0303\u0304\u200b", true,
+			"invisible characters 1 (12%), bidi overrides 1, stacked combining marks 5"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, cr := d.Check(spamcheck.Request{Msg: tt.msg})
+			assert.Equal(t, tt.spam, spam)
+			require.Len(t, cr, 1)
+			assert.Equal(t, "formatting", cr[0].Name)
+			assert.Equal(t, tt.spam, cr[0].Spam)
+			assert.Equal(t, tt.details, cr[0].Details)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		d.Formatting.Enabled = false
+		spam, cr := d.Check(spamcheck.Request{Msg: "invoice \u202efdp.exe"})
+		assert.False(t, spam)
+		assert.Empty(t, cr)
+	})
+}
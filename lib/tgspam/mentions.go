@@ -0,0 +1,102 @@
+package tgspam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// mention target types, same as telegram chat types, plus bot and unknown for unresolved targets
+const (
+	MentionTypeUnknown    = ""
+	MentionTypePrivate    = "private"
+	MentionTypeGroup      = "group"
+	MentionTypeSupergroup = "supergroup"
+	MentionTypeChannel    = "channel"
+	MentionTypeBot        = "bot"
+)
+
+// MentionTarget is a resolved target of @username mention
+type MentionTarget struct {
+	Username string // username without "@"
+	ID       int64  // chat or user id, 0 if not resolved
+	Type     string // one of MentionType* values
+}
+
+// MentionResolver resolves mentioned username to the target, e.g. with telegram getChat call.
+// Unknown usernames are not an error, MentionTypeUnknown returned for them.
+type MentionResolver interface {
+	ResolveMention(ctx context.Context, username string) (MentionTarget, error)
+}
+
+// WithMentionResolver sets the resolver for mentioned usernames. Without resolver, mention target check is disabled.
+func (d *Detector) WithMentionResolver(r MentionResolver) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.mentionRes = r
+}
+
+func (d *Detector) mentionResolver() MentionResolver {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.mentionRes
+}
+
+// isSuspiciousMention checks targets of mentioned usernames. Mentions of channels and bots are spam unless
+// the username is in Mentions.Allowed list, and so are mentions of recently created targets, i.e. with id above
+// Mentions.RecentID. Telegram ids are assigned sequentially, so the id is a rough estimation of the target age.
+// Targets failed to resolve are skipped.
+func (d *Detector) isSuspiciousMention(ctx context.Context, req spamcheck.Request) spamcheck.Response {
+	resolver := d.mentionResolver()
+	resolved := 0
+	var lastErr error
+	for _, username := range req.Meta.MentionedUsernames {
+		username = strings.TrimPrefix(username, "@")
+		if d.isMentionAllowed(username) {
+			continue
+		}
+		target, err := resolver.ResolveMention(ctx, username)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resolved++
+		switch {
+		case target.Type == MentionTypeChannel:
+			return spamcheck.Response{Name: "mention-target", Spam: true, Details: fmt.Sprintf("mention of channel @%s", username)}
+		case target.Type == MentionTypeBot:
+			return spamcheck.Response{Name: "mention-target", Spam: true, Details: fmt.Sprintf("mention of bot @%s", username)}
+		case d.Mentions.RecentID > 0 && peerID(target.ID) > d.Mentions.RecentID:
+			return spamcheck.Response{Name: "mention-target", Spam: true,
+				Details: fmt.Sprintf("mention of recently created %s @%s", target.Type, username)}
+		}
+	}
+	if lastErr != nil && resolved == 0 {
+		return spamcheck.Response{Name: "mention-target", Spam: false, Details: "failed to resolve mentions", Error: lastErr}
+	}
+	return spamcheck.Response{Name: "mention-target", Spam: false, Details: fmt.Sprintf("mentions checked: %d", resolved)}
+}
+
+func (d *Detector) isMentionAllowed(username string) bool {
+	for _, a := range d.Mentions.Allowed {
+		if strings.EqualFold(strings.TrimPrefix(a, "@"), username) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerID returns the id without telegram's chat type prefix, i.e. -100xxx for channels and supergroups
+// and -xxx for groups, making ids of all targets comparable
+func peerID(id int64) int64 {
+	const channelPrefix = 1000000000000
+	switch {
+	case id <= -channelPrefix:
+		return -id - channelPrefix
+	case id < 0:
+		return -id
+	}
+	return id
+}
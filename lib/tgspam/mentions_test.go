@@ -0,0 +1,81 @@
+package tgspam
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// fakeMentionResolver resolves usernames by the map, error for unknown usernames
+type fakeMentionResolver map[string]MentionTarget
+
+func (f fakeMentionResolver) ResolveMention(_ context.Context, username string) (MentionTarget, error) {
+	if res, ok := f[username]; ok {
+		return res, nil
+	}
+	return MentionTarget{}, errors.New("can't resolve")
+}
+
+func TestDetector_CheckMentions(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	d.Mentions.Enabled = true
+	d.Mentions.Allowed = []string{"@OurChannel", "helperbot"}
+	d.Mentions.RecentID = 7000000000
+	d.WithMentionResolver(fakeMentionResolver{
+		"ourchannel": {Username: "ourchannel", ID: -1001234567890, Type: MentionTypeChannel},
+		"promo":      {Username: "promo", ID: -1001234567891, Type: MentionTypeChannel},
+		"coolbot":    {Username: "coolbot", Type: MentionTypeBot},
+		"oldgroup":   {Username: "oldgroup", ID: -1001234567892, Type: MentionTypeSupergroup},
+		"newgroup":   {Username: "newgroup", ID: -1007123456789, Type: MentionTypeSupergroup},
+		"someone":    {Username: "someone", Type: MentionTypeUnknown},
+	})
+
+	tbl := []struct {
+		name      string
+		usernames []string
+		spam      bool
+		details   string
+		err       bool
+	}{
+		{name: "no mentions", details: "mentions checked: 0"},
+		{name: "channel", usernames: []string{"someone", "promo"}, spam: true, details: "mention of channel @promo"},
+		{name: "allowed channel, case-insensitive", usernames: []string{"ourchannel"}, details: "mentions checked: 0"},
+		{name: "bot", usernames: []string{"coolbot"}, spam: true, details: "mention of bot @coolbot"},
+		{name: "allowed bot", usernames: []string{"HelperBot"}, details: "mentions checked: 0"},
+		{name: "old group", usernames: []string{"oldgroup", "someone"}, details: "mentions checked: 2"},
+		{name: "recently created group", usernames: []string{"newgroup"}, spam: true,
+			details: "mention of recently created supergroup @newgroup"},
+		{name: "failed to resolve", usernames: []string{"unknown"}, details: "failed to resolve mentions", err: true},
+		{name: "failed to resolve some", usernames: []string{"unknown", "someone"}, details: "mentions checked: 1"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, cr := d.Check(spamcheck.Request{Msg: "hello", Meta: spamcheck.MetaData{MentionedUsernames: tt.usernames}})
+			require.Len(t, cr, 1)
+			assert.Equal(t, tt.spam, spam)
+			assert.Equal(t, "mention-target", cr[0].Name)
+			assert.Equal(t, tt.details, cr[0].Details)
+			assert.Equal(t, tt.err, cr[0].Error != nil)
+		})
+	}
+
+	t.Run("disabled without resolver", func(t *testing.T) {
+		dd := NewDetector(Config{MaxAllowedEmoji: -1})
+		dd.Mentions.Enabled = true
+		spam, cr := dd.Check(spamcheck.Request{Msg: "hello", Meta: spamcheck.MetaData{MentionedUsernames: []string{"promo"}}})
+		assert.False(t, spam)
+		assert.Empty(t, cr)
+	})
+}
+
+func TestPeerID(t *testing.T) {
+	assert.Equal(t, int64(1234567890), peerID(-1001234567890))
+	assert.Equal(t, int64(123456), peerID(-123456))
+	assert.Equal(t, int64(123456), peerID(123456))
+}
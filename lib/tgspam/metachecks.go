@@ -2,6 +2,7 @@ package tgspam
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 
@@ -13,10 +14,14 @@ import (
 type MetaCheck func(req spamcheck.Request) spamcheck.Response
 
 // LinksCheck is a function that returns a MetaCheck function that checks the number of links in the message.
-// It uses custom meta-info if it is provided, otherwise it counts the number of links in the message.
+// It uses custom meta-info if it is provided, i.e. the list of links or the number of links,
+// otherwise it counts the number of links in the message.
 func LinksCheck(limit int) MetaCheck {
 	return func(req spamcheck.Request) spamcheck.Response {
 		links := req.Meta.Links
+		if len(req.Meta.URLs) > 0 {
+			links = len(req.Meta.URLs)
+		}
 		if links == 0 {
 			links = strings.Count(req.Msg, "http://") + strings.Count(req.Msg, "https://")
 		}
@@ -34,6 +39,7 @@ func LinksCheck(limit int) MetaCheck {
 var linkRe = regexp.MustCompile(`https?://\S+`)
 
 // LinkOnlyCheck is a function that returns a MetaCheck function that checks if the req.Msg contains only links.
+// Links of the meta-info are removed from the message as well, to catch links without scheme like t.me/xxx.
 func LinkOnlyCheck() MetaCheck {
 	return func(req spamcheck.Request) spamcheck.Response {
 		if strings.TrimSpace(req.Msg) == "" {
@@ -44,6 +50,9 @@ func LinkOnlyCheck() MetaCheck {
 			}
 		}
 		msgWithoutLinks := linkRe.ReplaceAllString(req.Msg, "")
+		for _, u := range req.Meta.URLs {
+			msgWithoutLinks = strings.ReplaceAll(msgWithoutLinks, u, "")
+		}
 		msgWithoutLinks = strings.TrimSpace(msgWithoutLinks)
 
 		if msgWithoutLinks == "" {
@@ -205,3 +214,88 @@ func UsernameSymbolsCheck(symbols string) MetaCheck {
 		}
 	}
 }
+
+// DocumentCheck is a function that returns a MetaCheck function.
+// It checks if the document's file name matches any of the denied entries. An entry is either a file extension,
+// like "apk" or ".apk", or a file name pattern, like "*.exe" or "invoice*.pdf", see path.Match.
+// Matching is case-insensitive. If denied is empty, the check is disabled.
+func DocumentCheck(denied []string) MetaCheck {
+	return func(req spamcheck.Request) spamcheck.Response {
+		if len(denied) == 0 {
+			return spamcheck.Response{Name: "document", Spam: false, Details: "check disabled"}
+		}
+		if !req.Meta.HasDocument {
+			return spamcheck.Response{Name: "document", Spam: false, Details: "no document"}
+		}
+
+		name := strings.ToLower(req.Meta.DocumentName)
+		ext := strings.TrimPrefix(path.Ext(name), ".")
+		for _, d := range denied {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if d == "" {
+				continue
+			}
+			if strings.ContainsAny(d, "*?[") {
+				if matched, err := path.Match(d, name); err == nil && matched {
+					return spamcheck.Response{Name: "document", Spam: true,
+						Details: fmt.Sprintf("document %q matches %q", req.Meta.DocumentName, d)}
+				}
+				continue
+			}
+			if ext != "" && ext == strings.TrimPrefix(d, ".") {
+				return spamcheck.Response{Name: "document", Spam: true,
+					Details: fmt.Sprintf("document %q has prohibited extension %q", req.Meta.DocumentName, ext)}
+			}
+		}
+		return spamcheck.Response{Name: "document", Spam: false, Details: "document allowed"}
+	}
+}
+
+// ContactCheck is a function that returns a MetaCheck function.
+// It checks if the message has a contact card. Meta checks are applied to the first messages only,
+// so it catches contact cards sent by new users.
+func ContactCheck() MetaCheck {
+	return func(req spamcheck.Request) spamcheck.Response {
+		if req.Meta.HasContact {
+			return spamcheck.Response{
+				Name:    "contact",
+				Spam:    true,
+				Details: "contact card from new user",
+			}
+		}
+		return spamcheck.Response{
+			Name:    "contact",
+			Spam:    false,
+			Details: "no contact card",
+		}
+	}
+}
+
+// ViaBotCheck is a function that returns a MetaCheck function.
+// It checks if the message is sent via an inline bot, except the allowed bots. Bot usernames are case-insensitive,
+// with or without "@" prefix.
+func ViaBotCheck(allowed []string) MetaCheck {
+	return func(req spamcheck.Request) spamcheck.Response {
+		if req.Meta.ViaBot == "" {
+			return spamcheck.Response{
+				Name:    "via-bot",
+				Spam:    false,
+				Details: "not sent via bot",
+			}
+		}
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(a), "@"), req.Meta.ViaBot) {
+				return spamcheck.Response{
+					Name:    "via-bot",
+					Spam:    false,
+					Details: fmt.Sprintf("sent via allowed bot @%s", req.Meta.ViaBot),
+				}
+			}
+		}
+		return spamcheck.Response{
+			Name:    "via-bot",
+			Spam:    true,
+			Details: fmt.Sprintf("sent via inline bot @%s", req.Meta.ViaBot),
+		}
+	}
+}
@@ -23,6 +23,15 @@ func TestLinksCheck(t *testing.T) {
 			limit:    2,
 			expected: spamcheck.Response{Name: "links", Spam: false, Details: "links 0/2"},
 		},
+		{
+			name: "Above limit with meta urls",
+			req: spamcheck.Request{
+				Msg:  "Join t.me/xxx or click here",
+				Meta: spamcheck.MetaData{URLs: []string{"t.me/xxx", "https://spam.com"}, Links: 2},
+			},
+			limit:    1,
+			expected: spamcheck.Response{Name: "links", Spam: true, Details: "too many links 2/1"},
+		},
 		{
 			name: "Below limit with meta",
 			req: spamcheck.Request{
@@ -91,6 +100,22 @@ func TestLinkOnlyCheck(t *testing.T) {
 			},
 			expected: spamcheck.Response{Name: "link-only", Spam: true, Details: "message contains links only"},
 		},
+		{
+			name: "with only links without scheme",
+			req: spamcheck.Request{
+				Msg:  "t.me/xxx bit.ly/abc",
+				Meta: spamcheck.MetaData{URLs: []string{"t.me/xxx", "bit.ly/abc"}},
+			},
+			expected: spamcheck.Response{Name: "link-only", Spam: true, Details: "message contains links only"},
+		},
+		{
+			name: "with hidden link and text",
+			req: spamcheck.Request{
+				Msg:  "click here",
+				Meta: spamcheck.MetaData{URLs: []string{"https://spam.com"}},
+			},
+			expected: spamcheck.Response{Name: "link-only", Spam: false, Details: "message contains text"},
+		},
 		{
 			name: "with a single link, no text",
 			req: spamcheck.Request{
@@ -586,3 +611,110 @@ func TestUsernameSymbolsCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestDocumentCheck(t *testing.T) {
+	denied := []string{"apk", ".EXE", "invoice*.pdf"}
+	tests := []struct {
+		name     string
+		denied   []string
+		meta     spamcheck.MetaData
+		expected spamcheck.Response
+	}{
+		{
+			name:     "check disabled",
+			meta:     spamcheck.MetaData{HasDocument: true, DocumentName: "app.apk"},
+			expected: spamcheck.Response{Name: "document", Spam: false, Details: "check disabled"},
+		},
+		{
+			name:     "no document",
+			denied:   denied,
+			expected: spamcheck.Response{Name: "document", Spam: false, Details: "no document"},
+		},
+		{
+			name:   "denied extension",
+			denied: denied,
+			meta:   spamcheck.MetaData{HasDocument: true, DocumentName: "Free-VPN.APK"},
+			expected: spamcheck.Response{Name: "document", Spam: true,
+				Details: `document "Free-VPN.APK" has prohibited extension "apk"`},
+		},
+		{
+			name:   "denied extension with dot",
+			denied: denied,
+			meta:   spamcheck.MetaData{HasDocument: true, DocumentName: "setup.exe"},
+			expected: spamcheck.Response{Name: "document", Spam: true,
+				Details: `document "setup.exe" has prohibited extension "exe"`},
+		},
+		{
+			name:   "denied pattern",
+			denied: denied,
+			meta:   spamcheck.MetaData{HasDocument: true, DocumentName: "Invoice-2024.pdf"},
+			expected: spamcheck.Response{Name: "document", Spam: true,
+				Details: `document "Invoice-2024.pdf" matches "invoice*.pdf"`},
+		},
+		{
+			name:     "allowed document",
+			denied:   denied,
+			meta:     spamcheck.MetaData{HasDocument: true, DocumentName: "report.pdf"},
+			expected: spamcheck.Response{Name: "document", Spam: false, Details: "document allowed"},
+		},
+		{
+			name:     "document without name",
+			denied:   denied,
+			meta:     spamcheck.MetaData{HasDocument: true},
+			expected: spamcheck.Response{Name: "document", Spam: false, Details: "document allowed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := DocumentCheck(tt.denied)
+			assert.Equal(t, tt.expected, check(spamcheck.Request{Meta: tt.meta}))
+		})
+	}
+}
+
+func TestContactCheck(t *testing.T) {
+	check := ContactCheck()
+	assert.Equal(t, spamcheck.Response{Name: "contact", Spam: false, Details: "no contact card"},
+		check(spamcheck.Request{Msg: "some text"}))
+	assert.Equal(t, spamcheck.Response{Name: "contact", Spam: true, Details: "contact card from new user"},
+		check(spamcheck.Request{Meta: spamcheck.MetaData{HasContact: true}}))
+}
+
+func TestViaBotCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		viaBot   string
+		expected spamcheck.Response
+	}{
+		{
+			name:     "not via bot",
+			expected: spamcheck.Response{Name: "via-bot", Spam: false, Details: "not sent via bot"},
+		},
+		{
+			name:     "via bot",
+			viaBot:   "promo_bot",
+			expected: spamcheck.Response{Name: "via-bot", Spam: true, Details: "sent via inline bot @promo_bot"},
+		},
+		{
+			name:     "via allowed bot",
+			allowed:  []string{"@gif", "Vote"},
+			viaBot:   "vote",
+			expected: spamcheck.Response{Name: "via-bot", Spam: false, Details: "sent via allowed bot @vote"},
+		},
+		{
+			name:     "via not allowed bot",
+			allowed:  []string{"gif"},
+			viaBot:   "promo_bot",
+			expected: spamcheck.Response{Name: "via-bot", Spam: true, Details: "sent via inline bot @promo_bot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := ViaBotCheck(tt.allowed)
+			assert.Equal(t, tt.expected, check(spamcheck.Request{Meta: spamcheck.MetaData{ViaBot: tt.viaBot}}))
+		})
+	}
+}
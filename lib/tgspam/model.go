@@ -0,0 +1,185 @@
+package tgspam
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+	"log"
+	"slices"
+	"strings"
+)
+
+// model is an immutable snapshot of the data used by checks: classifier, tokenized spam samples,
+// stop words and excluded tokens. Published model is never modified, updates are made on a copy
+// which replaces the current model atomically, so checks running on the old model are not affected.
+type model struct {
+	classifier     classifier
+	tokenizedSpam  []map[string]int
+	spamSamples    []string // texts of spam samples, in the same order as tokenizedSpam
+	stopWords      []string
+	excludedTokens map[string]struct{}
+	deniedDomains  []string
+	allowedDomains []string
+	normalized     bool            // samples, stop words and excluded tokens are normalized, messages are normalized the same way
+	stemmers       []scriptStemmer // stemmers of words by script, samples, excluded tokens and messages are stemmed, nil - no stemming
+	wordNGrams     int             // max length of word n-grams used as classifier features, less than 2 - none
+	charNGrams     int             // length of character n-grams of tokens used as classifier features, 0 - none
+}
+
+// newModel makes an empty model
+func newModel() *model {
+	return &model{
+		classifier:     newClassifier(),
+		tokenizedSpam:  []map[string]int{},
+		stopWords:      []string{},
+		excludedTokens: map[string]struct{}{},
+	}
+}
+
+// clone returns a copy of the model safe to update. The classifier is copied deeply, while tokenized samples,
+// stop words, excluded tokens and domain lists are shared, as updates never change them in place.
+func (m *model) clone() *model {
+	return &model{
+		classifier:     m.classifier.clone(),
+		tokenizedSpam:  slices.Clip(m.tokenizedSpam),
+		spamSamples:    slices.Clip(m.spamSamples),
+		stopWords:      m.stopWords,
+		excludedTokens: m.excludedTokens,
+		deniedDomains:  m.deniedDomains,
+		allowedDomains: m.allowedDomains,
+		normalized:     m.normalized,
+		stemmers:       m.stemmers,
+		wordNGrams:     m.wordNGrams,
+		charNGrams:     m.charNGrams,
+	}
+}
+
+// classifierReady reports if the classifier learned both spam and ham samples
+func (m *model) classifierReady() bool {
+	return m.classifier.nAllDocument > 0 && m.classifier.nDocumentByClass[ClassHam] > 0 && m.classifier.nDocumentByClass[ClassSpam] > 0
+}
+
+// normalize returns the text normalized with normalizeText if the model is normalized, the text as is otherwise
+func (m *model) normalize(text string) string {
+	if !m.normalized {
+		return text
+	}
+	return normalizeText(text)
+}
+
+// stem returns the stem of the word if the model is stemmed, the word as is otherwise
+func (m *model) stem(word string) string {
+	if len(m.stemmers) == 0 {
+		return word
+	}
+	return stemWord(m.stemmers, word)
+}
+
+// buildDocs builds a list of classifier documents from a message
+func (m *model) buildDocs(msg string, sc spamClass) []document {
+	docs := []document{}
+	for token := range readerIterator(bytes.NewBufferString(msg)) {
+		docs = append(docs, document{spamClass: sc, tokens: m.features(token)})
+	}
+	return docs
+}
+
+// tokenize takes a string and returns a map where the keys are unique words (tokens)
+// and the values are the frequencies of those words in the string.
+// exclude tokens representing common words. The input is normalized for normalized model,
+// and words are reduced to their stems for stemmed model.
+func (m *model) tokenize(inp string) map[string]int {
+	tokenFrequency := make(map[string]int)
+	for token, short := range m.words(inp) {
+		if !short {
+			tokenFrequency[token]++
+		}
+	}
+	return tokenFrequency
+}
+
+// features returns unique classifier features of the input: tokens, as made by tokenize, and, if enabled for
+// the model, word n-grams of all words, including short ones, and character n-grams of tokens
+func (m *model) features(inp string) []string {
+	res := []string{}
+	seen := map[string]struct{}{}
+	add := func(feature string) {
+		if _, ok := seen[feature]; !ok {
+			seen[feature] = struct{}{}
+			res = append(res, feature)
+		}
+	}
+	words := []string{}
+	for token, short := range m.words(inp) {
+		words = append(words, token)
+		if short {
+			continue
+		}
+		add(token)
+		for _, ngram := range charNGrams(token, m.charNGrams) {
+			add(ngram)
+		}
+	}
+	for _, ngram := range wordNGrams(words, m.wordNGrams) {
+		add(ngram)
+	}
+	return res
+}
+
+// words returns an iterator of cleaned, lowercased words of the input in order, along with a flag of short word.
+// Short words, less than 3 characters, are not tokens but used for word n-grams. Excluded tokens are skipped.
+func (m *model) words(inp string) iter.Seq2[string, bool] {
+	isExcludedToken := func(token string) bool {
+		if _, ok := m.excludedTokens[strings.ToLower(token)]; ok {
+			return true
+		}
+		return false
+	}
+
+	return func(yield func(string, bool) bool) {
+		for _, token := range strings.Fields(m.normalize(inp)) {
+			if isExcludedToken(token) {
+				continue
+			}
+			token = cleanEmoji(token)
+			token = strings.Trim(token, ".,!?-:;()#")
+			token = strings.ToLower(token)
+			if token == "" {
+				continue
+			}
+			short := len([]rune(token)) < 3
+			if len(m.stemmers) > 0 && !short { // excluded tokens are stemmed, check the stem as well
+				if token = m.stem(token); isExcludedToken(token) {
+					continue
+				}
+			}
+			if !yield(token, short) {
+				return
+			}
+		}
+	}
+}
+
+// readerIterator parses readers and returns an iterator of data elements, each line is an element.
+func readerIterator(readers ...io.Reader) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, reader := range readers {
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				line := scanner.Text()
+				// each line with a single element
+				cleanToken := strings.Trim(line, " \n\r\t")
+				if cleanToken != "" {
+					if !yield(cleanToken) {
+						return
+					}
+				}
+			}
+
+			if err := scanner.Err(); err != nil {
+				log.Printf("[WARN] failed to read tokens, error=%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package tgspam
+
+import (
+	"strings"
+)
+
+// charNGramPrefix marks character n-gram features, tokens never start with it as it is trimmed by tokenizer
+const charNGramPrefix = "#"
+
+// wordNGrams returns n-grams of consecutive words, from bigrams up to n-grams of maxLen words, like "passive income".
+// Returns nothing for maxLen less than 2.
+func wordNGrams(words []string, maxLen int) []string {
+	res := []string{}
+	for n := 2; n <= maxLen; n++ {
+		for i := 0; i+n <= len(words); i++ {
+			res = append(res, strings.Join(words[i:i+n], " "))
+		}
+	}
+	return res
+}
+
+// charNGrams returns character n-grams of the token, prefixed with charNGramPrefix to keep them apart from tokens.
+// Returns nothing for n less than 1 or tokens shorter than n characters.
+func charNGrams(token string, n int) []string {
+	runes := []rune(token)
+	if n < 1 || len(runes) < n {
+		return nil
+	}
+	res := make([]string, 0, len(runes)-n+1)
+	for i := 0; i+n <= len(runes); i++ {
+		res = append(res, charNGramPrefix+string(runes[i:i+n]))
+	}
+	return res
+}
+
+// isNGram checks if the classifier feature is a word or character n-gram, not a single token
+func isNGram(feature string) bool {
+	return strings.HasPrefix(feature, charNGramPrefix) || strings.Contains(feature, " ")
+}
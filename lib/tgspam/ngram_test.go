@@ -0,0 +1,143 @@
+package tgspam
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+	"github.com/umputun/tg-spam/lib/tgspam/mocks"
+)
+
+func TestWordNGrams(t *testing.T) {
+	words := []string{"пишите", "в", "лс", "сейчас"}
+	assert.Empty(t, wordNGrams(words, 0))
+	assert.Empty(t, wordNGrams(words, 1))
+	assert.Equal(t, []string{"пишите в", "в лс", "лс сейчас"}, wordNGrams(words, 2))
+	assert.Equal(t, []string{"пишите в", "в лс", "лс сейчас", "пишите в лс", "в лс сейчас"}, wordNGrams(words, 3))
+	assert.Empty(t, wordNGrams([]string{"one"}, 3))
+}
+
+func TestCharNGrams(t *testing.T) {
+	assert.Equal(t, []string{"#зар", "#ара", "#раб"}, charNGrams("зараб", 3))
+	assert.Equal(t, []string{"#abc"}, charNGrams("abc", 3))
+	assert.Empty(t, charNGrams("ab", 3))
+	assert.Empty(t, charNGrams("abc", 0))
+
+	assert.True(t, isNGram("#abc"))
+	assert.True(t, isNGram("passive income"))
+	assert.False(t, isNGram("income"))
+}
+
+func TestModel_Features(t *testing.T) {
+	m := newModel()
+	m.excludedTokens = map[string]struct{}{"the": {}}
+	assert.ElementsMatch(t, []string{"passive", "income"}, m.features("The passive, passive income!"))
+
+	m.wordNGrams = 3
+	assert.ElementsMatch(t, []string{"passive", "income", "passive passive", "passive income", "passive passive income"},
+		m.features("The passive, passive income!"))
+	assert.ElementsMatch(t, []string{"пишите", "пишите в", "в лс", "пишите в лс"}, m.features("Пишите в ЛС"))
+
+	m.wordNGrams, m.charNGrams = 0, 4
+	assert.ElementsMatch(t, []string{"income", "#inco", "#ncom", "#come"}, m.features("income"))
+
+	assert.Equal(t, map[string]int{"passive": 2, "income": 1}, m.tokenize("The passive, passive income!"),
+		"tokens not affected by n-grams")
+}
+
+func TestDetector_CheckNGrams(t *testing.T) {
+	spamSamples := "passive income from home\npassive income every day\nearn income online"
+	hamSamples := "passive voice in english\nthe income tax is due\nhome sweet home\nhow was your day\nworking from home every day"
+
+	t.Run("with n-grams", func(t *testing.T) {
+		cfg := Config{MaxAllowedEmoji: -1}
+		cfg.NGrams.Words = 2
+		cfg.NGrams.MinCount = 2
+		d := NewDetector(cfg)
+		lr, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader(spamSamples)},
+			[]io.Reader{strings.NewReader(hamSamples)})
+		require.NoError(t, err)
+		assert.Equal(t, 18, lr.PrunedNGrams)
+
+		m := d.model.Load()
+		assert.Equal(t, map[spamClass]int{ClassSpam: 2}, m.classifier.learningResults["passive income"])
+		assert.Equal(t, map[spamClass]int{ClassSpam: 1, ClassHam: 1}, m.classifier.learningResults["every day"])
+		assert.NotContains(t, m.classifier.learningResults, "earn income", "rare n-gram pruned")
+
+		spam, cr := d.Check(spamcheck.Request{Msg: "passive income"})
+		assert.True(t, spam)
+		assert.Equal(t, "classifier", cr[0].Name)
+		assert.True(t, cr[0].Spam)
+	})
+
+	t.Run("unlearn", func(t *testing.T) {
+		cfg := Config{MaxAllowedEmoji: -1}
+		cfg.NGrams.Words = 2
+		cfg.NGrams.Chars = 3
+		cfg.NGrams.MinCount = 2
+		d := NewDetector(cfg)
+		d.WithSpamUpdater(&mocks.SampleUpdaterMock{
+			AppendFunc: func(msg string) error { return nil },
+			RemoveFunc: func(msg string) error { return nil },
+		})
+		_, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader(spamSamples)},
+			[]io.Reader{strings.NewReader(hamSamples)})
+		require.NoError(t, err)
+		before := d.model.Load().classifier
+
+		require.NoError(t, d.UpdateSpam("passive income from crypto"))
+		assert.Equal(t, 1, d.model.Load().classifier.learningResults["from crypto"][ClassSpam])
+		require.NoError(t, d.RemoveSpam("passive income from crypto"))
+		assert.Equal(t, before.learningResults, d.model.Load().classifier.learningResults)
+		assert.Equal(t, before.nFrequencyByClass, d.model.Load().classifier.nFrequencyByClass)
+
+		require.NoError(t, d.RemoveSpam("earn income online"), "pruned n-grams are skipped")
+		assert.NotContains(t, d.model.Load().classifier.learningResults, "earn")
+		require.NoError(t, d.RemoveSpam("passive income from home"))
+		assert.Equal(t, 1, d.model.Load().classifier.learningResults["passive income"][ClassSpam])
+	})
+
+	t.Run("max features on update", func(t *testing.T) {
+		cfg := Config{MaxAllowedEmoji: -1}
+		cfg.NGrams.Words = 2
+		cfg.NGrams.MinCount = 2
+		cfg.NGrams.MaxFeatures = 3
+		d := NewDetector(cfg)
+		d.WithSpamUpdater(&mocks.SampleUpdaterMock{
+			AppendFunc: func(msg string) error { return nil },
+			RemoveFunc: func(msg string) error { return nil },
+		})
+		_, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader(spamSamples)},
+			[]io.Reader{strings.NewReader(hamSamples)})
+		require.NoError(t, err)
+
+		require.NoError(t, d.UpdateSpam("passive income from crypto"))
+		m := d.model.Load()
+		ngrams := []string{}
+		for token := range m.classifier.learningResults {
+			if isNGram(token) {
+				ngrams = append(ngrams, token)
+			}
+		}
+		assert.ElementsMatch(t, []string{"passive income", "every day", "from home"}, ngrams, "new rare n-grams pruned")
+		assert.Equal(t, 3, m.classifier.learningResults["passive income"][ClassSpam])
+
+		require.NoError(t, d.RemoveSpam("passive income from crypto"))
+		assert.Equal(t, 2, d.model.Load().classifier.learningResults["passive income"][ClassSpam])
+	})
+
+	t.Run("without n-grams", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1})
+		lr, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader(spamSamples)},
+			[]io.Reader{strings.NewReader(hamSamples)})
+		require.NoError(t, err)
+		assert.Zero(t, lr.PrunedNGrams)
+		for token := range d.model.Load().classifier.learningResults {
+			assert.False(t, isNGram(token), token)
+		}
+	})
+}
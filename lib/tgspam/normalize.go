@@ -0,0 +1,290 @@
+package tgspam
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// scripts of letters folded by normalization
+const (
+	scriptNone = iota
+	scriptLatin
+	scriptCyrillic
+)
+
+// confusables maps letters looking the same in Latin, Cyrillic and Greek scripts to the pair of {latin, cyrillic}
+// equivalents, 0 if the letter has no equivalent in the script. Greek letters have no script of their own here,
+// they are folded to the script of the word.
+var confusables = map[rune][2]rune{
+	// latin letters
+	'A': {'A', 'А'}, 'B': {'B', 'В'}, 'C': {'C', 'С'}, 'E': {'E', 'Е'}, 'H': {'H', 'Н'}, 'K': {'K', 'К'},
+	'M': {'M', 'М'}, 'O': {'O', 'О'}, 'P': {'P', 'Р'}, 'T': {'T', 'Т'}, 'X': {'X', 'Х'}, 'Y': {'Y', 'У'},
+	'a': {'a', 'а'}, 'c': {'c', 'с'}, 'e': {'e', 'е'}, 'i': {'i', 'і'}, 'k': {'k', 'к'}, 'o': {'o', 'о'},
+	'p': {'p', 'р'}, 's': {'s', 'ѕ'}, 'x': {'x', 'х'}, 'y': {'y', 'у'},
+	// cyrillic letters
+	'А': {'A', 'А'}, 'В': {'B', 'В'}, 'С': {'C', 'С'}, 'Е': {'E', 'Е'}, 'Н': {'H', 'Н'}, 'К': {'K', 'К'},
+	'М': {'M', 'М'}, 'О': {'O', 'О'}, 'Р': {'P', 'Р'}, 'Т': {'T', 'Т'}, 'Х': {'X', 'Х'}, 'У': {'Y', 'У'},
+	'а': {'a', 'а'}, 'с': {'c', 'с'}, 'е': {'e', 'е'}, 'і': {'i', 'і'}, 'к': {'k', 'к'}, 'о': {'o', 'о'},
+	'р': {'p', 'р'}, 'ѕ': {'s', 'ѕ'}, 'х': {'x', 'х'}, 'у': {'y', 'у'},
+	// greek letters
+	'Α': {'A', 'А'}, 'Β': {'B', 'В'}, 'Ε': {'E', 'Е'}, 'Ζ': {'Z', 'З'}, 'Η': {'H', 'Н'}, 'Ι': {'I', 'І'},
+	'Κ': {'K', 'К'}, 'Μ': {'M', 'М'}, 'Ν': {'N', 0}, 'Ο': {'O', 'О'}, 'Ρ': {'P', 'Р'}, 'Τ': {'T', 'Т'},
+	'Υ': {'Y', 'У'}, 'Χ': {'X', 'Х'}, 'α': {'a', 'а'}, 'ι': {'i', 'і'}, 'κ': {'k', 'к'}, 'ν': {'v', 0},
+	'ο': {'o', 'о'}, 'ρ': {'p', 'р'}, 'υ': {'u', 0}, 'χ': {'x', 'х'},
+}
+
+// leetChars maps characters used instead of letters in leetspeak to the letters, by script of the word
+var leetChars = map[int]map[rune]rune{
+	scriptLatin:    {'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's'},
+	scriptCyrillic: {'0': 'о', '3': 'з', '4': 'ч', '6': 'б', '@': 'а'},
+}
+
+// normalizeText folds obfuscated text to the canonical form, used for matching with samples and stop words:
+//   - NFKC normalization, turning math-alphanumeric, fullwidth and other fancy letters into regular ones
+//   - folding of homoglyphs in mixed-script words to the dominant script of the word, Latin or Cyrillic.
+//     Scripts are checked for each run of letters, so compounds like "PostgreSQL-сервер" or "iPhone-а" are kept
+//   - lowercasing
+//   - leetspeak de-obfuscation, like "m0ney" to "money", for digits and symbols surrounded by letters
+//   - collapsing of letters repeated more than twice, like "freeee" to "free"
+//
+// Words are separated by a single space in the result.
+func normalizeText(text string) string {
+	words := strings.Fields(norm.NFKC.String(text))
+	for i, w := range words {
+		w = strings.ToLower(foldConfusables(w))
+		w = collapseRepeats(decodeLeet(w))
+		words[i] = w
+	}
+	return strings.Join(words, " ")
+}
+
+// obfuscatedWords returns words of the text written with fancy letters, like math-alphanumeric or fullwidth ones,
+// or mixing letters of different scripts with homoglyphs among them. Scripts are checked for each run of letters
+// of the word, as parts of compounds, like "SQL-запрос" or "e-mail-адрес", are often written in different scripts.
+func obfuscatedWords(text string) []string {
+	res := []string{}
+	for _, w := range strings.Fields(text) {
+		if hasFancyLetters(w) {
+			res = append(res, w)
+			continue
+		}
+		for _, part := range splitLetterRuns(norm.NFKC.String(w)) {
+			if dominantScript(part) != scriptNone && hasConfusables(part) {
+				res = append(res, w)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// isObfuscated checks if the message has words obfuscated with fancy letters or homoglyphs of different scripts
+func (d *Detector) isObfuscated(msg string) spamcheck.Response {
+	words := obfuscatedWords(msg)
+	if len(words) == 0 {
+		return spamcheck.Response{Name: "obfuscation", Spam: false, Details: "not detected"}
+	}
+	if len(words) > 3 {
+		words = append(words[:3], "...")
+	}
+	return spamcheck.Response{Name: "obfuscation", Spam: true,
+		Details: fmt.Sprintf("obfuscation detected: %s", strings.Join(words, ", "))}
+}
+
+// foldConfusables replaces homoglyphs of each run of letters of the word with letters of a single script,
+// if the run mixes letters of Latin, Cyrillic and Greek scripts. Runs of a single script are kept as is.
+func foldConfusables(word string) string {
+	parts := splitLetterRuns(word)
+	for i, part := range parts {
+		parts[i] = foldRun(part)
+	}
+	return strings.Join(parts, "")
+}
+
+// foldRun replaces homoglyphs of the run of letters with letters of a single script. The dominant script
+// of the run is preferred, unless only folding to the other one makes the run a single-script one.
+func foldRun(word string) string {
+	script := dominantScript(word)
+	if script == scriptNone {
+		return word
+	}
+	fold := func(script int) string {
+		var b strings.Builder
+		b.Grow(len(word))
+		for _, r := range word {
+			if c, ok := confusables[r]; ok && c[script-1] != 0 {
+				r = c[script-1]
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	res := fold(script)
+	if dominantScript(res) == scriptNone {
+		return res
+	}
+	other := scriptLatin
+	if script == scriptLatin {
+		other = scriptCyrillic
+	}
+	if alt := fold(other); dominantScript(alt) == scriptNone {
+		return alt
+	}
+	return res
+}
+
+// splitLetterRuns splits the word into runs of letters and runs of other characters, like hyphens, punctuation
+// and digits, joined together the runs make the word back
+func splitLetterRuns(word string) []string {
+	isLetter := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsMark(r) }
+	res := []string{}
+	start, prev := 0, false
+	for i, r := range word {
+		if cur := isLetter(r); i > 0 && cur != prev {
+			res = append(res, word[start:i])
+			start = i
+		}
+		prev = isLetter(r)
+	}
+	if start < len(word) {
+		res = append(res, word[start:])
+	}
+	return res
+}
+
+// dominantScript returns the script of the most letters of the word if the word mixes scripts, scriptNone otherwise
+func dominantScript(word string) int {
+	var latin, cyrillic, greek int
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		}
+	}
+	mixed := 0
+	for _, n := range []int{latin, cyrillic, greek} {
+		if n > 0 {
+			mixed++
+		}
+	}
+	switch {
+	case mixed < 2:
+		return scriptNone
+	case cyrillic > latin:
+		return scriptCyrillic
+	case latin > 0:
+		return scriptLatin
+	}
+	return scriptNone
+}
+
+// hasConfusables checks if the word has letters looking the same in different scripts
+func hasConfusables(word string) bool {
+	for _, r := range word {
+		if _, ok := confusables[r]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFancyLetters checks if the word has math-alphanumeric, fullwidth, circled or other letters turned into
+// regular ones by NFKC normalization
+func hasFancyLetters(word string) bool {
+	for _, r := range word {
+		if (r >= 0x1D400 && r <= 0x1D7FF) || (r >= 0xFF21 && r <= 0xFF5A) || (r >= 0x24B6 && r <= 0x24E9) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeLeet replaces leetspeak characters of the word with letters. Characters surrounded by letters are
+// always replaced, a single digit at the start or at the end of the word only if the word has 3 letters or more,
+// so numbers, like "100usd" or "mp3", are kept.
+func decodeLeet(word string) string {
+	runes := []rune(word)
+	letters := 0
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters == 0 {
+		return word
+	}
+	script := scriptLatin
+	if cyrillicWord(runes) {
+		script = scriptCyrillic
+	}
+	table := leetChars[script]
+	isLetter := func(i int) bool { return i >= 0 && i < len(runes) && unicode.IsLetter(runes[i]) }
+
+	res := make([]rune, len(runes))
+	copy(res, runes)
+	for i := 0; i < len(runes); i++ {
+		if _, ok := table[runes[i]]; !ok {
+			continue
+		}
+		// find the run of leet characters starting at i
+		j := i
+		for j < len(runes) {
+			if _, ok := table[runes[j]]; !ok {
+				break
+			}
+			j++
+		}
+		interior := isLetter(i-1) && isLetter(j)
+		edge := j-i == 1 && letters >= 3 && unicode.IsDigit(runes[i]) && (i == 0 && isLetter(j) || j == len(runes) && isLetter(i-1))
+		if interior || edge {
+			for k := i; k < j; k++ {
+				res[k] = table[runes[k]]
+			}
+		}
+		i = j
+	}
+	return string(res)
+}
+
+// cyrillicWord checks if most letters of the word are cyrillic
+func cyrillicWord(runes []rune) bool {
+	cyrillic, other := 0, 0
+	for _, r := range runes {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.IsLetter(r):
+			other++
+		}
+	}
+	return cyrillic > other
+}
+
+// collapseRepeats collapses runs of the same letter longer than two to two letters
+func collapseRepeats(word string) string {
+	var b strings.Builder
+	b.Grow(len(word))
+	var prev rune
+	run := 0
+	for _, r := range word {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run > 2 && unicode.IsLetter(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
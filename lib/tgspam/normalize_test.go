@@ -0,0 +1,141 @@
+package tgspam
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tbl := []struct {
+		name string
+		inp  string
+		want string
+	}{
+		{"plain text", "Hello world", "hello world"},
+		{"plain russian", "Привет, как дела?", "привет, как дела?"},
+		{"greek in cyrillic word", "Ζаработок в сети", "заработок в сети"},
+		{"latin in cyrillic word", "зapaбoтoк", "заработок"},
+		{"cyrillic in latin word", "Еаrn mоnеу", "earn money"},
+		{"math bold letters", "𝐄𝐚𝐫𝐧 𝐦𝐨𝐧𝐞𝐲", "earn money"},
+		{"fullwidth letters", "Ｆｒｅｅ", "free"},
+		{"circled letters", "ⓕⓡⓔⓔ", "free"},
+		{"leetspeak", "m0ney fr3e 0ffer", "money free offer"},
+		{"leetspeak cyrillic", "зараб0ток", "заработок"},
+		{"leetspeak symbols", "ca$h m@il", "cash mail"},
+		{"numbers kept", "win 100usd mp3 2024 covid19 @user", "win 100usd mp3 2024 covid19 @user"},
+		{"repeated letters", "freeeee moneyyyy!!!!! 10000", "free moneyy!!!!! 10000"},
+		{"spaces collapsed", "  earn\n\tmoney  ", "earn money"},
+		{"mixed-script compounds kept", "SQL-запрос, iPhone-а, Wi-Fi-роутер, e-mail-адрес и PostgreSQL-сервер",
+			"sql-запрос, iphone-а, wi-fi-роутер, e-mail-адрес и postgresql-сервер"},
+		{"homoglyphs in compound part", "PostgreSQL-сeрвeр Wi-Fі", "postgresql-сервер wi-fi"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeText(tt.inp))
+		})
+	}
+}
+
+func TestSplitLetterRuns(t *testing.T) {
+	tbl := []struct {
+		inp  string
+		want []string
+	}{
+		{"word", []string{"word"}},
+		{"PostgreSQL-сервер", []string{"PostgreSQL", "-", "сервер"}},
+		{"e-mail-адрес,", []string{"e", "-", "mail", "-", "адрес", ","}},
+		{"m0ney", []string{"m", "0", "ney"}},
+		{"2024", []string{"2024"}},
+		{"", []string{}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.inp, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitLetterRuns(tt.inp))
+		})
+	}
+}
+
+func TestObfuscatedWords(t *testing.T) {
+	tbl := []struct {
+		inp  string
+		want []string
+	}{
+		{"Hello world, привет мир", []string{}},
+		{"Ζаработок в сети", []string{"Ζаработок"}},
+		{"𝐄𝐚𝐫𝐧 money Ｆｒｅｅ", []string{"𝐄𝐚𝐫𝐧", "Ｆｒｅｅ"}},
+		{"Еаrn mоnеу now", []string{"Еаrn", "mоnеу"}},
+		{"m0ney freeee", []string{}},
+		{"Ελληνικά κείμενο", []string{}},
+		{"SQL-запрос, iPhone-а, Wi-Fi-роутер, e-mail-адрес, PostgreSQL-сервер, Python3-скрипт", []string{}},
+		{"PostgreSQL-сeрвeр и 3аработок-online", []string{"PostgreSQL-сeрвeр"}},
+		{"m0nеy", []string{"m0nеy"}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.inp, func(t *testing.T) {
+			assert.Equal(t, tt.want, obfuscatedWords(tt.inp))
+		})
+	}
+}
+
+func TestDetector_CheckObfuscation(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: -1})
+	d.Normalize.Obfuscation = true
+
+	spam, cr := d.Check(spamcheck.Request{Msg: "Hello, привет!"})
+	assert.False(t, spam)
+	require.Len(t, cr, 1)
+	assert.Equal(t, spamcheck.Response{Name: "obfuscation", Spam: false, Details: "not detected"}, cr[0])
+
+	spam, cr = d.Check(spamcheck.Request{Msg: "Настроил PostgreSQL-сервер и Wi-Fi-роутер, шлю SQL-запрос с iPhone-а"})
+	assert.False(t, spam, "mixed-script compounds are not obfuscation")
+	require.Len(t, cr, 1)
+	assert.Equal(t, "not detected", cr[0].Details)
+
+	spam, cr = d.Check(spamcheck.Request{Msg: "𝐄𝐚𝐫𝐧 𝐦𝐨𝐧𝐞𝐲 𝐧𝐨𝐰 Ζаработок"})
+	assert.True(t, spam)
+	require.Len(t, cr, 1)
+	assert.Equal(t, spamcheck.Response{Name: "obfuscation", Spam: true,
+		Details: "obfuscation detected: 𝐄𝐚𝐫𝐧, 𝐦𝐨𝐧𝐞𝐲, 𝐧𝐨𝐰, ...", Score: 1}, cr[0])
+}
+
+func TestDetector_CheckNormalized(t *testing.T) {
+	spamSamples := strings.NewReader("заработок в сети без вложений\nearn money online fast")
+	hamSamples := strings.NewReader("привет как дела\nhello, how are you doing")
+
+	t.Run("normalized", func(t *testing.T) {
+		cfg := Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.5}
+		cfg.Normalize.Enabled = true
+		d := NewDetector(cfg)
+		_, err := d.LoadStopWords(bytes.NewBufferString("Быстрый Ζаработок\n"))
+		require.NoError(t, err)
+		_, err = d.LoadSamples(strings.NewReader(""), []io.Reader{spamSamples}, []io.Reader{hamSamples})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"быстрый заработок"}, d.model.Load().stopWords)
+
+		spam, cr := d.Check(spamcheck.Request{Msg: "Быстрый зapaб0ток!"})
+		assert.True(t, spam)
+		assert.Equal(t, spamcheck.Response{Name: "stopword", Spam: true, Details: "быстрый заработок", Score: 1,
+			Evidence: []spamcheck.Evidence{{Kind: spamcheck.EvidenceStopWord, Text: "быстрый заработок"}}}, cr[0])
+
+		spam, cr = d.Check(spamcheck.Request{Msg: "𝐞𝐚𝐫𝐧 𝐦𝐨𝐧𝐞𝐲 𝐨𝐧𝐥𝐢𝐧𝐞 fast"})
+		assert.True(t, spam)
+		assert.Equal(t, "similarity", cr[1].Name)
+		assert.True(t, cr[1].Spam)
+	})
+
+	t.Run("not normalized", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.5})
+		_, err := d.LoadStopWords(bytes.NewBufferString("Быстрый Ζаработок\n"))
+		require.NoError(t, err)
+		spam, _ := d.Check(spamcheck.Request{Msg: "быстрый зapaб0ток!"})
+		assert.False(t, spam)
+	})
+}
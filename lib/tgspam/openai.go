@@ -67,8 +67,8 @@ func newOpenAIChecker(client openAIClient, params OpenAIConfig) *openAIChecker {
 	return &openAIChecker{client: client, params: params}
 }
 
-// check checks if a text is spam using OpenAI API
-func (o *openAIChecker) check(msg string, history []spamcheck.Request) (spam bool, cr spamcheck.Response) {
+// check checks if a text is spam using OpenAI API. Retries are stopped if ctx is canceled.
+func (o *openAIChecker) check(ctx context.Context, msg string, history []spamcheck.Request) (spam bool, cr spamcheck.Response) {
 	if o.client == nil {
 		return false, spamcheck.Response{}
 	}
@@ -87,7 +87,7 @@ func (o *openAIChecker) check(msg string, history []spamcheck.Request) (spam boo
 	var resp openAIResponse
 	var err error
 	for i := 0; i < o.params.RetryCount; i++ {
-		if resp, err = o.sendRequest(msg); err == nil {
+		if resp, err = o.sendRequest(ctx, msg); err == nil || ctx.Err() != nil {
 			break
 		}
 	}
@@ -96,11 +96,15 @@ func (o *openAIChecker) check(msg string, history []spamcheck.Request) (spam boo
 			Spam: false, Name: "openai", Details: fmt.Sprintf("OpenAI error: %v", err), Error: err}
 	}
 
-	return resp.IsSpam, spamcheck.Response{Spam: resp.IsSpam, Name: "openai",
+	score := 0.0 // ham doesn't add to the total score
+	if resp.IsSpam {
+		score = float64(resp.Confidence) / 100
+	}
+	return resp.IsSpam, spamcheck.Response{Spam: resp.IsSpam, Name: "openai", Score: max(0, min(1, score)),
 		Details: strings.TrimSuffix(resp.Reason, ".") + ", confidence: " + fmt.Sprintf("%d%%", resp.Confidence)}
 }
 
-func (o *openAIChecker) sendRequest(msg string) (response openAIResponse, err error) {
+func (o *openAIChecker) sendRequest(ctx context.Context, msg string) (response openAIResponse, err error) {
 	// reduce the request size with tokenizer and fallback to default reducer if it fails.
 	// the API supports 4097 tokens ~16000 characters (<=4 per token) for request + result together.
 	// the response is limited to 1000 tokens, and OpenAI always reserved it for the result.
@@ -139,7 +143,7 @@ func (o *openAIChecker) sendRequest(msg string) (response openAIResponse, err er
 	}
 
 	resp, err := o.client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model:          o.params.Model,
 			MaxTokens:      o.params.MaxTokensResponse,
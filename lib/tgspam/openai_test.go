@@ -40,7 +40,7 @@ func TestOpenAIChecker_Check(t *testing.T) {
 				}},
 			}, nil
 		}
-		spam, details := checker.check("some text", nil)
+		spam, details := checker.check(context.Background(), "some text", nil)
 		t.Logf("spam: %v, details: %+v", spam, details)
 		assert.True(t, spam)
 		assert.Equal(t, "openai", details.Name)
@@ -57,7 +57,7 @@ func TestOpenAIChecker_Check(t *testing.T) {
 				}},
 			}, nil
 		}
-		spam, details := checker.check("some text", nil)
+		spam, details := checker.check(context.Background(), "some text", nil)
 		t.Logf("spam: %v, details: %+v", spam, details)
 		assert.False(t, spam)
 		assert.Equal(t, "openai", details.Name)
@@ -70,7 +70,7 @@ func TestOpenAIChecker_Check(t *testing.T) {
 			contextMoqParam context.Context, chatCompletionRequest openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
 			return openai.ChatCompletionResponse{}, assert.AnError
 		}
-		spam, details := checker.check("some text", nil)
+		spam, details := checker.check(context.Background(), "some text", nil)
 		t.Logf("spam: %v, details: %+v", spam, details)
 		assert.False(t, spam)
 		assert.Equal(t, "openai", details.Name)
@@ -87,7 +87,7 @@ func TestOpenAIChecker_Check(t *testing.T) {
 				}},
 			}, nil
 		}
-		spam, details := checker.check("some text", nil)
+		spam, details := checker.check(context.Background(), "some text", nil)
 		t.Logf("spam: %v, details: %+v", spam, details)
 		assert.False(t, spam)
 		assert.Equal(t, "openai", details.Name)
@@ -102,7 +102,7 @@ func TestOpenAIChecker_Check(t *testing.T) {
 			contextMoqParam context.Context, chatCompletionRequest openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
 			return openai.ChatCompletionResponse{}, nil
 		}
-		spam, details := checker.check("some text", nil)
+		spam, details := checker.check(context.Background(), "some text", nil)
 		t.Logf("spam: %v, details: %+v", spam, details)
 		assert.False(t, spam)
 		assert.Equal(t, "openai", details.Name)
@@ -134,7 +134,7 @@ func TestOpenAIChecker_CheckWithHistory(t *testing.T) {
 		{Msg: "third message", UserName: "user1"},
 	}
 
-	spam, details := checker.check("current message", history)
+	spam, details := checker.check(context.Background(), "current message", history)
 	t.Logf("spam: %v, details: %+v", spam, details)
 	assert.True(t, spam)
 	assert.Equal(t, "openai", details.Name)
@@ -204,7 +204,7 @@ History:
 		t.Run(tt.name, func(t *testing.T) {
 			clientMock.ResetCalls() // reset mock before each test case
 			checker := newOpenAIChecker(clientMock, OpenAIConfig{Model: "gpt-4o-mini"})
-			checker.check(tt.currentMsg, tt.history)
+			checker.check(context.Background(), tt.currentMsg, tt.history)
 			assert.Equal(t, tt.expectedMessage, capturedMsg, "message formatting mismatch")
 			assert.Equal(t, 1, len(clientMock.CreateChatCompletionCalls()))
 		})
@@ -0,0 +1,36 @@
+package tgspam
+
+import (
+	"strings"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+// profileApplies reports if profile checks should be executed, i.e. enabled and the request has display name or bio
+func (d *Detector) profileApplies(st *CheckState) bool {
+	return d.Profile.Enabled && (strings.TrimSpace(st.UserDisplayName) != "" || strings.TrimSpace(st.UserBio) != "")
+}
+
+// checkProfile runs the check on the user's display name and bio separately and returns the result named as the
+// profile check. The first spam result wins, details prefixed with the checked part, i.e. "name" or "bio".
+func (d *Detector) checkProfile(name string, req spamcheck.Request, check func(text string) spamcheck.Response) spamcheck.Response {
+	parts := []struct{ part, text string }{{"name", req.UserDisplayName}, {"bio", req.UserBio}}
+	res := spamcheck.Response{Name: name}
+	details := []string{}
+	for _, p := range parts {
+		if strings.TrimSpace(p.text) == "" {
+			continue
+		}
+		r := check(p.text)
+		if r.Spam {
+			return spamcheck.Response{Name: name, Spam: true, Details: p.part + ": " + r.Details, Score: r.Score, Error: r.Error}
+		}
+		details = append(details, p.part+": "+r.Details)
+		res.Score = max(res.Score, r.Score)
+		if r.Error != nil {
+			res.Error = r.Error
+		}
+	}
+	res.Details = strings.Join(details, ", ")
+	return res
+}
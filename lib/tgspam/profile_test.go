@@ -0,0 +1,102 @@
+package tgspam
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestDetector_CheckProfile(t *testing.T) {
+	d := NewDetector(Config{MaxAllowedEmoji: 1, MultiLangWords: 1})
+	d.Profile.Enabled = true
+	_, err := d.LoadStopWords(strings.NewReader("earn 500$"))
+	require.NoError(t, err)
+	_, err = d.LoadSamples(strings.NewReader(""),
+		[]io.Reader{strings.NewReader("easy money daily dm me\ncrypto signals dm me for profit\nmoney daily profit dm")},
+		[]io.Reader{strings.NewReader("hello everyone\nhow are you today\nnice to meet you all")})
+	require.NoError(t, err)
+
+	results := func(req spamcheck.Request) map[string]spamcheck.Response {
+		req.Msg = "hello everyone"
+		_, cr := d.Check(req)
+		res := map[string]spamcheck.Response{}
+		for _, r := range cr {
+			if strings.HasPrefix(r.Name, "profile-") {
+				res[r.Name] = r
+			}
+		}
+		return res
+	}
+
+	t.Run("clean profile", func(t *testing.T) {
+		res := results(spamcheck.Request{UserDisplayName: "John Doe", UserBio: "I like hiking"})
+		require.Len(t, res, 4, "each profile check reported separately")
+		for _, r := range res {
+			assert.False(t, r.Spam, r.Name)
+		}
+		assert.Equal(t, "name: not found, bio: not found", res["profile-stopword"].Details)
+		assert.Equal(t, "name: 0/1, bio: 0/1", res["profile-emoji"].Details)
+	})
+
+	t.Run("stop word in name", func(t *testing.T) {
+		res := results(spamcheck.Request{UserDisplayName: "💰Earn 500$ daily – DM"})
+		assert.Equal(t, spamcheck.Response{Name: "profile-stopword", Spam: true, Details: "name: earn 500$"},
+			spamcheck.Response{Name: res["profile-stopword"].Name, Spam: res["profile-stopword"].Spam,
+				Details: res["profile-stopword"].Details})
+	})
+
+	t.Run("emoji in bio", func(t *testing.T) {
+		res := results(spamcheck.Request{UserDisplayName: "John", UserBio: "🔥🔥🔥 hot deals"})
+		assert.True(t, res["profile-emoji"].Spam)
+		assert.Equal(t, "bio: 3/1", res["profile-emoji"].Details)
+	})
+
+	t.Run("multi-lingual name", func(t *testing.T) {
+		res := results(spamcheck.Request{UserDisplayName: "Мaрина Pаботa"})
+		assert.True(t, res["profile-multi-lingual"].Spam)
+		assert.True(t, strings.HasPrefix(res["profile-multi-lingual"].Details, "name: "))
+	})
+
+	t.Run("classifier on bio", func(t *testing.T) {
+		res := results(spamcheck.Request{UserDisplayName: "John", UserBio: "easy money daily profit dm me"})
+		assert.True(t, res["profile-classifier"].Spam)
+		assert.True(t, strings.HasPrefix(res["profile-classifier"].Details, "bio: "), res["profile-classifier"].Details)
+	})
+
+	t.Run("no profile, checks skipped", func(t *testing.T) {
+		assert.Empty(t, results(spamcheck.Request{}))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		d.Profile.Enabled = false
+		defer func() { d.Profile.Enabled = true }()
+		assert.Empty(t, results(spamcheck.Request{UserDisplayName: "💰Earn 500$ daily – DM"}))
+	})
+
+	t.Run("check user", func(t *testing.T) {
+		spamNames := func(cr []spamcheck.Response) []string {
+			names := []string{}
+			for _, r := range cr {
+				if r.Spam {
+					names = append(names, r.Name)
+				}
+			}
+			return names
+		}
+
+		spam, cr := d.CheckUser(context.Background(), spamcheck.Request{Msg: "John\nearn 500$ now", UserDisplayName: "John",
+			UserBio: "earn 500$ now"})
+		assert.True(t, spam)
+		assert.Equal(t, []string{"stopword"}, spamNames(cr), "stop words in bio checked once")
+
+		spam, cr = d.CheckUser(context.Background(), spamcheck.Request{Msg: "earn 500$ John", UserDisplayName: "earn 500$ John"})
+		assert.True(t, spam)
+		assert.Equal(t, []string{"stopword"}, spamNames(cr), "stop words in name checked once")
+	})
+}
@@ -0,0 +1,121 @@
+package tgspam
+
+import (
+	"log"
+	"unicode"
+)
+
+// Stemmer reduces an inflected lowercase word to its stem, e.g. "заработка" and "заработком" to "заработк",
+// so different forms of the same word are counted as a single token
+type Stemmer interface {
+	Stem(word string) string
+}
+
+// StemmerFunc is an adapter to use ordinary functions as Stemmer
+type StemmerFunc func(word string) string
+
+// Stem calls f(word)
+func (f StemmerFunc) Stem(word string) string { return f(word) }
+
+// DefaultStemmers returns stemmers used if Stemming.Stemmers is not set: English for Latin and Russian for Cyrillic
+// script. The Russian stemmer handles common endings of Ukrainian and other Cyrillic languages reasonably well.
+func DefaultStemmers() map[string]Stemmer {
+	return map[string]Stemmer{"Latin": EnglishStemmer{}, "Cyrillic": RussianStemmer{}}
+}
+
+// scriptStemmer is a stemmer for words of a script
+type scriptStemmer struct {
+	script  *unicode.RangeTable
+	stemmer Stemmer
+}
+
+// makeStemmers makes stemmers by script from Stemming config, nil if stemming is disabled.
+// Stemmers of unknown scripts are skipped. Scripts don't share letters, so the order of stemmers doesn't matter.
+func (d *Detector) makeStemmers() []scriptStemmer {
+	if !d.Stemming.Enabled {
+		return nil
+	}
+	stemmers := d.Stemming.Stemmers
+	if len(stemmers) == 0 {
+		stemmers = DefaultStemmers()
+	}
+	res := make([]scriptStemmer, 0, len(stemmers))
+	for name, s := range stemmers {
+		table, ok := unicode.Scripts[name]
+		if !ok || s == nil {
+			log.Printf("[WARN] no stemming for unknown script %q", name)
+			continue
+		}
+		res = append(res, scriptStemmer{script: table, stemmer: s})
+	}
+	return res
+}
+
+// stemWord returns the stem of the word made by the stemmer of the word's script. Words mixing scripts,
+// words of scripts without a stemmer and words with non-letter characters, like links, numbers or mentions,
+// are returned as is.
+func stemWord(stemmers []scriptStemmer, word string) string {
+	var stemmer Stemmer
+	var script *unicode.RangeTable
+	for _, r := range word {
+		if !unicode.IsLetter(r) {
+			return word
+		}
+		if script == nil {
+			for _, s := range stemmers {
+				if unicode.Is(s.script, r) {
+					script, stemmer = s.script, s.stemmer
+					break
+				}
+			}
+			if script == nil {
+				return word
+			}
+			continue
+		}
+		if !unicode.Is(script, r) {
+			return word
+		}
+	}
+	if stemmer == nil {
+		return word
+	}
+	return stemmer.Stem(word)
+}
+
+// suffixRegion returns the start of the region after the first non-vowel following a vowel, looking from the
+// position "from". This is R1 of Snowball stemmers for from=0, and R2 for from=R1.
+func suffixRegion(w []rune, from int, isVowel func(rune) bool) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// hasSuffixAt checks if the word ends with the suffix starting at the position "limit" or after it
+func hasSuffixAt(w []rune, suffix string, limit int) bool {
+	s := []rune(suffix)
+	start := len(w) - len(s)
+	if start < limit || start < 0 {
+		return false
+	}
+	return string(w[start:]) == suffix
+}
+
+// longestSuffix returns the longest of suffixes the word ends with, starting at the position "limit" or after it
+func longestSuffix(w []rune, limit int, suffixes ...string) (string, bool) {
+	res, found := "", false
+	for _, s := range suffixes {
+		if len(s) > len(res) && hasSuffixAt(w, s, limit) {
+			res, found = s, true
+		}
+	}
+	return res, found
+}
+
+// trimSuffix returns the word without the suffix, the suffix must be at the end of the word
+func trimSuffix(w []rune, suffix string) []rune {
+	return w[:len(w)-len([]rune(suffix))]
+}
@@ -0,0 +1,229 @@
+package tgspam
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// EnglishStemmer is the Snowball English (Porter2) stemmer, https://snowballstem.org/algorithms/english/stemmer.html
+type EnglishStemmer struct{}
+
+// enExceptions are words with irregular stems, returned as is or replaced before stemming
+var enExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie", "idly": "idl", "gently": "gentl",
+	"ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl", "sky": "sky", "news": "news", "howe": "howe",
+	"atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// enInvariants are words not changed after step 1a
+var enInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+	"proceed": true, "exceed": true, "succeed": true,
+}
+
+// Stem returns the stem of the lowercase English word
+func (EnglishStemmer) Stem(word string) string {
+	if len([]rune(word)) <= 2 {
+		return word
+	}
+	if s, ok := enExceptions[word]; ok {
+		return s
+	}
+
+	w := []rune(strings.TrimPrefix(word, "'"))
+	// "y" at the start of the word or after a vowel is a consonant, marked as "Y"
+	for i, r := range w {
+		if r == 'y' && (i == 0 || enVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+	r1, r2 := enRegions(w)
+
+	w = enStep1a(enStep0(w))
+	if enInvariants[string(w)] {
+		return string(w)
+	}
+	w = enStep1c(enStep1b(w, r1))
+	w = enStep5(enStep4(enStep3(enStep2(w, r1), r1, r2), r2), r1, r2)
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+// enVowel checks if the letter is an English vowel
+func enVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// enRegions returns R1 and R2 regions of the word, R1 follows "gener", "commun" and "arsen" prefixes
+func enRegions(w []rune) (r1, r2 int) {
+	r1 = -1
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+			break
+		}
+	}
+	if r1 < 0 {
+		r1 = suffixRegion(w, 0, enVowel)
+	}
+	return r1, suffixRegion(w, r1, enVowel)
+}
+
+// enHasVowel checks if the part of the word has a vowel
+func enHasVowel(w []rune) bool {
+	for _, r := range w {
+		if enVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// enShortSyllable checks if the word ends with a short syllable: a vowel followed by a non-vowel other than
+// "w", "x" or "Y" and preceded by a non-vowel, or a vowel followed by a non-vowel at the start of the word
+func enShortSyllable(w []rune) bool {
+	n := len(w)
+	if n == 2 {
+		return enVowel(w[0]) && !enVowel(w[1])
+	}
+	return n > 2 && !enVowel(w[n-3]) && enVowel(w[n-2]) && !enVowel(w[n-1]) && !strings.ContainsRune("wxY", w[n-1])
+}
+
+// enStep0 removes apostrophe endings
+func enStep0(w []rune) []rune {
+	if s, ok := longestSuffix(w, 0, "'s'", "'s", "'"); ok {
+		return trimSuffix(w, s)
+	}
+	return w
+}
+
+// enStep1a removes plural endings
+func enStep1a(w []rune) []rune {
+	s, ok := longestSuffix(w, 0, "sses", "ied", "ies", "us", "ss", "s")
+	if !ok {
+		return w
+	}
+	switch s {
+	case "sses":
+		return trimSuffix(w, "es")
+	case "ied", "ies":
+		if len(w) > 4 {
+			return trimSuffix(w, "ed")
+		}
+		return trimSuffix(w, "d")
+	case "s":
+		if enHasVowel(w[:len(w)-2]) {
+			return trimSuffix(w, s)
+		}
+	}
+	return w
+}
+
+// enStep1b removes past tense and gerund endings
+func enStep1b(w []rune, r1 int) []rune {
+	s, ok := longestSuffix(w, 0, "eed", "eedly", "ed", "edly", "ing", "ingly")
+	if !ok {
+		return w
+	}
+	if s == "eed" || s == "eedly" {
+		if hasSuffixAt(w, s, r1) {
+			return append(trimSuffix(w, s), 'e', 'e')
+		}
+		return w
+	}
+	stem := trimSuffix(w, s)
+	if !enHasVowel(stem) {
+		return w
+	}
+	w = stem
+	switch {
+	case hasSuffixAt(w, "at", 0), hasSuffixAt(w, "bl", 0), hasSuffixAt(w, "iz", 0):
+		return append(w, 'e')
+	case len(w) > 1 && w[len(w)-1] == w[len(w)-2] && strings.ContainsRune("bdfgmnprt", w[len(w)-1]):
+		return w[:len(w)-1]
+	case r1 >= len(w) && enShortSyllable(w):
+		return append(w, 'e')
+	}
+	return w
+}
+
+// enStep1c replaces final "y" with "i" after a non-vowel which is not the first letter of the word
+func enStep1c(w []rune) []rune {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !enVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+// enStep2Suffixes are suffixes replaced in step 2, "ogi" and "li" are handled separately
+var enStep2Suffixes = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent", "izer": "ize", "ization": "ize",
+	"ational": "ate", "ation": "ate", "ator": "ate", "alism": "al", "aliti": "al", "alli": "al", "fulness": "ful",
+	"ousli": "ous", "ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble", "fulli": "ful",
+	"lessli": "less", "ogi": "og", "li": "",
+}
+
+// enStep2 replaces derivational suffixes in R1
+func enStep2(w []rune, r1 int) []rune {
+	s, ok := longestSuffix(w, 0, slices.Collect(maps.Keys(enStep2Suffixes))...)
+	if !ok || !hasSuffixAt(w, s, r1) {
+		return w
+	}
+	stem := trimSuffix(w, s)
+	switch s {
+	case "ogi":
+		if len(stem) == 0 || stem[len(stem)-1] != 'l' {
+			return w
+		}
+	case "li":
+		if len(stem) == 0 || !strings.ContainsRune("cdeghkmnrt", stem[len(stem)-1]) {
+			return w
+		}
+	}
+	return append(stem, []rune(enStep2Suffixes[s])...)
+}
+
+// enStep3Suffixes are suffixes replaced in step 3, "ative" is removed in R2 only
+var enStep3Suffixes = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic", "ical": "ic",
+	"ful": "", "ness": "", "ative": "",
+}
+
+// enStep3 replaces derivational suffixes in R1
+func enStep3(w []rune, r1, r2 int) []rune {
+	s, ok := longestSuffix(w, 0, slices.Collect(maps.Keys(enStep3Suffixes))...)
+	if !ok || !hasSuffixAt(w, s, r1) || (s == "ative" && !hasSuffixAt(w, s, r2)) {
+		return w
+	}
+	return append(trimSuffix(w, s), []rune(enStep3Suffixes[s])...)
+}
+
+// enStep4 removes suffixes in R2
+func enStep4(w []rune, r2 int) []rune {
+	s, ok := longestSuffix(w, 0, "al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+		"ism", "ate", "iti", "ous", "ive", "ize", "ion")
+	if !ok || !hasSuffixAt(w, s, r2) {
+		return w
+	}
+	stem := trimSuffix(w, s)
+	if s == "ion" && (len(stem) == 0 || (stem[len(stem)-1] != 's' && stem[len(stem)-1] != 't')) {
+		return w
+	}
+	return stem
+}
+
+// enStep5 removes final "e" and undoubles final "l"
+func enStep5(w []rune, r1, r2 int) []rune {
+	switch {
+	case hasSuffixAt(w, "e", r2), hasSuffixAt(w, "e", r1) && !enShortSyllable(w[:len(w)-1]):
+		return w[:len(w)-1]
+	case hasSuffixAt(w, "ll", r2-1):
+		return w[:len(w)-1]
+	}
+	return w
+}
@@ -0,0 +1,123 @@
+package tgspam
+
+import (
+	"slices"
+	"strings"
+)
+
+// RussianStemmer is the Snowball Russian stemmer, https://snowballstem.org/algorithms/russian/stemmer.html
+type RussianStemmer struct{}
+
+// ruEndings are endings of a class of words, some of them are removed only after "а" or "я"
+type ruEndings struct {
+	afterA []string // endings removed only if preceded by "а" or "я"
+	any    []string // endings removed regardless of the preceding letter
+}
+
+var (
+	ruPerfectiveGerund = ruEndings{
+		afterA: []string{"в", "вши", "вшись"},
+		any:    []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"},
+	}
+	ruAdjective = ruEndings{
+		any: []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом", "его", "ого",
+			"ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"},
+	}
+	ruParticiple = ruEndings{
+		afterA: []string{"ем", "нн", "вш", "ющ", "щ"},
+		any:    []string{"ивш", "ывш", "ующ"},
+	}
+	ruReflexive = ruEndings{any: []string{"ся", "сь"}}
+	ruVerb      = ruEndings{
+		afterA: []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"},
+		any: []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+			"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"},
+	}
+	ruNoun = ruEndings{
+		any: []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+			"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"},
+	}
+	ruDerivational = ruEndings{any: []string{"ост", "ость"}}
+)
+
+// Stem returns the stem of the lowercase Russian word
+func (RussianStemmer) Stem(word string) string {
+	w := []rune(strings.ReplaceAll(word, "ё", "е"))
+	rv := len(w) // region after the first vowel, all endings are removed in it
+	for i, r := range w {
+		if ruVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r2 := suffixRegion(w, suffixRegion(w, 0, ruVowel), ruVowel)
+
+	// step 1: remove inflectional endings
+	if stem, ok := ruPerfectiveGerund.remove(w, rv); ok {
+		w = stem
+	} else {
+		if stem, ok := ruReflexive.remove(w, rv); ok {
+			w = stem
+		}
+		if stem, ok := ruAdjectival(w, rv); ok {
+			w = stem
+		} else if stem, ok := ruVerb.remove(w, rv); ok {
+			w = stem
+		} else if stem, ok := ruNoun.remove(w, rv); ok {
+			w = stem
+		}
+	}
+
+	// step 2: remove final "и"
+	if hasSuffixAt(w, "и", rv) {
+		w = trimSuffix(w, "и")
+	}
+
+	// step 3: remove derivational ending in R2
+	if stem, ok := ruDerivational.remove(w, max(rv, r2)); ok {
+		w = stem
+	}
+
+	// step 4: remove superlative ending, undouble "н" and remove soft sign
+	if s, ok := longestSuffix(w, rv, "ейш", "ейше"); ok {
+		w = trimSuffix(w, s)
+	}
+	switch {
+	case hasSuffixAt(w, "нн", rv):
+		w = trimSuffix(w, "н")
+	case hasSuffixAt(w, "ь", rv):
+		w = trimSuffix(w, "ь")
+	}
+	return string(w)
+}
+
+// ruVowel checks if the letter is a Russian vowel
+func ruVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+// remove removes the longest ending in the region starting at the position "limit". Endings allowed only after
+// "а" or "я" are removed if the preceding letter is in the region as well.
+func (e ruEndings) remove(w []rune, limit int) ([]rune, bool) {
+	s, ok := longestSuffix(w, limit, slices.Concat(e.afterA, e.any)...)
+	if !ok {
+		return w, false
+	}
+	stem := trimSuffix(w, s)
+	if slices.Contains(e.afterA, s) && (len(stem) <= limit || (stem[len(stem)-1] != 'а' && stem[len(stem)-1] != 'я')) {
+		return w, false
+	}
+	return stem, true
+}
+
+// ruAdjectival removes adjective ending, optionally preceded by participle ending
+func ruAdjectival(w []rune, rv int) ([]rune, bool) {
+	stem, ok := ruAdjective.remove(w, rv)
+	if !ok {
+		return w, false
+	}
+	if s, ok := ruParticiple.remove(stem, rv); ok {
+		stem = s
+	}
+	return stem, true
+}
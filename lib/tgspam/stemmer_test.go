@@ -0,0 +1,120 @@
+package tgspam
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/tg-spam/lib/spamcheck"
+)
+
+func TestEnglishStemmer(t *testing.T) {
+	tbl := map[string]string{
+		"caresses": "caress", "ponies": "poni", "ties": "tie", "cats": "cat", "gas": "gas", "kiwis": "kiwi",
+		"running": "run", "hopping": "hop", "hoping": "hope", "agreed": "agre", "feed": "feed", "luxuriating": "luxuri",
+		"generously": "generous", "happiness": "happi", "connection": "connect", "connections": "connect",
+		"investments": "invest", "investing": "invest", "opportunity": "opportun", "consignment": "consign",
+		"knightly": "knight", "money": "money", "online": "onlin", "earning": "earn", "earned": "earn",
+		"skies": "sky", "news": "news", "innings": "inning", "ugly": "ugli", "cry": "cri", "by": "by",
+		"crypto": "crypto", "trading": "trade", "profitable": "profit", "guaranteed": "guarante", "fully": "fulli",
+		"abandoned": "abandon", "controlling": "control", "rolled": "roll", "generalization": "general",
+	}
+	s := EnglishStemmer{}
+	for word, want := range tbl {
+		assert.Equal(t, want, s.Stem(word), word)
+	}
+}
+
+func TestRussianStemmer(t *testing.T) {
+	tbl := map[string]string{
+		"заработок": "заработок", "заработка": "заработк", "заработком": "заработк", "заработать": "заработа",
+		"быстрый": "быстр", "быстрого": "быстр", "бесплатно": "бесплатн", "работать": "работа", "работы": "работ",
+		"деньги": "деньг", "денег": "денег", "вложений": "вложен", "вложения": "вложен", "книги": "книг",
+		"стола": "стол", "ёлка": "елк", "прочитавши": "прочита", "умывшись": "ум", "одевшись": "одевш",
+		"пишите": "пиш", "пишет": "пишет", "подписывайтесь": "подписыва", "новейшие": "нов", "жизнь": "жизн",
+		"радость": "радост", "длинный": "длин", "в": "в", "да": "да",
+	}
+	s := RussianStemmer{}
+	for word, want := range tbl {
+		assert.Equal(t, want, s.Stem(word), word)
+	}
+}
+
+func TestStemWord(t *testing.T) {
+	d := NewDetector(Config{})
+	d.Stemming.Enabled = true
+	stemmers := d.makeStemmers()
+	require.Len(t, stemmers, 2)
+
+	tbl := []struct {
+		word, want string
+	}{
+		{"investments", "invest"},
+		{"заработком", "заработк"},
+		{"заробітком", "заробітк"},
+		{"ελληνικά", "ελληνικά"},
+		{"mixedслово", "mixedслово"},
+		{"100usd", "100usd"},
+		{"t.me/blah", "t.me/blah"},
+		{"@users", "@users"},
+		{"", ""},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.word, func(t *testing.T) {
+			assert.Equal(t, tt.want, stemWord(stemmers, tt.word))
+		})
+	}
+
+	t.Run("custom stemmers", func(t *testing.T) {
+		d := NewDetector(Config{})
+		d.Stemming.Enabled = true
+		d.Stemming.Stemmers = map[string]Stemmer{
+			"Greek":   StemmerFunc(func(w string) string { return strings.TrimSuffix(w, "ά") }),
+			"Unknown": EnglishStemmer{},
+		}
+		stemmers := d.makeStemmers()
+		require.Len(t, stemmers, 1)
+		assert.Equal(t, "ελληνικ", stemWord(stemmers, "ελληνικά"))
+		assert.Equal(t, "investments", stemWord(stemmers, "investments"), "no stemmer for latin")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, NewDetector(Config{}).makeStemmers())
+	})
+}
+
+func TestDetector_CheckStemmed(t *testing.T) {
+	spamSamples := strings.NewReader("быстрый заработок в сети без вложений\nearn money with crypto investments")
+	hamSamples := strings.NewReader("привет как дела\nhello, how are you doing")
+	excluded := strings.NewReader("сети\nwith")
+
+	cfg := Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.5}
+	cfg.Stemming.Enabled = true
+	d := NewDetector(cfg)
+	_, err := d.LoadSamples(excluded, []io.Reader{spamSamples}, []io.Reader{hamSamples})
+	require.NoError(t, err)
+
+	m := d.model.Load()
+	assert.Equal(t, map[string]struct{}{"сет": {}, "with": {}}, m.excludedTokens)
+	assert.Equal(t, map[string]int{"быстр": 1, "заработок": 1, "без": 1, "вложен": 1}, m.tokenizedSpam[0])
+	assert.Equal(t, map[string]int{"earn": 1, "money": 1, "crypto": 1, "invest": 1}, m.tokenizedSpam[1])
+	assert.Equal(t, map[string]int{"быстр": 1, "заработк": 1, "вложен": 1}, m.tokenize("Быстрого заработка, сеть, вложения!"))
+
+	spam, cr := d.Check(spamcheck.Request{Msg: "earned money investing crypto"})
+	assert.True(t, spam)
+	assert.Equal(t, "similarity", cr[0].Name)
+	assert.True(t, cr[0].Spam)
+
+	t.Run("not stemmed", func(t *testing.T) {
+		d := NewDetector(Config{MaxAllowedEmoji: -1, SimilarityThreshold: 0.5})
+		_, err := d.LoadSamples(strings.NewReader(""), []io.Reader{strings.NewReader("earn money with crypto investments")},
+			[]io.Reader{strings.NewReader("hello, how are you doing")})
+		require.NoError(t, err)
+		_, cr := d.Check(spamcheck.Request{Msg: "earned money investing crypto"})
+		assert.Equal(t, "similarity", cr[0].Name)
+		assert.False(t, cr[0].Spam)
+	})
+}